@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/innovocloud/issue-sync/pkg/config"
+	"github.com/innovocloud/issue-sync/pkg/github"
+	"github.com/innovocloud/issue-sync/pkg/jira"
+	"github.com/innovocloud/issue-sync/pkg/sync"
+	"github.com/spf13/cobra"
+)
+
+// reportCmd writes the links between GitHub and JIRA issues without syncing.
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Writes a report of the JIRA issue synced from each GitHub issue",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
+		format, _ := cmd.Flags().GetString("format")
+		if format != "csv" && format != "json" {
+			return exitError{ExitConfigError, fmt.Errorf("unknown report format %q; use csv or json", format)}
+		}
+
+		cfg, err := config.NewConfig(cmd)
+		if err != nil {
+			return exitError{ExitConfigError, err}
+		}
+		cfg.SetVersion(Version)
+
+		jiraClient, err := jira.NewJIRAClient(&cfg)
+		if err != nil {
+			return exitError{ExitConfigError, err}
+		}
+		ghClient, err := github.NewGitHubClient(cfg)
+		if err != nil {
+			return exitError{ExitConfigError, err}
+		}
+
+		links, err := sync.Links(cfg, ghClient, jiraClient)
+		if err != nil {
+			return exitError{ExitSyncError, err}
+		}
+
+		out := io.Writer(os.Stdout)
+		if path, _ := cmd.Flags().GetString("output"); path != "" {
+			f, err := os.Create(path)
+			if err != nil {
+				return exitError{ExitConfigError, err}
+			}
+			defer f.Close()
+			out = f
+		}
+
+		if format == "json" {
+			return writeJSONReport(out, links)
+		}
+		return writeCSVReport(out, links)
+	},
+}
+
+// writeJSONReport writes the links as an indented JSON array.
+func writeJSONReport(w io.Writer, links []sync.Link) error {
+	if links == nil {
+		links = []sync.Link{}
+	}
+	b, err := json.MarshalIndent(links, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(b))
+	return err
+}
+
+// writeCSVReport writes the links as CSV with a header row.
+func writeCSVReport(w io.Writer, links []sync.Link) error {
+	c := csv.NewWriter(w)
+	c.Write([]string{"github_number", "github_url", "jira_key", "last_synced"})
+	for _, l := range links {
+		c.Write([]string{strconv.Itoa(l.GitHubNumber), l.GitHubURL, l.JIRAKey, l.LastSync})
+	}
+	c.Flush()
+	return c.Error()
+}
+
+func init() {
+	reportCmd.Flags().String("format", "csv", "Format of the report (csv or json)")
+	reportCmd.Flags().StringP("output", "o", "", "File to write the report to (default is stdout)")
+	RootCmd.AddCommand(reportCmd)
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/innovocloud/issue-sync/pkg/sync"
+)
+
+func TestWriteReport(t *testing.T) {
+	links := []sync.Link{
+		{GitHubNumber: 1, GitHubURL: "https://github.com/o/r/issues/1", JIRAKey: "TEST-1", LastSync: "2020-01-02T03:04:05.000+0000"},
+		{GitHubNumber: 2, GitHubURL: "https://github.com/o/r/issues/2"},
+	}
+
+	tests := []struct {
+		name  string
+		write func(*bytes.Buffer, []sync.Link) error
+		links []sync.Link
+		want  string
+	}{
+		{"csv", func(b *bytes.Buffer, l []sync.Link) error { return writeCSVReport(b, l) }, links,
+			"github_number,github_url,jira_key,last_synced\n" +
+				"1,https://github.com/o/r/issues/1,TEST-1,2020-01-02T03:04:05.000+0000\n" +
+				"2,https://github.com/o/r/issues/2,,\n"},
+		{"csv without links", func(b *bytes.Buffer, l []sync.Link) error { return writeCSVReport(b, l) }, nil,
+			"github_number,github_url,jira_key,last_synced\n"},
+		{"json", func(b *bytes.Buffer, l []sync.Link) error { return writeJSONReport(b, l) }, links[1:],
+			"[\n  {\n    \"github_number\": 2,\n    \"github_url\": \"https://github.com/o/r/issues/2\",\n    \"jira_key\": \"\",\n    \"last_synced\": \"\"\n  }\n]\n"},
+		{"json without links", func(b *bytes.Buffer, l []sync.Link) error { return writeJSONReport(b, l) }, nil, "[]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tt.write(&b, tt.links); err != nil {
+				t.Fatal(err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("report = %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"os"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -8,14 +9,54 @@ import (
 	"github.com/innovocloud/issue-sync/pkg/github"
 	"github.com/innovocloud/issue-sync/pkg/jira"
 	"github.com/innovocloud/issue-sync/pkg/sync"
+	"github.com/innovocloud/issue-sync/pkg/webhook"
 	"github.com/spf13/cobra"
 )
 
+// Exit codes of issue-sync. A daemon only exits on configuration errors.
+const (
+	ExitSuccess        = 0
+	ExitError          = 1 // e.g. invalid command line arguments
+	ExitConfigError    = 2
+	ExitSyncError      = 3
+	ExitPartialFailure = 4
+)
+
+// exitError is an error which causes issue-sync to exit with a specific code.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e exitError) Error() string {
+	return e.err.Error()
+}
+
+// syncExitError returns the exitError for the result of a sync, or nil if it
+// succeeded.
+func syncExitError(err error) error {
+	switch err.(type) {
+	case nil:
+		return nil
+	case sync.PartialError:
+		return exitError{ExitPartialFailure, err}
+	default:
+		return exitError{ExitSyncError, err}
+	}
+}
+
 // Execute provides a single function to run the root command and handle errors.
 func Execute() {
 	// Create a temporary logger that we can use if an error occurs before the real one is instantiated.
 	log := logrus.New()
 	if err := RootCmd.Execute(); err != nil {
+		if e, ok := err.(exitError); ok {
+			// Sync errors have already been logged by the sync loop.
+			if e.code == ExitConfigError {
+				log.Error(e)
+			}
+			os.Exit(e.code)
+		}
 		log.Fatal(err)
 	}
 }
@@ -26,26 +67,46 @@ var RootCmd = &cobra.Command{
 	Short: "A tool to synchronize GitHub and JIRA issues",
 	Long:  "Full docs coming later; see https://github.com/innovocloud/issue-sync",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		// The arguments were parsed; later errors are logged by Execute
+		// without the usage.
+		cmd.SilenceUsage = true
+		cmd.SilenceErrors = true
+
 		cfg, err := config.NewConfig(cmd)
 		if err != nil {
-			return err
+			return exitError{ExitConfigError, err}
 		}
+		cfg.SetVersion(Version)
 
 		log := cfg.GetLogger()
+		if cfg.IsForce() {
+			log.Warn("Forcing updates; every matched JIRA issue will be rewritten, generating heavy JIRA activity")
+		}
 
 		jiraClient, err := jira.NewJIRAClient(&cfg)
 		if err != nil {
-			return err
+			return exitError{ExitConfigError, err}
 		}
 		ghClient, err := github.NewGitHubClient(cfg)
 		if err != nil {
-			return err
+			return exitError{ExitConfigError, err}
 		}
 
-		for {
-			err := sync.Sync(cfg, ghClient, jiraClient)
-			if err != nil {
-				log.Error(err)
+		if cfg.GetWebhookAddress() != "" {
+			go func() {
+				if err := webhook.ListenAndServe(cfg, ghClient, jiraClient); err != nil {
+					log.Fatalf("Webhook server failed: %v", err)
+				}
+			}()
+		}
+
+		run := func() error {
+			syncErr := sync.Sync(cfg, ghClient, jiraClient)
+			if syncErr != nil {
+				log.Error(syncErr)
+			}
+			if s, ok := ghClient.(github.Summarizer); ok {
+				s.LogSummary()
 			}
 
 			if !cfg.IsDryRun() {
@@ -54,14 +115,53 @@ var RootCmd = &cobra.Command{
 					log.Error(err)
 				}
 			}
-			if !cfg.IsDaemon() {
-				return nil
+			return syncErr
+		}
+		wait := func() {
+			// Wait for the next run, recomputing the wait if the period is reloaded.
+			last := time.Now()
+			for waiting := true; waiting; {
+				select {
+				case <-time.After(time.Until(last.Add(cfg.GetDaemonPeriod()))):
+					waiting = false
+				case <-cfg.ConfigChanged():
+					log.Infof("Configuration reloaded; next sync in %v", time.Until(last.Add(cfg.GetDaemonPeriod())))
+				}
+			}
+
+			// The custom fields may have been recreated since the last run.
+			if err := jiraClient.RefreshFieldIDs(); err != nil {
+				log.Errorf("Error refreshing JIRA custom field IDs; keeping the previous ones: %v", err)
 			}
-			<-time.After(cfg.GetDaemonPeriod())
 		}
+
+		return syncLoop(cfg, run, wait)
 	},
 }
 
+// syncLoop runs a sync, and then, as a daemon, waits for the next one until
+// the configured number of syncs ran, if any. It returns the exitError of the
+// last sync.
+func syncLoop(cfg config.Config, run func() error, wait func()) error {
+	log := cfg.GetLogger()
+
+	for iteration := 1; ; iteration++ {
+		syncErr := run()
+		if max := cfg.GetMaxIterations(); cfg.IsDaemon() && max > 0 && iteration >= max {
+			log.Infof("Ran %d syncs; exiting", iteration)
+			return syncExitError(syncErr)
+		}
+		if !cfg.IsDaemon() {
+			if cfg.GetWebhookAddress() != "" {
+				// Without a period, only webhooks trigger further syncs.
+				select {}
+			}
+			return syncExitError(syncErr)
+		}
+		wait()
+	}
+}
+
 func init() {
 	RootCmd.PersistentFlags().String("log-level", logrus.InfoLevel.String(), "Set the global log level")
 	RootCmd.PersistentFlags().String("config", "", "Config file (default is $HOME/.issue-sync.json)")
@@ -69,9 +169,90 @@ func init() {
 	RootCmd.PersistentFlags().StringP("jira-user", "u", "", "Set the JIRA username to authenticate with")
 	RootCmd.PersistentFlags().StringP("jira-secret", "p", "", "Set the JIRA password to authenticate with")
 	RootCmd.PersistentFlags().StringP("jira-uri", "U", "", "Set the base uri of the JIRA instance")
-	RootCmd.PersistentFlags().StringP("jira-project", "P", "", "Set the key of the JIRA project")
-	RootCmd.PersistentFlags().StringP("since", "s", "1970-01-01T00:00:00+0000", "Set the day that the update should run forward from")
+	RootCmd.PersistentFlags().StringP("jira-project", "P", "", "Set the key or name of the JIRA project")
+	RootCmd.PersistentFlags().StringP("since", "s", "", "Set the day that the update should run forward from (default syncs all issues, or those of the first run lookback)")
 	RootCmd.PersistentFlags().BoolP("dry-run", "d", false, "Print out actions to be taken, but do not execute them")
+	RootCmd.PersistentFlags().Bool("explain", false, "Log each GitHub search query with a breakdown of its user, repository and since clauses")
+	RootCmd.PersistentFlags().Bool("force", false, "Rewrite every matched JIRA issue, even if it didn't change; this updates every issue in JIRA")
 	RootCmd.PersistentFlags().DurationP("timeout", "T", time.Minute, "Set the maximum timeout on all API calls")
 	RootCmd.PersistentFlags().Duration("period", 1*time.Hour, "How often to synchronize; set to 0 for one-shot mode")
+	RootCmd.PersistentFlags().StringSlice("default-labels", nil, "Labels applied to every synced JIRA issue")
+	RootCmd.PersistentFlags().StringSlice("timeline-events", nil, "GitHub timeline events to sync as JIRA comments (e.g. closed,labeled,assigned)")
+	RootCmd.PersistentFlags().String("webhook-address", "", "Address to listen on for GitHub webhooks (e.g. :8080); periodic syncs continue as reconciliation")
+	RootCmd.PersistentFlags().String("webhook-secret", "", "Secret used to verify the signature of GitHub webhook deliveries")
+	RootCmd.PersistentFlags().String("empty-body-placeholder", "", "Description to set when a GitHub issue has no body (e.g. \"(no description provided)\"); if empty, the description is left unset")
+	RootCmd.PersistentFlags().String("checkpoint-file", "", "File recording the issues processed so far, to resume an interrupted sync")
+	RootCmd.PersistentFlags().Bool("convert-emoji", false, "Translate GitHub emoji shortcodes into JIRA emoticons or Unicode")
+	RootCmd.PersistentFlags().Bool("github-user-fallback", true, "Use the login from a comment when its GitHub user can't be found")
+	RootCmd.PersistentFlags().Int("github-concurrency", 0, "Maximum number of concurrent GitHub API calls; 0 is unlimited")
+	RootCmd.PersistentFlags().Int("jira-concurrency", 0, "Maximum number of concurrent JIRA API calls; 0 is unlimited")
+	RootCmd.PersistentFlags().String("create-defaults", "", "JSON object of JIRA field keys to values set on every created issue, e.g. to fill required fields; fields issue-sync sets are never overridden")
+	RootCmd.PersistentFlags().Int("max-description-length", 32767, "Maximum length of a JIRA issue description; longer GitHub bodies are truncated. 0 disables the limit")
+	RootCmd.PersistentFlags().StringSlice("sync-fields", []string{"summary", "description", "status", "reporter", "uri", "labels"}, "Issue fields issue-sync writes to JIRA; others are left under JIRA's control")
+	RootCmd.PersistentFlags().String("sprint", "", "Name of the sprint new JIRA issues are added to, or \"active\" for the active sprint")
+	RootCmd.PersistentFlags().String("sprint-board", "", "ID of the agile board to find the sprint on (default is the project's first scrum board)")
+	RootCmd.PersistentFlags().String("comment-marker", "issue-sync", "Identifier used in the hidden markers linking JIRA comments to GitHub comments")
+	RootCmd.PersistentFlags().Bool("use-properties", false, "Store sync metadata in JIRA issue properties and match issues by them")
+	RootCmd.PersistentFlags().String("environment-template", "", "Go template for the JIRA Environment field, e.g. '{{.Section \"Environment\"}}' to copy an issue form section")
+	RootCmd.PersistentFlags().String("search-query", "", "GitHub search query used instead of the one built from the configured repos and members")
+	RootCmd.PersistentFlags().Bool("search-query-since", true, "Append the since clause to the configured search query")
+	RootCmd.PersistentFlags().String("issue-type", "Aufgabe", "Name of the JIRA issue type created for GitHub issues")
+	RootCmd.PersistentFlags().String("pr-issue-type", "", "Name of the JIRA issue type created for GitHub pull requests (default is the issue type)")
+	RootCmd.PersistentFlags().StringSlice("reopen-transitions", nil, "JIRA transitions applied in order when a GitHub issue is reopened, e.g. Reopen,Start Progress")
+	RootCmd.PersistentFlags().BoolP("quiet", "q", false, "Only log warnings and errors, e.g. when run from cron")
+	RootCmd.PersistentFlags().Bool("mirror-jira-comments", false, "Copy comments made on JIRA issues back to their GitHub issues")
+	RootCmd.PersistentFlags().Duration("max-issue-age", 0, "Don't create JIRA issues for GitHub issues created longer ago than this, e.g. 2160h; existing ones are still updated. 0 is unlimited")
+	RootCmd.PersistentFlags().Bool("state-comments", false, "Comment on JIRA issues when their GitHub issue is closed or reopened; requires the status field to be synced")
+	RootCmd.PersistentFlags().Bool("parallel-repos", false, "Sync the configured organisations and repositories concurrently")
+	RootCmd.PersistentFlags().String("type-field", "", "Name of a JIRA single-select field set from the GitHub type label, as mapped by type-labels in the config file")
+	RootCmd.PersistentFlags().Float64("failure-threshold", 0.1, "Share of the issues in a sync, between 0 and 1, which may fail before a warning is logged")
+	RootCmd.PersistentFlags().String("reply-indicator", "", "Text prefixed to JIRA comments copied from GitHub replies, i.e. comments starting with a quote (e.g. \"(reply)\")")
+	RootCmd.PersistentFlags().Bool("last-writer-wins", false, "Don't overwrite JIRA issues edited more recently than their GitHub issues")
+	RootCmd.PersistentFlags().String("first-run-lookback", "", "How far back the first run syncs issues when no since date is set, e.g. 90d; by default all issues are synced")
+	RootCmd.PersistentFlags().Bool("include-archived", false, "Sync the archived repositories of organisations configured without a list of repositories")
+	RootCmd.PersistentFlags().Bool("sync-due-date", false, "Set the due date of JIRA issues from the due date of their GitHub milestone")
+	RootCmd.PersistentFlags().String("due-date-label", "", "Prefix of the GitHub labels holding an issue's due date, e.g. 'due:' for 'due:2026-12-31', which takes precedence over the milestone's")
+	RootCmd.PersistentFlags().String("triage-label", "", "JIRA label added to newly created issues only, to flag them for triage")
+	RootCmd.PersistentFlags().String("triage-assignee", "", "JIRA user newly created issues are assigned to for triage")
+	RootCmd.PersistentFlags().String("triage-component", "", "JIRA component newly created issues are filed under for triage")
+	RootCmd.PersistentFlags().String("github-source-team", "", "Slug of a team of the user source organisation; only issues involving its members are synced")
+	RootCmd.PersistentFlags().String("comment-since", "", "Don't copy GitHub comments created before this date (ISO-8601) to JIRA")
+	RootCmd.PersistentFlags().String("post-sync-command", "", "Shell command run after each sync; it receives the result as JSON on stdin and in $ISSUE_SYNC_RESULT")
+	RootCmd.PersistentFlags().Bool("jira-server-clock", false, "Use the JIRA server's clock rather than the local one for the Last Issue-Sync Update times")
+	RootCmd.PersistentFlags().String("reporter-indicator", "", "Text added to the header of JIRA comments copied from the GitHub issue's author (e.g. \"(reporter)\"); requires the reporter field to be synced")
+	RootCmd.PersistentFlags().StringSlice("required-fields", []string{"GitHub Labels", "GitHub Status", "GitHub Reporter", "Last Issue-Sync Update", "GitHub URI"}, "Custom fields which must exist in JIRA; others are not written if missing (GitHub ID and GitHub Number are always required)")
+	RootCmd.PersistentFlags().Int("dry-run-description-length", 50, "Number of characters of issue descriptions printed by dry runs")
+	RootCmd.PersistentFlags().Int("dry-run-comment-length", 100, "Number of characters of comment bodies printed by dry runs")
+	RootCmd.PersistentFlags().String("truncate-notice", "...", "Text appended to the descriptions and comments truncated by dry runs")
+	RootCmd.PersistentFlags().Int("max-iterations", 0, "Number of syncs the daemon runs before exiting, e.g. for smoke tests; 0 runs until stopped")
+	RootCmd.PersistentFlags().StringSlice("terminal-statuses", nil, "JIRA statuses in which issue fields are no longer updated, e.g. Closed; comments are still synced")
+	RootCmd.PersistentFlags().Bool("dedupe-comments", false, "Delete duplicate JIRA copies of GitHub comments, keeping the oldest")
+	RootCmd.PersistentFlags().Bool("github-etag-cache", false, "Cache GitHub issue and comment listings and request them with their ETags, so unchanged ones don't count against the rate limit")
+	RootCmd.PersistentFlags().String("transition-fields", "", "JSON object of JIRA transition names to objects of field keys to values set by the transition, e.g. to fill required fields")
+	RootCmd.PersistentFlags().String("github-token-command", "", "Shell command printing a GitHub token, or a JSON object with token and expires_at; it is rerun before the token expires")
+	RootCmd.PersistentFlags().Duration("github-token-lifetime", time.Hour, "How long a token printed by the GitHub token command is used, if it doesn't give expires_at")
+	RootCmd.PersistentFlags().Int64("github-app-id", 0, "ID of the GitHub App to authenticate as, instead of a token")
+	RootCmd.PersistentFlags().Int64("github-app-installation-id", 0, "ID of the installation of the GitHub App to sync")
+	RootCmd.PersistentFlags().String("github-app-private-key-path", "", "Path to the PEM private key of the GitHub App")
+	RootCmd.PersistentFlags().String("github-base-url", "", "Base URL of the GitHub API, e.g. https://github.example.com/api/v3/ for GitHub Enterprise; defaults to api.github.com")
+	RootCmd.PersistentFlags().String("jira-snapshot", "", "JIRA snapshot file a dry run reads instead of connecting to JIRA")
+	RootCmd.PersistentFlags().Duration("github-rate-limit-max-wait", time.Hour, "Longest time to sleep for an exhausted GitHub rate limit to reset; 0 retries like other failures")
+	RootCmd.PersistentFlags().String("summary-template", "", "Go template building the JIRA summary from the GitHub issue (e.g. '[#{{.Number}}] {{.Title}}'); must contain {{.Title}}")
+	RootCmd.PersistentFlags().Bool("chunk-comments", false, "Split GitHub comments too long for JIRA into several JIRA comments instead of truncating them")
+	RootCmd.PersistentFlags().Bool("native-reporter", false, "Set the JIRA reporter of new issues to the JIRA user the GitHub author is mapped to in user-map")
+	RootCmd.PersistentFlags().String("default-reporter", "", "JIRA user who reports new issues whose GitHub author has no JIRA user, with native-reporter")
+	RootCmd.PersistentFlags().Int("sync-workers", 1, "Number of GitHub issues of a repository synced to JIRA at once")
+	RootCmd.PersistentFlags().String("body-rewrites", "", `JSON array of rules applied in order to issue and comment bodies before they are copied, e.g. [{"pattern": "token=\\w+", "replace": "token=REDACTED"}]`)
+	RootCmd.PersistentFlags().StringSlice("lenient-operations", nil, "JIRA operations (comment, transition) which are skipped with a warning, rather than failing the issue, when JIRA forbids them")
+	RootCmd.PersistentFlags().Bool("detect-conflicts", false, "Don't overwrite JIRA descriptions edited in JIRA since issue-sync last wrote them; comment on the conflict instead")
+	RootCmd.PersistentFlags().Int("delete-workers", 1, "Number of JIRA comments deleted at once, e.g. when removing duplicate comments")
+	RootCmd.PersistentFlags().StringSlice("author-filter", nil, "GitHub logins of the authors whose issues are synced; any of them may have opened an issue")
+	RootCmd.PersistentFlags().Float64("create-rate", 0, "Maximum number of JIRA issues created per second; 0 is unlimited")
+	RootCmd.PersistentFlags().Int("create-batch-size", 0, "Number of JIRA issues created between pauses of create-batch-pause; 0 disables the pauses")
+	RootCmd.PersistentFlags().Duration("create-batch-pause", time.Minute, "Pause after every create-batch-size JIRA issues created")
+	RootCmd.PersistentFlags().String("comment-edits", "replace", "How JIRA copies of edited GitHub comments are updated: replace, or append to keep the previous versions")
+	RootCmd.PersistentFlags().Duration("github-secondary-rate-limit-wait", time.Minute, "Time to sleep after hitting a GitHub secondary rate limit, e.g. of search, which doesn't say when to retry")
+	RootCmd.PersistentFlags().String("unsyncable-comment", "", "Text of the JIRA comment, linking to the original, copied from a GitHub comment with content JIRA can't show, e.g. an embedded video")
+	RootCmd.PersistentFlags().StringSlice("recreate-transitions", nil, "JIRA transitions applied, in order, to a new JIRA issue created for a reopened GitHub issue")
+	RootCmd.PersistentFlags().StringSlice("writable-fields", nil, "Keys of the JIRA fields, e.g. customfield_10010, which may be written besides those of issue-sync; others are dropped with a warning. Empty allows all")
 }
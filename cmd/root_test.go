@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/innovocloud/issue-sync/pkg/config"
+	"github.com/innovocloud/issue-sync/pkg/sync"
+)
+
+func TestSyncExitError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code int
+	}{
+		{"success", nil, ExitSuccess},
+		{"partial failure", sync.PartialError{Failed: 1, Total: 10}, ExitPartialFailure},
+		{"failed repositories", sync.PartialError{Repos: []string{"o/r"}}, ExitPartialFailure},
+		{"sync error", errors.New("JIRA unavailable"), ExitSyncError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := syncExitError(tt.err)
+			if tt.err == nil {
+				if err != nil {
+					t.Errorf("syncExitError(nil) = %v, want nil", err)
+				}
+				return
+			}
+
+			e, ok := err.(exitError)
+			if !ok {
+				t.Fatalf("syncExitError() = %T, want an exitError", err)
+			}
+			if e.code != tt.code {
+				t.Errorf("exit code = %d, want %d", e.code, tt.code)
+			}
+			if e.Error() != tt.err.Error() {
+				t.Errorf("Error() = %q, want %q", e.Error(), tt.err.Error())
+			}
+		})
+	}
+}
+
+func TestSyncLoopIterations(t *testing.T) {
+	failed := errors.New("JIRA unavailable")
+
+	tests := []struct {
+		name string
+		max  int
+		errs []error
+		want int
+		code int
+	}{
+		{"single run", 0, nil, 1, ExitSuccess},
+		{"one iteration", 1, nil, 1, ExitSuccess},
+		{"three iterations", 3, nil, 3, ExitSuccess},
+		{"last sync failed", 2, []error{nil, failed}, 2, ExitSyncError},
+		{"earlier sync failed", 2, []error{failed, nil}, 2, ExitSuccess},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]interface{}{"max-iterations": tt.max}
+			if tt.max > 0 {
+				values["period"] = "1h"
+			}
+			cfg, err := config.NewTestConfig(values)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			runs, waits := 0, 0
+			run := func() error {
+				runs++
+				if runs > tt.want {
+					t.Fatalf("ran %d syncs, want %d", runs, tt.want)
+				}
+				if runs <= len(tt.errs) {
+					return tt.errs[runs-1]
+				}
+				return nil
+			}
+
+			err = syncLoop(cfg, run, func() { waits++ })
+			if runs != tt.want || waits != tt.want-1 {
+				t.Errorf("ran %d syncs and waited %d times, want %d and %d", runs, waits, tt.want, tt.want-1)
+			}
+			code := ExitSuccess
+			if e, ok := err.(exitError); ok {
+				code = e.code
+			}
+			if code != tt.code {
+				t.Errorf("syncLoop() = %v, want exit code %d", err, tt.code)
+			}
+		})
+	}
+}
+
+func TestExitCodes(t *testing.T) {
+	// Scripts tell the outcomes apart by the codes, so they must be distinct.
+	codes := map[int]string{}
+	for name, code := range map[string]int{
+		"success":         ExitSuccess,
+		"error":           ExitError,
+		"config error":    ExitConfigError,
+		"sync error":      ExitSyncError,
+		"partial failure": ExitPartialFailure,
+	} {
+		if other, ok := codes[code]; ok {
+			t.Errorf("%s and %s both exit with %d", name, other, code)
+		}
+		codes[code] = name
+	}
+}
+
+func TestConfigExitError(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// A configuration without any GitHub credentials is invalid.
+	path := filepath.Join(dir, "config.json")
+	if err := ioutil.WriteFile(path, []byte(`{"log-level": "panic"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	RootCmd.SetArgs([]string{"--config", path})
+	err = RootCmd.Execute()
+
+	e, ok := err.(exitError)
+	if !ok {
+		t.Fatalf("Execute() = %v, want an exitError", err)
+	}
+	if e.code != ExitConfigError {
+		t.Errorf("exit code = %d, want %d", e.code, ExitConfigError)
+	}
+}
@@ -1,17 +1,27 @@
 package config
 
 import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 	"syscall"
+	"text/template"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/andygrunwald/go-jira"
 	"github.com/fsnotify/fsnotify"
+	"github.com/innovocloud/issue-sync/pkg/convert"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/ssh/terminal"
@@ -28,8 +38,10 @@ const defaultLogLevel = logrus.InfoLevel
 type Config struct {
 	// cmdFile is the file Viper is using for its configuration (default $HOME/.issue-sync.json).
 	cmdFile string
-	// cmdConfig is the Viper configuration object created from the command line and config file.
-	cmdConfig viper.Viper
+	// cmdConfig is the Viper configuration object created from the command line and config file,
+	// with the state parsed from it. It is shared by all copies of the Config, so that reloaded
+	// values are seen everywhere.
+	cmdConfig *store
 
 	// log is a logger set up with the configured log level, app name, etc.
 	log logrus.Entry
@@ -38,21 +50,61 @@ type Config struct {
 	basicAuth bool
 
 	// fieldIDs is the list of custom fields we pulled from the `fields` JIRA endpoint.
-	fieldIDs fields
+	// It is shared by all copies of the Config, so that refreshed IDs are seen everywhere.
+	fieldIDs *fieldSet
 
 	// project represents the JIRA project the user has requested.
 	project jira.Project
 
+	// jiraLocation is the time zone of the JIRA user, in which JQL dates are
+	// interpreted.
+	jiraLocation *time.Location
+
 	// since is the parsed value of the `since` configuration parameter, which is the earliest that
 	// a GitHub issue can have been updated to be retrieved.
 	since time.Time
+
+	// converter, if set, replaces the converter built from the options.
+	converter convert.Converter
+
+	// changed receives a value whenever the configuration file has been reloaded.
+	changed chan struct{}
+
+	// startup holds the values of the options which require a restart to change,
+	// as they were when the application started.
+	startup map[string]string
+
+	// version is the version of issue-sync, which is recorded on synced issues.
+	version string
+}
+
+// restartKeys are the configuration options which are only read when the
+// clients are created, so changing them requires a restart.
+var restartKeys = []string{
+	"github-token",
+	"github-token-command",
+	"github-app-id",
+	"github-app-installation-id",
+	"github-app-private-key-path",
+	"jira-user",
+	"jira-secret",
+	"jira-token",
+	"jira-consumer-key",
+	"jira-private-key-path",
+	"jira-uri",
+	"jira-project",
+	"create-rate",
+	"create-batch-size",
+	"create-batch-pause",
 }
 
 // NewConfig creates a new, immutable configuration object. This object
 // holds the Viper configuration and the logger, and is validated. The
 // JIRA configuration is not yet initialized.
 func NewConfig(cmd *cobra.Command) (Config, error) {
-	config := Config{}
+	config := Config{
+		fieldIDs: &fieldSet{},
+	}
 
 	var err error
 	config.cmdFile, err = cmd.Flags().GetString("config")
@@ -60,20 +112,73 @@ func NewConfig(cmd *cobra.Command) (Config, error) {
 		config.cmdFile = ""
 	}
 
-	config.cmdConfig = *newViper("issue-sync", config.cmdFile)
+	v, loaded := newViper("issue-sync", config.cmdFile)
+	config.cmdConfig = newStore(v)
 	config.cmdConfig.BindPFlags(cmd.Flags())
 
 	config.cmdFile = config.cmdConfig.ConfigFileUsed()
 
-	config.log = *newLogger("issue-sync", config.cmdConfig.GetString("log-level"))
+	config.log = *newLogger("issue-sync", logLevel(config.cmdConfig))
 
 	if err := config.validateConfig(); err != nil {
 		return Config{}, err
 	}
 
+	config.changed = make(chan struct{}, 1)
+	config.startup = map[string]string{}
+	for _, key := range restartKeys {
+		config.startup[key] = config.cmdConfig.GetString(key)
+	}
+	if loaded {
+		config.cmdConfig.watch(config.reload)
+	}
+
 	return config, nil
 }
 
+// reload is called when the configuration file changes. It reads the new
+// values, which all copies of the Config see, applies the log level, parses
+// the options again, validates the `since` date, warns about options which
+// won't take effect until a restart, and signals the daemon loop. If the
+// options can't be parsed, the previously parsed ones are kept.
+func (c Config) reload(e fsnotify.Event) {
+	c.log.WithField("file", e.Name).Info("config file changed")
+
+	if err := c.cmdConfig.readInConfig(); err != nil {
+		c.log.Errorf("Error reading the changed config file; keeping the previous configuration: %v", err)
+		return
+	}
+
+	c.log.Logger.Level = logLevel(c.cmdConfig)
+
+	if p, err := c.parseOptions(); err != nil {
+		c.log.Errorf("Invalid reloaded config: %v; keeping the previous templates, rewrites, defaults, field IDs and conversions", err)
+	} else {
+		c.cmdConfig.setParsed(p)
+	}
+
+	if _, err := time.Parse(dateFormat, c.cmdConfig.GetString("since")); err != nil {
+		c.log.Errorf("Since date in reloaded config must be in ISO-8601 format; keeping %s", c.since.Format(dateFormat))
+	}
+
+	for _, key := range restartKeys {
+		if c.cmdConfig.GetString(key) != c.startup[key] {
+			c.log.Warnf("Change to %s requires a restart to take effect", key)
+		}
+	}
+
+	select {
+	case c.changed <- struct{}{}:
+	default:
+	}
+}
+
+// ConfigChanged returns a channel which receives a value whenever the
+// configuration file has been reloaded.
+func (c Config) ConfigChanged() <-chan struct{} {
+	return c.changed
+}
+
 // GetConfigFile returns the file that Viper loaded the configuration from.
 func (c Config) GetConfigFile() string {
 	return c.cmdFile
@@ -91,7 +196,11 @@ func (c Config) IsBasicAuth() bool {
 }
 
 // GetSinceParam returns the `since` configuration parameter, parsed as a time.Time.
+// If the value has since been changed to an invalid date, the last valid one is used.
 func (c Config) GetSinceParam() time.Time {
+	if since, err := time.Parse(dateFormat, c.cmdConfig.GetString("since")); err == nil {
+		return since
+	}
 	return c.since
 }
 
@@ -105,6 +214,18 @@ func (c Config) IsDryRun() bool {
 	return c.cmdConfig.GetBool("dry-run")
 }
 
+// IsExplaining returns whether the GitHub search queries are logged with a
+// breakdown of their user, repository and since clauses.
+func (c Config) IsExplaining() bool {
+	return c.cmdConfig.GetBool("explain")
+}
+
+// IsForce returns whether every matched issue is rewritten, whether or not
+// it changed.
+func (c Config) IsForce() bool {
+	return c.cmdConfig.GetBool("force")
+}
+
 // IsDaemon returns whether the application is running as a daemon
 func (c Config) IsDaemon() bool {
 	return c.cmdConfig.GetDuration("period") != 0
@@ -120,11 +241,50 @@ func (c Config) GetTimeout() time.Duration {
 	return c.cmdConfig.GetDuration("timeout")
 }
 
+// GetConverter returns the converter used to transform issue and comment bodies.
+// A Config without one, such as a zero Config, leaves bodies unchanged.
+func (c Config) GetConverter() convert.Converter {
+	if c.converter != nil {
+		return c.converter
+	}
+	if converter := c.cmdConfig.getParsed().converter; converter != nil {
+		return converter
+	}
+	return convert.IdentityConverter{}
+}
+
+// SetConverter replaces the converter used to transform issue and comment
+// bodies, allowing a different markup dialect to be targeted.
+func (c *Config) SetConverter(converter convert.Converter) {
+	c.converter = converter
+}
+
+// GetVersion returns the version of issue-sync.
+func (c Config) GetVersion() string {
+	return c.version
+}
+
+// SetVersion sets the version of issue-sync, which is only known to the
+// command that was built with it.
+func (c *Config) SetVersion(version string) {
+	c.version = version
+}
+
 // GetProject returns the JIRA project the user has configured.
 func (c Config) GetProject() jira.Project {
 	return c.project
 }
 
+// GetJIRALocation returns the time zone of the JIRA user, in which JIRA
+// interprets the dates of JQL queries, or the local time zone if it isn't
+// known.
+func (c Config) GetJIRALocation() *time.Location {
+	if c.jiraLocation == nil {
+		return time.Local
+	}
+	return c.jiraLocation
+}
+
 // GetProjectKey returns the JIRA key of the configured project.
 func (c Config) GetProjectKey() string {
 	return c.project.Key
@@ -150,21 +310,698 @@ func (c Config) GetSourceOrganisation() string {
 
 }
 
+// GetSourceTeam returns the slug of the team of the source organisation whose
+// members filter the issues instead of all of the organisation's members, or
+// an empty string to use the whole organisation.
+func (c Config) GetSourceTeam() string {
+	return c.cmdConfig.GetString("github-source-team")
+}
+
+// GetDefaultLabels returns the labels which are applied to every synced JIRA
+// issue in addition to the labels of the GitHub issue.
+func (c Config) GetDefaultLabels() []string {
+	return c.cmdConfig.GetStringSlice("default-labels")
+}
+
+// GetTimelineEvents returns the GitHub timeline event types (e.g. "closed",
+// "labeled") which are synced to JIRA as comments. If empty, no timeline
+// events are synced.
+func (c Config) GetTimelineEvents() []string {
+	return c.cmdConfig.GetStringSlice("timeline-events")
+}
+
+// GetWebhookAddress returns the address on which to listen for GitHub webhooks.
+// If empty, the webhook server is disabled.
+func (c Config) GetWebhookAddress() string {
+	return c.cmdConfig.GetString("webhook-address")
+}
+
+// GetWebhookSecret returns the secret used to verify the signature of GitHub
+// webhook deliveries.
+func (c Config) GetWebhookSecret() string {
+	return c.cmdConfig.GetString("webhook-secret")
+}
+
+// GetEmptyBodyPlaceholder returns the description to use on JIRA issues whose
+// GitHub issue has no body. If empty, the description is not set at all.
+func (c Config) GetEmptyBodyPlaceholder() string {
+	return c.cmdConfig.GetString("empty-body-placeholder")
+}
+
+// GetCheckpointFile returns the path of the file recording the GitHub issues
+// processed so far in a run, allowing an interrupted run to be resumed. If
+// empty, no checkpoint is kept.
+func (c Config) GetCheckpointFile() string {
+	return c.cmdConfig.GetString("checkpoint-file")
+}
+
+// IsGitHubUserFallback returns whether the login embedded in a comment should
+// be used when its author can no longer be retrieved from GitHub.
+func (c Config) IsGitHubUserFallback() bool {
+	return c.cmdConfig.GetBool("github-user-fallback")
+}
+
+// GetGitHubConcurrency returns the maximum number of GitHub API calls which
+// may be in flight at once. Zero means unlimited.
+func (c Config) GetGitHubConcurrency() int {
+	return c.cmdConfig.GetInt("github-concurrency")
+}
+
+// GetJIRAConcurrency returns the maximum number of JIRA API calls which may
+// be in flight at once. Zero means unlimited.
+func (c Config) GetJIRAConcurrency() int {
+	return c.cmdConfig.GetInt("jira-concurrency")
+}
+
+// GetStatusLabels returns the map of JIRA status names to the GitHub label
+// applied to the GitHub issue when its JIRA issue reaches that status. The
+// status names are lower case, as the configuration keys are case-insensitive.
+func (c Config) GetStatusLabels() map[string]string {
+	return c.cmdConfig.GetStringMapString("status-labels")
+}
+
+// bodyRewrite is a rule of the `body-rewrites` option, replacing the matches
+// of a regular expression in issue and comment bodies.
+type bodyRewrite struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+
+	regex *regexp.Regexp
+}
+
+// RewriteBody applies the configured rewrite rules, in order, to the body of
+// a GitHub issue or comment before it is copied to JIRA, e.g. to redact
+// secrets or rewrite internal links. The replacements may refer to groups of
+// the patterns, e.g. `${1}`.
+func (c Config) RewriteBody(body string) string {
+	for _, r := range c.cmdConfig.getParsed().bodyRewrites {
+		body = r.regex.ReplaceAllString(body, r.Replace)
+	}
+	return body
+}
+
+// GetCreateDefaults returns the raw JIRA field values, keyed by field key (e.g.
+// "customfield_10010" or "priority"), which are set on every created issue.
+func (c Config) GetCreateDefaults() map[string]interface{} {
+	return c.cmdConfig.getParsed().createDefaults
+}
+
+// GetMaxDescriptionLength returns the maximum length of a JIRA issue
+// description; longer GitHub issue bodies are truncated. Zero means no limit.
+func (c Config) GetMaxDescriptionLength() int {
+	return c.cmdConfig.GetInt("max-description-length")
+}
+
+// Names of the issue fields which can be listed in the `sync-fields` option.
+const (
+	SyncSummary     = "summary"
+	SyncDescription = "description"
+	SyncStatus      = "status"
+	SyncReporter    = "reporter"
+	SyncURI         = "uri"
+	SyncLabels      = "labels"
+)
+
+// allSyncFields is the default value of the `sync-fields` option.
+var allSyncFields = []string{SyncSummary, SyncDescription, SyncStatus, SyncReporter, SyncURI, SyncLabels}
+
+// syncFieldKeys are the custom fields the issue fields in the `sync-fields`
+// option are written to. The other issue fields are standard JIRA fields.
+var syncFieldKeys = map[string]fieldKey{
+	SyncStatus:   GitHubStatus,
+	SyncReporter: GitHubReporter,
+	SyncURI:      GitHubURI,
+	SyncLabels:   GitHubLabels,
+}
+
+// IsFieldSynced returns whether the named issue field (e.g. SyncLabels) is
+// listed in the `sync-fields` option, and should therefore be written to JIRA.
+// Fields whose optional custom field is missing are never synced.
+func (c Config) IsFieldSynced(name string) bool {
+	if key, ok := syncFieldKeys[name]; ok && !c.HasField(key) {
+		return false
+	}
+
+	for _, f := range c.cmdConfig.GetStringSlice("sync-fields") {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetSprint returns the name of the sprint new JIRA issues are added to, or
+// "active" for the board's active sprint. If empty, no sprint is set.
+func (c Config) GetSprint() string {
+	return c.cmdConfig.GetString("sprint")
+}
+
+// GetSprintBoard returns the ID of the agile board whose sprints are searched.
+// If empty, the first scrum board of the JIRA project is used.
+func (c Config) GetSprintBoard() string {
+	return c.cmdConfig.GetString("sprint-board")
+}
+
+// GetCommentMarker returns the identifier used in the hidden markers which
+// link JIRA comments to the GitHub comments they were copied from.
+func (c Config) GetCommentMarker() string {
+	return c.cmdConfig.GetString("comment-marker")
+}
+
+// IsUsingProperties returns whether issue-sync metadata is stored in JIRA
+// issue entity properties, and issues are matched by them instead of by the
+// GitHub ID custom field.
+func (c Config) IsUsingProperties() bool {
+	return c.cmdConfig.GetBool("use-properties")
+}
+
+// GetEnvironmentTemplate returns the template used to fill the JIRA
+// Environment field, or nil if the field isn't synced.
+func (c Config) GetEnvironmentTemplate() *template.Template {
+	return c.cmdConfig.getParsed().environmentTemplate
+}
+
+// GetSummaryTemplate returns the template used to build the JIRA summary
+// from the GitHub issue, or nil if the summary is the issue title.
+func (c Config) GetSummaryTemplate() *template.Template {
+	return c.cmdConfig.getParsed().summaryTemplate
+}
+
+// GetSearchQuery returns the GitHub search query used to find issues instead
+// of the one built from the organisation members and repositories. If empty,
+// the built query is used.
+func (c Config) GetSearchQuery() string {
+	return c.cmdConfig.GetString("search-query")
+}
+
+// IsSearchQuerySince returns whether the `since` clause is appended to the
+// configured search query.
+func (c Config) IsSearchQuerySince() bool {
+	return c.cmdConfig.GetBool("search-query-since")
+}
+
+// GetIssueType returns the name of the JIRA issue type created for GitHub issues.
+func (c Config) GetIssueType() string {
+	return c.cmdConfig.GetString("issue-type")
+}
+
+// GetPRIssueType returns the name of the JIRA issue type created for GitHub
+// pull requests. If empty, the issue type of GitHub issues is used.
+func (c Config) GetPRIssueType() string {
+	return c.cmdConfig.GetString("pr-issue-type")
+}
+
+// GetRecreateTransitions returns the names of the JIRA transitions applied,
+// in order, to a new JIRA issue whose GitHub issue is open but was reopened,
+// e.g. because its JIRA issue was deleted and is recreated, so that it doesn't
+// start in the initial status.
+func (c Config) GetRecreateTransitions() []string {
+	return c.cmdConfig.GetStringSlice("recreate-transitions")
+}
+
+// GetReopenTransitions returns the names of the JIRA transitions applied, in
+// order, to a done JIRA issue when its GitHub issue is reopened.
+func (c Config) GetReopenTransitions() []string {
+	return c.cmdConfig.GetStringSlice("reopen-transitions")
+}
+
+// IsMirroringJIRAComments returns whether comments made on JIRA issues are
+// copied back to their GitHub issues.
+func (c Config) IsMirroringJIRAComments() bool {
+	return c.cmdConfig.GetBool("mirror-jira-comments")
+}
+
+// GetMaxIssueAge returns the maximum age of GitHub issues for which JIRA
+// issues are created. Existing JIRA issues are updated whatever the age of
+// their GitHub issue. 0 means there is no limit.
+func (c Config) GetMaxIssueAge() time.Duration {
+	return c.cmdConfig.GetDuration("max-issue-age")
+}
+
+// IsCommentingStateChanges returns whether a comment is posted on a JIRA
+// issue when the state of its GitHub issue changes. This is independent of
+// any transitions applied to the JIRA issue.
+func (c Config) IsCommentingStateChanges() bool {
+	return c.cmdConfig.GetBool("state-comments")
+}
+
+// IsSyncingReposInParallel returns whether the configured organisations
+// and repositories are synced concurrently rather than one after another.
+func (c Config) IsSyncingReposInParallel() bool {
+	return c.cmdConfig.GetBool("parallel-repos")
+}
+
+// GetTypeField returns the name of the JIRA single-select custom field set
+// from the type label of each GitHub issue, or "" if there is none.
+func (c Config) GetTypeField() string {
+	return c.cmdConfig.GetString("type-field")
+}
+
+// GetTypeLabels returns the map of GitHub labels to the option of the type
+// field selected for issues with that label. The labels are lower case, as
+// the configuration keys are case-insensitive.
+func (c Config) GetTypeLabels() map[string]string {
+	return c.cmdConfig.GetStringMapString("type-labels")
+}
+
+// GetFailureThreshold returns the share of the issues in a sync, between 0
+// and 1, which may fail before a warning is logged.
+func (c Config) GetFailureThreshold() float64 {
+	return c.cmdConfig.GetFloat64("failure-threshold")
+}
+
+// GetReplyIndicator returns the text prefixed to JIRA copies of GitHub
+// comments which reply to an earlier comment by quoting it.
+func (c Config) GetReplyIndicator() string {
+	return c.cmdConfig.GetString("reply-indicator")
+}
+
+// GetUserMap returns the map of GitHub logins to JIRA usernames. The logins
+// are lower case, as the configuration keys are case-insensitive.
+func (c Config) GetUserMap() map[string]string {
+	return c.cmdConfig.GetStringMapString("user-map")
+}
+
+// IsLastWriterWins returns whether JIRA issues edited more recently than
+// their GitHub issues, since the last sync, are left as they are rather than
+// overwritten.
+func (c Config) IsLastWriterWins() bool {
+	return c.cmdConfig.GetBool("last-writer-wins")
+}
+
+// IsIncludingArchived returns whether the archived repositories of the
+// organisations configured without a list of repositories are synced.
+func (c Config) IsIncludingArchived() bool {
+	return c.cmdConfig.GetBool("include-archived")
+}
+
+// IsSyncingDueDate returns whether the due date of JIRA issues is set
+// from the due date of their GitHub issue's milestone or due date label.
+func (c Config) IsSyncingDueDate() bool {
+	return c.cmdConfig.GetBool("sync-due-date")
+}
+
+// GetDueDateLabel returns the prefix of the GitHub labels which hold the due
+// date of an issue, e.g. "due:" for "due:2026-12-31", or an empty string if
+// due dates are only taken from milestones.
+func (c Config) GetDueDateLabel() string {
+	return c.cmdConfig.GetString("due-date-label")
+}
+
+// GetTriageLabel returns the JIRA label added to newly created issues to
+// flag them for triage, or an empty string if none is added.
+func (c Config) GetTriageLabel() string {
+	return c.cmdConfig.GetString("triage-label")
+}
+
+// GetTriageAssignee returns the name of the JIRA user newly created issues
+// are assigned to for triage, or an empty string to leave them unassigned.
+func (c Config) GetTriageAssignee() string {
+	return c.cmdConfig.GetString("triage-assignee")
+}
+
+// GetTriageComponent returns the JIRA component newly created issues are
+// filed under for triage, or an empty string if none is set.
+func (c Config) GetTriageComponent() string {
+	return c.cmdConfig.GetString("triage-component")
+}
+
+// GetCommentSince returns the creation date before which GitHub comments are
+// not copied to JIRA, and false if all comments are. Comments copied before
+// are still updated.
+func (c Config) GetCommentSince() (time.Time, bool) {
+	since, err := time.Parse(dateFormat, c.cmdConfig.GetString("comment-since"))
+	if err != nil {
+		return time.Time{}, false
+	}
+	return since, true
+}
+
+// GetPostSyncCommand returns the shell command run after each sync, which
+// receives the result of the sync as JSON, or an empty string if none is run.
+func (c Config) GetPostSyncCommand() string {
+	return c.cmdConfig.GetString("post-sync-command")
+}
+
+// IsUsingJIRAServerClock returns whether the times issue-sync stores on JIRA
+// issues are taken from the JIRA server's clock, as seen in the Date headers
+// of its responses, rather than the local clock.
+func (c Config) IsUsingJIRAServerClock() bool {
+	return c.cmdConfig.GetBool("jira-server-clock")
+}
+
+// GetReporterIndicator returns the text added to the header of JIRA copies
+// of GitHub comments by the author of the issue, e.g. "(reporter)", or an
+// empty string if they aren't marked.
+func (c Config) GetReporterIndicator() string {
+	return c.cmdConfig.GetString("reporter-indicator")
+}
+
+// IsFieldRequired returns whether the named custom field written on every
+// issue (e.g. "GitHub Reporter") must exist in JIRA. The GitHub ID and Number
+// fields identify the issues, so they are always required.
+func (c Config) IsFieldRequired(name string) bool {
+	if name == "GitHub ID" || name == "GitHub Number" {
+		return true
+	}
+	for _, f := range c.cmdConfig.GetStringSlice("required-fields") {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDryRunDescriptionLength returns the number of characters of issue
+// descriptions printed by dry runs.
+func (c Config) GetDryRunDescriptionLength() int {
+	return c.cmdConfig.GetInt("dry-run-description-length")
+}
+
+// GetDryRunCommentLength returns the number of characters of comment bodies
+// printed by dry runs.
+func (c Config) GetDryRunCommentLength() int {
+	return c.cmdConfig.GetInt("dry-run-comment-length")
+}
+
+// GetTruncateNotice returns the text appended to the text truncated by dry
+// runs, e.g. "…[truncated]".
+func (c Config) GetTruncateNotice() string {
+	return c.cmdConfig.GetString("truncate-notice")
+}
+
+// GetMaxIterations returns the number of syncs a daemon runs before it
+// exits, e.g. for smoke tests. 0 means it runs until it is stopped.
+func (c Config) GetMaxIterations() int {
+	return c.cmdConfig.GetInt("max-iterations")
+}
+
+// GetTerminalStatuses returns the JIRA statuses in which the fields of issues
+// are no longer updated, e.g. because the workflow forbids editing them.
+// Comments are still synced.
+func (c Config) GetTerminalStatuses() []string {
+	return c.cmdConfig.GetStringSlice("terminal-statuses")
+}
+
+// IsDedupingComments returns whether duplicate JIRA copies of a GitHub
+// comment, e.g. left by issues which were relinked, are deleted, keeping the
+// oldest copy.
+func (c Config) IsDedupingComments() bool {
+	return c.cmdConfig.GetBool("dedupe-comments")
+}
+
+// IsCachingGitHubResponses returns whether the GitHub issue and comment
+// listings are cached and requested again conditionally with their ETags, so
+// that unchanged listings don't count against the GitHub rate limit.
+func (c Config) IsCachingGitHubResponses() bool {
+	return c.cmdConfig.GetBool("github-etag-cache")
+}
+
+// GetTransitionFields returns the raw JIRA field values, keyed by field key
+// (e.g. "resolution"), set when applying the named transition, e.g. to fill
+// the required fields of its screen. Transition names are case-insensitive.
+func (c Config) GetTransitionFields(name string) map[string]interface{} {
+	return c.cmdConfig.getParsed().transitionFields[strings.ToLower(name)]
+}
+
+// GetGitHubTokenCommand returns the shell command which prints a GitHub
+// token, used instead of the static `github-token` when set.
+func (c Config) GetGitHubTokenCommand() string {
+	return c.cmdConfig.GetString("github-token-command")
+}
+
+// GetGitHubTokenLifetime returns how long a token printed by the GitHub token
+// command is used, if the command doesn't give its expiry.
+func (c Config) GetGitHubTokenLifetime() time.Duration {
+	return c.cmdConfig.GetDuration("github-token-lifetime")
+}
+
+// GetGitHubApp returns the ID of the GitHub App issue-sync authenticates as
+// and the ID of its installation, which are zero if it uses a token instead.
+func (c Config) GetGitHubApp() (appID, installationID int64) {
+	return c.cmdConfig.GetInt64("github-app-id"), c.cmdConfig.GetInt64("github-app-installation-id")
+}
+
+// GetGitHubBaseURL returns the base URL of the GitHub API, e.g. of a GitHub
+// Enterprise server, or an empty string to use api.github.com.
+func (c Config) GetGitHubBaseURL() string {
+	return c.cmdConfig.GetString("github-base-url")
+}
+
+// GetGitHubAppKey reads and parses the private key of the GitHub App.
+func (c Config) GetGitHubAppKey() (*rsa.PrivateKey, error) {
+	b, err := ioutil.ReadFile(c.cmdConfig.GetString("github-app-private-key-path"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read GitHub App private key: %v", err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("unable to decode GitHub App private key PEM block")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse GitHub App private key: %v", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("GitHub App private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
+
+// GetJIRASnapshot returns the path of the JIRA snapshot an offline dry run
+// reads instead of the JIRA server, or an empty string to use the server.
+func (c Config) GetJIRASnapshot() string {
+	return c.cmdConfig.GetString("jira-snapshot")
+}
+
+// GetGitHubRateLimitMaxWait returns the longest issue-sync sleeps for the
+// GitHub rate limit to reset once it is exhausted, or 0 to retry the request
+// like any other failure.
+func (c Config) GetGitHubRateLimitMaxWait() time.Duration {
+	return c.cmdConfig.GetDuration("github-rate-limit-max-wait")
+}
+
+// IsChunkingComments returns whether GitHub comments too long for a JIRA
+// comment are split into several JIRA comments rather than truncated.
+func (c Config) IsChunkingComments() bool {
+	return c.cmdConfig.GetBool("chunk-comments")
+}
+
+// IsSyncingNativeReporter returns whether the JIRA reporter of new issues is
+// set to the JIRA user the author of the GitHub issue is mapped to.
+func (c Config) IsSyncingNativeReporter() bool {
+	return c.cmdConfig.GetBool("native-reporter")
+}
+
+// GetDefaultReporter returns the JIRA user who is the reporter of new issues
+// whose GitHub author has no JIRA user, or an empty string to leave the
+// reporter to JIRA.
+func (c Config) GetDefaultReporter() string {
+	return c.cmdConfig.GetString("default-reporter")
+}
+
+// GetSyncWorkers returns the number of GitHub issues of a repository which
+// are synced to JIRA at once. With more than one, the comments and events of
+// the next issues are fetched from GitHub while the current ones are synced.
+func (c Config) GetSyncWorkers() int {
+	return c.cmdConfig.GetInt("sync-workers")
+}
+
+// Names of the JIRA operations which can be listed in the
+// `lenient-operations` option.
+const (
+	OperationComment    = "comment"
+	OperationTransition = "transition"
+)
+
+// allOperations are the operations which can be made lenient.
+var allOperations = []string{OperationComment, OperationTransition}
+
+// IsLenient returns whether the named JIRA operation (e.g. OperationComment)
+// is listed in the `lenient-operations` option. When JIRA forbids a lenient
+// operation, e.g. because the account may edit issues but not comment on
+// them, a warning is logged and the rest of the issue is still synced.
+func (c Config) IsLenient(operation string) bool {
+	for _, op := range c.cmdConfig.GetStringSlice("lenient-operations") {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDetectingConflicts returns whether the description of a JIRA issue which
+// was edited in JIRA since issue-sync last wrote it is left as it is, with a
+// comment noting the conflict, rather than overwritten by the GitHub
+// description.
+func (c Config) IsDetectingConflicts() bool {
+	return c.cmdConfig.GetBool("detect-conflicts")
+}
+
+// GetDeleteWorkers returns the number of JIRA comments deleted at once, e.g.
+// when removing duplicate copies of GitHub comments.
+func (c Config) GetDeleteWorkers() int {
+	return c.cmdConfig.GetInt("delete-workers")
+}
+
+// GetAuthorFilter returns the GitHub logins of the authors whose issues are
+// synced, in addition to the other filters, or nil to sync the issues of any
+// author. It doesn't apply to a configured search query.
+func (c Config) GetAuthorFilter() []string {
+	return c.cmdConfig.GetStringSlice("author-filter")
+}
+
+// loginRegex matches a valid GitHub login, or the login of a GitHub App's bot
+// as used in search queries, e.g. app/dependabot.
+var loginRegex = regexp.MustCompile("^(?:app/)?[A-Za-z0-9](?:-?[A-Za-z0-9])*$")
+
+// GetCreateRate returns the maximum number of JIRA issues created per
+// second, or zero for no limit. It is separate from the JIRA concurrency, so
+// that a first sync of many issues doesn't trip JIRA's abuse detection.
+func (c Config) GetCreateRate() float64 {
+	return c.cmdConfig.GetFloat64("create-rate")
+}
+
+// GetCreateBatch returns the number of JIRA issues created between pauses,
+// and the length of the pauses. A batch size of zero means no pauses.
+func (c Config) GetCreateBatch() (int, time.Duration) {
+	return c.cmdConfig.GetInt("create-batch-size"), c.cmdConfig.GetDuration("create-batch-pause")
+}
+
+// Modes of the `comment-edits` option.
+const (
+	// CommentEditsReplace replaces the body of the JIRA copy of an edited
+	// GitHub comment.
+	CommentEditsReplace = "replace"
+	// CommentEditsAppend replaces it too, but keeps the previous bodies after
+	// the new one, with the time of each edit.
+	CommentEditsAppend = "append"
+)
+
+// GetCommentEdits returns how the JIRA copy of an edited GitHub comment is
+// updated: CommentEditsReplace or CommentEditsAppend.
+func (c Config) GetCommentEdits() string {
+	return c.cmdConfig.GetString("comment-edits")
+}
+
+// GetGitHubSecondaryRateLimitWait returns how long issue-sync sleeps when it
+// hits a secondary rate limit of GitHub, e.g. of search, which doesn't say
+// when it may retry. It is capped by the maximum rate limit wait.
+func (c Config) GetGitHubSecondaryRateLimitWait() time.Duration {
+	return c.cmdConfig.GetDuration("github-secondary-rate-limit-wait")
+}
+
+// GetUnsyncableComment returns the text of the JIRA comment copied from a
+// GitHub comment with content the converter reports JIRA can't show, such as
+// an embedded video, followed by a link to the GitHub comment. If empty, such comments are
+// copied as they are.
+func (c Config) GetUnsyncableComment() string {
+	return c.cmdConfig.GetString("unsyncable-comment")
+}
+
 // configFile is a serializable representation of the current Viper configuration.
 type configFile struct {
-	GithubToken         string         `yaml:"github-token,omitempty" mapstructure:"github-token"`
-	GitHubRepos         []Organisation `yaml:"repos,omitempty" mapstructure:"repos"`
-	GitHubUserSourceOrg string         `yaml:"github-user-source-org,omitempty" mapstructure:"github-user-source-org"`
-	JIRAUser            string         `yaml:"jira-user,omitempty" mapstructure:"jira-user"`
-	JIRAToken           string         `yaml:"jira-token,omitempty" mapstructure:"jira-token"`
-	JIRASecret          string         `yaml:"jira-secret,omitempty" mapstructure:"jira-secret"`
-	JIRAKey             string         `yaml:"jira-private-key-path,omitempty" mapstructure:"jira-private-key-path"`
-	JIRACKey            string         `yaml:"jira-consumer-key,omitempty" mapstructure:"jira-consumer-key"`
-	JIRAURI             string         `yaml:"jira-uri,omitempty" mapstructure:"jira-uri"`
-	JIRAProject         string         `yaml:"jira-project,omitempty" mapstructure:"jira-project"`
-	LogLevel            string         `yaml:"log-level,omitempty" mapstructure:"log-level"`
-	Since               string         `yaml:"since,omitempty" mapstructure:"since"`
-	Timeout             time.Duration  `yaml:"timeout,omitempty" mapstructure:"timeout"`
+	GithubToken             string            `yaml:"github-token,omitempty" mapstructure:"github-token"`
+	GitHubRepos             []Organisation    `yaml:"repos,omitempty" mapstructure:"repos"`
+	GitHubUserSourceOrg     string            `yaml:"github-user-source-org,omitempty" mapstructure:"github-user-source-org"`
+	JIRAUser                string            `yaml:"jira-user,omitempty" mapstructure:"jira-user"`
+	JIRAToken               string            `yaml:"jira-token,omitempty" mapstructure:"jira-token"`
+	JIRASecret              string            `yaml:"jira-secret,omitempty" mapstructure:"jira-secret"`
+	JIRAKey                 string            `yaml:"jira-private-key-path,omitempty" mapstructure:"jira-private-key-path"`
+	JIRACKey                string            `yaml:"jira-consumer-key,omitempty" mapstructure:"jira-consumer-key"`
+	JIRAURI                 string            `yaml:"jira-uri,omitempty" mapstructure:"jira-uri"`
+	JIRAProject             string            `yaml:"jira-project,omitempty" mapstructure:"jira-project"`
+	LogLevel                string            `yaml:"log-level,omitempty" mapstructure:"log-level"`
+	Since                   string            `yaml:"since,omitempty" mapstructure:"since"`
+	Timeout                 time.Duration     `yaml:"timeout,omitempty" mapstructure:"timeout"`
+	ConvertEmoji            bool              `yaml:"convert-emoji,omitempty" mapstructure:"convert-emoji"`
+	DefaultLabels           []string          `yaml:"default-labels,omitempty" mapstructure:"default-labels"`
+	TimelineEvents          []string          `yaml:"timeline-events,omitempty" mapstructure:"timeline-events"`
+	WebhookAddress          string            `yaml:"webhook-address,omitempty" mapstructure:"webhook-address"`
+	WebhookSecret           string            `yaml:"webhook-secret,omitempty" mapstructure:"webhook-secret"`
+	EmptyBodyPlaceholder    string            `yaml:"empty-body-placeholder,omitempty" mapstructure:"empty-body-placeholder"`
+	CheckpointFile          string            `yaml:"checkpoint-file,omitempty" mapstructure:"checkpoint-file"`
+	GitHubUserFallback      bool              `yaml:"github-user-fallback" mapstructure:"github-user-fallback"`
+	GitHubConcurrency       int               `yaml:"github-concurrency,omitempty" mapstructure:"github-concurrency"`
+	JIRAConcurrency         int               `yaml:"jira-concurrency,omitempty" mapstructure:"jira-concurrency"`
+	StatusLabels            map[string]string `yaml:"status-labels,omitempty" mapstructure:"status-labels"`
+	CreateDefaults          string            `yaml:"create-defaults,omitempty" mapstructure:"create-defaults"`
+	MaxDescriptionLength    int               `yaml:"max-description-length" mapstructure:"max-description-length"`
+	SyncFields              []string          `yaml:"sync-fields,omitempty" mapstructure:"sync-fields"`
+	Sprint                  string            `yaml:"sprint,omitempty" mapstructure:"sprint"`
+	SprintBoard             string            `yaml:"sprint-board,omitempty" mapstructure:"sprint-board"`
+	CommentMarker           string            `yaml:"comment-marker,omitempty" mapstructure:"comment-marker"`
+	UseProperties           bool              `yaml:"use-properties,omitempty" mapstructure:"use-properties"`
+	EnvironmentTemplate     string            `yaml:"environment-template,omitempty" mapstructure:"environment-template"`
+	SearchQuery             string            `yaml:"search-query,omitempty" mapstructure:"search-query"`
+	SearchQuerySince        bool              `yaml:"search-query-since" mapstructure:"search-query-since"`
+	IssueType               string            `yaml:"issue-type,omitempty" mapstructure:"issue-type"`
+	PRIssueType             string            `yaml:"pr-issue-type,omitempty" mapstructure:"pr-issue-type"`
+	ReopenTransitions       []string          `yaml:"reopen-transitions,omitempty" mapstructure:"reopen-transitions"`
+	Quiet                   bool              `yaml:"quiet,omitempty" mapstructure:"quiet"`
+	MirrorJIRAComments      bool              `yaml:"mirror-jira-comments,omitempty" mapstructure:"mirror-jira-comments"`
+	MaxIssueAge             time.Duration     `yaml:"max-issue-age,omitempty" mapstructure:"max-issue-age"`
+	StateComments           bool              `yaml:"state-comments,omitempty" mapstructure:"state-comments"`
+	ParallelRepos           bool              `yaml:"parallel-repos,omitempty" mapstructure:"parallel-repos"`
+	TypeField               string            `yaml:"type-field,omitempty" mapstructure:"type-field"`
+	TypeLabels              map[string]string `yaml:"type-labels,omitempty" mapstructure:"type-labels"`
+	FailureThreshold        float64           `yaml:"failure-threshold" mapstructure:"failure-threshold"`
+	ReplyIndicator          string            `yaml:"reply-indicator,omitempty" mapstructure:"reply-indicator"`
+	UserMap                 map[string]string `yaml:"user-map,omitempty" mapstructure:"user-map"`
+	LastWriterWins          bool              `yaml:"last-writer-wins,omitempty" mapstructure:"last-writer-wins"`
+	FirstRunLookback        string            `yaml:"first-run-lookback,omitempty" mapstructure:"first-run-lookback"`
+	IncludeArchived         bool              `yaml:"include-archived,omitempty" mapstructure:"include-archived"`
+	SyncDueDate             bool              `yaml:"sync-due-date,omitempty" mapstructure:"sync-due-date"`
+	DueDateLabel            string            `yaml:"due-date-label,omitempty" mapstructure:"due-date-label"`
+	TriageLabel             string            `yaml:"triage-label,omitempty" mapstructure:"triage-label"`
+	TriageAssignee          string            `yaml:"triage-assignee,omitempty" mapstructure:"triage-assignee"`
+	TriageComponent         string            `yaml:"triage-component,omitempty" mapstructure:"triage-component"`
+	GitHubSourceTeam        string            `yaml:"github-source-team,omitempty" mapstructure:"github-source-team"`
+	CommentSince            string            `yaml:"comment-since,omitempty" mapstructure:"comment-since"`
+	PostSyncCommand         string            `yaml:"post-sync-command,omitempty" mapstructure:"post-sync-command"`
+	JIRAServerClock         bool              `yaml:"jira-server-clock,omitempty" mapstructure:"jira-server-clock"`
+	ReporterIndicator       string            `yaml:"reporter-indicator,omitempty" mapstructure:"reporter-indicator"`
+	RequiredFields          []string          `yaml:"required-fields,omitempty" mapstructure:"required-fields"`
+	DryRunDescriptionLength int               `yaml:"dry-run-description-length" mapstructure:"dry-run-description-length"`
+	DryRunCommentLength     int               `yaml:"dry-run-comment-length" mapstructure:"dry-run-comment-length"`
+	TruncateNotice          string            `yaml:"truncate-notice" mapstructure:"truncate-notice"`
+	MaxIterations           int               `yaml:"max-iterations,omitempty" mapstructure:"max-iterations"`
+	TerminalStatuses        []string          `yaml:"terminal-statuses,omitempty" mapstructure:"terminal-statuses"`
+	DedupeComments          bool              `yaml:"dedupe-comments,omitempty" mapstructure:"dedupe-comments"`
+	GitHubETagCache         bool              `yaml:"github-etag-cache,omitempty" mapstructure:"github-etag-cache"`
+	TransitionFields        string            `yaml:"transition-fields,omitempty" mapstructure:"transition-fields"`
+	GitHubTokenCommand      string            `yaml:"github-token-command,omitempty" mapstructure:"github-token-command"`
+	GitHubTokenLifetime     time.Duration     `yaml:"github-token-lifetime" mapstructure:"github-token-lifetime"`
+	GitHubAppID             int64             `yaml:"github-app-id,omitempty" mapstructure:"github-app-id"`
+	GitHubAppInstallationID int64             `yaml:"github-app-installation-id,omitempty" mapstructure:"github-app-installation-id"`
+	GitHubAppKey            string            `yaml:"github-app-private-key-path,omitempty" mapstructure:"github-app-private-key-path"`
+	GitHubBaseURL           string            `yaml:"github-base-url,omitempty" mapstructure:"github-base-url"`
+	JIRASnapshot            string            `yaml:"jira-snapshot,omitempty" mapstructure:"jira-snapshot"`
+	GitHubRateLimitMaxWait  time.Duration     `yaml:"github-rate-limit-max-wait" mapstructure:"github-rate-limit-max-wait"`
+	SummaryTemplate         string            `yaml:"summary-template,omitempty" mapstructure:"summary-template"`
+	ChunkComments           bool              `yaml:"chunk-comments,omitempty" mapstructure:"chunk-comments"`
+	NativeReporter          bool              `yaml:"native-reporter,omitempty" mapstructure:"native-reporter"`
+	DefaultReporter         string            `yaml:"default-reporter,omitempty" mapstructure:"default-reporter"`
+	SyncWorkers             int               `yaml:"sync-workers" mapstructure:"sync-workers"`
+	BodyRewrites            string            `yaml:"body-rewrites,omitempty" mapstructure:"body-rewrites"`
+	FieldIDs                map[string]string `yaml:"field-ids,omitempty" mapstructure:"field-ids"`
+	LenientOperations       []string          `yaml:"lenient-operations,omitempty" mapstructure:"lenient-operations"`
+	DetectConflicts         bool              `yaml:"detect-conflicts,omitempty" mapstructure:"detect-conflicts"`
+	DeleteWorkers           int               `yaml:"delete-workers" mapstructure:"delete-workers"`
+	AuthorFilter            []string          `yaml:"author-filter,omitempty" mapstructure:"author-filter"`
+	CreateRate              float64           `yaml:"create-rate,omitempty" mapstructure:"create-rate"`
+	CreateBatchSize         int               `yaml:"create-batch-size,omitempty" mapstructure:"create-batch-size"`
+	CreateBatchPause        time.Duration     `yaml:"create-batch-pause,omitempty" mapstructure:"create-batch-pause"`
+	CommentEdits            string            `yaml:"comment-edits" mapstructure:"comment-edits"`
+	SecondaryRateLimitWait  time.Duration     `yaml:"github-secondary-rate-limit-wait" mapstructure:"github-secondary-rate-limit-wait"`
+	UnsyncableComment       string            `yaml:"unsyncable-comment,omitempty" mapstructure:"unsyncable-comment"`
+	RecreateTransitions     []string          `yaml:"recreate-transitions,omitempty" mapstructure:"recreate-transitions"`
+	WritableFields          []string          `yaml:"writable-fields,omitempty" mapstructure:"writable-fields"`
 }
 
 // SaveConfig updates the `since` parameter to now, then saves the configuration file.
@@ -174,7 +1011,7 @@ func (c *Config) SaveConfig() error {
 	var cf configFile
 	c.cmdConfig.Unmarshal(&cf)
 
-	b, err := yaml.Marshal(cf)
+	b, err := marshalConfig(cf, c.cmdConfig.ConfigFileUsed())
 	if err != nil {
 		return err
 	}
@@ -190,12 +1027,49 @@ func (c *Config) SaveConfig() error {
 	return nil
 }
 
+// marshalConfig encodes the configuration in the format of the file it is
+// saved to: JSON for a .json file, and YAML otherwise.
+func marshalConfig(cf configFile, path string) ([]byte, error) {
+	b, err := yaml.Marshal(cf)
+	if err != nil || strings.ToLower(filepath.Ext(path)) != ".json" {
+		return b, err
+	}
+
+	// The configuration fields are named by their YAML tags, so the JSON is
+	// encoded from the YAML.
+	var v interface{}
+	if err := yaml.Unmarshal(b, &v); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(jsonCompatible(v), "", "  ")
+}
+
+// jsonCompatible converts the maps decoded by the YAML package, which have
+// interface{} keys, to maps with string keys which can be encoded as JSON.
+func jsonCompatible(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonCompatible(value)
+		}
+		return m
+	case []interface{}:
+		for i := range v {
+			v[i] = jsonCompatible(v[i])
+		}
+		return v
+	}
+	return v
+}
+
 // newViper generates a viper configuration object which
 // merges (in order from highest to lowest priority) the
 // command line options, configuration file options, and
 // default configuration values. This viper object becomes
-// the single source of truth for the app configuration.
-func newViper(appName, cfgFile string) *viper.Viper {
+// the single source of truth for the app configuration. It also
+// returns whether a configuration file was loaded.
+func newViper(appName, cfgFile string) (*viper.Viper, bool) {
 	log := logrus.New()
 	v := viper.New()
 
@@ -209,12 +1083,9 @@ func newViper(appName, cfgFile string) *viper.Viper {
 		v.SetConfigFile(cfgFile)
 	}
 
-	if err := v.ReadInConfig(); err == nil {
+	err := v.ReadInConfig()
+	if err == nil {
 		log.WithField("file", v.ConfigFileUsed()).Infof("config file loaded")
-		v.WatchConfig()
-		v.OnConfigChange(func(e fsnotify.Event) {
-			log.WithField("file", e.Name).Info("config file changed")
-		})
 	} else {
 		if cfgFile != "" {
 			log.WithError(err).Warningf("Error reading config file: %v", cfgFile)
@@ -225,7 +1096,7 @@ func newViper(appName, cfgFile string) *viper.Viper {
 		v.Debug()
 	}
 
-	return v
+	return v, err == nil
 }
 
 // parseLogLevel is a helper function to parse the log level passed in the
@@ -244,12 +1115,50 @@ func parseLogLevel(level string) logrus.Level {
 	return ll
 }
 
+// logLevel returns the log level set in the configuration. In quiet
+// mode, only warnings and errors are logged, whatever the log level.
+func logLevel(v *store) logrus.Level {
+	level := parseLogLevel(v.GetString("log-level"))
+	if v.GetBool("quiet") && level > logrus.WarnLevel {
+		return logrus.WarnLevel
+	}
+	return level
+}
+
+// parseLookback parses the `first-run-lookback` configuration parameter: a
+// number of days, such as "90d", or a duration, such as "720h", which must
+// not be negative. An empty value is no lookback, so all issues are synced.
+func parseLookback(lookback string) (time.Duration, error) {
+	if lookback == "" {
+		return 0, nil
+	}
+
+	var d time.Duration
+	if strings.HasSuffix(lookback, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(lookback, "d"))
+		if err != nil {
+			return 0, err
+		}
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		var err error
+		if d, err = time.ParseDuration(lookback); err != nil {
+			return 0, err
+		}
+	}
+
+	if d < 0 {
+		return 0, fmt.Errorf("%s is negative", lookback)
+	}
+	return d, nil
+}
+
 // newLogger uses the log level provided in the configuration
 // to create a new logrus logger and set fields on it to make
 // it easy to use.
-func newLogger(app, level string) *logrus.Entry {
+func newLogger(app string, level logrus.Level) *logrus.Entry {
 	logger := logrus.New()
-	logger.Level = parseLogLevel(level)
+	logger.Level = level
 	logEntry := logrus.NewEntry(logger).WithFields(logrus.Fields{
 		"app": app,
 	})
@@ -266,14 +1175,42 @@ func (c *Config) validateConfig() error {
 	// Log level and config file location are validated already
 
 	c.log.Debug("Checking config variables...")
-	token := c.cmdConfig.GetString("github-token")
-	if token == "" {
-		return errors.New("GitHub token required")
+	appID, installationID := c.GetGitHubApp()
+	if appID != 0 || installationID != 0 || c.cmdConfig.GetString("github-app-private-key-path") != "" {
+		c.log.Debug("Using GitHub App authentication")
+
+		if appID == 0 {
+			return errors.New("GitHub App ID required")
+		}
+		if installationID == 0 {
+			return errors.New("GitHub App installation ID required")
+		}
+		if _, err := c.GetGitHubAppKey(); err != nil {
+			return err
+		}
+	} else if c.cmdConfig.GetString("github-token") == "" && c.GetGitHubTokenCommand() == "" {
+		return errors.New("GitHub token, token command or App required")
+	}
+	if c.GetGitHubRateLimitMaxWait() < 0 || c.GetGitHubSecondaryRateLimitWait() < 0 {
+		return errors.New("github-rate-limit-max-wait and github-secondary-rate-limit-wait must not be negative")
+	}
+	if c.GetGitHubTokenLifetime() <= 0 {
+		return errors.New("github-token-lifetime must be positive")
+	}
+	if base := c.GetGitHubBaseURL(); base != "" {
+		if u, err := url.Parse(base); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("github-base-url must be an absolute HTTP(S) URL, got %q", base)
+		}
 	}
 
 	c.basicAuth = (c.cmdConfig.GetString("jira-user") != "") && (c.cmdConfig.GetString("jira-secret") != "")
 
-	if c.basicAuth {
+	if snapshot := c.GetJIRASnapshot(); snapshot != "" {
+		if !c.IsDryRun() {
+			return errors.New("jira-snapshot may only be used in a dry run")
+		}
+		c.log.Debugf("Reading JIRA from the snapshot %s", snapshot)
+	} else if c.basicAuth {
 		c.log.Debug("Using HTTP Basic Authentication")
 
 		jUser := c.cmdConfig.GetString("jira-user")
@@ -333,9 +1270,102 @@ func (c *Config) validateConfig() error {
 		return errors.New("JIRA project required")
 	}
 
+	if c.cmdConfig.GetString("webhook-address") != "" && c.cmdConfig.GetString("webhook-secret") == "" {
+		return errors.New("webhook secret required to verify webhook deliveries")
+	}
+
+	for _, f := range c.cmdConfig.GetStringSlice("sync-fields") {
+		valid := false
+		for _, name := range allSyncFields {
+			valid = valid || f == name
+		}
+		if !valid {
+			return fmt.Errorf("unknown sync field %q; must be one of %s", f, strings.Join(allSyncFields, ", "))
+		}
+	}
+
+	for _, op := range c.cmdConfig.GetStringSlice("lenient-operations") {
+		valid := false
+		for _, name := range allOperations {
+			valid = valid || op == name
+		}
+		if !valid {
+			return fmt.Errorf("unknown lenient operation %q; must be one of %s", op, strings.Join(allOperations, ", "))
+		}
+	}
+
+	if c.cmdConfig.GetInt("max-iterations") < 0 {
+		return errors.New("max iterations must not be negative")
+	}
+
+	if c.cmdConfig.GetInt("dry-run-description-length") < 0 || c.cmdConfig.GetInt("dry-run-comment-length") < 0 {
+		return errors.New("dry run lengths must not be negative")
+	}
+
+	for _, f := range c.cmdConfig.GetStringSlice("required-fields") {
+		valid := false
+		for _, name := range managedFields {
+			valid = valid || f == name
+		}
+		if !valid {
+			return fmt.Errorf("unknown required field %q; must be one of %s", f, strings.Join(managedFields, ", "))
+		}
+	}
+
+	if query := c.cmdConfig.GetString("search-query"); query != "" && strings.TrimSpace(query) == "" {
+		return errors.New("search query must not be blank")
+	}
+
+	if t := c.cmdConfig.GetFloat64("failure-threshold"); t < 0 || t > 1 {
+		return errors.New("failure threshold must be between 0 and 1")
+	}
+
+	if c.GetSyncWorkers() < 1 {
+		return errors.New("sync-workers must be at least 1")
+	}
+
+	for _, login := range c.GetAuthorFilter() {
+		if !loginRegex.MatchString(login) || len(strings.TrimPrefix(login, "app/")) > 39 {
+			return fmt.Errorf("author filter: %q is not a valid GitHub login", login)
+		}
+	}
+
+	if size, pause := c.GetCreateBatch(); c.GetCreateRate() < 0 || size < 0 || pause < 0 {
+		return errors.New("create-rate, create-batch-size and create-batch-pause must not be negative")
+	}
+
+	if edits := c.GetCommentEdits(); edits != CommentEditsReplace && edits != CommentEditsAppend {
+		return fmt.Errorf("unknown comment edits mode %q; must be %s or %s", edits, CommentEditsReplace, CommentEditsAppend)
+	}
+
+	if c.GetDeleteWorkers() < 1 {
+		return errors.New("delete-workers must be at least 1")
+	}
+
+	if c.GetDefaultReporter() != "" && !c.IsSyncingNativeReporter() {
+		return errors.New("default-reporter requires native-reporter")
+	}
+
+	p, err := c.parseOptions()
+	if err != nil {
+		return err
+	}
+	c.cmdConfig.setParsed(p)
+
+	lookback, err := parseLookback(c.cmdConfig.GetString("first-run-lookback"))
+	if err != nil {
+		return fmt.Errorf("first run lookback must be a non-negative number of days such as 90d, or a duration: %v", err)
+	}
+
 	sinceStr := c.cmdConfig.GetString("since")
 	if sinceStr == "" {
-		c.cmdConfig.Set("since", "1970-01-01T00:00:00+0000")
+		// No since date was ever saved, so this is the first run.
+		sinceStr = "1970-01-01T00:00:00+0000"
+		if lookback > 0 {
+			sinceStr = time.Now().Add(-lookback).Format(dateFormat)
+			c.log.Infof("No since date set; syncing the issues updated since %s", sinceStr)
+		}
+		c.cmdConfig.Set("since", sinceStr)
 	}
 
 	since, err := time.Parse(dateFormat, sinceStr)
@@ -344,7 +1374,95 @@ func (c *Config) validateConfig() error {
 	}
 	c.since = since
 
+	if commentSince := c.cmdConfig.GetString("comment-since"); commentSince != "" {
+		if _, err := time.Parse(dateFormat, commentSince); err != nil {
+			return errors.New("Comment since date must be in ISO-8601 format")
+		}
+	}
+
 	c.log.Debug("All config variables are valid!")
 
 	return nil
 }
+
+// parseOptions parses the options which are parsed once rather than on every
+// use, and builds the converter. It is called on validation, and again when
+// the configuration file is reloaded.
+func (c Config) parseOptions() (*parsed, error) {
+	p := &parsed{
+		converter: convert.JIRAConverter{
+			Emoji: c.cmdConfig.GetBool("convert-emoji"),
+			Users: c.GetUserMap(),
+		},
+	}
+
+	if env := c.cmdConfig.GetString("environment-template"); env != "" {
+		tmpl, err := template.New("environment").Parse(env)
+		if err != nil {
+			return nil, fmt.Errorf("environment template is invalid: %v", err)
+		}
+		p.environmentTemplate = tmpl
+	}
+
+	if summary := c.cmdConfig.GetString("summary-template"); summary != "" {
+		if !strings.Contains(summary, "{{.Title}}") {
+			return nil, errors.New("summary template must contain {{.Title}}")
+		}
+		tmpl, err := template.New("summary").Parse(summary)
+		if err != nil {
+			return nil, fmt.Errorf("summary template is invalid: %v", err)
+		}
+		p.summaryTemplate = tmpl
+	}
+
+	if defaults := c.cmdConfig.GetString("create-defaults"); defaults != "" {
+		if err := json.Unmarshal([]byte(defaults), &p.createDefaults); err != nil {
+			return nil, fmt.Errorf("create defaults must be a JSON object of JIRA field keys to values: %v", err)
+		}
+	}
+
+	if rewrites := c.cmdConfig.GetString("body-rewrites"); rewrites != "" {
+		if err := json.Unmarshal([]byte(rewrites), &p.bodyRewrites); err != nil {
+			return nil, fmt.Errorf("body rewrites must be a JSON array of objects with a pattern and a replacement: %v", err)
+		}
+		for i, r := range p.bodyRewrites {
+			regex, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("body rewrite %d has an invalid pattern: %v", i+1, err)
+			}
+			p.bodyRewrites[i].regex = regex
+		}
+	}
+
+	if pins := c.cmdConfig.GetStringMapString("field-ids"); len(pins) > 0 {
+		names := map[string]string{}
+		for name := range (fields{}).byName() {
+			names[strings.ToLower(name)] = name
+		}
+		p.pinnedFields = map[string]string{}
+		for key, id := range pins {
+			name, ok := names[strings.ToLower(key)]
+			if !ok {
+				return nil, fmt.Errorf("field IDs: '%s' is not a custom field used by issue-sync", key)
+			}
+			id = strings.TrimPrefix(strings.TrimSpace(id), "customfield_")
+			if _, err := strconv.Atoi(id); err != nil {
+				return nil, fmt.Errorf("field IDs: '%s' must be a custom field ID like customfield_10010", pins[key])
+			}
+			p.pinnedFields[name] = id
+		}
+	}
+
+	if fields := c.cmdConfig.GetString("transition-fields"); fields != "" {
+		var byName map[string]map[string]interface{}
+		if err := json.Unmarshal([]byte(fields), &byName); err != nil {
+			return nil, fmt.Errorf("transition fields must be a JSON object of transition names to objects of JIRA field keys to values: %v", err)
+		}
+		p.transitionFields = map[string]map[string]interface{}{}
+		for name, values := range byName {
+			p.transitionFields[strings.ToLower(name)] = values
+		}
+	}
+
+	return p, nil
+}
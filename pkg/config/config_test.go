@@ -0,0 +1,277 @@
+package config
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLookback(t *testing.T) {
+	tests := []struct {
+		lookback string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{"", 0, false},
+		{"90d", 90 * 24 * time.Hour, false},
+		{"0d", 0, false},
+		{"720h", 720 * time.Hour, false},
+		{"-1d", 0, true},
+		{"-5h", 0, true},
+		{"ninety", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.lookback, func(t *testing.T) {
+			got, err := parseLookback(tt.lookback)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("parseLookback(%q) = %v, %v; want %v, error %v", tt.lookback, got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFirstRunSince(t *testing.T) {
+	tests := []struct {
+		name     string
+		lookback string
+		want     time.Duration
+		wantErr  bool
+	}{
+		{"all issues by default", "", 0, false},
+		{"lookback", "30d", 30 * 24 * time.Hour, false},
+		{"negative lookback", "-30d", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewTestConfig(map[string]interface{}{"since": "", "first-run-lookback": tt.lookback})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTestConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			since := c.GetSinceParam()
+			if tt.want == 0 {
+				if !since.Equal(time.Unix(0, 0)) {
+					t.Errorf("since = %v, want the epoch", since)
+				}
+				return
+			}
+			if ago := time.Since(since); ago < tt.want || ago > tt.want+time.Minute {
+				t.Errorf("since = %v ago, want %v", ago, tt.want)
+			}
+		})
+	}
+}
+
+func TestZeroConfigConverter(t *testing.T) {
+	var c Config
+	if got := c.GetConverter().ToTarget("**bold**"); got != "**bold**" {
+		t.Errorf("ToTarget() of a zero Config = %q, want the body unchanged", got)
+	}
+}
+
+func TestValidateGitHubBaseURL(t *testing.T) {
+	tests := []struct {
+		base    string
+		wantErr bool
+	}{
+		{"", false},
+		{"https://github.example.com/api/v3/", false},
+		{"http://localhost:8080", false},
+		{"github.example.com/api/v3", true},
+		{"ftp://github.example.com/", true},
+		{"https://", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.base, func(t *testing.T) {
+			if _, err := NewTestConfig(map[string]interface{}{"github-base-url": tt.base}); (err != nil) != tt.wantErr {
+				t.Errorf("NewTestConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSyncFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []string
+		wantErr bool
+	}{
+		{"all", allSyncFields, false},
+		{"some", []string{SyncSummary, SyncDescription}, false},
+		{"none", []string{}, false},
+		{"unknown", []string{SyncSummary, "assignee"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTestConfig(map[string]interface{}{"sync-fields": tt.fields}); (err != nil) != tt.wantErr {
+				t.Errorf("NewTestConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDryRunLengths(t *testing.T) {
+	tests := []struct {
+		name        string
+		description int
+		comment     int
+		wantErr     bool
+	}{
+		{"lengths", 50, 100, false},
+		{"zero", 0, 0, false},
+		{"negative description", -1, 100, true},
+		{"negative comment", 50, -1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewTestConfig(map[string]interface{}{
+				"dry-run-description-length": tt.description,
+				"dry-run-comment-length":     tt.comment,
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewTestConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSearchQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr bool
+	}{
+		{"unset", "", false},
+		{"query", "org:o label:bug", false},
+		{"blank", "  \t", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTestConfig(map[string]interface{}{"search-query": tt.query}); (err != nil) != tt.wantErr {
+				t.Errorf("NewTestConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateAuthorFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		authors []string
+		wantErr bool
+	}{
+		{"unset", nil, false},
+		{"logins", []string{"octocat", "a-b-1"}, false},
+		{"app bot", []string{"app/dependabot"}, false},
+		{"invalid characters", []string{"octo cat"}, true},
+		{"leading hyphen", []string{"-octocat"}, true},
+		{"double hyphen", []string{"octo--cat"}, true},
+		{"too long", []string{strings.Repeat("a", 40)}, true},
+		{"longest login", []string{"app/" + strings.Repeat("a", 39)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewTestConfig(map[string]interface{}{"author-filter": tt.authors}); (err != nil) != tt.wantErr {
+				t.Errorf("NewTestConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRewriteBody(t *testing.T) {
+	redact := `{"pattern": "token=\\w+", "replace": "token=REDACTED"}`
+
+	tests := []struct {
+		name     string
+		rewrites string
+		body     string
+		want     string
+		wantErr  bool
+	}{
+		{"no rules", "", "token=abc123", "token=abc123", false},
+		{"redaction", "[" + redact + "]", "Use token=abc123 and token=def", "Use token=REDACTED and token=REDACTED", false},
+		{"no match", "[" + redact + "]", "No secrets here", "No secrets here", false},
+		{"groups", `[{"pattern": "https://wiki\\.internal/(\\S+)", "replace": "https://wiki.example.com/${1}"}]`,
+			"See https://wiki.internal/page", "See https://wiki.example.com/page", false},
+		// Each rule is applied to the output of the one before.
+		{"in order", `[{"pattern": "a", "replace": "b"}, {"pattern": "b", "replace": "c"}]`, "ab", "cc", false},
+		{"reversed order", `[{"pattern": "b", "replace": "c"}, {"pattern": "a", "replace": "b"}]`, "ab", "bc", false},
+		{"invalid pattern", `[{"pattern": "(", "replace": ""}]`, "", "", true},
+		{"invalid JSON", `{"pattern": "a"}`, "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewTestConfig(map[string]interface{}{"body-rewrites": tt.rewrites})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewTestConfig() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := c.RewriteBody(tt.body); got != tt.want {
+				t.Errorf("RewriteBody(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveConfigFormat(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		json     bool
+	}{
+		{"json", "config.json", `{"jira-project": "SAVED", "field-ids": {"GitHub ID": "10001"}}`, true},
+		{"yaml", "config.yaml", "jira-project: SAVED\nfield-ids:\n  GitHub ID: \"10001\"\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, tt.file)
+			c := fileConfig(t, path, tt.contents)
+			if err := c.SaveConfig(); err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var saved map[string]interface{}
+			if err := json.Unmarshal(b, &saved); (err == nil) != tt.json {
+				t.Fatalf("saved file is JSON: %v, want %v:\n%s", err == nil, tt.json, b)
+			}
+
+			// The saved file is read back in its own format.
+			reread := fileConfig(t, path, string(b))
+			if got := reread.cmdConfig.GetString("jira-project"); got != "SAVED" {
+				t.Errorf("saved jira-project = %q, want SAVED", got)
+			}
+			if got := reread.cmdConfig.GetStringMapString("field-ids")["github id"]; got != "10001" {
+				t.Errorf("saved field-ids = %v, want GitHub ID 10001", reread.cmdConfig.GetStringMapString("field-ids"))
+			}
+		})
+	}
+}
@@ -3,6 +3,9 @@ package config
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
 
 	jira "github.com/andygrunwald/go-jira"
 )
@@ -27,8 +30,63 @@ type jiraField struct {
 	} `json:"schema,omitempty"`
 }
 
-// getFieldIDs requests the metadata of every issue field in the JIRA
-// project, and saves the IDs of the custom fields used by issue-sync.
+// fieldTypes are the JIRA schema types of the custom fields used by
+// issue-sync, which must match the type of the values it writes to them. The
+// GitHub ID and number may also be kept in text fields, to which they are
+// written as strings.
+var fieldTypes = map[string][]string{
+	"GitHub ID":              {"number", "string"},
+	"GitHub Number":          {"number", "string"},
+	"GitHub Labels":          {"string"},
+	"GitHub Status":          {"string"},
+	"GitHub Reporter":        {"string"},
+	"Last Issue-Sync Update": {"datetime"},
+	"GitHub URI":             {"string"},
+	"GitHub Repo":            {"string"},
+	"Issue-Sync Version":     {"string"},
+	"GitHub Comment Count":   {"number"},
+	"GitHub State Reason":    {"string"},
+}
+
+// managedFields are the names of the custom fields issue-sync writes on every
+// issue. Those listed in the `required-fields` option must exist; the others
+// are simply not written if they are missing.
+var managedFields = []string{
+	"GitHub ID",
+	"GitHub Number",
+	"GitHub Labels",
+	"GitHub Status",
+	"GitHub Reporter",
+	"Last Issue-Sync Update",
+	"GitHub URI",
+}
+
+// checkFieldType returns an error if the type of a custom field used by
+// issue-sync doesn't match the values it writes to it, e.g. if the GitHub
+// ID field is a text field rather than a number field.
+func checkFieldType(name string, field jiraField) error {
+	if name == "type field" {
+		if field.Schema.Type != "option" {
+			return fmt.Errorf("'%s' custom field has type %s, but the type field must be a single-select field", field.Name, field.Schema.Type)
+		}
+		return nil
+	}
+	want, ok := fieldTypes[name]
+	if !ok {
+		return nil
+	}
+	for _, t := range want {
+		if field.Schema.Type == t {
+			return nil
+		}
+	}
+	return fmt.Errorf("'%s' custom field has type %s, but must be a %s field; recreate it with the correct type", field.Name, field.Schema.Type, strings.Join(want, " or "))
+}
+
+// getFieldIDs requests the metadata of every issue field on the JIRA
+// server, which is shared by all projects, and saves the IDs of the custom
+// fields used by issue-sync after checking their types. Fields are matched by
+// name, except those pinned to an ID by the `field-ids` option.
 func (c Config) getFieldIDs(client jira.Client) (fields, error) {
 	c.log.Debug("Collecting field IDs.")
 	req, err := client.NewRequest("GET", "/rest/api/2/field", nil)
@@ -44,39 +102,61 @@ func (c Config) getFieldIDs(client jira.Client) (fields, error) {
 
 	fieldIDs := fields{}
 
+	pinnedFields := c.cmdConfig.getParsed().pinnedFields
+	pinned := map[string]string{}
+	for name, id := range pinnedFields {
+		pinned[id] = name
+	}
+
 	for _, field := range *jFields {
-		switch field.Name {
-		case "GitHub ID":
-			fieldIDs.githubID = fmt.Sprint(field.Schema.CustomID)
-		case "GitHub Number":
-			fieldIDs.githubNumber = fmt.Sprint(field.Schema.CustomID)
-		case "GitHub Labels":
-			fieldIDs.githubLabels = fmt.Sprint(field.Schema.CustomID)
-		case "GitHub Status":
-			fieldIDs.githubStatus = fmt.Sprint(field.Schema.CustomID)
-		case "GitHub Reporter":
-			fieldIDs.githubReporter = fmt.Sprint(field.Schema.CustomID)
-		case "Last Issue-Sync Update":
-			fieldIDs.lastUpdate = fmt.Sprint(field.Schema.CustomID)
-		case "GitHub URI":
-			fieldIDs.githubURI = fmt.Sprint(field.Schema.CustomID)
+		name, ok := pinned[fmt.Sprint(field.Schema.CustomID)]
+		if !ok {
+			name = field.Name
+			if typeField := c.GetTypeField(); typeField != "" && field.Name == typeField {
+				name = "type field"
+			}
+			// A pinned field is only matched by its ID, so that a field
+			// which shares or has taken its name is ignored.
+			if _, ok := pinnedFields[name]; ok {
+				continue
+			}
+		}
+
+		if err := checkFieldType(name, field); err != nil {
+			return fields{}, err
+		}
+		fieldIDs.set(name, field)
+	}
+
+	for name, id := range pinnedFields {
+		if fieldIDs.byName()[name] == "" {
+			return fieldIDs, fmt.Errorf("could not find custom field customfield_%s, which is pinned as '%s'", id, name)
+		}
+	}
+
+	managed := fieldIDs.byName()
+	for _, name := range managedFields {
+		if managed[name] != "" {
+			continue
+		}
+		if c.IsFieldRequired(name) {
+			return fieldIDs, fmt.Errorf("could not find ID of '%s' custom field; check that it is named correctly", name)
 		}
+		c.log.Infof("No '%s' custom field; it will not be written.", name)
+	}
+
+	if c.GetTypeField() != "" && fieldIDs.typeField == "" {
+		return fieldIDs, fmt.Errorf("could not find ID of '%s' custom field; check that the type field is named correctly", c.GetTypeField())
 	}
 
-	if fieldIDs.githubID == "" {
-		return fieldIDs, errors.New("could not find ID of 'GitHub ID' custom field; check that it is named correctly")
-	} else if fieldIDs.githubNumber == "" {
-		return fieldIDs, errors.New("could not find ID of 'GitHub Number' custom field; check that it is named correctly")
-	} else if fieldIDs.githubLabels == "" {
-		return fieldIDs, errors.New("could not find ID of 'Github Labels' custom field; check that it is named correctly")
-	} else if fieldIDs.githubStatus == "" {
-		return fieldIDs, errors.New("could not find ID of 'Github Status' custom field; check that it is named correctly")
-	} else if fieldIDs.githubReporter == "" {
-		return fieldIDs, errors.New("could not find ID of 'Github Reporter' custom field; check that it is named correctly")
-	} else if fieldIDs.lastUpdate == "" {
-		return fieldIDs, errors.New("could not find ID of 'Last Issue-Sync Update' custom field; check that it is named correctly")
-	} else if fieldIDs.lastUpdate == "" {
-		return fieldIDs, errors.New("could not find ID of 'GitHub URI' custom field; check that it is named correctly")
+	if c.GetSprint() != "" && fieldIDs.sprint == "" {
+		return fieldIDs, errors.New("could not find ID of 'Sprint' custom field; check that JIRA Software is enabled for the project")
+	}
+
+	// The 'GitHub Repo' field is optional. Without it, GitHub numbers are
+	// ambiguous when several repositories are synced into one project.
+	if fieldIDs.githubRepo == "" {
+		c.log.Debug("No 'GitHub Repo' custom field; issue repositories will not be recorded.")
 	}
 
 	c.log.Debug("All fields have been checked.")
@@ -86,42 +166,130 @@ func (c Config) getFieldIDs(client jira.Client) (fields, error) {
 
 // GetFieldID returns the customfield ID of a JIRA custom field.
 func (c Config) GetFieldID(key fieldKey) string {
+	ids := c.fieldIDs.get()
+
 	switch key {
 	case GitHubID:
-		return c.fieldIDs.githubID
+		return ids.githubID
 	case GitHubNumber:
-		return c.fieldIDs.githubNumber
+		return ids.githubNumber
 	case GitHubLabels:
-		return c.fieldIDs.githubLabels
+		return ids.githubLabels
 	case GitHubReporter:
-		return c.fieldIDs.githubReporter
+		return ids.githubReporter
 	case GitHubStatus:
-		return c.fieldIDs.githubStatus
+		return ids.githubStatus
 	case LastISUpdate:
-		return c.fieldIDs.lastUpdate
+		return ids.lastUpdate
 	case GitHubURI:
-		return c.fieldIDs.githubURI
+		return ids.githubURI
+	case Sprint:
+		return ids.sprint
+	case GitHubRepo:
+		return ids.githubRepo
+	case Version:
+		return ids.version
+	case TypeField:
+		return ids.typeField
+	case GitHubCommentCount:
+		return ids.commentCount
+	case GitHubStateReason:
+		return ids.stateReason
 	default:
 		return ""
 	}
 }
 
+// HasField returns whether the JIRA custom field exists, for the custom
+// fields which are optional.
+func (c Config) HasField(key fieldKey) bool {
+	return c.GetFieldID(key) != ""
+}
+
+// GetGitHubIDClause returns the quoted JQL clause name of the GitHub ID field,
+// e.g. `"GitHub ID"`, which some JIRA instances accept where cf[XXXXX]
+// fails, or an empty string if it has none.
+func (c Config) GetGitHubIDClause() string {
+	return c.fieldIDs.get().githubIDClause
+}
+
+// nameClause returns the first JQL clause name of a field which isn't its
+// cf[XXXXX] form, quoted for JQL, or an empty string if it has none.
+func nameClause(field jiraField) string {
+	for _, clause := range field.ClauseNames {
+		if !strings.HasPrefix(clause, "cf[") {
+			return strconv.Quote(clause)
+		}
+	}
+	return ""
+}
+
+// IsTextField returns whether the GitHub ID or number custom field is a text
+// field rather than a number field.
+func (c Config) IsTextField(key fieldKey) bool {
+	ids := c.fieldIDs.get()
+	return (key == GitHubID && ids.githubIDText) || (key == GitHubNumber && ids.githubNumberText)
+}
+
+// GetNumberValue returns the value written to a custom field holding a
+// number, such as the GitHub ID: the number itself, or its decimal string if
+// the field is a text field.
+func (c Config) GetNumberValue(key fieldKey, n int) interface{} {
+	if c.IsTextField(key) {
+		return strconv.Itoa(n)
+	}
+	return n
+}
+
 // GetFieldKey returns customfield_XXXXX, where XXXXX is the custom field ID (see GetFieldID).
 func (c Config) GetFieldKey(key fieldKey) string {
 	return fmt.Sprintf("customfield_%s", c.GetFieldID(key))
 }
 
+// IsFieldWritable returns whether issue-sync may write the JIRA field with the
+// given key, e.g. customfield_10010, so that fields managed by other
+// integrations aren't overwritten by mistake. If the `writable-fields` option
+// is empty, any field may be written; otherwise only the listed fields and
+// those issue-sync manages, which include the environment if it is templated.
+func (c Config) IsFieldWritable(key string) bool {
+	allowed := c.cmdConfig.GetStringSlice("writable-fields")
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, k := range allowed {
+		if k == key {
+			return true
+		}
+	}
+
+	if key == "environment" && c.GetEnvironmentTemplate() != nil {
+		return true
+	}
+	for _, id := range c.fieldIDs.get().byName() {
+		if id != "" && fmt.Sprintf("customfield_%s", id) == key {
+			return true
+		}
+	}
+	return false
+}
+
 // fieldKey is an enum-like type to represent the customfield ID keys
 type fieldKey int
 
 const (
-	GitHubID       fieldKey = iota
-	GitHubNumber   fieldKey = iota
-	GitHubLabels   fieldKey = iota
-	GitHubStatus   fieldKey = iota
-	GitHubReporter fieldKey = iota
-	LastISUpdate   fieldKey = iota
-	GitHubURI      fieldKey = iota
+	GitHubID           fieldKey = iota
+	GitHubNumber       fieldKey = iota
+	GitHubLabels       fieldKey = iota
+	GitHubStatus       fieldKey = iota
+	GitHubReporter     fieldKey = iota
+	LastISUpdate       fieldKey = iota
+	GitHubURI          fieldKey = iota
+	Sprint             fieldKey = iota
+	GitHubRepo         fieldKey = iota
+	Version            fieldKey = iota
+	TypeField          fieldKey = iota
+	GitHubCommentCount fieldKey = iota
+	GitHubStateReason  fieldKey = iota
 )
 
 // fields represents the custom field IDs of the JIRA custom fields we care about
@@ -133,4 +301,96 @@ type fields struct {
 	githubStatus   string
 	lastUpdate     string
 	githubURI      string
+	sprint         string
+	githubRepo     string
+	version        string
+	typeField      string
+	commentCount   string
+	stateReason    string
+
+	// githubIDClause is the JQL clause name of the GitHub ID field.
+	githubIDClause string
+
+	// githubIDText and githubNumberText are whether the GitHub ID and number
+	// fields are text rather than number fields.
+	githubIDText     bool
+	githubNumberText bool
+}
+
+// byName returns the custom field IDs keyed by the names of the fields.
+func (f fields) byName() map[string]string {
+	return map[string]string{
+		"GitHub ID":              f.githubID,
+		"GitHub Number":          f.githubNumber,
+		"GitHub Labels":          f.githubLabels,
+		"GitHub Status":          f.githubStatus,
+		"GitHub Reporter":        f.githubReporter,
+		"Last Issue-Sync Update": f.lastUpdate,
+		"GitHub URI":             f.githubURI,
+		"Sprint":                 f.sprint,
+		"GitHub Repo":            f.githubRepo,
+		"Issue-Sync Version":     f.version,
+		"type field":             f.typeField,
+		"GitHub Comment Count":   f.commentCount,
+		"GitHub State Reason":    f.stateReason,
+	}
+}
+
+// set saves the ID of the custom field used by issue-sync as the field of the
+// given name, if it is one of them.
+func (f *fields) set(name string, field jiraField) {
+	id := fmt.Sprint(field.Schema.CustomID)
+	switch name {
+	case "GitHub ID":
+		f.githubID = id
+		f.githubIDClause = nameClause(field)
+		f.githubIDText = field.Schema.Type == "string"
+	case "GitHub Number":
+		f.githubNumber = id
+		f.githubNumberText = field.Schema.Type == "string"
+	case "GitHub Labels":
+		f.githubLabels = id
+	case "GitHub Status":
+		f.githubStatus = id
+	case "GitHub Reporter":
+		f.githubReporter = id
+	case "Last Issue-Sync Update":
+		f.lastUpdate = id
+	case "GitHub URI":
+		f.githubURI = id
+	case "Sprint":
+		f.sprint = id
+	case "GitHub Repo":
+		f.githubRepo = id
+	case "Issue-Sync Version":
+		f.version = id
+	case "type field":
+		f.typeField = id
+	case "GitHub Comment Count":
+		f.commentCount = id
+	case "GitHub State Reason":
+		f.stateReason = id
+	}
+}
+
+// fieldSet guards the custom field IDs, which may be refreshed while they
+// are read. A nil set, e.g. of a zero Config, holds no IDs.
+type fieldSet struct {
+	mu  sync.RWMutex
+	ids fields
+}
+
+func (s *fieldSet) get() fields {
+	if s == nil {
+		return fields{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ids
+}
+
+func (s *fieldSet) set(ids fields) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ids = ids
 }
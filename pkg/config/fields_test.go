@@ -0,0 +1,185 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+func TestCheckFieldType(t *testing.T) {
+	tests := []struct {
+		name    string
+		typ     string
+		wantErr bool
+	}{
+		{"GitHub ID", "number", false},
+		{"GitHub ID", "string", false},
+		{"GitHub ID", "datetime", true},
+		{"GitHub Number", "string", false},
+		{"GitHub Comment Count", "string", true},
+		{"GitHub Labels", "number", true},
+		{"Last Issue-Sync Update", "datetime", false},
+		{"type field", "option", false},
+		{"type field", "string", true},
+		{"Unrelated", "array", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.typ, func(t *testing.T) {
+			var field jiraField
+			field.Name = tt.name
+			field.Schema.Type = tt.typ
+			if err := checkFieldType(tt.name, field); (err != nil) != tt.wantErr {
+				t.Errorf("checkFieldType() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetNumberValue(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want interface{}
+	}{
+		{"number", 42},
+		{"string", "42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			var field jiraField
+			field.Schema.Type = tt.typ
+			field.Schema.CustomID = 10001
+			var ids fields
+			ids.set("GitHub ID", field)
+			c := Config{fieldIDs: &fieldSet{ids: ids}}
+
+			if got := c.GetNumberValue(GitHubID, 42); got != tt.want {
+				t.Errorf("GetNumberValue() = %#v, want %#v", got, tt.want)
+			}
+			if got := c.GetNumberValue(GitHubNumber, 42); got != 42 {
+				t.Errorf("GetNumberValue() of the number field = %#v, want 42", got)
+			}
+		})
+	}
+}
+
+func TestNameClause(t *testing.T) {
+	tests := []struct {
+		name    string
+		clauses []string
+		want    string
+	}{
+		{"name", []string{"cf[10001]", "GitHub ID"}, `"GitHub ID"`},
+		{"name first", []string{"githubid", "cf[10001]"}, `"githubid"`},
+		{"custom field only", []string{"cf[10001]"}, ""},
+		{"none", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var field jiraField
+			field.ClauseNames = tt.clauses
+			if got := nameClause(field); got != tt.want {
+				t.Errorf("nameClause() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetFieldIDsPinned(t *testing.T) {
+	// Two fields are named GitHub ID, and the labels field was renamed.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`[
+			{"name": "GitHub ID", "schema": {"type": "number", "customId": 10001}},
+			{"name": "GitHub ID", "schema": {"type": "number", "customId": 10011}},
+			{"name": "GitHub Number", "schema": {"type": "number", "customId": 10002}},
+			{"name": "Old Labels", "schema": {"type": "string", "customId": 10003}}
+		]`))
+	}))
+	defer server.Close()
+	client, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		pins      map[string]string
+		want      map[string]string
+		wantErr   bool
+		configErr bool
+	}{
+		{"by name", nil, map[string]string{"GitHub Number": "10002", "GitHub Labels": ""}, false, false},
+		{"pinned renamed field", map[string]string{"GitHub Labels": "10003"}, map[string]string{"GitHub Number": "10002", "GitHub Labels": "10003"}, false, false},
+		{"pinned among same names", map[string]string{"GitHub ID": "10001"}, map[string]string{"GitHub ID": "10001"}, false, false},
+		{"customfield prefix", map[string]string{"GitHub ID": "customfield_10011"}, map[string]string{"GitHub ID": "10011"}, false, false},
+		{"names ignore case", map[string]string{"github labels": "10003"}, map[string]string{"GitHub Labels": "10003"}, false, false},
+		// A pinned field isn't matched by name, even if it isn't found.
+		{"pinned field missing", map[string]string{"GitHub Number": "10099"}, nil, true, false},
+		{"unknown field", map[string]string{"Priority": "10004"}, nil, false, true},
+		{"invalid ID", map[string]string{"GitHub ID": "id"}, nil, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewTestConfig(map[string]interface{}{"field-ids": tt.pins})
+			if (err != nil) != tt.configErr {
+				t.Fatalf("NewTestConfig() error = %v, want error %v", err, tt.configErr)
+			}
+			if err != nil {
+				return
+			}
+
+			ids, err := c.getFieldIDs(*client)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getFieldIDs() error = %v, want error %v", err, tt.wantErr)
+			}
+			for name, want := range tt.want {
+				if got := ids.byName()[name]; got != want {
+					t.Errorf("ID of '%s' = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestIsFieldWritable(t *testing.T) {
+	pins := map[string]string{"GitHub ID": "10001", "GitHub Number": "10002"}
+	allowed := []string{"customfield_10010", "priority"}
+
+	tests := []struct {
+		name     string
+		writable []string
+		template string
+		key      string
+		want     bool
+	}{
+		{"no allowlist", nil, "", "customfield_10099", true},
+		{"allowed", allowed, "", "customfield_10010", true},
+		{"allowed system field", allowed, "", "priority", true},
+		{"not allowed", allowed, "", "customfield_10099", false},
+		{"issue-sync field", allowed, "", "customfield_10002", true},
+		{"environment templated", allowed, "{{.Repo}}", "environment", true},
+		{"environment not templated", allowed, "", "environment", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewTestConfig(map[string]interface{}{
+				"field-ids":            pins,
+				"writable-fields":      tt.writable,
+				"environment-template": tt.template,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.IsFieldWritable(tt.key); got != tt.want {
+				t.Errorf("IsFieldWritable(%q) = %v, want %v", tt.key, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,6 +1,40 @@
 package config
 
+import "strings"
+
 type Organisation struct {
 	Name  string   `yaml:"name" mapstructure:"name"`
 	Repos []string `yaml:"repos,omitempty" mapstructure:"repos"`
+	// Overrides are the settings which differ for some repositories of the
+	// organisation, keyed by repository name.
+	Overrides map[string]RepoOverride `yaml:"overrides,omitempty" mapstructure:"overrides"`
+}
+
+// RepoOverride holds the settings of a repository which replace the global
+// ones for its issues. Settings which are empty aren't replaced.
+type RepoOverride struct {
+	IssueType     string   `yaml:"issue-type,omitempty" mapstructure:"issue-type"`
+	Components    []string `yaml:"components,omitempty" mapstructure:"components"`
+	DefaultLabels []string `yaml:"default-labels,omitempty" mapstructure:"default-labels"`
+}
+
+// GetRepoOverride returns the overrides of the repository `owner/repo`, which
+// are empty if it has none. Names are compared ignoring case, as on GitHub.
+func (c Config) GetRepoOverride(repo string) RepoOverride {
+	parts := strings.SplitN(repo, "/", 2)
+	if len(parts) != 2 {
+		return RepoOverride{}
+	}
+
+	for _, org := range c.GetRepos() {
+		if !strings.EqualFold(org.Name, parts[0]) {
+			continue
+		}
+		for name, override := range org.Overrides {
+			if strings.EqualFold(name, parts[1]) {
+				return override
+			}
+		}
+	}
+	return RepoOverride{}
 }
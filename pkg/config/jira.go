@@ -2,7 +2,11 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
 
 	jira "github.com/andygrunwald/go-jira"
 	"github.com/dghubble/oauth1"
@@ -16,27 +20,185 @@ func (c Config) SetJIRAToken(token *oauth1.Token) {
 }
 
 // LoadJIRAConfig loads the JIRA configuration (project key,
-// custom field IDs) from a remote JIRA server.
+// custom field IDs) from a remote JIRA server. The field metadata is global
+// to the server rather than per project, so it is requested once here, apart
+// from the project.
 func (c *Config) LoadJIRAConfig(client jira.Client) error {
-	proj, res, err := client.Project.Get(c.cmdConfig.GetString("jira-project"))
+	proj, err := c.getProject(client, c.cmdConfig.GetString("jira-project"))
 	if err != nil {
-		c.log.Errorf("Error retrieving JIRA project; check key and credentials. Error: %v", err)
-		defer res.Body.Close()
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			c.log.Errorf("Error occured trying to read error body: %v", err)
-			return err
+		return err
+	}
+	c.project = *proj
+
+	if err := c.checkIssueTypes(*proj); err != nil {
+		return err
+	}
+
+	ids, err := c.getFieldIDs(client)
+	if err != nil {
+		return err
+	}
+	// The set is shared by the copies of the Config made from here on.
+	if c.fieldIDs == nil {
+		c.fieldIDs = &fieldSet{}
+	}
+	c.fieldIDs.set(ids)
+
+	c.jiraLocation = c.getJIRALocation(client)
+
+	// An offline dry run has no users to check.
+	if reporter := c.GetDefaultReporter(); reporter != "" && c.GetJIRASnapshot() == "" {
+		if _, res, err := client.User.Get(reporter); err != nil {
+			if res != nil && res.StatusCode == http.StatusNotFound {
+				return fmt.Errorf("default reporter %s is not a JIRA user", reporter)
+			}
+			return fmt.Errorf("error retrieving default reporter %s: %v", reporter, err)
 		}
+	}
+
+	return nil
+}
 
-		c.log.Debugf("Error body: %s", body)
-		return errors.New(string(body))
+// RefreshFieldIDs resolves the custom field IDs again, and checks their types.
+// If a custom field was deleted and recreated, e.g. by a JIRA administrator,
+// since they were last resolved, its ID has changed; this is logged, and the
+// new ID is used from then on, rather than writing to a stale field.
+func (c Config) RefreshFieldIDs(client jira.Client) error {
+	if c.fieldIDs == nil {
+		return errors.New("custom field IDs can't be refreshed before the JIRA configuration is loaded")
 	}
-	c.project = *proj
 
-	c.fieldIDs, err = c.getFieldIDs(client)
+	ids, err := c.getFieldIDs(client)
 	if err != nil {
 		return err
 	}
 
+	old := c.fieldIDs.get().byName()
+	for name, id := range ids.byName() {
+		if old[name] != id {
+			c.log.Warnf("ID of '%s' custom field changed from %q to %q; it was probably recreated. Using the new ID.", name, old[name], id)
+		}
+	}
+	c.fieldIDs.set(ids)
+
 	return nil
 }
+
+// getJIRALocation returns the time zone of the JIRA user, in which JIRA
+// interprets the dates of JQL queries. If it can't be retrieved, the local
+// time zone is assumed.
+func (c Config) getJIRALocation(client jira.Client) *time.Location {
+	req, err := client.NewRequest("GET", "/rest/api/2/myself", nil)
+	if err != nil {
+		c.log.Warnf("Error creating JIRA user request; assuming the local time zone for JQL: %v", err)
+		return time.Local
+	}
+
+	var myself struct {
+		TimeZone string `json:"timeZone"`
+	}
+	if _, err := client.Do(req, &myself); err != nil {
+		c.log.Warnf("Error retrieving the JIRA user; assuming the local time zone for JQL: %v", err)
+		return time.Local
+	}
+
+	loc, err := time.LoadLocation(myself.TimeZone)
+	if err != nil || myself.TimeZone == "" {
+		c.log.Warnf("Unknown time zone %q of the JIRA user; assuming the local time zone for JQL", myself.TimeZone)
+		return time.Local
+	}
+
+	c.log.Debugf("JIRA user's time zone is %s", loc)
+	return loc
+}
+
+// getProject retrieves the JIRA project with the given key. If there is no
+// project with that key, the project with that name is retrieved instead, so
+// that a human-readable name may be configured. The key it resolves to is
+// kept in the project for the rest of the run.
+func (c Config) getProject(client jira.Client, project string) (*jira.Project, error) {
+	proj, res, err := client.Project.Get(project)
+	if err == nil {
+		return proj, nil
+	}
+	if res == nil || res.StatusCode != http.StatusNotFound {
+		return nil, c.projectError(res, err)
+	}
+
+	list, res, err := client.Project.GetList()
+	if err != nil {
+		return nil, c.projectError(res, err)
+	}
+
+	for _, p := range *list {
+		if !strings.EqualFold(p.Name, project) {
+			continue
+		}
+
+		c.log.Infof("Resolved JIRA project %s to key %s", project, p.Key)
+
+		proj, res, err := client.Project.Get(p.Key)
+		if err != nil {
+			return nil, c.projectError(res, err)
+		}
+		return proj, nil
+	}
+
+	return nil, fmt.Errorf("no JIRA project has the key or name %s; check the project and credentials", project)
+}
+
+// checkIssueTypes returns an error listing the issue types of the project if
+// one of the configured issue types isn't among them, as JIRA would refuse to
+// create issues of that type. A project whose issue types aren't listed, e.g.
+// in a snapshot, isn't checked.
+func (c Config) checkIssueTypes(proj jira.Project) error {
+	if len(proj.IssueTypes) == 0 {
+		return nil
+	}
+
+	available := make([]string, len(proj.IssueTypes))
+	for i, t := range proj.IssueTypes {
+		available[i] = t.Name
+	}
+
+	names := []string{c.GetIssueType(), c.GetPRIssueType()}
+	for _, org := range c.GetRepos() {
+		for _, override := range org.Overrides {
+			names = append(names, override.IssueType)
+		}
+	}
+
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, t := range available {
+			found = found || strings.EqualFold(t, name)
+		}
+		if !found {
+			return fmt.Errorf("JIRA project %s has no issue type %q; available types are: %s", proj.Key, name, strings.Join(available, ", "))
+		}
+	}
+
+	return nil
+}
+
+// projectError logs an error retrieving the JIRA project, and returns the body
+// of the response as the error, if there is one.
+func (c Config) projectError(res *jira.Response, err error) error {
+	c.log.Errorf("Error retrieving JIRA project; check key and credentials. Error: %v", err)
+	if res == nil {
+		return err
+	}
+
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		c.log.Errorf("Error occured trying to read error body: %v", err)
+		return err
+	}
+
+	c.log.Debugf("Error body: %s", body)
+	return errors.New(string(body))
+}
@@ -0,0 +1,242 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/spf13/viper"
+)
+
+// jiraServer serves the project, fields and user LoadJIRAConfig requests,
+// counting them by path in requests unless it is nil. The only other JIRA
+// user is "reporter".
+func jiraServer(requests map[string]int) *httptest.Server {
+	responses := map[string]string{
+		"/rest/api/2/project":      `[{"key": "TEST", "name": "Test Project"}]`,
+		"/rest/api/2/project/TEST": `{"key": "TEST"}`,
+		"/rest/api/2/field": `[
+			{"name": "GitHub ID", "schema": {"type": "number", "customId": 10001}},
+			{"name": "GitHub Number", "schema": {"type": "number", "customId": 10002}}
+		]`,
+		"/rest/api/2/myself":                 `{"timeZone": "UTC"}`,
+		"/rest/api/2/user?username=reporter": `{"name": "reporter"}`,
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests != nil {
+			requests[r.URL.Path]++
+		}
+		body, ok := responses[r.URL.Path]
+		if !ok {
+			body, ok = responses[r.URL.RequestURI()]
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+}
+
+func TestZeroConfigFieldIDs(t *testing.T) {
+	var c Config
+	if id := c.GetFieldID(GitHubID); id != "" {
+		t.Errorf("GetFieldID() of a zero Config = %q, want none", id)
+	}
+	if c.HasField(LastISUpdate) {
+		t.Error("zero Config has the Last Issue-Sync Update field")
+	}
+	if err := c.RefreshFieldIDs(jira.Client{}); err == nil {
+		t.Error("RefreshFieldIDs() of a zero Config succeeded")
+	}
+}
+
+func TestLoadJIRAConfigWithoutFieldSet(t *testing.T) {
+	server := jiraServer(nil)
+	defer server.Close()
+
+	client, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	v.Set("jira-project", "TEST")
+	c := Config{cmdConfig: newStore(v), log: *newLogger("issue-sync", parseLogLevel("panic"))}
+
+	if err := c.LoadJIRAConfig(*client); err != nil {
+		t.Fatalf("LoadJIRAConfig() = %v", err)
+	}
+	if id := c.GetFieldID(GitHubNumber); id != "10002" {
+		t.Errorf("GetFieldID(GitHubNumber) = %q, want 10002", id)
+	}
+
+	// Copies share the set, so refreshed IDs are seen by all of them.
+	copied := c
+	if err := c.RefreshFieldIDs(*client); err != nil {
+		t.Fatalf("RefreshFieldIDs() = %v", err)
+	}
+	if id := copied.GetFieldID(GitHubID); id != "10001" {
+		t.Errorf("GetFieldID(GitHubID) of a copy = %q, want 10001", id)
+	}
+}
+
+func TestLoadJIRAConfigFieldRequests(t *testing.T) {
+	tests := []struct {
+		name     string
+		project  string
+		requests map[string]int
+	}{
+		{"project key", "TEST", map[string]int{
+			"/rest/api/2/project/TEST": 1,
+			"/rest/api/2/field":        1,
+			"/rest/api/2/myself":       1,
+		}},
+		{"project name", "Test Project", map[string]int{
+			"/rest/api/2/project/Test Project": 1,
+			"/rest/api/2/project":              1,
+			"/rest/api/2/project/TEST":         1,
+			"/rest/api/2/field":                1,
+			"/rest/api/2/myself":               1,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := map[string]int{}
+			server := jiraServer(requests)
+			defer server.Close()
+
+			client, err := jira.NewClient(nil, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v := viper.New()
+			v.Set("jira-project", tt.project)
+			c := Config{cmdConfig: newStore(v), log: *newLogger("issue-sync", parseLogLevel("panic"))}
+
+			if err := c.LoadJIRAConfig(*client); err != nil {
+				t.Fatalf("LoadJIRAConfig() = %v", err)
+			}
+			if c.project.Key != "TEST" {
+				t.Errorf("project key = %q, want TEST", c.project.Key)
+			}
+			if !reflect.DeepEqual(requests, tt.requests) {
+				t.Errorf("requests = %v, want %v", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestLoadJIRAConfigDefaultReporter(t *testing.T) {
+	tests := []struct {
+		name     string
+		reporter string
+		snapshot string
+		wantErr  bool
+	}{
+		{"no default reporter", "", "", false},
+		{"existing user", "reporter", "", false},
+		{"missing user", "nobody", "", true},
+		// An offline dry run can't look the user up.
+		{"offline", "nobody", "snapshot.json", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := jiraServer(nil)
+			defer server.Close()
+
+			client, err := jira.NewClient(nil, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			v := viper.New()
+			v.Set("jira-project", "TEST")
+			v.Set("default-reporter", tt.reporter)
+			v.Set("jira-snapshot", tt.snapshot)
+			c := Config{cmdConfig: newStore(v), log: *newLogger("issue-sync", parseLogLevel("panic"))}
+
+			if err := c.LoadJIRAConfig(*client); (err != nil) != tt.wantErr {
+				t.Errorf("LoadJIRAConfig() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGetProject(t *testing.T) {
+	server := jiraServer(nil)
+	defer server.Close()
+
+	client, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := Config{log: *newLogger("issue-sync", parseLogLevel("panic"))}
+
+	tests := []struct {
+		name    string
+		project string
+		wantKey string
+		wantErr bool
+	}{
+		{"key", "TEST", "TEST", false},
+		{"name", "Test Project", "TEST", false},
+		{"name case", "test PROJECT", "TEST", false},
+		{"unknown", "Other Project", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proj, err := c.getProject(*client, tt.project)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getProject() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && proj.Key != tt.wantKey {
+				t.Errorf("getProject() key = %q, want %q", proj.Key, tt.wantKey)
+			}
+		})
+	}
+}
+
+func TestCheckIssueTypes(t *testing.T) {
+	types := []jira.IssueType{{Name: "Task"}, {Name: "Bug"}}
+
+	tests := []struct {
+		name        string
+		issueType   string
+		prIssueType string
+		types       []jira.IssueType
+		wantErr     string
+	}{
+		{"available types", "Task", "Bug", types, ""},
+		{"case insensitive", "task", "", types, ""},
+		{"missing issue type", "Story", "", types, `JIRA project TEST has no issue type "Story"; available types are: Task, Bug`},
+		{"missing PR issue type", "Task", "Pull Request", types, `JIRA project TEST has no issue type "Pull Request"; available types are: Task, Bug`},
+		// A project without listed issue types, e.g. from a snapshot, isn't checked.
+		{"types not listed", "Story", "", nil, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := viper.New()
+			v.Set("issue-type", tt.issueType)
+			v.Set("pr-issue-type", tt.prIssueType)
+			c := Config{cmdConfig: newStore(v), log: *newLogger("issue-sync", parseLogLevel("panic"))}
+
+			err := c.checkIssueTypes(jira.Project{Key: "TEST", IssueTypes: tt.types})
+			var got string
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.wantErr {
+				t.Errorf("checkIssueTypes() error = %q, want %q", got, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package config
+
+import (
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/innovocloud/issue-sync/pkg/convert"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// store is the Viper configuration shared by all copies of a Config, together
+// with the state parsed from it. Viper isn't safe for concurrent use, and the
+// configuration file is read again while syncs run, so every access goes
+// through the lock.
+type store struct {
+	mu     sync.RWMutex
+	v      *viper.Viper
+	parsed *parsed
+}
+
+// parsed holds the option values which are validated and parsed once, rather
+// than on every use, and the converter built from the options. It is rebuilt
+// as a whole when the configuration file is reloaded, so a value is never
+// changed once parsed.
+type parsed struct {
+	// converter transforms issue and comment bodies between GitHub and JIRA markup.
+	converter convert.Converter
+
+	// createDefaults is the parsed value of the `create-defaults` configuration
+	// parameter: raw JIRA field values set on every issue created.
+	createDefaults map[string]interface{}
+
+	// bodyRewrites is the parsed value of the `body-rewrites` configuration
+	// parameter, in the order they are applied.
+	bodyRewrites []bodyRewrite
+
+	// pinnedFields is the parsed value of the `field-ids` configuration
+	// parameter: the customfield IDs of managed fields, keyed by field name.
+	pinnedFields map[string]string
+
+	// transitionFields is the parsed value of the `transition-fields`
+	// configuration parameter, keyed by lower case transition name.
+	transitionFields map[string]map[string]interface{}
+
+	// environmentTemplate is the parsed value of the `environment-template`
+	// configuration parameter, or nil if it isn't set.
+	environmentTemplate *template.Template
+
+	// summaryTemplate is the parsed value of the `summary-template`
+	// configuration parameter, or nil if it isn't set.
+	summaryTemplate *template.Template
+}
+
+// newStore returns a store of the given Viper configuration, with nothing
+// parsed yet.
+func newStore(v *viper.Viper) *store {
+	return &store{v: v, parsed: &parsed{}}
+}
+
+// getParsed returns the state currently parsed from the options. A nil store,
+// e.g. of a zero Config, has none.
+func (s *store) getParsed() *parsed {
+	if s == nil {
+		return &parsed{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.parsed
+}
+
+// setParsed replaces the state parsed from the options.
+func (s *store) setParsed(p *parsed) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.parsed = p
+}
+
+// readInConfig reads the configuration file again.
+func (s *store) readInConfig() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.v.ReadInConfig()
+}
+
+// watch calls onChange whenever the configuration file changes. The file is
+// watched through a Viper object of its own, as Viper reads the changed file
+// before calling onChange, which must not race with the reads of the store.
+func (s *store) watch(onChange func(fsnotify.Event)) {
+	watcher := viper.New()
+	watcher.SetConfigFile(s.ConfigFileUsed())
+	watcher.OnConfigChange(onChange)
+	watcher.WatchConfig()
+}
+
+// BindPFlags binds the options to the command line flags.
+func (s *store) BindPFlags(flags *pflag.FlagSet) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.v.BindPFlags(flags)
+}
+
+// ConfigFileUsed returns the configuration file which was loaded.
+func (s *store) ConfigFileUsed() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.ConfigFileUsed()
+}
+
+// Set overrides the value of an option.
+func (s *store) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.v.Set(key, value)
+}
+
+// Unmarshal decodes all the options into rawVal. Viper lower cases the keys
+// of its maps in place as it does, so this takes the write lock.
+func (s *store) Unmarshal(rawVal interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.v.Unmarshal(rawVal)
+}
+
+// GetBool returns the value of an option as a bool.
+func (s *store) GetBool(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.GetBool(key)
+}
+
+// GetDuration returns the value of an option as a duration.
+func (s *store) GetDuration(key string) time.Duration {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.GetDuration(key)
+}
+
+// GetFloat64 returns the value of an option as a float64.
+func (s *store) GetFloat64(key string) float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.GetFloat64(key)
+}
+
+// GetInt returns the value of an option as an int.
+func (s *store) GetInt(key string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.GetInt(key)
+}
+
+// GetInt64 returns the value of an option as an int64.
+func (s *store) GetInt64(key string) int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.GetInt64(key)
+}
+
+// GetString returns the value of an option as a string.
+func (s *store) GetString(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.GetString(key)
+}
+
+// GetStringMapString returns the value of an option as a map of strings.
+func (s *store) GetStringMapString(key string) map[string]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.GetStringMapString(key)
+}
+
+// GetStringSlice returns the value of an option as a slice of strings.
+func (s *store) GetStringSlice(key string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.v.GetStringSlice(key)
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// fileConfig returns a validated configuration read from a YAML file with
+// the given contents, which can be reloaded.
+func fileConfig(t *testing.T, path, contents string) Config {
+	t.Helper()
+	if err := ioutil.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	v := viper.New()
+	for key, value := range testDefaults {
+		v.SetDefault(key, value)
+	}
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	c := Config{
+		cmdConfig: newStore(v),
+		fieldIDs:  &fieldSet{},
+		changed:   make(chan struct{}, 1),
+	}
+	c.log = *newLogger("issue-sync", logLevel(c.cmdConfig))
+	if err := c.validateConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	c := fileConfig(t, path, `body-rewrites: '[{"pattern": "secret", "replace": "one"}]'`)
+	copied := c
+
+	tests := []struct {
+		name     string
+		contents string
+		want     string
+	}{
+		{"changed rewrite", `body-rewrites: '[{"pattern": "secret", "replace": "two"}]'`, "two"},
+		{"invalid rewrite", `body-rewrites: '[{"pattern": "(", "replace": "three"}]'`, "two"},
+		{"removed rewrite", `log-level: panic`, "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ioutil.WriteFile(path, []byte(tt.contents), 0644); err != nil {
+				t.Fatal(err)
+			}
+			c.reload(fsnotify.Event{Name: path})
+
+			if got := copied.RewriteBody("secret"); got != tt.want {
+				t.Errorf("RewriteBody() of a copy = %q, want %q", got, tt.want)
+			}
+			select {
+			case <-copied.ConfigChanged():
+			default:
+				t.Error("reload didn't signal the change")
+			}
+		})
+	}
+}
+
+func TestReloadConcurrently(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+
+	c := fileConfig(t, path, `summary-template: '[{{.Repo}}] {{.Title}}'`)
+
+	// Run with -race: reading the options while the file is reloaded must
+	// not race.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				c.GetSummaryTemplate()
+				c.GetSinceParam()
+				c.GetRepos()
+				c.GetConverter()
+			}
+		}()
+	}
+	for i := 0; i < 10; i++ {
+		c.reload(fsnotify.Event{Name: path})
+		<-c.ConfigChanged()
+	}
+	wg.Wait()
+
+	if c.GetSummaryTemplate() == nil {
+		t.Error("GetSummaryTemplate() = nil after reloading")
+	}
+}
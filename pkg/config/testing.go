@@ -0,0 +1,67 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// testDefaults are the option values of a test configuration which aren't
+// given: the credentials validation requires, and the defaults of the
+// command line flags which must be valid.
+var testDefaults = map[string]interface{}{
+	"log-level":             logrus.PanicLevel.String(),
+	"github-token":          "token",
+	"jira-user":             "issue-sync",
+	"jira-secret":           "secret",
+	"jira-uri":              "https://jira.example.com",
+	"jira-project":          "TEST",
+	"since":                 "2020-01-01T00:00:00+0000",
+	"sync-fields":           allSyncFields,
+	"comment-marker":        "issue-sync",
+	"comment-edits":         CommentEditsReplace,
+	"sync-workers":          1,
+	"delete-workers":        1,
+	"github-token-lifetime": "1h",
+	"failure-threshold":     0.1,
+}
+
+// NewTestConfig returns a configuration made of the given option values, for
+// the tests of the packages which use it. The values are validated as by
+// NewConfig. JIRA isn't contacted: the custom field IDs are those pinned by
+// the `field-ids` option, and the project is the one given.
+func NewTestConfig(values map[string]interface{}) (Config, error) {
+	v := viper.New()
+	for key, value := range testDefaults {
+		v.SetDefault(key, value)
+	}
+	for key, value := range values {
+		v.Set(key, value)
+	}
+
+	config := Config{
+		cmdConfig: newStore(v),
+		fieldIDs:  &fieldSet{},
+	}
+	config.log = *newLogger("issue-sync", logLevel(config.cmdConfig))
+	if err := config.validateConfig(); err != nil {
+		return Config{}, err
+	}
+
+	var ids fields
+	for name, id := range config.cmdConfig.getParsed().pinnedFields {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return Config{}, fmt.Errorf("field ID of '%s': %v", name, err)
+		}
+		var field jiraField
+		field.Schema.CustomID = n
+		ids.set(name, field)
+	}
+	config.fieldIDs.set(ids)
+	config.project.Key = v.GetString("jira-project")
+
+	return config, nil
+}
@@ -1,10 +1,90 @@
 package convert
 
 import (
+	"bytes"
+	"fmt"
 	"regexp"
 	"strings"
 )
 
+// Converter transforms issue and comment bodies between the markup
+// used by the source (GitHub) and the markup used by the target
+// (JIRA by default). It allows a different markup dialect to be
+// swapped in without changing the sync logic.
+type Converter interface {
+	ToTarget(source string) string
+	ToSource(target string) string
+}
+
+// Checker is implemented by Converters which can report source content that
+// ToTarget can't represent in the target markup.
+type Checker interface {
+	Check(source string) error
+}
+
+// UnrepresentableError is the error of a Checker for source content which
+// can't be represented in the target markup.
+type UnrepresentableError struct {
+	Content string
+}
+
+func (e UnrepresentableError) Error() string {
+	return fmt.Sprintf("%q can't be represented in the target markup", e.Content)
+}
+
+// JIRAConverter is the default Converter, translating between GitHub
+// Markdown and JIRA wiki markup.
+type JIRAConverter struct {
+	// Emoji enables the translation of GitHub emoji shortcodes.
+	Emoji bool
+	// Users maps lower case GitHub logins to JIRA usernames, to convert
+	// @mentions into JIRA user mentions.
+	Users map[string]string
+}
+
+// IdentityConverter is a Converter which leaves bodies as they are, e.g. for
+// a configuration which has no converter.
+type IdentityConverter struct{}
+
+// ToTarget returns the source unchanged.
+func (IdentityConverter) ToTarget(source string) string {
+	return source
+}
+
+// ToSource returns the target unchanged.
+func (IdentityConverter) ToSource(target string) string {
+	return target
+}
+
+// ToTarget converts GitHub Markdown to JIRA wiki markup.
+func (c JIRAConverter) ToTarget(source string) string {
+	out := ToJira(source)
+	if c.Emoji {
+		out = ReplaceEmoji(out)
+	}
+	return ReplaceMentions(out, c.Users)
+}
+
+// Check reports GitHub content which JIRA markup can't show: embedded videos
+// and frames, which would be copied as raw HTML, and links to uploaded
+// videos, which GitHub plays inline.
+func (JIRAConverter) Check(source string) error {
+	if content := unrepresentableRegex.FindString(source); content != "" {
+		return UnrepresentableError{content}
+	}
+	return nil
+}
+
+// unrepresentableRegex matches the content reported by JIRAConverter.Check.
+// Uploaded videos are recognised by their extension; attachment URLs without
+// one, which GitHub also uses for images, aren't matched.
+var unrepresentableRegex = regexp.MustCompile(`(?i)<(?:video|iframe|object|embed)\b|https://(?:github\.com/user-attachments|user-images\.githubusercontent\.com)/\S+?\.(?:mp4|mov|webm)\b`)
+
+// ToSource converts JIRA wiki markup to GitHub Markdown.
+func (JIRAConverter) ToSource(target string) string {
+	return ToMD(target)
+}
+
 // This is a go port of github.com/FokkeZB/J2M
 
 func ToJira(markdown string) (out string) {
@@ -22,6 +102,9 @@ func ToJira(markdown string) (out string) {
 	// fix empty syntax blocks
 	out = strings.Replace(out, "{code:}", "{code}", -1)
 
+	// blockquotes, horizontal rules and tables
+	out = convertBlocks(out)
+
 	// bold
 	var bold = regexp.MustCompile(`(?s:\*{2}(.*?)\*{2})`)
 	out = bold.ReplaceAllString(out, "*$1*")
@@ -29,6 +112,370 @@ func ToJira(markdown string) (out string) {
 	return out
 }
 
-func ToMD(jira string) string {
-	return jira
+// ToMD converts JIRA wiki markup to GitHub Markdown. It is the reverse of
+// ToJira, and additionally converts headings and links.
+func ToMD(jira string) (out string) {
+
+	out = jira
+
+	// code blocks
+	var code = regexp.MustCompile(`(?s:\{code(?::([a-z-]+))?\}(.*?)\{code\})`)
+	out = code.ReplaceAllString(out, "```$1$2```")
+
+	var noformat = regexp.MustCompile(`(?s:\{noformat\}(.*?)\{noformat\})`)
+	out = noformat.ReplaceAllString(out, "```$1```")
+
+	// quotes, horizontal rules, tables, headings, bold and links
+	out = convertJIRALines(out)
+
+	return out
+}
+
+// jiraHeadingRegex matches a JIRA heading line, capturing its level and text.
+var jiraHeadingRegex = regexp.MustCompile(`^h([1-6])\. (.*)$`)
+
+// jiraBoldRegex matches JIRA bold text. The `*` must be followed directly by
+// text, so that list items are not matched.
+var jiraBoldRegex = regexp.MustCompile(`\*(\S[^*\n]*?)\*`)
+
+// jiraLinkRegex matches a JIRA link with a title, capturing the title and URL.
+var jiraLinkRegex = regexp.MustCompile(`\[([^|\]\n]+)\|([^\]\n]+)\]`)
+
+// convertJIRALines converts the line-based JIRA markup to Markdown. Lines in
+// code blocks, which ToMD has already fenced, are not touched.
+func convertJIRALines(jira string) string {
+	lines := strings.Split(jira, "\n")
+	out := make([]string, 0, len(lines))
+
+	inCode, inQuote := false, false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		eol := lines[i][len(line):]
+
+		if inCode || strings.Contains(line, "```") {
+			if strings.Count(line, "```")%2 == 1 {
+				inCode = !inCode
+			}
+			out = append(out, lines[i])
+			continue
+		}
+
+		if strings.TrimSpace(line) == "{quote}" {
+			inQuote = !inQuote
+			continue
+		}
+
+		if strings.HasPrefix(line, "||") {
+			header := splitJIRATableRow(line[1 : len(line)-1])
+			out = append(out, "| "+strings.Join(header, " | ")+" |"+eol)
+			out = append(out, strings.Repeat("| --- ", len(header))+"|"+eol)
+			continue
+		}
+
+		if strings.HasPrefix(line, "|") && strings.HasSuffix(line, "|") && len(line) > 1 {
+			out = append(out, "| "+strings.Join(splitJIRATableRow(line), " | ")+" |"+eol)
+			continue
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "----":
+			// `---` below text would make it a heading, so space it out.
+			line = "- - -"
+		case strings.HasPrefix(line, "bq. "):
+			line = "> " + line[len("bq. "):]
+		case jiraHeadingRegex.MatchString(line):
+			matches := jiraHeadingRegex.FindStringSubmatch(line)
+			level := int(matches[1][0] - '0')
+			line = strings.Repeat("#", level) + " " + matches[2]
+		}
+
+		line = jiraBoldRegex.ReplaceAllString(line, "**$1**")
+		line = jiraLinkRegex.ReplaceAllString(line, "[$1]($2)")
+
+		if inQuote {
+			line = "> " + line
+		}
+
+		out = append(out, line+eol)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// splitJIRATableRow splits a JIRA table row, or a header row with its outer
+// `|` removed, into its cells. Header cells are separated by `||`.
+func splitJIRATableRow(row string) []string {
+	row = strings.Trim(row, "|")
+	cells := strings.Split(strings.Replace(row, "||", "|", -1), "|")
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+	}
+	return cells
+}
+
+// blockquoteRegex matches a line of a Markdown blockquote, capturing the quoted
+// text. Only a `>` at the start of the line begins a quote, so inline uses such
+// as `a > b` are left alone.
+var blockquoteRegex = regexp.MustCompile(`^ {0,3}> ?(.*)$`)
+
+// horizontalRuleRegex matches a Markdown horizontal rule: three or more `-`,
+// `*` or `_` characters on a line of their own, optionally separated by spaces.
+var horizontalRuleRegex = regexp.MustCompile(`^ {0,3}(?:(?:- *){3,}|(?:\* *){3,}|(?:_ *){3,})$`)
+
+// tableSeparatorRegex matches the separator row between the header and the body
+// of a Markdown table, including the optional `:` alignment markers.
+var tableSeparatorRegex = regexp.MustCompile(`^ *\|? *:?-+:? *(?:\| *:?-+:? *)*\|? *$`)
+
+// convertBlocks converts the line-based Markdown blocks, blockquotes,
+// horizontal rules and tables, into their JIRA equivalents. A single quoted
+// line becomes `bq.`, while a multi-line quote is wrapped in `{quote}`. Lines
+// inside `{code}` blocks are not touched.
+func convertBlocks(markdown string) string {
+	lines := strings.Split(markdown, "\n")
+	out := make([]string, 0, len(lines))
+
+	inCode := false
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSuffix(lines[i], "\r")
+		eol := lines[i][len(line):]
+
+		if inCode || strings.Contains(line, "{code") {
+			if strings.Count(line, "{code")%2 == 1 {
+				inCode = !inCode
+			}
+			out = append(out, lines[i])
+			continue
+		}
+
+		if isTableStart(lines, i) {
+			header := splitTableRow(line)
+			out = append(out, "||"+strings.Join(header, "||")+"||"+eol)
+
+			// Skip the header and the separator row, which only carries alignment
+			// and has no JIRA equivalent.
+			for i += 2; i < len(lines); i++ {
+				row := strings.TrimSuffix(lines[i], "\r")
+				if !strings.Contains(row, "|") || strings.TrimSpace(row) == "" {
+					break
+				}
+				out = append(out, "|"+strings.Join(splitTableRow(row), "|")+"|"+lines[i][len(row):])
+			}
+			i--
+			continue
+		}
+
+		if horizontalRuleRegex.MatchString(line) {
+			// A `---` directly below text is a heading underline, not a rule.
+			if !strings.HasPrefix(strings.TrimSpace(line), "-") || i == 0 || strings.TrimSpace(lines[i-1]) == "" {
+				out = append(out, "----"+eol)
+				continue
+			}
+		}
+
+		if !blockquoteRegex.MatchString(line) {
+			out = append(out, lines[i])
+			continue
+		}
+
+		var quoted []string
+		for ; i < len(lines); i++ {
+			matches := blockquoteRegex.FindStringSubmatch(strings.TrimSuffix(lines[i], "\r"))
+			if matches == nil {
+				break
+			}
+			quoted = append(quoted, matches[1])
+		}
+		i--
+
+		out = append(out, convertQuote(quoted, eol)...)
+	}
+
+	return strings.Join(out, "\n")
+}
+
+// isTableStart reports whether the line at index i is the header row of a
+// Markdown table, which must be followed by a separator row.
+func isTableStart(lines []string, i int) bool {
+	if i+1 >= len(lines) || !strings.Contains(lines[i], "|") {
+		return false
+	}
+	separator := strings.TrimSuffix(lines[i+1], "\r")
+	return strings.Contains(separator, "|") && tableSeparatorRegex.MatchString(separator)
+}
+
+// splitTableRow splits a Markdown table row into its cells. Escaped pipes
+// (`\|`) do not split cells and are kept escaped, which JIRA also understands.
+// Empty cells are given a single space, as JIRA would otherwise merge them.
+func splitTableRow(row string) []string {
+	row = strings.TrimSpace(row)
+	row = strings.TrimPrefix(row, "|")
+	if strings.HasSuffix(row, "|") && !strings.HasSuffix(row, "\\|") {
+		row = row[:len(row)-1]
+	}
+
+	var cells []string
+	start := 0
+	for i := 0; i < len(row); i++ {
+		switch row[i] {
+		case '\\':
+			i++
+		case '|':
+			cells = append(cells, row[start:i])
+			start = i + 1
+		}
+	}
+	cells = append(cells, row[start:])
+
+	for i, cell := range cells {
+		cells[i] = strings.TrimSpace(cell)
+		if cells[i] == "" {
+			cells[i] = " "
+		}
+	}
+	return cells
+}
+
+// convertQuote renders the text of a blockquote as JIRA markup. JIRA cannot
+// nest `{quote}` blocks, so nested quotes are rendered as `bq.` lines inside
+// the outer quote.
+func convertQuote(quoted []string, eol string) []string {
+	if len(quoted) == 1 && !blockquoteRegex.MatchString(quoted[0]) {
+		return []string{"bq. " + quoted[0] + eol}
+	}
+
+	out := []string{"{quote}" + eol}
+	for _, line := range quoted {
+		if blockquoteRegex.MatchString(line) {
+			for blockquoteRegex.MatchString(line) {
+				line = blockquoteRegex.FindStringSubmatch(line)[1]
+			}
+			line = "bq. " + line
+		}
+		out = append(out, line+eol)
+	}
+	return append(out, "{quote}"+eol)
+}
+
+// emojiShortcodeRegex matches a GitHub emoji shortcode such as `:smile:`.
+var emojiShortcodeRegex = regexp.MustCompile(`:([a-z0-9_+\-]+):`)
+
+// emojiShortcodes maps common GitHub emoji shortcodes to either a JIRA
+// emoticon, where JIRA has an equivalent, or the Unicode character.
+var emojiShortcodes = map[string]string{
+	"smile":                 ":)",
+	"slightly_smiling_face": ":)",
+	"disappointed":          ":(",
+	"stuck_out_tongue":      ":P",
+	"grinning":              ":D",
+	"wink":                  ";)",
+	"+1":                    "(y)",
+	"thumbsup":              "(y)",
+	"-1":                    "(n)",
+	"thumbsdown":            "(n)",
+	"heavy_check_mark":      "(/)",
+	"white_check_mark":      "(/)",
+	"x":                     "(x)",
+	"warning":               "(!)",
+	"question":              "(?)",
+	"bulb":                  "(on)",
+	"star":                  "(*)",
+	"heart":                 "\u2764\ufe0f",
+	"tada":                  "\U0001f389",
+	"rocket":                "\U0001f680",
+	"fire":                  "\U0001f525",
+	"eyes":                  "\U0001f440",
+	"bug":                   "\U0001f41b",
+	"laughing":              "\U0001f606",
+	"joy":                   "\U0001f602",
+	"confused":              "\U0001f615",
+	"cry":                   "\U0001f622",
+	"thinking":              "\U0001f914",
+	"pray":                  "\U0001f64f",
+	"clap":                  "\U0001f44f",
+	"wave":                  "\U0001f44b",
+	"100":                   "\U0001f4af",
+	"sparkles":              "\u2728",
+	"zap":                   "\u26a1",
+	"construction":          "\U0001f6a7",
+	"memo":                  "\U0001f4dd",
+	"lock":                  "\U0001f512",
+}
+
+// ReplaceEmoji translates known GitHub emoji shortcodes in the text into
+// their JIRA or Unicode equivalents. Unknown shortcodes, and those in code
+// blocks and code spans, are left untouched.
+func ReplaceEmoji(text string) string {
+	return replaceOutsideCode(text, func(text string) string {
+		return emojiShortcodeRegex.ReplaceAllStringFunc(text, func(code string) string {
+			if emoji, ok := emojiShortcodes[strings.Trim(code, ":")]; ok {
+				return emoji
+			}
+			return code
+		})
+	})
+}
+
+// mentionRegex matches a GitHub @mention, capturing the character before it,
+// which must not be part of a word so that email addresses aren't matched,
+// and the login.
+var mentionRegex = regexp.MustCompile(`(^|[^\w.@/\x60])@([A-Za-z0-9](?:[A-Za-z0-9-]*[A-Za-z0-9])?)`)
+
+// ReplaceMentions turns the GitHub @mentions in the text into JIRA user
+// mentions, for the logins mapped to a JIRA username in users, or into links
+// to the GitHub profile otherwise. The keys of users must be lower case, as
+// logins are case-insensitive. Mentions in code blocks and code spans, and
+// team mentions such as @org/team, are left untouched.
+func ReplaceMentions(text string, users map[string]string) string {
+	return replaceOutsideCode(text, func(text string) string {
+		return replaceLineMentions(text, users)
+	})
+}
+
+// replaceOutsideCode applies replace to the parts of each line of the text
+// which aren't in code: Markdown or JIRA code blocks, and code spans.
+func replaceOutsideCode(text string, replace func(string) string) string {
+	lines := strings.Split(text, "\n")
+
+	inCode := false
+	for i, line := range lines {
+		if inCode || strings.Contains(line, "{code") || strings.Contains(line, "```") || strings.Contains(line, "{noformat}") {
+			if (strings.Count(line, "{code")+strings.Count(line, "```")+strings.Count(line, "{noformat}"))%2 == 1 {
+				inCode = !inCode
+			}
+			continue
+		}
+
+		// Odd parts of the line are in code spans.
+		parts := strings.Split(line, "`")
+		for j := 0; j < len(parts); j += 2 {
+			parts[j] = replace(parts[j])
+		}
+		lines[i] = strings.Join(parts, "`")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// replaceLineMentions replaces the mentions in text without code.
+func replaceLineMentions(text string, users map[string]string) string {
+	var out bytes.Buffer
+	last := 0
+	for _, m := range mentionRegex.FindAllStringSubmatchIndex(text, -1) {
+		// m[2:4] is the character before the mention, m[4:6] the login.
+		if m[1] < len(text) && text[m[1]] == '/' {
+			continue
+		}
+		login := text[m[4]:m[5]]
+
+		out.WriteString(text[last:m[3]])
+		if user, ok := users[strings.ToLower(login)]; ok {
+			fmt.Fprintf(&out, "[~%s]", user)
+		} else {
+			fmt.Fprintf(&out, "[@%s|https://github.com/%s]", login, login)
+		}
+		last = m[1]
+	}
+	out.WriteString(text[last:])
+
+	return out.String()
 }
@@ -0,0 +1,193 @@
+package convert
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestJIRAConverterCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   string
+	}{
+		{"text", "Fixed in #12.", ""},
+		{"image", "![screenshot](https://github.com/user-attachments/assets/0b1c-2d3e)", ""},
+		{"image upload", "https://user-images.githubusercontent.com/1/2-3.png", ""},
+		{"video tag", `<video src="demo.mp4" controls>`, "<video"},
+		{"frame", "<IFRAME src=x>", "<IFRAME"},
+		{"attached video", "See https://github.com/user-attachments/assets/0b1c/demo.mp4 here",
+			"https://github.com/user-attachments/assets/0b1c/demo.mp4"},
+		{"uploaded video", "https://user-images.githubusercontent.com/1/2-3.MOV",
+			"https://user-images.githubusercontent.com/1/2-3.MOV"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := JIRAConverter{}.Check(tt.source)
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Check() = %v, want nil", err)
+				}
+				return
+			}
+			if e, ok := err.(UnrepresentableError); !ok || e.Content != tt.want {
+				t.Errorf("Check() = %v, want content %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceEmoji(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"shortcode", "Done :tada:", "Done \U0001f389"},
+		{"unknown", "Done :not-an-emoji:", "Done :not-an-emoji:"},
+		{"code span", "Use `:tada:` for :tada:", "Use `:tada:` for \U0001f389"},
+		{"fenced block", "```\n:tada:\n```\n:tada:", "```\n:tada:\n```\n\U0001f389"},
+		{"JIRA block", "{code:go}\n:tada:\n{code}\n:tada:", "{code:go}\n:tada:\n{code}\n\U0001f389"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReplaceEmoji(tt.text); got != tt.want {
+				t.Errorf("ReplaceEmoji() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplaceMentions(t *testing.T) {
+	users := map[string]string{"octocat": "ocat"}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"mapped", "Thanks @octocat!", "Thanks [~ocat]!"},
+		{"mapped login case", "@OctoCat please review", "[~ocat] please review"},
+		{"unmapped", "cc @hubot", "cc [@hubot|https://github.com/hubot]"},
+		{"several", "@octocat and @hubot", "[~ocat] and [@hubot|https://github.com/hubot]"},
+		{"email", "mail me@example.com", "mail me@example.com"},
+		{"team", "cc @org/team", "cc @org/team"},
+		{"code span", "run `@octocat` then @octocat", "run `@octocat` then [~ocat]"},
+		{"code block", "{code}\n@octocat\n{code}\n@octocat", "{code}\n@octocat\n{code}\n[~ocat]"},
+		{"fenced block", "```\n@hubot\n```", "```\n@hubot\n```"},
+		{"no mention", "an @ sign", "an @ sign"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReplaceMentions(tt.text, users); got != tt.want {
+				t.Errorf("ReplaceMentions() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertBlocks(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"single-line quote", "> quoted", "bq. quoted"},
+		{"indented quote", "   > quoted", "bq. quoted"},
+		{"multi-line quote", "> one\n> two", "{quote}\none\ntwo\n{quote}"},
+		{"quote between text", "before\n> quoted\nafter", "before\nbq. quoted\nafter"},
+		{"nested quote", "> outer\n> > inner", "{quote}\nouter\nbq. inner\n{quote}"},
+		{"nested quote only", "> > inner", "{quote}\nbq. inner\n{quote}"},
+		{"inline greater than", "a > b", "a > b"},
+		{"CRLF quote", "> one\r\n> two\r\n", "{quote}\r\none\r\ntwo\r\n{quote}\r\n"},
+		{"rule", "text\n\n---\n\ntext", "text\n\n----\n\ntext"},
+		{"rule at start", "---\ntext", "----\ntext"},
+		{"spaced rule", "- - -", "----"},
+		{"asterisk rule", "***", "----"},
+		{"underscore rule", "___", "----"},
+		{"setext heading", "Heading\n---", "Heading\n---"},
+		{"asterisk rule below text", "text\n***", "text\n----"},
+		{"quote in code block", "{code}\n> quoted\n---\n{code}", "{code}\n> quoted\n---\n{code}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertBlocks(tt.markdown); got != tt.want {
+				t.Errorf("convertBlocks(%q) = %q, want %q", tt.markdown, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertTables(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		want     string
+	}{
+		{"header row", "| a | b |\n| --- | --- |", "||a||b||"},
+		{"body rows", "| a | b |\n|---|---|\n| 1 | 2 |\n| 3 | 4 |", "||a||b||\n|1|2|\n|3|4|"},
+		{"without outer pipes", "a | b\n--- | ---\n1 | 2", "||a||b||\n|1|2|"},
+		{"alignment", "| a | b | c |\n| :--- | :---: | ---: |\n| 1 | 2 | 3 |", "||a||b||c||\n|1|2|3|"},
+		{"empty cells", "| a | b |\n| --- | --- |\n| | 2 |\n| 1 | |", "||a||b||\n| |2|\n|1| |"},
+		{"escaped pipe", "| a | b |\n| --- | --- |\n| x \\| y | 2 |", "||a||b||\n|x \\| y|2|"},
+		{"escaped pipe at end", "| a | b |\n| --- | --- |\n| 1 | y \\|", "||a||b||\n|1|y \\||"},
+		{"ends at blank line", "| a |\n| --- |\n| 1 |\n\ntext | not a row", "||a||\n|1|\n\ntext | not a row"},
+		{"ends at text", "| a |\n| --- |\n| 1 |\ntext", "||a||\n|1|\ntext"},
+		{"pipe without separator", "a | b\nc | d", "a | b\nc | d"},
+		{"CRLF", "| a |\r\n| --- |\r\n| 1 |\r\n", "||a||\r\n|1|\r\n"},
+		{"in code block", "{code}\n| a |\n| --- |\n{code}", "{code}\n| a |\n| --- |\n{code}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := convertBlocks(tt.markdown); got != tt.want {
+				t.Errorf("convertBlocks(%q) = %q, want %q", tt.markdown, got, tt.want)
+			}
+		})
+	}
+
+	// Fenced code blocks are converted to {code} before the tables.
+	const fenced = "```\n| a |\n| --- |\n```"
+	if got, want := ToJira(fenced), "{code}\n| a |\n| --- |\n{code}"; got != want {
+		t.Errorf("ToJira(%q) = %q, want %q", fenced, got, want)
+	}
+}
+
+func TestSplitTableRow(t *testing.T) {
+	tests := []struct {
+		row  string
+		want []string
+	}{
+		{"| a | b |", []string{"a", "b"}},
+		{"a | b", []string{"a", "b"}},
+		{"|a|b|", []string{"a", "b"}},
+		{"| | b |", []string{" ", "b"}},
+		{"| a | |", []string{"a", " "}},
+		{`| a \| b | c |`, []string{`a \| b`, "c"}},
+		{`| a | b \|`, []string{"a", `b \|`}},
+		{"  | a |  ", []string{"a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.row, func(t *testing.T) {
+			if got := splitTableRow(tt.row); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitTableRow(%q) = %q, want %q", tt.row, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIdentityConverter(t *testing.T) {
+	const body = "**bold** :tada: @user"
+	var c Converter = IdentityConverter{}
+	if got := c.ToTarget(body); got != body {
+		t.Errorf("ToTarget() = %q, want %q", got, body)
+	}
+	if got := c.ToSource(body); got != body {
+		t.Errorf("ToSource() = %q, want %q", got, body)
+	}
+}
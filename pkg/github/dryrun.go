@@ -0,0 +1,157 @@
+package github
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+)
+
+// Summarizer is implemented by clients which record the actions they were
+// asked to take, so that a summary can be printed at the end of a run.
+type Summarizer interface {
+	LogSummary()
+}
+
+// dryrunGHClient is an implementation of GitHubClient which performs all
+// GET requests the same as the realGHClient, but does not perform any
+// unsafe requests which may modify server data, instead printing out the
+// actions it is asked to perform without making the request.
+type dryrunGHClient struct {
+	*realGHClient
+
+	// created and updated count the issues which would have been created
+	// and updated since the last summary. Repositories may be synced in
+	// parallel, so they are guarded by mu.
+	mu      sync.Mutex
+	created int
+	updated int
+}
+
+// CreateIssue prints out the fields that would be set on a new GitHub issue
+// were it to be created according to the provided request. It returns an
+// issue built from the request, without a number.
+func (g *dryrunGHClient) CreateIssue(owner, repo string, issue github.IssueRequest) (github.Issue, error) {
+	log := g.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Create new GitHub issue in %s/%s:", owner, repo)
+	log.Infof("  Title: %s", issue.GetTitle())
+	log.Infof("  Body: %s", truncate(g.config, issue.GetBody(), g.config.GetDryRunDescriptionLength()))
+	if issue.Labels != nil {
+		log.Infof("  Labels: %v", issue.GetLabels())
+	}
+	log.Info("")
+
+	g.mu.Lock()
+	g.created++
+	g.mu.Unlock()
+
+	return github.Issue{
+		Title: issue.Title,
+		Body:  issue.Body,
+		State: issue.State,
+	}, nil
+}
+
+// UpdateIssue prints out the fields that would be set on a GitHub issue were
+// it to be updated according to the provided request. It returns an issue
+// built from the request.
+func (g *dryrunGHClient) UpdateIssue(owner, repo string, number int, issue github.IssueRequest) (github.Issue, error) {
+	log := g.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Update GitHub issue %s/%s#%d:", owner, repo, number)
+	if issue.Title != nil {
+		log.Infof("  Title: %s", issue.GetTitle())
+	}
+	if issue.Body != nil {
+		log.Infof("  Body: %s", truncate(g.config, issue.GetBody(), g.config.GetDryRunDescriptionLength()))
+	}
+	if issue.State != nil {
+		log.Infof("  State: %s", issue.GetState())
+	}
+	if issue.Labels != nil {
+		log.Infof("  Labels: %v", issue.GetLabels())
+	}
+	log.Info("")
+
+	g.mu.Lock()
+	g.updated++
+	g.mu.Unlock()
+
+	return github.Issue{
+		Number: &number,
+		Title:  issue.Title,
+		Body:   issue.Body,
+		State:  issue.State,
+	}, nil
+}
+
+// AddLabels prints out the labels that would be added to a GitHub issue.
+func (g *dryrunGHClient) AddLabels(owner, repo string, number int, labels []string) error {
+	log := g.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Add labels to GitHub issue %s/%s#%d:", owner, repo, number)
+	log.Infof("  Labels: %v", labels)
+	log.Info("")
+
+	g.mu.Lock()
+	g.updated++
+	g.mu.Unlock()
+
+	return nil
+}
+
+// CreateComment prints out the body of a comment that would be added to a
+// GitHub issue. It returns a comment with that body, without an ID.
+func (g *dryrunGHClient) CreateComment(issue github.Issue, body string) (github.IssueComment, error) {
+	log := g.config.GetLogger()
+
+	log.Info("")
+	log.Infof("Create comment on GitHub issue #%d:", issue.GetNumber())
+	log.Infof("  Body: %s", truncate(g.config, body, g.config.GetDryRunCommentLength()))
+	log.Info("")
+
+	return github.IssueComment{
+		Body: &body,
+	}, nil
+}
+
+// LogSummary prints the number of GitHub issues which would have been
+// created and updated since the last summary, then resets the counts.
+func (g *dryrunGHClient) LogSummary() {
+	log := g.config.GetLogger()
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	log.Infof("Dry run: would have created %d and updated %d GitHub issues.", g.created, g.updated)
+
+	g.created = 0
+	g.updated = 0
+}
+
+// newlineReplaceRegex is a regex to match both "\r\n" and just "\n" newline styles,
+// in order to allow us to escape both sequences cleanly in the output of a dry run.
+var newlineReplaceRegex = regexp.MustCompile("\r?\n")
+
+// truncate is a utility function to replace all the newlines in
+// the string with the characters "\n", then truncate it to no
+// more than `length` characters, followed by the configured notice.
+//
+// This function is identical to that in the jira package.
+func truncate(cfg config.Config, s string, length int) string {
+	if s == "" {
+		return "empty"
+	}
+
+	s = newlineReplaceRegex.ReplaceAllString(s, "\\n")
+	r := []rune(s)
+	if len(r) <= length {
+		return s
+	}
+	return string(r[:length]) + cfg.GetTruncateNotice()
+}
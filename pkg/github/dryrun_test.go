@@ -0,0 +1,81 @@
+package github
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+)
+
+func TestDryRunClient(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg, err := config.NewTestConfig(map[string]interface{}{"dry-run": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	g := &dryrunGHClient{realGHClient: &realGHClient{config: cfg, client: client}}
+
+	issue := github.Issue{Number: github.Int(1), URL: github.String(server.URL + "/repos/o/r/issues/1")}
+	request := github.IssueRequest{Title: github.String("Title"), Body: github.String("Body"), State: github.String("open")}
+
+	tests := []struct {
+		name             string
+		do               func() error
+		created, updated int
+	}{
+		{"create issue", func() error {
+			created, err := g.CreateIssue("o", "r", request)
+			if created.GetTitle() != "Title" || created.Number != nil {
+				t.Errorf("CreateIssue() = %v, want an unnumbered issue built from the request", created)
+			}
+			return err
+		}, 1, 0},
+		{"update issue", func() error {
+			updated, err := g.UpdateIssue("o", "r", 1, request)
+			if updated.GetNumber() != 1 || updated.GetState() != "open" {
+				t.Errorf("UpdateIssue() = %v, want issue 1 built from the request", updated)
+			}
+			return err
+		}, 0, 1},
+		{"add labels", func() error {
+			return g.AddLabels("o", "r", 1, []string{"bug"})
+		}, 0, 1},
+		{"create comment", func() error {
+			comment, err := g.CreateComment(issue, "Comment")
+			if comment.GetBody() != "Comment" {
+				t.Errorf("CreateComment() body = %q, want %q", comment.GetBody(), "Comment")
+			}
+			return err
+		}, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.do(); err != nil {
+				t.Fatal(err)
+			}
+			if g.created != tt.created || g.updated != tt.updated {
+				t.Errorf("summary counts %d created and %d updated, want %d and %d", g.created, g.updated, tt.created, tt.updated)
+			}
+			g.LogSummary()
+			if g.created != 0 || g.updated != 0 {
+				t.Errorf("LogSummary() left %d created and %d updated, want the counts reset", g.created, g.updated)
+			}
+		})
+	}
+
+	if len(requests) > 0 {
+		t.Errorf("dry run made requests to GitHub: %v", requests)
+	}
+}
@@ -0,0 +1,160 @@
+package github
+
+import (
+	"bytes"
+	"container/list"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// etagCacheSize is the most response body bytes an etagTransport keeps.
+const etagCacheSize = 32 << 20
+
+// etagEntry is a cached response to a GitHub API request.
+type etagEntry struct {
+	key    string
+	etag   string
+	header http.Header
+	body   []byte
+}
+
+// etagTransport makes conditional requests for the GitHub issue and comment
+// listings it has already seen. GitHub answers them with 304 Not Modified if
+// nothing changed, which doesn't count against the rate limit; the cached
+// response is then returned in its place. The cache is bounded by the size
+// of the bodies, evicting the least recently used responses first, as a
+// daemon requests ever more listings.
+type etagTransport struct {
+	base    http.RoundTripper
+	maxSize int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	size    int
+}
+
+// newETagTransport returns an etagTransport making its requests through base.
+func newETagTransport(base http.RoundTripper) *etagTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &etagTransport{
+		base:    base,
+		maxSize: etagCacheSize,
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+	}
+}
+
+// get returns the cached response to the request with the given key, marking
+// it as recently used.
+func (t *etagTransport) get(key string) (etagEntry, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	e, ok := t.entries[key]
+	if !ok {
+		return etagEntry{}, false
+	}
+	t.lru.MoveToFront(e)
+	return e.Value.(etagEntry), true
+}
+
+// put caches a response, evicting the least recently used ones until the
+// cache fits its size. A response larger than the whole cache isn't cached.
+func (t *etagTransport) put(entry etagEntry) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if e, ok := t.entries[entry.key]; ok {
+		t.remove(e)
+	}
+	if len(entry.body) > t.maxSize {
+		return
+	}
+
+	t.entries[entry.key] = t.lru.PushFront(entry)
+	t.size += len(entry.body)
+	for t.size > t.maxSize {
+		t.remove(t.lru.Back())
+	}
+}
+
+// remove drops a cached response; the lock must be held.
+func (t *etagTransport) remove(e *list.Element) {
+	entry := t.lru.Remove(e).(etagEntry)
+	delete(t.entries, entry.key)
+	t.size -= len(entry.body)
+}
+
+// cacheable returns whether the responses to a request are cached: requests
+// listing the issues of a repository, or the comments or events of an issue.
+func cacheable(req *http.Request) bool {
+	return req.Method == http.MethodGet && strings.HasPrefix(req.URL.Path, "/repos/") && strings.Contains(req.URL.Path, "/issues")
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *etagTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !cacheable(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	entry, cached := t.get(key)
+
+	if cached {
+		// A RoundTripper must not modify the request it was given.
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+
+	res, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode == http.StatusNotModified && cached {
+		res.Body.Close()
+
+		// The headers of the 304 response, e.g. the rate limits, are newer.
+		header := entry.header.Clone()
+		for k, v := range res.Header {
+			header[k] = v
+		}
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         res.Proto,
+			ProtoMajor:    res.ProtoMajor,
+			ProtoMinor:    res.ProtoMinor,
+			Header:        header,
+			Body:          ioutil.NopCloser(bytes.NewReader(entry.body)),
+			ContentLength: int64(len(entry.body)),
+			Request:       req,
+		}, nil
+	}
+
+	etag := res.Header.Get("ETag")
+	if res.StatusCode != http.StatusOK || etag == "" {
+		return res, nil
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	t.put(etagEntry{
+		key:    key,
+		etag:   etag,
+		header: res.Header.Clone(),
+		body:   body,
+	})
+
+	return res, nil
+}
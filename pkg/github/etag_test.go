@@ -0,0 +1,133 @@
+package github
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+// etagServer serves fixed bodies by path, tagged with their contents, and
+// answers conditional requests for unchanged bodies with 304 Not Modified.
+type etagServer struct {
+	bodies map[string]string
+	// conditional records whether the last request was conditional.
+	conditional bool
+}
+
+func (s *etagServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body := s.bodies[r.URL.Path]
+	etag := strconv.Quote(body)
+
+	match := r.Header.Get("If-None-Match")
+	s.conditional = match != ""
+	w.Header().Set("ETag", etag)
+	if match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Write([]byte(body))
+}
+
+func TestETagTransport(t *testing.T) {
+	handler := &etagServer{bodies: map[string]string{
+		"/repos/o/r/issues":   "[1]",
+		"/repos/o/r/issues/1": "[1,2]",
+		"/user":               "{}",
+	}}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	client := &http.Client{Transport: newETagTransport(nil)}
+
+	tests := []struct {
+		name        string
+		path        string
+		body        string
+		conditional bool
+	}{
+		{"first request", "/repos/o/r/issues", "[1]", false},
+		{"not modified", "/repos/o/r/issues", "[1]", true},
+		{"other listing", "/repos/o/r/issues/1", "[1,2]", false},
+		{"modified", "/repos/o/r/issues", "[1,3]", true},
+		{"modified cached", "/repos/o/r/issues", "[1,3]", true},
+		{"not cached", "/user", "{}", false},
+		{"not cached again", "/user", "{}", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler.bodies[tt.path] = tt.body
+
+			res, err := client.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if res.StatusCode != http.StatusOK {
+				t.Errorf("status = %d, want %d", res.StatusCode, http.StatusOK)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body = %s, want %s", body, tt.body)
+			}
+			if handler.conditional != tt.conditional {
+				t.Errorf("conditional request = %v, want %v", handler.conditional, tt.conditional)
+			}
+		})
+	}
+}
+
+func TestETagTransportEviction(t *testing.T) {
+	handler := &etagServer{bodies: map[string]string{
+		"/repos/o/a/issues": "aaaa",
+		"/repos/o/b/issues": "bbbb",
+		"/repos/o/c/issues": "cccc",
+		"/repos/o/d/issues": "dddddddddd",
+	}}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	// The cache holds two of the bodies.
+	transport := newETagTransport(nil)
+	transport.maxSize = 8
+	client := &http.Client{Transport: transport}
+
+	tests := []struct {
+		path        string
+		conditional bool
+	}{
+		{"/repos/o/a/issues", false},
+		{"/repos/o/b/issues", false},
+		{"/repos/o/a/issues", true},
+		// The least recently used, b, is evicted.
+		{"/repos/o/c/issues", false},
+		{"/repos/o/b/issues", false},
+		{"/repos/o/c/issues", true},
+		// a was evicted by b.
+		{"/repos/o/a/issues", false},
+		// A body larger than the cache isn't cached.
+		{"/repos/o/d/issues", false},
+		{"/repos/o/d/issues", false},
+	}
+
+	for i, tt := range tests {
+		res, err := client.Get(server.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+
+		if handler.conditional != tt.conditional {
+			t.Errorf("request %d of %s: conditional = %v, want %v", i+1, tt.path, handler.conditional, tt.conditional)
+		}
+		if transport.size > transport.maxSize {
+			t.Errorf("request %d of %s: cache holds %d bytes, more than %d", i+1, tt.path, transport.size, transport.maxSize)
+		}
+	}
+}
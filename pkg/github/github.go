@@ -2,13 +2,19 @@ package github
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"sync"
 
 	"time"
 
 	"github.com/cenkalti/backoff"
 	"github.com/innovocloud/issue-sync/pkg/config"
+	"github.com/innovocloud/issue-sync/pkg/limit"
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 )
@@ -19,18 +25,54 @@ import (
 type GitHubClient interface {
 	ListIssues() ([]github.Issue, error)
 	ListComments(issue github.Issue) ([]*github.IssueComment, error)
+	ListTimeline(issue github.Issue) ([]*github.Timeline, error)
 	GetMembers(org string) ([]*github.User, error)
+	GetTeamMembers(org, team string) ([]*github.User, error)
 	GetUser(login string) (github.User, error)
 	GetRateLimits() (github.RateLimits, error)
 	SearchIssues(query string) ([]github.Issue, error)
+	CreateIssue(owner, repo string, issue github.IssueRequest) (github.Issue, error)
+	UpdateIssue(owner, repo string, number int, issue github.IssueRequest) (github.Issue, error)
+	AddLabels(owner, repo string, number int, labels []string) error
+	CreateComment(issue github.Issue, body string) (github.IssueComment, error)
+	ListRepos(owner string, user bool) ([]Repository, error)
+	GetStateReason(issue github.Issue) (string, error)
 }
 
+// Repository is a GitHub repository, with whether it is archived, which the
+// GitHub API client library doesn't decode yet.
+type Repository struct {
+	github.Repository
+	Archived bool `json:"archived"`
+}
+
+// ErrUserNotFound is returned by GetUser when the GitHub user doesn't exist,
+// e.g. because the account has been deleted.
+var ErrUserNotFound = errors.New("GitHub user not found")
+
 // realGHClient is a standard GitHub clients, that actually makes all of the
 // requests against the GitHub REST API. It is the canonical implementation
 // of GitHubClient.
 type realGHClient struct {
-	config config.Config
-	client *github.Client
+	config  config.Config
+	client  *github.Client
+	limiter limit.Limiter
+
+	// reasons caches the state reasons of issues, which the GitHub API client
+	// library doesn't decode yet, so they are requested separately.
+	reasons *reasonCache
+}
+
+// reasonCache holds the state reason of each issue, by issue ID, as of the
+// time the issue was last updated.
+type reasonCache struct {
+	mu      sync.Mutex
+	reasons map[int]cachedReason
+}
+
+type cachedReason struct {
+	updated time.Time
+	reason  string
 }
 
 // SearchIssues returns the list of GitHub issues since the last run of the tool based on the search query.
@@ -159,6 +201,128 @@ func (g realGHClient) ListComments(issue github.Issue) ([]*github.IssueComment,
 	return comments, nil
 }
 
+// ListTimeline returns the list of all timeline events on a GitHub issue in
+// ascending order of creation.
+func (g realGHClient) ListTimeline(issue github.Issue) ([]*github.Timeline, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+	splitURL := strings.Split(issue.GetURL(), "/")
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var events []*github.Timeline
+
+	for page := 1; page <= pages; page++ {
+		e, res, err := g.request(func() (interface{}, *github.Response, error) {
+			return g.client.Issues.ListIssueTimeline(ctx, splitURL[4], splitURL[5], issue.GetNumber(), &github.ListOptions{
+				Page:    page,
+				PerPage: 100,
+			})
+		})
+		if err != nil {
+			log.Errorf("Error retrieving GitHub timeline for issue #%d. Error: %v.", issue.GetNumber(), err)
+			return nil, err
+		}
+		eventPage, ok := e.([]*github.Timeline)
+		if !ok {
+			log.Errorf("Get GitHub timeline did not return events! Got: %v", e)
+			return nil, fmt.Errorf("Get GitHub timeline failed: expected []*github.Timeline; got %T", e)
+		}
+
+		pages = res.LastPage
+		events = append(events, eventPage...)
+	}
+
+	return events, nil
+}
+
+// ListRepos returns all of the repositories of a GitHub organisation, or of a
+// GitHub user if user is set.
+func (g realGHClient) ListRepos(owner string, user bool) ([]Repository, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	var repos []Repository
+
+	for page := 1; page <= pages; page++ {
+		r, res, err := g.request(func() (interface{}, *github.Response, error) {
+			u := fmt.Sprintf("orgs/%s/repos?page=%d&per_page=100", owner, page)
+			if user {
+				u = fmt.Sprintf("users/%s/repos?page=%d&per_page=100", owner, page)
+			}
+			req, err := g.client.NewRequest("GET", u, nil)
+			if err != nil {
+				return nil, nil, err
+			}
+			var repoPage []Repository
+			res, err := g.client.Do(ctx, req, &repoPage)
+			return repoPage, res, err
+		})
+		if err != nil {
+			log.Errorf("Error retrieving GitHub repositories of %s. Error: %v", owner, err)
+			return nil, err
+		}
+		repoPage, ok := r.([]Repository)
+		if !ok {
+			log.Errorf("List GitHub repositories did not return repositories! Got: %v", r)
+			return nil, fmt.Errorf("List GitHub repositories failed: expected []Repository; got %T", r)
+		}
+
+		pages = res.LastPage
+		repos = append(repos, repoPage...)
+	}
+
+	return repos, nil
+}
+
+// GetStateReason returns the reason a GitHub issue is in its state, e.g.
+// "completed" or "not_planned" for closed issues, or an empty string if it has
+// none. Reasons are cached until the issue is updated.
+func (g realGHClient) GetStateReason(issue github.Issue) (string, error) {
+	log := g.config.GetLogger()
+
+	g.reasons.mu.Lock()
+	cached, ok := g.reasons.reasons[issue.GetID()]
+	g.reasons.mu.Unlock()
+	if ok && cached.updated.Equal(issue.GetUpdatedAt()) {
+		return cached.reason, nil
+	}
+
+	r, _, err := g.request(func() (interface{}, *github.Response, error) {
+		req, err := g.client.NewRequest("GET", issue.GetURL(), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		var i struct {
+			StateReason *string `json:"state_reason"`
+		}
+		res, err := g.client.Do(context.Background(), req, &i)
+		return i.StateReason, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving state reason of GitHub issue #%d. Error: %v", issue.GetNumber(), err)
+		return "", err
+	}
+	reason, ok := r.(*string)
+	if !ok {
+		return "", fmt.Errorf("Get GitHub state reason failed: expected *string; got %T", r)
+	}
+
+	cached = cachedReason{updated: issue.GetUpdatedAt()}
+	if reason != nil {
+		cached.reason = *reason
+	}
+	g.reasons.mu.Lock()
+	g.reasons.reasons[issue.GetID()] = cached
+	g.reasons.mu.Unlock()
+
+	return cached.reason, nil
+}
+
 // GetMembers returns a set of GitHub users from an Organisation.
 func (g realGHClient) GetMembers(org string) ([]*github.User, error) {
 	log := g.config.GetLogger()
@@ -180,16 +344,93 @@ func (g realGHClient) GetMembers(org string) ([]*github.User, error) {
 	return users, nil
 }
 
-// GetUser returns a GitHub user from its login.
+// GetTeamMembers returns the members of a team, identified by its slug, of a
+// GitHub Organisation.
+func (g realGHClient) GetTeamMembers(org, team string) ([]*github.User, error) {
+	log := g.config.GetLogger()
+
+	ctx := context.Background()
+
+	var id int
+	// Set it so that it will run the loop once, and it'll be updated in the loop.
+	pages := 1
+	for page := 1; page <= pages && id == 0; page++ {
+		t, res, err := g.request(func() (interface{}, *github.Response, error) {
+			return g.client.Organizations.ListTeams(ctx, org, &github.ListOptions{
+				Page:    page,
+				PerPage: 100,
+			})
+		})
+		if err != nil {
+			log.Errorf("Error retrieving GitHub teams of %s. Error: %v", org, err)
+			return nil, err
+		}
+		teams, ok := t.([]*github.Team)
+		if !ok {
+			log.Errorf("List GitHub teams did not return teams! Got: %v", t)
+			return nil, fmt.Errorf("List GitHub teams failed: expected []*github.Team; got %T", t)
+		}
+
+		pages = res.LastPage
+		for _, tm := range teams {
+			if tm.GetSlug() == team {
+				id = tm.GetID()
+			}
+		}
+	}
+	if id == 0 {
+		return nil, fmt.Errorf("GitHub team %s not found in organisation %s", team, org)
+	}
+
+	var users []*github.User
+	pages = 1
+	for page := 1; page <= pages; page++ {
+		u, res, err := g.request(func() (interface{}, *github.Response, error) {
+			return g.client.Organizations.ListTeamMembers(ctx, id, &github.OrganizationListTeamMembersOptions{
+				ListOptions: github.ListOptions{
+					Page:    page,
+					PerPage: 100,
+				},
+			})
+		})
+		if err != nil {
+			log.Errorf("Error retrieving members of GitHub team %s. Error: %v", team, err)
+			return nil, err
+		}
+		userPage, ok := u.([]*github.User)
+		if !ok {
+			log.Errorf("List GitHub team members did not return users! Got: %v", u)
+			return nil, fmt.Errorf("List GitHub team members failed: expected []*github.User; got %T", u)
+		}
+
+		pages = res.LastPage
+		users = append(users, userPage...)
+	}
+
+	return users, nil
+}
+
+// GetUser returns a GitHub user from its login. If the user doesn't
+// exist, ErrUserNotFound is returned.
 func (g realGHClient) GetUser(login string) (github.User, error) {
 	log := g.config.GetLogger()
 
-	u, _, err := g.request(func() (interface{}, *github.Response, error) {
-		return g.client.Users.Get(context.Background(), login)
+	u, res, err := g.request(func() (interface{}, *github.Response, error) {
+		user, res, err := g.client.Users.Get(context.Background(), login)
+		// A missing user won't appear by retrying, so stop the backoff.
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil, res, nil
+		}
+		return user, res, err
 	})
 
 	if err != nil {
 		log.Errorf("Error retrieving GitHub user %s. Error: %v", login, err)
+		return github.User{}, err
+	}
+	if res != nil && res.StatusCode == http.StatusNotFound {
+		log.Debugf("GitHub user %s does not exist", login)
+		return github.User{}, ErrUserNotFound
 	}
 
 	user, ok := u.(*github.User)
@@ -201,6 +442,88 @@ func (g realGHClient) GetUser(login string) (github.User, error) {
 	return *user, nil
 }
 
+// CreateIssue creates a new GitHub issue in the given repository from the
+// provided request. It returns the created issue.
+func (g realGHClient) CreateIssue(owner, repo string, issue github.IssueRequest) (github.Issue, error) {
+	log := g.config.GetLogger()
+
+	i, _, err := g.request(func() (interface{}, *github.Response, error) {
+		return g.client.Issues.Create(context.Background(), owner, repo, &issue)
+	})
+	if err != nil {
+		log.Errorf("Error creating GitHub issue in %s/%s. Error: %v", owner, repo, err)
+		return github.Issue{}, err
+	}
+	is, ok := i.(*github.Issue)
+	if !ok {
+		log.Errorf("Create GitHub issue did not return issue! Got: %v", i)
+		return github.Issue{}, fmt.Errorf("Create GitHub issue failed: expected *github.Issue; got %T", i)
+	}
+
+	return *is, nil
+}
+
+// UpdateIssue edits the GitHub issue identified by its number in the given
+// repository with the provided request. It returns the updated issue.
+func (g realGHClient) UpdateIssue(owner, repo string, number int, issue github.IssueRequest) (github.Issue, error) {
+	log := g.config.GetLogger()
+
+	i, _, err := g.request(func() (interface{}, *github.Response, error) {
+		return g.client.Issues.Edit(context.Background(), owner, repo, number, &issue)
+	})
+	if err != nil {
+		log.Errorf("Error updating GitHub issue %s/%s#%d. Error: %v", owner, repo, number, err)
+		return github.Issue{}, err
+	}
+	is, ok := i.(*github.Issue)
+	if !ok {
+		log.Errorf("Update GitHub issue did not return issue! Got: %v", i)
+		return github.Issue{}, fmt.Errorf("Update GitHub issue failed: expected *github.Issue; got %T", i)
+	}
+
+	return *is, nil
+}
+
+// AddLabels adds the labels to the GitHub issue identified by its number in
+// the given repository. Labels the issue already has are left as they are.
+func (g realGHClient) AddLabels(owner, repo string, number int, labels []string) error {
+	log := g.config.GetLogger()
+
+	_, _, err := g.request(func() (interface{}, *github.Response, error) {
+		return g.client.Issues.AddLabelsToIssue(context.Background(), owner, repo, number, labels)
+	})
+	if err != nil {
+		log.Errorf("Error adding labels to GitHub issue %s/%s#%d. Error: %v", owner, repo, number, err)
+		return err
+	}
+
+	return nil
+}
+
+// CreateComment adds a comment with the given body to a GitHub issue. It
+// returns the created comment.
+func (g realGHClient) CreateComment(issue github.Issue, body string) (github.IssueComment, error) {
+	log := g.config.GetLogger()
+
+	c, _, err := g.request(func() (interface{}, *github.Response, error) {
+		splitURL := strings.Split(issue.GetURL(), "/")
+		return g.client.Issues.CreateComment(context.Background(), splitURL[4], splitURL[5], issue.GetNumber(), &github.IssueComment{
+			Body: &body,
+		})
+	})
+	if err != nil {
+		log.Errorf("Error creating GitHub comment on issue #%d. Error: %v", issue.GetNumber(), err)
+		return github.IssueComment{}, err
+	}
+	comment, ok := c.(*github.IssueComment)
+	if !ok {
+		log.Errorf("Create GitHub comment did not return comment! Got: %v", c)
+		return github.IssueComment{}, fmt.Errorf("Create GitHub comment failed: expected *github.IssueComment; got %T", c)
+	}
+
+	return *comment, nil
+}
+
 // GetRateLimits returns the current rate limits on the GitHub API. This is a
 // simple and lightweight request that can also be used simply for testing the API.
 func (g *realGHClient) GetRateLimits() (github.RateLimits, error) {
@@ -237,12 +560,31 @@ func (g *realGHClient) request(f func() (interface{}, *github.Response, error))
 	var ret interface{}
 	var res *github.Response
 
-	op := func() error {
+	do := func() error {
+		g.limiter.Acquire()
+		defer g.limiter.Release()
+
 		var err error
 		ret, res, err = f()
 		return err
 	}
 
+	op := func() error {
+		err := do()
+		// Retrying won't help until the rate limit resets, so wait for it
+		// outside of the backoff, once.
+		if wait, ok := rateLimitWait(err, g.config.GetGitHubRateLimitMaxWait()); ok {
+			log.Warnf("GitHub rate limit exhausted; sleeping %v until it resets", wait.Round(time.Second))
+			time.Sleep(wait)
+			err = do()
+		} else if wait, ok := secondaryRateLimitWait(err, g.config.GetGitHubSecondaryRateLimitWait(), g.config.GetGitHubRateLimitMaxWait()); ok {
+			log.Warnf("GitHub secondary rate limit hit; sleeping %v before retrying", wait.Round(time.Second))
+			time.Sleep(wait)
+			err = do()
+		}
+		return err
+	}
+
 	b := backoff.NewExponentialBackOff()
 	b.MaxElapsedTime = g.config.GetTimeout()
 
@@ -258,6 +600,73 @@ func (g *realGHClient) request(f func() (interface{}, *github.Response, error))
 	return ret, res, backoffErr
 }
 
+// rateLimitWait returns how long to sleep for the rate limit to reset if err
+// is caused by the rate limit being exhausted, at most max.
+func rateLimitWait(err error, max time.Duration) (time.Duration, bool) {
+	rlErr, ok := err.(*github.RateLimitError)
+	if !ok || max <= 0 {
+		return 0, false
+	}
+	// Allow for the clocks of GitHub and issue-sync differing slightly.
+	wait := time.Until(rlErr.Rate.Reset.Time) + time.Second
+	if wait > max {
+		wait = max
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// secondaryRateLimitWait returns how long to sleep if err is caused by a
+// secondary rate limit, at most max: as long as GitHub asks in Retry-After,
+// or the given wait otherwise. GitHub reports secondary limits, which search
+// hits most often, with 403 or 429 and a message rather than the rate limit
+// headers; the vendored client only recognizes their old form.
+func secondaryRateLimitWait(err error, wait, max time.Duration) (time.Duration, bool) {
+	if max <= 0 {
+		return 0, false
+	}
+
+	switch e := err.(type) {
+	case *github.AbuseRateLimitError:
+		if e.RetryAfter != nil {
+			wait = *e.RetryAfter
+		}
+	case *github.ErrorResponse:
+		if e.Response == nil || (e.Response.StatusCode != http.StatusForbidden && e.Response.StatusCode != http.StatusTooManyRequests) {
+			return 0, false
+		}
+		if !strings.Contains(strings.ToLower(e.Message), "secondary rate limit") && !strings.Contains(e.DocumentationURL, "secondary-rate-limits") {
+			return 0, false
+		}
+		if seconds, err := strconv.Atoi(e.Response.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(seconds) * time.Second
+		}
+	default:
+		return 0, false
+	}
+
+	if wait > max {
+		wait = max
+	}
+	return wait, true
+}
+
+// apiBaseURL returns the base URL of the GitHub API requests: the configured
+// one, or else that of github.com.
+func apiBaseURL(cfg config.Config) (*url.URL, error) {
+	base := cfg.GetGitHubBaseURL()
+	if base == "" {
+		return github.NewClient(nil).BaseURL, nil
+	}
+	// The paths of the requests are relative to the base URL.
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return url.Parse(base)
+}
+
 // NewGitHubClient creates a GitHubClient and returns it; which
 // implementation it uses depends on the configuration of this
 // run. For example, a dry-run clients may be created which does
@@ -269,20 +678,39 @@ func NewGitHubClient(config config.Config) (GitHubClient, error) {
 	log := config.GetLogger()
 
 	ctx := context.Background()
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: config.GetConfigString("github-token")},
-	)
+	baseURL, err := apiBaseURL(config)
+	if err != nil {
+		return &realGHClient{}, err
+	}
+	ts, err := newTokenSource(config, baseURL)
+	if err != nil {
+		return &realGHClient{}, err
+	}
 	tc := oauth2.NewClient(ctx, ts)
+	if config.IsCachingGitHubResponses() {
+		tc.Transport = newETagTransport(tc.Transport)
+	}
 
 	client := github.NewClient(tc)
+	client.BaseURL = baseURL
 
-	ret = &realGHClient{
-		config: config,
-		client: client,
+	real := &realGHClient{
+		config:  config,
+		client:  client,
+		limiter: limit.New(config.GetGitHubConcurrency()),
+		reasons: &reasonCache{reasons: map[int]cachedReason{}},
+	}
+
+	if config.IsDryRun() {
+		ret = &dryrunGHClient{
+			realGHClient: real,
+		}
+	} else {
+		ret = real
 	}
 
 	// Make a request so we can check that we can connect fine.
-	_, err := ret.GetRateLimits()
+	_, err = ret.GetRateLimits()
 	if err != nil {
 		return &realGHClient{}, err
 	}
@@ -0,0 +1,322 @@
+package github
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	"github.com/innovocloud/issue-sync/pkg/limit"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		notice string
+		s      string
+		length int
+		want   string
+	}{
+		{"empty", "...", "", 5, "empty"},
+		{"short", "...", "body", 5, "body"},
+		{"at the length", "...", "12345", 5, "12345"},
+		{"truncated", "...", "123456", 5, "12345..."},
+		{"custom notice", "…[truncated]", "123456", 5, "12345…[truncated]"},
+		{"no notice", "", "123456", 5, "12345"},
+		{"newlines", "...", "a\nb\r\nc", 10, "a\\nb\\nc"},
+		{"multi-byte", "...", "€€€€€€", 3, "€€€..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.NewTestConfig(map[string]interface{}{"truncate-notice": tt.notice})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := truncate(cfg, tt.s, tt.length); got != tt.want {
+				t.Errorf("truncate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		want     string
+		wantErr  bool
+		notFound bool
+	}{
+		{"found", http.StatusOK, "octocat", false, false},
+		{"missing", http.StatusNotFound, "", true, true},
+		{"server error", http.StatusInternalServerError, "", true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				if tt.status == http.StatusOK {
+					w.Write([]byte(`{"login": "octocat"}`))
+				}
+			}))
+			defer server.Close()
+
+			// A short timeout, so that the server error isn't retried.
+			cfg, err := config.NewTestConfig(map[string]interface{}{"timeout": "1ms"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := github.NewClient(nil)
+			client.BaseURL, _ = url.Parse(server.URL + "/")
+			g := realGHClient{config: cfg, client: client}
+
+			user, err := g.GetUser("octocat")
+			if (err != nil) != tt.wantErr || (err == ErrUserNotFound) != tt.notFound {
+				t.Errorf("GetUser() error = %v, want error %v, not found %v", err, tt.wantErr, tt.notFound)
+			}
+			if user.GetLogin() != tt.want {
+				t.Errorf("GetUser() login = %q, want %q", user.GetLogin(), tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimitWait(t *testing.T) {
+	exhausted := func(reset time.Duration) error {
+		return &github.RateLimitError{Rate: github.Rate{Reset: github.Timestamp{Time: time.Now().Add(reset)}}}
+	}
+
+	tests := []struct {
+		name     string
+		err      error
+		max      time.Duration
+		min      time.Duration
+		want     time.Duration
+		wantWait bool
+	}{
+		{"until reset", exhausted(10 * time.Second), time.Hour, 10 * time.Second, 11 * time.Second, true},
+		{"capped", exhausted(2 * time.Hour), time.Hour, time.Hour, time.Hour, true},
+		{"already reset", exhausted(-time.Minute), time.Hour, 0, 0, true},
+		{"waiting disabled", exhausted(10 * time.Second), 0, 0, 0, false},
+		{"other error", errors.New("502 Bad Gateway"), time.Hour, 0, 0, false},
+		{"abuse limit", &github.AbuseRateLimitError{}, time.Hour, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rateLimitWait(tt.err, tt.max)
+			if ok != tt.wantWait || got < tt.min || got > tt.want {
+				t.Errorf("rateLimitWait() = %v, %v; want between %v and %v, %v", got, ok, tt.min, tt.want, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestRequestRateLimitExhausted(t *testing.T) {
+	tests := []struct {
+		name string
+		// reset is when the exhausted rate limit resets, from now.
+		reset    time.Duration
+		maxWait  string
+		requests int
+		sleep    bool
+		slept    time.Duration
+		wantErr  bool
+	}{
+		{"resets", -time.Second, "1h", 2, true, 0, false},
+		// The limit is still exhausted, so the client doesn't request again.
+		{"capped wait", time.Hour, "50ms", 1, true, 50 * time.Millisecond, true},
+		// The request is retried by the backoff instead.
+		{"waiting disabled", -time.Second, "0s", 2, false, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", fmt.Sprint(time.Now().Add(tt.reset).Unix()))
+					w.WriteHeader(http.StatusForbidden)
+					w.Write([]byte(`{"message": "API rate limit exceeded for 127.0.0.1."}`))
+					return
+				}
+				w.Write([]byte(`{"login": "octocat"}`))
+			}))
+			defer server.Close()
+
+			cfg, err := config.NewTestConfig(map[string]interface{}{
+				"timeout":                    "1ms",
+				"github-rate-limit-max-wait": tt.maxWait,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := github.NewClient(nil)
+			client.BaseURL, _ = url.Parse(server.URL + "/")
+			g := realGHClient{config: cfg, client: client}
+			var out bytes.Buffer
+			logger := cfg.GetLogger().Logger
+			logger.Out = &out
+			logger.Level = logrus.WarnLevel
+
+			start := time.Now()
+			_, err = g.GetUser("octocat")
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetUser() error = %v, want error %v", err, tt.wantErr)
+			}
+			if requests != tt.requests {
+				t.Errorf("%d requests made, want %d", requests, tt.requests)
+			}
+			if slept := strings.Contains(out.String(), "sleeping"); slept != tt.sleep {
+				t.Errorf("slept for the rate limit = %v, want %v", slept, tt.sleep)
+			}
+			if slept := time.Since(start); slept < tt.slept {
+				t.Errorf("returned after %v, want a sleep of %v", slept, tt.slept)
+			}
+		})
+	}
+}
+
+func TestSecondaryRateLimitWait(t *testing.T) {
+	limited := func(status int, message, docs, retryAfter string) error {
+		header := http.Header{}
+		if retryAfter != "" {
+			header.Set("Retry-After", retryAfter)
+		}
+		return &github.ErrorResponse{
+			Response:         &http.Response{StatusCode: status, Header: header},
+			Message:          message,
+			DocumentationURL: docs,
+		}
+	}
+	retryAfter := 30 * time.Second
+	secondary := "You have exceeded a secondary rate limit. Please wait a few minutes before you try again."
+
+	tests := []struct {
+		name     string
+		err      error
+		max      time.Duration
+		want     time.Duration
+		wantWait bool
+	}{
+		{"secondary limit", limited(http.StatusForbidden, secondary, "", ""), time.Hour, time.Minute, true},
+		{"too many requests", limited(http.StatusTooManyRequests, secondary, "", ""), time.Hour, time.Minute, true},
+		{"documentation URL", limited(http.StatusForbidden, "Forbidden", "https://docs.github.com/rest/overview/resources-in-the-rest-api#secondary-rate-limits", ""), time.Hour, time.Minute, true},
+		{"retry after", limited(http.StatusForbidden, secondary, "", "10"), time.Hour, 10 * time.Second, true},
+		{"capped", limited(http.StatusForbidden, secondary, "", "7200"), time.Hour, time.Hour, true},
+		{"abuse limit", &github.AbuseRateLimitError{}, time.Hour, time.Minute, true},
+		{"abuse limit retry after", &github.AbuseRateLimitError{RetryAfter: &retryAfter}, time.Hour, retryAfter, true},
+		{"waiting disabled", limited(http.StatusForbidden, secondary, "", ""), 0, 0, false},
+		{"forbidden", limited(http.StatusForbidden, "Resource not accessible by integration", "", ""), time.Hour, 0, false},
+		{"other status", limited(http.StatusBadGateway, secondary, "", ""), time.Hour, 0, false},
+		{"other error", errors.New("502 Bad Gateway"), time.Hour, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := secondaryRateLimitWait(tt.err, time.Minute, tt.max)
+			if got != tt.want || ok != tt.wantWait {
+				t.Errorf("secondaryRateLimitWait() = %v, %v; want %v, %v", got, ok, tt.want, tt.wantWait)
+			}
+		})
+	}
+}
+
+func TestRequestSecondaryRateLimit(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"message": "You have exceeded a secondary rate limit. Please wait a few minutes before you try again."}`))
+			return
+		}
+		w.Write([]byte(`{"total_count": 0, "items": []}`))
+	}))
+	defer server.Close()
+
+	// The backoff alone would retry within the timeout.
+	wait := 200 * time.Millisecond
+	cfg, err := config.NewTestConfig(map[string]interface{}{
+		"timeout":                          "1ms",
+		"github-rate-limit-max-wait":       "1h",
+		"github-secondary-rate-limit-wait": wait.String(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := github.NewClient(nil)
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	g := realGHClient{config: cfg, client: client}
+	var out bytes.Buffer
+	logger := cfg.GetLogger().Logger
+	logger.Out = &out
+	logger.Level = logrus.WarnLevel
+
+	start := time.Now()
+	if _, err := g.SearchIssues("is:issue"); err != nil {
+		t.Fatalf("SearchIssues() error = %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("%d requests made, want 2", requests)
+	}
+	if !strings.Contains(out.String(), "secondary rate limit hit") {
+		t.Errorf("didn't sleep for the secondary rate limit; logged:\n%s", out.String())
+	}
+	if slept := time.Since(start); slept < wait {
+		t.Errorf("returned after %v, want a sleep of %v", slept, wait)
+	}
+}
+
+func TestRequestConcurrency(t *testing.T) {
+	// Only the GitHub limit applies to GitHub requests.
+	cfg, err := config.NewTestConfig(map[string]interface{}{
+		"github-concurrency": 2,
+		"jira-concurrency":   5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := &realGHClient{config: cfg, limiter: limit.New(cfg.GetGitHubConcurrency())}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inFlight, max := 0, 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.request(func() (interface{}, *github.Response, error) {
+				mu.Lock()
+				inFlight++
+				if inFlight > max {
+					max = inFlight
+				}
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+				return nil, nil, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if max > cfg.GetGitHubConcurrency() {
+		t.Errorf("%d GitHub requests in flight, want at most %d", max, cfg.GetGitHubConcurrency())
+	}
+}
@@ -0,0 +1,200 @@
+package github
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	"golang.org/x/oauth2"
+)
+
+// tokenRefreshMargin is how long before its expiry a token is replaced, so
+// requests in flight don't fail with an expired token.
+const tokenRefreshMargin = 5 * time.Minute
+
+// newTokenSource returns the source of the token used to authenticate with
+// GitHub: an installation token of the GitHub App, the token printed by the
+// `github-token-command`, or the static `github-token`. Expiring tokens are
+// replaced shortly before they expire. Installation tokens are created
+// through the API at the given base URL, like all other requests.
+func newTokenSource(cfg config.Config, baseURL *url.URL) (oauth2.TokenSource, error) {
+	if appID, installationID := cfg.GetGitHubApp(); appID != 0 {
+		key, err := cfg.GetGitHubAppKey()
+		if err != nil {
+			return nil, err
+		}
+		client := github.NewClient(nil)
+		client.BaseURL = baseURL
+		return oauth2.ReuseTokenSource(nil, appTokenSource{
+			cfg:            cfg,
+			appID:          appID,
+			installationID: installationID,
+			key:            key,
+			client:         client,
+		}), nil
+	}
+
+	command := cfg.GetGitHubTokenCommand()
+	if command == "" {
+		return oauth2.StaticTokenSource(
+			&oauth2.Token{AccessToken: cfg.GetConfigString("github-token")},
+		), nil
+	}
+	return oauth2.ReuseTokenSource(nil, commandTokenSource{
+		cfg:     cfg,
+		command: command,
+	}), nil
+}
+
+// commandTokenSource mints tokens by running a shell command. The command
+// prints either the token itself or a JSON object with the token and its
+// expiry, as returned by GitHub for app installation tokens.
+type commandTokenSource struct {
+	cfg     config.Config
+	command string
+}
+
+// expiringToken is a token and its expiry, as GitHub returns installation
+// tokens and a token command may print them.
+type expiringToken struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Token runs the command and returns the token it printed.
+func (s commandTokenSource) Token() (*oauth2.Token, error) {
+	log := s.cfg.GetLogger()
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.GetTimeout())
+	defer cancel()
+
+	out, err := exec.CommandContext(ctx, "sh", "-c", s.command).Output()
+	if err != nil {
+		return nil, fmt.Errorf("error running GitHub token command: %v", err)
+	}
+
+	token, err := parseCommandToken(out, time.Now().Add(s.cfg.GetGitHubTokenLifetime()))
+	if err != nil {
+		return nil, err
+	}
+	log.Debugf("Obtained a GitHub token expiring at %s", token.ExpiresAt.Format(time.RFC3339))
+
+	return token.oauth2(), nil
+}
+
+// oauth2 returns the token set to expire early enough to be replaced before
+// GitHub rejects it.
+func (t expiringToken) oauth2() *oauth2.Token {
+	// Short-lived tokens are replaced halfway through their lifetime instead.
+	margin := tokenRefreshMargin
+	if remaining := time.Until(t.ExpiresAt); remaining < 2*margin {
+		margin = remaining / 2
+	}
+	return &oauth2.Token{
+		AccessToken: t.Token,
+		Expiry:      t.ExpiresAt.Add(-margin),
+	}
+}
+
+// parseCommandToken parses the output of a token command. A bare token
+// expires at the given default.
+func parseCommandToken(out []byte, expiry time.Time) (expiringToken, error) {
+	s := strings.TrimSpace(string(out))
+	if strings.HasPrefix(s, "{") {
+		var token expiringToken
+		if err := json.Unmarshal([]byte(s), &token); err != nil {
+			return expiringToken{}, fmt.Errorf("error parsing GitHub token command output: %v", err)
+		}
+		if token.ExpiresAt.IsZero() {
+			token.ExpiresAt = expiry
+		}
+		s, expiry = token.Token, token.ExpiresAt
+	}
+	if s == "" {
+		return expiringToken{}, errors.New("GitHub token command printed no token")
+	}
+	return expiringToken{Token: s, ExpiresAt: expiry}, nil
+}
+
+// appJWTLifetime is how long the JWTs authenticating as the GitHub App are
+// valid; GitHub accepts at most ten minutes.
+const appJWTLifetime = 9 * time.Minute
+
+// appTokenSource mints installation tokens of a GitHub App, authenticating
+// as the app with a JWT signed by its private key.
+type appTokenSource struct {
+	cfg            config.Config
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	client         *github.Client
+}
+
+// Token creates an installation token.
+func (s appTokenSource) Token() (*oauth2.Token, error) {
+	log := s.cfg.GetLogger()
+
+	jwt, err := s.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.GetTimeout())
+	defer cancel()
+
+	req, err := s.client.NewRequest("POST", fmt.Sprintf("app/installations/%d/access_tokens", s.installationID), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	var token expiringToken
+	if _, err := s.client.Do(ctx, req, &token); err != nil {
+		return nil, fmt.Errorf("error creating GitHub App installation token: %v", err)
+	}
+	if token.Token == "" {
+		return nil, errors.New("GitHub returned no installation token")
+	}
+	log.Debugf("Obtained a GitHub App installation token expiring at %s", token.ExpiresAt.Format(time.RFC3339))
+
+	return token.oauth2(), nil
+}
+
+// jwt returns the JWT authenticating as the app, signed with RS256. It is
+// backdated a minute to allow for clock drift.
+func (s appTokenSource) jwt(now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(appJWTLifetime).Unix(),
+		"iss": s.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(header) + "." + enc.EncodeToString(claims)
+	hash := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, s.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing GitHub App JWT: %v", err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
@@ -0,0 +1,226 @@
+package github
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/innovocloud/issue-sync/pkg/config"
+)
+
+func TestParseCommandToken(t *testing.T) {
+	expiry := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	printed := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		out     string
+		want    expiringToken
+		wantErr bool
+	}{
+		{"bare token", "token\n", expiringToken{"token", expiry}, false},
+		{"json", `{"token": "token", "expires_at": "2020-06-01T00:00:00Z"}`, expiringToken{"token", printed}, false},
+		{"json without expiry", `{"token": "token"}`, expiringToken{"token", expiry}, false},
+		{"empty", "\n", expiringToken{}, true},
+		{"json without token", `{"expires_at": "2020-06-01T00:00:00Z"}`, expiringToken{}, true},
+		{"invalid json", `{"token": `, expiringToken{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := parseCommandToken([]byte(tt.out), expiry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error: %v", err, tt.wantErr)
+			}
+			if token != tt.want {
+				t.Errorf("got token %+v, want %+v", token, tt.want)
+			}
+		})
+	}
+}
+
+// tokenServer serves GitHub App installation tokens expiring after the given
+// lifetime, numbering them by the request which created them.
+type tokenServer struct {
+	lifetime time.Duration
+	requests int
+}
+
+func (s *tokenServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.URL.Path != "/app/installations/42/access_tokens" {
+		http.NotFound(w, r)
+		return
+	}
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	s.requests++
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(expiringToken{
+		Token:     fmt.Sprintf("token-%d", s.requests),
+		ExpiresAt: time.Now().Add(s.lifetime),
+	})
+}
+
+func TestAppTokenSourceRefresh(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	keyPath := filepath.Join(dir, "key.pem")
+	pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(keyPath, pemKey, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		lifetime time.Duration
+		want     []string
+	}{
+		{"long-lived", time.Hour, []string{"token-1", "token-1", "token-1"}},
+		{"short-lived", time.Minute, []string{"token-1", "token-1", "token-1"}},
+		{"within refresh margin", 15 * time.Second, []string{"token-1", "token-2", "token-3"}},
+		{"expired", -time.Minute, []string{"token-1", "token-2", "token-3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &tokenServer{lifetime: tt.lifetime}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			// The tokens are created through the API at the configured URL.
+			cfg, err := config.NewTestConfig(map[string]interface{}{
+				"timeout":                     "1m",
+				"github-app-id":               1,
+				"github-app-installation-id":  42,
+				"github-app-private-key-path": keyPath,
+				"github-base-url":             server.URL,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			baseURL, err := apiBaseURL(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			source, err := newTokenSource(cfg, baseURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for range tt.want {
+				token, err := source.Token()
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, token.AccessToken)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("got tokens %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandTokenSourceRefresh(t *testing.T) {
+	tests := []struct {
+		name  string
+		out   string
+		calls int
+	}{
+		{"bare token", "token", 1},
+		{"json", fmt.Sprintf(`{"token": "token", "expires_at": "%s"}`, time.Now().Add(time.Hour).Format(time.RFC3339)), 1},
+		{"json expired", `{"token": "token", "expires_at": "2020-01-01T00:00:00Z"}`, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The command counts its runs in a file.
+			dir, err := ioutil.TempDir("", "issue-sync")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			runs := filepath.Join(dir, "runs")
+
+			cfg, err := config.NewTestConfig(map[string]interface{}{
+				"timeout":              "1m",
+				"github-token-command": fmt.Sprintf("echo >> %s; echo '%s'", runs, tt.out),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			source, err := newTokenSource(cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 0; i < 3; i++ {
+				token, err := source.Token()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if token.AccessToken != "token" {
+					t.Errorf("got token %q, want %q", token.AccessToken, "token")
+				}
+			}
+
+			b, err := ioutil.ReadFile(runs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if calls := strings.Count(string(b), "\n"); calls != tt.calls {
+				t.Errorf("command ran %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestAPIBaseURL(t *testing.T) {
+	tests := []struct {
+		name string
+		base string
+		want string
+	}{
+		{"default", "", "https://api.github.com/"},
+		{"enterprise", "https://github.example.com/api/v3/", "https://github.example.com/api/v3/"},
+		{"without trailing slash", "https://github.example.com/api/v3", "https://github.example.com/api/v3/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.NewTestConfig(map[string]interface{}{"github-base-url": tt.base})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := apiBaseURL(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("apiBaseURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
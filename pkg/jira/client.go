@@ -1,25 +1,43 @@
 package jira
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"time"
+	"unicode/utf8"
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/cenkalti/backoff"
 	"github.com/google/go-github/github"
 	"github.com/innovocloud/issue-sync/pkg/config"
+	"github.com/innovocloud/issue-sync/pkg/convert"
 	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	"github.com/innovocloud/issue-sync/pkg/limit"
 )
 
 // commentDateFormat is the format used in the headers of JIRA comments.
 const commentDateFormat = "15:04 PM, January 2 2006"
 
+// jqlDateFormat is the format of dates in JQL queries. JIRA interprets them
+// in the time zone of the authenticated user, so they are written in it (see
+// jqlDate).
+const jqlDateFormat = "2006/01/02 15:04"
+
+// jqlDate returns a time written for a JQL query, in the time zone of the
+// JIRA user.
+func jqlDate(cfg config.Config, t time.Time) string {
+	return t.In(cfg.GetJIRALocation()).Format(jqlDateFormat)
+}
+
 // maxJQLIssueLength is the maximum number of GitHub issues we can
 // use before we need to stop using JQL and filter issues ourself.
 const maxJQLIssueLength = 100
@@ -41,17 +59,90 @@ func getErrorBody(config config.Config, res *jira.Response) error {
 	return errors.New(string(body))
 }
 
+// ForbiddenError is returned when JIRA refuses a request with 403 Forbidden,
+// e.g. because the account may read issues but not comment on them.
+type ForbiddenError struct {
+	Body string
+}
+
+func (e ForbiddenError) Error() string {
+	return fmt.Sprintf("forbidden by JIRA: %s", e.Body)
+}
+
+// responseError returns the error for a failed JIRA API response, which is
+// a ForbiddenError if the request was forbidden. Like getErrorBody, it
+// closes the body.
+func responseError(config config.Config, res *jira.Response) error {
+	err := getErrorBody(config, res)
+	if res.StatusCode == http.StatusForbidden {
+		return ForbiddenError{Body: err.Error()}
+	}
+	return err
+}
+
 // JIRAClient is a wrapper around the JIRA API clients library we
 // use. It allows us to hide implementation details such as backoff
 // as well as swap in other implementations, such as for dry run
 // or test mocking.
 type JIRAClient interface {
 	ListIssues(ids []int) ([]jira.Issue, error)
+	ListIssuesUpdatedSince(t time.Time) ([]jira.Issue, error)
 	GetIssue(key string) (jira.Issue, error)
 	CreateIssue(issue jira.Issue) (jira.Issue, error)
 	UpdateIssue(issue jira.Issue) (jira.Issue, error)
 	CreateComment(issue jira.Issue, comment github.IssueComment, github ghClient.GitHubClient) (jira.Comment, error)
 	UpdateComment(issue jira.Issue, id string, comment github.IssueComment, github ghClient.GitHubClient) (jira.Comment, error)
+	DeleteComment(issue jira.Issue, id string) error
+	CreateEventComment(issue jira.Issue, event github.Timeline) (jira.Comment, error)
+	CreateStateComment(issue jira.Issue, ghIssue github.Issue) (jira.Comment, error)
+	CreateConflictComment(issue jira.Issue, ghIssue github.Issue) (jira.Comment, error)
+	ResolveSprint(name string) (int, bool, error)
+	UserExists(name string) (bool, error)
+	GetProperty(issue jira.Issue, key string, v interface{}) (bool, error)
+	SetProperty(issue jira.Issue, key string, v interface{}) error
+	TransitionIssue(issue jira.Issue, path []string) error
+	Now() time.Time
+	RefreshFieldIDs() error
+}
+
+// SyncPropertyKey is the key of the JIRA issue entity property in which
+// issue-sync stores its metadata, when properties are in use.
+const SyncPropertyKey = "issue-sync"
+
+// SyncProperty is the metadata issue-sync stores in the entity property of
+// each synced JIRA issue.
+type SyncProperty struct {
+	GitHubID     int    `json:"githubID"`
+	GitHubNumber int    `json:"githubNumber"`
+	GitHubRepo   string `json:"githubRepo,omitempty"`
+	LastSync     string `json:"lastSync"`
+}
+
+// DescriptionPropertyKey is the key of the JIRA issue entity property in
+// which issue-sync stores the hash of the description it last wrote, with
+// the `detect-conflicts` option.
+const DescriptionPropertyKey = "issue-sync-description"
+
+// DescriptionProperty is the value of the description entity property.
+type DescriptionProperty struct {
+	Hash string `json:"hash"`
+}
+
+// StatusPropertyKey is the key of the JIRA issue entity property in which
+// issue-sync stores the status it last applied a status label for.
+const StatusPropertyKey = "issue-sync-status"
+
+// StatusProperty is the value of the status entity property. Status is empty
+// once the issue has left the status it was labeled for.
+type StatusProperty struct {
+	Status string `json:"status"`
+}
+
+// DescriptionHash returns the hash of a JIRA description stored in the
+// DescriptionProperty.
+func DescriptionHash(desc string) string {
+	sum := sha256.Sum256([]byte(desc))
+	return fmt.Sprintf("%x", sum)
 }
 
 // NewJIRAClient creates a new JIRAClient and configures it with
@@ -61,41 +152,60 @@ type JIRAClient interface {
 func NewJIRAClient(cfg *config.Config) (JIRAClient, error) {
 	log := cfg.GetLogger()
 
-	var oauth *http.Client
+	var httpClient *http.Client
 	var err error
-	if !cfg.IsBasicAuth() {
-		oauth, err = newJIRAHTTPClient(*cfg)
+	if path := cfg.GetJIRASnapshot(); path != "" {
+		s, err := loadSnapshot(path)
+		if err != nil {
+			return dryrunJIRAClient{}, err
+		}
+		httpClient = &http.Client{Transport: s}
+		log.Infof("Dry run reading JIRA from the snapshot %s; no requests are made to JIRA", path)
+	} else if !cfg.IsBasicAuth() {
+		httpClient, err = newJIRAHTTPClient(*cfg)
 		if err != nil {
 			log.Errorf("Error getting OAuth config: %v", err)
 			return dryrunJIRAClient{}, err
 		}
 	}
 
-	client, err := jira.NewClient(oauth, cfg.GetConfigString("jira-uri"))
+	client, err := jira.NewClient(httpClient, cfg.GetConfigString("jira-uri"))
 	if err != nil {
 		log.Errorf("Error initializing JIRA clients; check your base URI. Error: %v", err)
 		return dryrunJIRAClient{}, err
 	}
 
-	if cfg.IsBasicAuth() {
+	if cfg.IsBasicAuth() && cfg.GetJIRASnapshot() == "" {
 		client.Authentication.SetBasicAuth(cfg.GetConfigString("jira-user"), cfg.GetConfigString("jira-secret"))
 	}
 
 	log.Debug("JIRA clients initialized")
 
-	cfg.LoadJIRAConfig(*client)
+	if err := cfg.LoadJIRAConfig(*client); err != nil {
+		return dryrunJIRAClient{}, err
+	}
 
 	var j JIRAClient
 
+	limiter := limit.New(cfg.GetJIRAConcurrency())
+	size, pause := cfg.GetCreateBatch()
+
 	if cfg.IsDryRun() {
 		j = dryrunJIRAClient{
-			cfg:    *cfg,
-			client: *client,
+			cfg:     *cfg,
+			client:  *client,
+			limiter: limiter,
+			clock:   &serverClock{},
+			syncIDs: &syncIDCache{},
 		}
 	} else {
 		j = realJIRAClient{
-			cfg:    *cfg,
-			client: *client,
+			cfg:      *cfg,
+			client:   *client,
+			limiter:  limiter,
+			creating: limit.NewThrottle(cfg.GetCreateRate(), size, pause),
+			clock:    &serverClock{},
+			syncIDs:  &syncIDCache{},
 		}
 	}
 
@@ -106,14 +216,23 @@ func NewJIRAClient(cfg *config.Config) (JIRAClient, error) {
 // of the requests against the JIRA REST API. It is the canonical
 // implementation of JIRAClient.
 type realJIRAClient struct {
-	cfg    config.Config
-	client jira.Client
+	cfg     config.Config
+	client  jira.Client
+	limiter limit.Limiter
+	// creating spaces the creation of issues.
+	creating *limit.Throttle
+	clock    *serverClock
+	syncIDs  *syncIDCache
 }
 
 // ListIssues returns a list of JIRA issues on the configured project which
 // have GitHub IDs in the provided list. `ids` should be a comma-separated
 // list of GitHub IDs.
 func (j realJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
+	if j.cfg.IsUsingProperties() {
+		return listIssuesByProperty(j.cfg, j, j.syncIDs, j.getIssues, ids)
+	}
+
 	idStrs := make([]string, len(ids))
 	for i, v := range ids {
 		idStrs[i] = fmt.Sprint(v)
@@ -122,31 +241,31 @@ func (j realJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
 	var jql string
 	// If the list of IDs is too long, we get a 414 Request-URI Too Large, so in that case,
 	// we'll need to do the filtering ourselves.
-	if len(ids) < maxJQLIssueLength {
-		jql = fmt.Sprintf("project='%s' AND cf[%s] in (%s)",
-			j.cfg.GetProjectKey(), j.cfg.GetFieldID(config.GitHubID), strings.Join(idStrs, ","))
+	if searchByJQL(j.cfg, ids) {
+		jql = githubIDJQL(j.cfg, fmt.Sprintf("cf[%s]", j.cfg.GetFieldID(config.GitHubID)), idStrs)
 	} else {
 		jql = fmt.Sprintf("project='%s'", j.cfg.GetProjectKey())
 	}
 
 	jiraIssues, err := j.getIssues(jql)
+	if clause := j.cfg.GetGitHubIDClause(); err != nil && searchByJQL(j.cfg, ids) && clause != "" {
+		log := j.cfg.GetLogger()
+		log.Warnf("Searching JIRA issues by cf[%s] failed; retrying by the clause name %s", j.cfg.GetFieldID(config.GitHubID), clause)
+		jiraIssues, err = j.getIssues(githubIDJQL(j.cfg, clause, idStrs))
+	}
 	if err != nil {
 		return nil, err
 	}
 
 	var filteredIssues []jira.Issue
 
-	if len(ids) < maxJQLIssueLength {
+	if searchByJQL(j.cfg, ids) {
 		// The issues were already filtered by our JQL, so use as is
 		filteredIssues = jiraIssues
 	} else {
 		// Filter only issues which have a defined GitHub ID in the list of IDs
-		ghIDFieldKey := j.cfg.GetFieldKey(config.GitHubID)
 		for _, v := range jiraIssues {
-			if val, _ := v.Fields.Unknowns.Value(ghIDFieldKey); val == nil {
-				continue
-			}
-			if id, err := v.Fields.Unknowns.Int(ghIDFieldKey); err == nil {
+			if id, ok := GetGitHubID(j.cfg, v); ok {
 				for _, idOpt := range ids {
 					if id == int64(idOpt) {
 						filteredIssues = append(filteredIssues, v)
@@ -160,6 +279,177 @@ func (j realJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
 	return filteredIssues, nil
 }
 
+// searchByJQL returns whether the JIRA issues with the given GitHub IDs are
+// searched for by their IDs in JQL, rather than filtered from all the issues
+// of the project: there must be few enough IDs for the request URI, and the
+// GitHub ID field must be a number field, as text fields can't be searched
+// with the `in` operator.
+func searchByJQL(cfg config.Config, ids []int) bool {
+	return len(ids) < maxJQLIssueLength && !cfg.IsTextField(config.GitHubID)
+}
+
+// githubIDJQL returns the JQL query for the issues of the project with the
+// given GitHub IDs, referring to the GitHub ID field by the given clause.
+func githubIDJQL(cfg config.Config, clause string, ids []string) string {
+	return fmt.Sprintf("project='%s' AND %s in (%s)", cfg.GetProjectKey(), clause, strings.Join(ids, ","))
+}
+
+// listIssuesByProperty implements ListIssues when issues are matched by their
+// SyncProperty rather than the GitHub ID custom field. Entity properties can't
+// be searched by JQL unless indexed by a JIRA app, so every issue of the
+// project is retrieved and its property read, unless already in the cache.
+// The GitHub ID of each matched issue is copied into its GitHub ID field, so
+// that it can be matched as usual.
+func listIssuesByProperty(cfg config.Config, client JIRAClient, cache *syncIDCache, getIssues func(string) ([]jira.Issue, error), ids []int) ([]jira.Issue, error) {
+	jiraIssues, err := getIssues(fmt.Sprintf("project='%s'", cfg.GetProjectKey()))
+	if err != nil {
+		return nil, err
+	}
+
+	wanted := map[int]bool{}
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var issues []jira.Issue
+	for _, v := range jiraIssues {
+		id, cached := cache.get(v.Key)
+		if !cached {
+			var prop SyncProperty
+			if _, err := client.GetProperty(v, SyncPropertyKey, &prop); err != nil {
+				return nil, err
+			}
+			id = prop.GitHubID
+			cache.set(v.Key, id)
+		}
+		if id == 0 || !wanted[id] {
+			continue
+		}
+
+		if v.Fields.Unknowns == nil {
+			v.Fields.Unknowns = map[string]interface{}{}
+		}
+		v.Fields.Unknowns[cfg.GetFieldKey(config.GitHubID)] = id
+		issues = append(issues, v)
+	}
+
+	return issues, nil
+}
+
+// GetProperty reads the entity property with the given key of a JIRA issue
+// into v. It returns false if the issue has no such property.
+func (j realJIRAClient) GetProperty(issue jira.Issue, key string, v interface{}) (bool, error) {
+	return getProperty(j.cfg, j.client, j.request, issue, key, v)
+}
+
+// getProperty implements GetProperty for both JIRA clients, making the
+// request through the provided request function.
+func getProperty(cfg config.Config, client jira.Client, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error), issue jira.Issue, key string, v interface{}) (bool, error) {
+	log := cfg.GetLogger()
+
+	req, err := client.NewRequest("GET", fmt.Sprintf("rest/api/2/issue/%s/properties/%s", issue.Key, key), nil)
+	if err != nil {
+		log.Errorf("Error creating property request: %s", err)
+		return false, err
+	}
+
+	property := struct {
+		Value json.RawMessage `json:"value"`
+	}{}
+
+	_, res, err := request(func() (interface{}, *jira.Response, error) {
+		res, err := client.Do(req, &property)
+		// A missing property won't appear by retrying, so stop the backoff.
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil, res, nil
+		}
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving property %s of JIRA issue %s: %v", key, issue.Key, err)
+		return false, getErrorBody(cfg, res)
+	}
+	if res != nil && res.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+
+	if err := json.Unmarshal(property.Value, v); err != nil {
+		return false, fmt.Errorf("invalid property %s on JIRA issue %s: %v", key, issue.Key, err)
+	}
+
+	return true, nil
+}
+
+// SetProperty stores v, as JSON, in the entity property with the given key
+// of a JIRA issue.
+func (j realJIRAClient) SetProperty(issue jira.Issue, key string, v interface{}) error {
+	log := j.cfg.GetLogger()
+
+	req, err := j.client.NewRequest("PUT", fmt.Sprintf("rest/api/2/issue/%s/properties/%s", issue.Key, key), v)
+	if err != nil {
+		log.Errorf("Error creating property request: %s", err)
+		return err
+	}
+
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		if err := rewind(req); err != nil {
+			return nil, nil, err
+		}
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error setting property %s of JIRA issue %s: %v", key, issue.Key, err)
+		return getErrorBody(j.cfg, res)
+	}
+	if prop, ok := v.(SyncProperty); ok && key == SyncPropertyKey {
+		j.syncIDs.set(issue.Key, prop.GitHubID)
+	}
+
+	return nil
+}
+
+// GetGitHubID returns the GitHub ID stored in the custom field of a JIRA issue,
+// and whether one was found. Depending on how the field was created, JIRA
+// stores the ID either as a number or as a string, so both are accepted.
+func GetGitHubID(cfg config.Config, issue jira.Issue) (int64, bool) {
+	log := cfg.GetLogger()
+
+	key := cfg.GetFieldKey(config.GitHubID)
+	val, _ := issue.Fields.Unknowns.Value(key)
+	if val == nil {
+		return 0, false
+	}
+
+	if id, err := issue.Fields.Unknowns.Int(key); err == nil {
+		return id, true
+	}
+
+	if str, err := issue.Fields.Unknowns.String(key); err == nil {
+		if id, err := strconv.ParseInt(str, 10, 64); err == nil {
+			return id, true
+		}
+		if id, err := strconv.ParseFloat(str, 64); err == nil {
+			return int64(id), true
+		}
+	}
+
+	log.Warnf("GitHub ID field of JIRA issue %s has unexpected value %v (%T)", issue.Key, val, val)
+	return 0, false
+}
+
+// ListIssuesUpdatedSince returns a list of all JIRA issues on the configured
+// project which have been updated at or after the provided time, in ascending
+// order of update.
+func (j realJIRAClient) ListIssuesUpdatedSince(t time.Time) ([]jira.Issue, error) {
+	jql := fmt.Sprintf("project='%s' AND updated >= '%s' ORDER BY updated ASC",
+		j.cfg.GetProjectKey(), jqlDate(j.cfg, t))
+
+	return j.getIssues(jql)
+}
+
+// getIssues returns all of the JIRA issues matching the JQL query, requesting
+// them page by page.
 func (j realJIRAClient) getIssues(jql string) ([]jira.Issue, error) {
 	log := j.cfg.GetLogger()
 	var issues []jira.Issue
@@ -197,7 +487,7 @@ func (j realJIRAClient) getIssues(jql string) ([]jira.Issue, error) {
 }
 
 // GetIssue returns a single JIRA issue within the configured project
-// according to the issue key (e.g. "PROJ-13").
+// according to the issue key (e.g. "PROJ-13") or ID.
 func (j realJIRAClient) GetIssue(key string) (jira.Issue, error) {
 	log := j.cfg.GetLogger()
 
@@ -223,6 +513,9 @@ func (j realJIRAClient) GetIssue(key string) (jira.Issue, error) {
 func (j realJIRAClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
 	log := j.cfg.GetLogger()
 
+	issue = writableFields(j.cfg, issue)
+	j.creating.Wait()
+
 	i, res, err := j.request(func() (interface{}, *jira.Response, error) {
 		return j.client.Issue.Create(&issue)
 	})
@@ -240,12 +533,48 @@ func (j realJIRAClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
 	return *is, nil
 }
 
+// writableFields returns the issue without the fields which issue-sync may
+// not write, logging a warning for each of them. The fields of the given
+// issue are left as they are.
+func writableFields(cfg config.Config, issue jira.Issue) jira.Issue {
+	if issue.Fields == nil {
+		return issue
+	}
+
+	var dropped []string
+	for key := range issue.Fields.Unknowns {
+		if !cfg.IsFieldWritable(key) {
+			dropped = append(dropped, key)
+		}
+	}
+	if len(dropped) == 0 {
+		return issue
+	}
+
+	log := cfg.GetLogger()
+	sort.Strings(dropped)
+
+	fields := *issue.Fields
+	fields.Unknowns = map[string]interface{}{}
+	for key, value := range issue.Fields.Unknowns {
+		fields.Unknowns[key] = value
+	}
+	for _, key := range dropped {
+		log.Warnf("Not writing field %s of JIRA issue %s, which isn't in the writable fields", key, issue.Key)
+		delete(fields.Unknowns, key)
+	}
+	issue.Fields = &fields
+	return issue
+}
+
 // UpdateIssue updates a given issue (identified by the Key field of the provided
 // issue object) with the fields on the provided issue. It returns the updated
 // issue as it exists on JIRA.
 func (j realJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
 	log := j.cfg.GetLogger()
 
+	issue = writableFields(j.cfg, issue)
+
 	i, res, err := j.request(func() (interface{}, *jira.Response, error) {
 		return j.client.Issue.Update(&issue)
 	})
@@ -262,112 +591,748 @@ func (j realJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
 	return *is, nil
 }
 
+// ResolveSprint finds the sprint with the given name, or the active sprint if
+// the name is "active", on the configured agile board. It returns the ID of
+// the sprint, and false if no such sprint exists.
+func (j realJIRAClient) ResolveSprint(name string) (int, bool, error) {
+	return resolveSprint(j.cfg, j.client, j.request, name)
+}
+
+// UserExists returns whether there is a JIRA user with the given username.
+func (j realJIRAClient) UserExists(name string) (bool, error) {
+	return userExists(j.cfg, j.client, j.request, name)
+}
+
+// userExists implements UserExists for both JIRA clients, making the
+// requests through the provided request function.
+func userExists(cfg config.Config, client jira.Client, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error), name string) (bool, error) {
+	log := cfg.GetLogger()
+
+	_, res, err := request(func() (interface{}, *jira.Response, error) {
+		u, res, err := client.User.Get(name)
+		// A missing user won't appear by retrying, so stop the backoff.
+		if res != nil && res.StatusCode == http.StatusNotFound {
+			return nil, res, nil
+		}
+		return u, res, err
+	})
+	if err != nil {
+		log.Errorf("Error retrieving JIRA user %s: %v", name, err)
+		return false, getErrorBody(cfg, res)
+	}
+
+	return res == nil || res.StatusCode != http.StatusNotFound, nil
+}
+
+// resolveSprint implements ResolveSprint for both JIRA clients, making the
+// requests through the provided request function.
+func resolveSprint(cfg config.Config, client jira.Client, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error), name string) (int, bool, error) {
+	log := cfg.GetLogger()
+
+	boardID := cfg.GetSprintBoard()
+	if boardID == "" {
+		b, res, err := request(func() (interface{}, *jira.Response, error) {
+			return client.Board.GetAllBoards(&jira.BoardListOptions{
+				BoardType:      "scrum",
+				ProjectKeyOrID: cfg.GetProjectKey(),
+			})
+		})
+		if err != nil {
+			log.Errorf("Error retrieving JIRA boards: %v", err)
+			return 0, false, getErrorBody(cfg, res)
+		}
+		boards, ok := b.(*jira.BoardsList)
+		if !ok {
+			log.Errorf("Get JIRA boards did not return boards! Got: %v", b)
+			return 0, false, fmt.Errorf("get JIRA boards failed: expected *jira.BoardsList; got %T", b)
+		}
+		if len(boards.Values) == 0 {
+			log.Warnf("No scrum board found for JIRA project %s", cfg.GetProjectKey())
+			return 0, false, nil
+		}
+		boardID = fmt.Sprint(boards.Values[0].ID)
+	}
+
+	s, res, err := request(func() (interface{}, *jira.Response, error) {
+		return client.Board.GetAllSprints(boardID)
+	})
+	if err != nil {
+		log.Errorf("Error retrieving JIRA sprints: %v", err)
+		return 0, false, getErrorBody(cfg, res)
+	}
+	sprints, ok := s.([]jira.Sprint)
+	if !ok {
+		log.Errorf("Get JIRA sprints did not return sprints! Got: %v", s)
+		return 0, false, fmt.Errorf("get JIRA sprints failed: expected []jira.Sprint; got %T", s)
+	}
+
+	for _, sprint := range sprints {
+		if (name == "active" && sprint.State == "active") || sprint.Name == name {
+			return sprint.ID, true, nil
+		}
+	}
+
+	return 0, false, nil
+}
+
+// CommentMarker returns the hidden marker which identifies a JIRA comment as
+// the copy of the GitHub comment with the given ID. It is a JIRA anchor macro,
+// which isn't rendered, named after the configured comment marker.
+func CommentMarker(cfg config.Config, id int) string {
+	return commentPartMarker(cfg, id, 1)
+}
+
+// commentPartMarker returns the marker of a part of a GitHub comment split
+// into several JIRA comments. The first part has the same marker as a whole
+// comment; the others also hold their part number.
+func commentPartMarker(cfg config.Config, id, part int) string {
+	if part == 1 {
+		return fmt.Sprintf("{anchor:%s-%d}", cfg.GetCommentMarker(), id)
+	}
+	return fmt.Sprintf("{anchor:%s-%d-%d}", cfg.GetCommentMarker(), id, part)
+}
+
+// ParseCommentMarker looks for a marker created by CommentMarker at the start
+// of a JIRA comment body. It returns the GitHub comment ID and the rest of the
+// body, or false if the body has no marker.
+func ParseCommentMarker(cfg config.Config, body string) (int, string, bool) {
+	id, _, rest, ok := parseCommentPartMarker(cfg, body)
+	return id, rest, ok
+}
+
+// ParseCommentPart returns the part of its GitHub comment a JIRA comment
+// holds, counting from 1, which is 1 unless the comment was split.
+func ParseCommentPart(cfg config.Config, body string) int {
+	_, part, _, ok := parseCommentPartMarker(cfg, body)
+	if !ok {
+		return 1
+	}
+	return part
+}
+
+// parseCommentPartMarker parses a marker created by commentPartMarker,
+// returning the GitHub comment ID, the part and the rest of the body.
+func parseCommentPartMarker(cfg config.Config, body string) (int, int, string, bool) {
+	prefix := fmt.Sprintf("{anchor:%s-", cfg.GetCommentMarker())
+	if !strings.HasPrefix(body, prefix) {
+		return 0, 0, body, false
+	}
+
+	end := strings.Index(body, "}")
+	if end < 0 {
+		return 0, 0, body, false
+	}
+
+	ids := strings.SplitN(body[len(prefix):end], "-", 2)
+	id, err := strconv.Atoi(ids[0])
+	if err != nil {
+		return 0, 0, body, false
+	}
+	part := 1
+	if len(ids) == 2 {
+		if part, err = strconv.Atoi(ids[1]); err != nil || part < 1 {
+			return 0, 0, body, false
+		}
+	}
+
+	return id, part, body[end+1:], true
+}
+
+// CommentBody returns the body of a GitHub comment as it is copied to JIRA.
+// The configured rewrite rules are applied first. Replies, which start by
+// quoting an earlier comment, are prefixed with the configured reply
+// indicator, if any, and @mentions are converted.
+func CommentBody(cfg config.Config, comment github.IssueComment) string {
+	body := convert.ReplaceMentions(cfg.RewriteBody(comment.GetBody()), cfg.GetUserMap())
+	if indicator := cfg.GetReplyIndicator(); indicator != "" && isReply(body) {
+		return indicator + " " + body
+	}
+	return body
+}
+
+// isReply returns whether a comment body starts with a Markdown quote.
+func isReply(body string) bool {
+	return strings.HasPrefix(strings.TrimLeft(body, " \t\r\n"), ">")
+}
+
+// TransitionIssue applies each of the named transitions to a JIRA issue in
+// turn, re-reading the transitions available after each step as they depend
+// on the issue's new status. If a transition isn't available, the path is
+// impossible from the issue's status; this is logged and the path stopped.
+func (j realJIRAClient) TransitionIssue(issue jira.Issue, path []string) error {
+	log := j.cfg.GetLogger()
+
+	for _, name := range path {
+		transition, ok, err := findTransition(j.cfg, j.client, j.request, issue, name)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return nil
+		}
+
+		payload, missing := transitionPayload(j.cfg, transition)
+
+		// The JIRA API we're using drops the response of a failed transition,
+		// which holds the reason, so we build the request ourselves.
+		req, err := j.client.NewRequest("POST", fmt.Sprintf("rest/api/2/issue/%s/transitions", issue.ID), payload)
+		if err != nil {
+			log.Errorf("Error creating transition request: %s", err)
+			return err
+		}
+
+		_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+			if err := rewind(req); err != nil {
+				return nil, nil, err
+			}
+			res, err := j.client.Do(req, nil)
+			return nil, res, err
+		})
+		if err != nil {
+			log.Errorf("Error applying transition %s to JIRA issue %s: %v", name, issue.Key, err)
+			err := responseError(j.cfg, res)
+			if _, forbidden := err.(ForbiddenError); !forbidden && len(missing) > 0 {
+				return fmt.Errorf("transition %s of JIRA issue %s requires the fields %s, which aren't set in the transition fields option: %v", name, issue.Key, strings.Join(missing, ", "), err)
+			}
+			return err
+		}
+
+		log.Debugf("Applied transition %s to JIRA issue %s", name, issue.Key)
+	}
+
+	return nil
+}
+
+// findTransition returns the transition with the given name (ignoring case)
+// currently available on a JIRA issue, and false, after logging the
+// available ones, if there is none.
+func findTransition(cfg config.Config, client jira.Client, request func(func() (interface{}, *jira.Response, error)) (interface{}, *jira.Response, error), issue jira.Issue, name string) (jira.Transition, bool, error) {
+	log := cfg.GetLogger()
+
+	t, res, err := request(func() (interface{}, *jira.Response, error) {
+		return client.Issue.GetTransitions(issue.ID)
+	})
+	if err != nil {
+		log.Errorf("Error retrieving transitions of JIRA issue %s: %v", issue.Key, err)
+		return jira.Transition{}, false, getErrorBody(cfg, res)
+	}
+	transitions, ok := t.([]jira.Transition)
+	if !ok {
+		log.Errorf("Get JIRA transitions did not return transitions! Got: %v", t)
+		return jira.Transition{}, false, fmt.Errorf("get JIRA transitions failed: expected []jira.Transition; got %T", t)
+	}
+
+	names := make([]string, len(transitions))
+	for i, transition := range transitions {
+		if strings.EqualFold(transition.Name, name) {
+			return transition, true, nil
+		}
+		names[i] = transition.Name
+	}
+
+	log.Warnf("Transition %s is not available on JIRA issue %s (available: %s); stopping", name, issue.Key, strings.Join(names, ", "))
+	return jira.Transition{}, false, nil
+}
+
+// transitionPayload builds the request applying a transition to a JIRA issue,
+// with the field values configured for the transition. It also returns the
+// keys of the fields the transition requires which aren't configured, sorted.
+// JIRA may still fill these with default values, so they only explain why a
+// transition failed.
+func transitionPayload(cfg config.Config, transition jira.Transition) (map[string]interface{}, []string) {
+	values := cfg.GetTransitionFields(transition.Name)
+
+	var missing []string
+	for key, field := range transition.Fields {
+		if _, ok := values[key]; field.Required && !ok {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+
+	payload := map[string]interface{}{
+		"transition": jira.TransitionPayload{ID: transition.ID},
+	}
+	if len(values) > 0 {
+		payload["fields"] = values
+	}
+	return payload, missing
+}
+
 // maxBodyLength is the maximum length of a JIRA comment body, which is currently
 // 2^15-1.
-const maxBodyLength = 1 << 15
+const maxBodyLength = 1<<15 - 1
+
+// truncateBody cuts a JIRA comment body longer than the maximum length down
+// to it, at the last character boundary so that no character is split.
+func truncateBody(body string) string {
+	if len(body) <= maxBodyLength {
+		return body
+	}
+	i := maxBodyLength
+	for i > 0 && !utf8.RuneStart(body[i]) {
+		i--
+	}
+	return body[:i]
+}
+
+// commentHeaderReserve is the room left for the header in each JIRA comment
+// of a split GitHub comment.
+const commentHeaderReserve = 1 << 10
+
+// commentPayload builds the JIRA comment copied from a GitHub comment by the
+// given user on the given issue: a hidden marker, a header linking to the
+// GitHub comment and its author, and the body, truncated to the maximum length.
+func commentPayload(cfg config.Config, issue jira.Issue, comment github.IssueComment, user github.User) jira.Comment {
+	body := truncateBody(commentHeader(cfg, issue, comment, user, 1, 1) + CommentBody(cfg, comment))
+
+	return jira.Comment{
+		Body: body,
+	}
+}
+
+// editedCommentPayload builds the JIRA comment replacing the copy of an edited
+// GitHub comment, whose ID on the issue is given. In the append comment edit
+// mode, the body of the copy, and the history of its earlier edits, are kept
+// after the new body; the issue must then hold its comments.
+func editedCommentPayload(cfg config.Config, issue jira.Issue, id string, comment github.IssueComment, user github.User) jira.Comment {
+	jComment := commentPayload(cfg, issue, comment, user)
+	if cfg.GetCommentEdits() != config.CommentEditsAppend || issue.Fields == nil || issue.Fields.Comments == nil {
+		return jComment
+	}
+
+	for _, previous := range issue.Fields.Comments.Comments {
+		if previous.ID != id {
+			continue
+		}
+		// The previous body follows its header, which ends the first line.
+		end := strings.Index(previous.Body, ":\n\n")
+		if end < 0 {
+			break
+		}
+		old, history := splitCommentHistory(cfg, previous.Body[end+3:])
+		jComment.Body = fmt.Sprintf("%s%s\n----\nEdited on GitHub at %s. Previous version:\n{quote}%s{quote}%s",
+			jComment.Body, commentHistoryMarker(cfg), comment.GetUpdatedAt().Format(commentDateFormat), old, history)
+		jComment.Body = truncateBody(jComment.Body)
+		break
+	}
+	return jComment
+}
+
+// commentHistoryMarker returns the hidden marker separating the body of a
+// JIRA comment from the history of its edits.
+func commentHistoryMarker(cfg config.Config) string {
+	return fmt.Sprintf("\n\n{anchor:%s-history}", cfg.GetCommentMarker())
+}
+
+// splitCommentHistory splits the body of a JIRA comment after its header into
+// the current body and the history of its edits, if it has one.
+func splitCommentHistory(cfg config.Config, body string) (string, string) {
+	i := strings.Index(body, commentHistoryMarker(cfg))
+	if i < 0 {
+		return body, ""
+	}
+	return body[:i], body[i+len(commentHistoryMarker(cfg)):]
+}
+
+// StripCommentHistory returns the body of a JIRA comment after its header
+// without the history of its edits, i.e. the copy of the current body of the
+// GitHub comment.
+func StripCommentHistory(cfg config.Config, body string) string {
+	body, _ = splitCommentHistory(cfg, body)
+	return body
+}
+
+// commentPayloads builds the JIRA comments copied from a GitHub comment, in
+// order: one, as built by commentPayload, unless the comment is split.
+func commentPayloads(cfg config.Config, issue jira.Issue, comment github.IssueComment, user github.User) []jira.Comment {
+	chunks := CommentChunks(cfg, CommentBody(cfg, comment))
+	if len(chunks) == 1 {
+		return []jira.Comment{commentPayload(cfg, issue, comment, user)}
+	}
+
+	comments := make([]jira.Comment, len(chunks))
+	for i, chunk := range chunks {
+		comments[i] = jira.Comment{Body: truncateBody(commentHeader(cfg, issue, comment, user, i+1, len(chunks)) + chunk)}
+	}
+	return comments
+}
+
+// commentHeader returns the start of a JIRA comment copied from a GitHub
+// comment, up to its body. The comments of a split GitHub comment are marked
+// with their part.
+func commentHeader(cfg config.Config, issue jira.Issue, comment github.IssueComment, user github.User, part, parts int) string {
+	header := commentPartMarker(cfg, comment.GetID(), part)
+	header = fmt.Sprintf("%sComment [(ID %d)|%s]", header, comment.GetID(), comment.GetHTMLURL())
+	header = fmt.Sprintf("%s from GitHub user [%s|%s]", header, user.GetLogin(), user.GetHTMLURL())
+	if user.GetName() != "" {
+		header = fmt.Sprintf("%s (%s)", header, user.GetName())
+	}
+	if indicator := cfg.GetReporterIndicator(); indicator != "" && isReporter(cfg, issue, user) {
+		header = fmt.Sprintf("%s %s", header, indicator)
+	}
+	header = fmt.Sprintf("%s at %s", header, comment.CreatedAt.Format(commentDateFormat))
+	if parts > 1 {
+		header = fmt.Sprintf("%s (part %d of %d)", header, part, parts)
+	}
+	return header + ":\n\n"
+}
+
+// CommentChunks splits the body of a GitHub comment too long for a JIRA
+// comment into the bodies of the JIRA comments it is copied to, if the
+// `chunk-comments` option is set. The body is split before a paragraph break,
+// line break or space where possible, so the chunks read naturally and JIRA
+// doesn't trim whitespace from their ends. Otherwise the body is returned
+// whole, to be truncated.
+func CommentChunks(cfg config.Config, body string) []string {
+	size := maxBodyLength - commentHeaderReserve
+	if !cfg.IsChunkingComments() || len(body) <= size {
+		return []string{body}
+	}
+
+	var chunks []string
+	for len(body) > size {
+		cut := chunkBoundary(body, size)
+		chunks = append(chunks, body[:cut])
+		body = body[cut:]
+	}
+	return append(chunks, body)
+}
+
+// chunkBoundary returns where to cut the first chunk of at most size bytes
+// from a longer body: before the last separator in its second half, or else
+// at the last character boundary.
+func chunkBoundary(body string, size int) int {
+	for _, sep := range []string{"\n\n", "\n", " "} {
+		if i := strings.LastIndex(body[:size], sep); i > size/2 {
+			return i
+		}
+	}
+
+	i := size
+	for i > 0 && !utf8.RuneStart(body[i]) {
+		i--
+	}
+	return i
+}
+
+// isReporter returns whether a GitHub user is the author of the GitHub issue
+// of a JIRA issue, as stored in its GitHub Reporter field.
+func isReporter(cfg config.Config, issue jira.Issue, user github.User) bool {
+	if issue.Fields == nil || !cfg.IsFieldSynced(config.SyncReporter) {
+		return false
+	}
+	reporter, err := issue.Fields.Unknowns.String(cfg.GetFieldKey(config.GitHubReporter))
+	return err == nil && reporter != "" && reporter == user.GetLogin()
+}
+
+// commentUpdate is the payload of a request updating the body of a comment.
+type commentUpdate struct {
+	Body string `json:"body"`
+}
+
+// commentURL returns the API URL of a comment on a JIRA issue.
+func commentURL(issue jira.Issue, id string) string {
+	return fmt.Sprintf("rest/api/2/issue/%s/comment/%s", issue.Key, id)
+}
+
+// requestPayload builds the request the JIRA client would send, without
+// sending it, and returns its serialized body. It allows dry runs to check
+// and print the exact payloads of the requests they skip.
+func requestPayload(client jira.Client, method, url string, body interface{}) (string, error) {
+	req, err := client.NewRequest(method, url, body)
+	if err != nil {
+		return "", err
+	}
+	if req.Body == nil {
+		return "", nil
+	}
+	defer req.Body.Close()
+
+	b, err := ioutil.ReadAll(req.Body)
+	return string(b), err
+}
+
+// rewind resets the body of a request built by hand before each attempt to
+// send it, since sending a request consumes its body; otherwise retries would
+// send an empty body.
+func rewind(req *http.Request) error {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return err
+	}
+	req.Body = body
+	return nil
+}
 
 // CreateComment adds a comment to the provided JIRA issue using the fields from
-// the provided GitHub comment. It then returns the created comment.
+// the provided GitHub comment. It then returns the created comment. A comment
+// split into several JIRA comments is created part by part, and the first
+// part is returned.
 func (j realJIRAClient) CreateComment(issue jira.Issue, comment github.IssueComment, github ghClient.GitHubClient) (jira.Comment, error) {
 	log := j.cfg.GetLogger()
 
-	user, err := github.GetUser(comment.User.GetLogin())
+	user, err := getCommentUser(j.cfg, comment, github)
 	if err != nil {
 		return jira.Comment{}, err
 	}
 
-	body := fmt.Sprintf("Comment [(ID %d)|%s]", comment.GetID(), comment.GetHTMLURL())
-	body = fmt.Sprintf("%s from GitHub user [%s|%s]", body, user.GetLogin(), user.GetHTMLURL())
-	if user.GetName() != "" {
-		body = fmt.Sprintf("%s (%s)", body, user.GetName())
+	var first jira.Comment
+	for i, jComment := range commentPayloads(j.cfg, issue, comment, user) {
+		jComment := jComment
+		com, res, err := j.request(func() (interface{}, *jira.Response, error) {
+			return j.client.Issue.AddComment(issue.ID, &jComment)
+		})
+		if err != nil {
+			log.Errorf("Error creating JIRA comment on issue %s. Error: %v", issue.Key, err)
+			return jira.Comment{}, responseError(j.cfg, res)
+		}
+		co, ok := com.(*jira.Comment)
+		if !ok {
+			log.Errorf("Create JIRA comment did not return comment! Got: %v", com)
+			return jira.Comment{}, fmt.Errorf("Create JIRA comment failed: expected *jira.Comment; got %T", com)
+		}
+		if i == 0 {
+			first = *co
+		}
 	}
-	body = fmt.Sprintf(
-		"%s at %s:\n\n%s",
-		body,
-		comment.CreatedAt.Format(commentDateFormat),
-		comment.GetBody(),
-	)
+	return first, nil
+}
+
+// UpdateComment updates a comment (identified by the `id` parameter) on a given
+// JIRA with a new body from the fields of the given GitHub comment. It returns
+// the updated comment.
+func (j realJIRAClient) UpdateComment(issue jira.Issue, id string, comment github.IssueComment, github ghClient.GitHubClient) (jira.Comment, error) {
+	log := j.cfg.GetLogger()
 
-	if len(body) >= maxBodyLength {
-		body = body[:maxBodyLength]
+	user, err := getCommentUser(j.cfg, comment, github)
+	if err != nil {
+		return jira.Comment{}, err
 	}
 
-	jComment := jira.Comment{
-		Body: body,
+	// As it is, the JIRA API we're using doesn't have any way to update comments natively.
+	// So, we have to build the request ourselves.
+	req, err := j.client.NewRequest("PUT", commentURL(issue, id), commentUpdate{Body: editedCommentPayload(j.cfg, issue, id, comment, user).Body})
+	if err != nil {
+		log.Errorf("Error creating comment update request: %s", err)
+		return jira.Comment{}, err
 	}
 
 	com, res, err := j.request(func() (interface{}, *jira.Response, error) {
-		return j.client.Issue.AddComment(issue.ID, &jComment)
+		if err := rewind(req); err != nil {
+			return nil, nil, err
+		}
+		co := new(jira.Comment)
+		res, err := j.client.Do(req, co)
+		return co, res, err
 	})
 	if err != nil {
-		log.Errorf("Error creating JIRA comment on issue %s. Error: %v", issue.Key, err)
-		return jira.Comment{}, getErrorBody(j.cfg, res)
+		log.Errorf("Error updating comment: %v", err)
+		return jira.Comment{}, responseError(j.cfg, res)
 	}
 	co, ok := com.(*jira.Comment)
 	if !ok {
-		log.Errorf("Create JIRA comment did not return comment! Got: %v", com)
-		return jira.Comment{}, fmt.Errorf("Create JIRA comment failed: expected *jira.Comment; got %T", com)
+		log.Errorf("Update JIRA comment did not return comment! Got: %v", com)
+		return jira.Comment{}, fmt.Errorf("Update JIRA comment failed: expected *jira.Comment; got %T", com)
 	}
 	return *co, nil
 }
 
-// UpdateComment updates a comment (identified by the `id` parameter) on a given
-// JIRA with a new body from the fields of the given GitHub comment. It returns
-// the updated comment.
-func (j realJIRAClient) UpdateComment(issue jira.Issue, id string, comment github.IssueComment, github ghClient.GitHubClient) (jira.Comment, error) {
+// DeleteComment deletes a comment from the provided JIRA issue.
+func (j realJIRAClient) DeleteComment(issue jira.Issue, id string) error {
 	log := j.cfg.GetLogger()
 
-	user, err := github.GetUser(comment.User.GetLogin())
+	// As with updates, the JIRA API we're using can't delete comments natively.
+	req, err := j.client.NewRequest("DELETE", commentURL(issue, id), nil)
 	if err != nil {
-		return jira.Comment{}, err
+		log.Errorf("Error creating comment delete request: %s", err)
+		return err
 	}
 
-	body := fmt.Sprintf("Comment [(ID %d)|%s]", comment.GetID(), comment.GetHTMLURL())
-	body = fmt.Sprintf("%s from GitHub user [%s|%s]", body, user.GetLogin(), user.GetHTMLURL())
-	if user.GetName() != "" {
-		body = fmt.Sprintf("%s (%s)", body, user.GetName())
+	_, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		res, err := j.client.Do(req, nil)
+		return nil, res, err
+	})
+	if err != nil {
+		log.Errorf("Error deleting JIRA comment %s on issue %s. Error: %v", id, issue.Key, err)
+		return responseError(j.cfg, res)
 	}
-	body = fmt.Sprintf(
-		"%s at %s:\n\n%s",
-		body,
-		comment.CreatedAt.Format(commentDateFormat),
-		comment.GetBody(),
-	)
 
-	if len(body) < maxBodyLength {
-		body = body[:maxBodyLength]
+	return nil
+}
+
+// CreateEventComment adds a short comment to the provided JIRA issue describing
+// the provided GitHub timeline event. It then returns the created comment.
+func (j realJIRAClient) CreateEventComment(issue jira.Issue, event github.Timeline) (jira.Comment, error) {
+	log := j.cfg.GetLogger()
+
+	jComment := jira.Comment{
+		Body: eventBody(event),
 	}
 
-	// As it is, the JIRA API we're using doesn't have any way to update comments natively.
-	// So, we have to build the request ourselves.
-	request := struct {
-		Body string `json:"body"`
-	}{
-		Body: body,
+	com, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		return j.client.Issue.AddComment(issue.ID, &jComment)
+	})
+	if err != nil {
+		log.Errorf("Error creating JIRA event comment on issue %s. Error: %v", issue.Key, err)
+		return jira.Comment{}, responseError(j.cfg, res)
+	}
+	co, ok := com.(*jira.Comment)
+	if !ok {
+		log.Errorf("Create JIRA event comment did not return comment! Got: %v", com)
+		return jira.Comment{}, fmt.Errorf("Create JIRA event comment failed: expected *jira.Comment; got %T", com)
+	}
+	return *co, nil
+}
+
+// CreateStateComment adds a short comment to the provided JIRA issue noting
+// the current state of its GitHub issue, after the state has changed.
+func (j realJIRAClient) CreateStateComment(issue jira.Issue, ghIssue github.Issue) (jira.Comment, error) {
+	log := j.cfg.GetLogger()
+
+	jComment := jira.Comment{
+		Body: stateBody(ghIssue),
 	}
 
-	req, err := j.client.NewRequest("PUT", fmt.Sprintf("rest/api/2/issue/%s/comment/%s", issue.Key, id), request)
+	com, res, err := j.request(func() (interface{}, *jira.Response, error) {
+		return j.client.Issue.AddComment(issue.ID, &jComment)
+	})
 	if err != nil {
-		log.Errorf("Error creating comment update request: %s", err)
-		return jira.Comment{}, err
+		log.Errorf("Error creating JIRA state comment on issue %s. Error: %v", issue.Key, err)
+		return jira.Comment{}, responseError(j.cfg, res)
+	}
+	co, ok := com.(*jira.Comment)
+	if !ok {
+		log.Errorf("Create JIRA state comment did not return comment! Got: %v", com)
+		return jira.Comment{}, fmt.Errorf("Create JIRA state comment failed: expected *jira.Comment; got %T", com)
+	}
+	return *co, nil
+}
+
+// CreateConflictComment adds a comment to the provided JIRA issue noting that
+// the description of its GitHub issue wasn't copied, as the JIRA description
+// was edited since the last sync.
+func (j realJIRAClient) CreateConflictComment(issue jira.Issue, ghIssue github.Issue) (jira.Comment, error) {
+	log := j.cfg.GetLogger()
+
+	jComment := jira.Comment{
+		Body: conflictBody(ghIssue),
 	}
 
 	com, res, err := j.request(func() (interface{}, *jira.Response, error) {
-		res, err := j.client.Do(req, nil)
-		return nil, res, err
+		return j.client.Issue.AddComment(issue.ID, &jComment)
 	})
 	if err != nil {
-		log.Errorf("Error updating comment: %v", err)
-		return jira.Comment{}, getErrorBody(j.cfg, res)
+		log.Errorf("Error creating JIRA conflict comment on issue %s. Error: %v", issue.Key, err)
+		return jira.Comment{}, responseError(j.cfg, res)
 	}
 	co, ok := com.(*jira.Comment)
 	if !ok {
-		log.Errorf("Update JIRA comment did not return comment! Got: %v", com)
-		return jira.Comment{}, fmt.Errorf("Update JIRA comment failed: expected *jira.Comment; got %T", com)
+		log.Errorf("Create JIRA conflict comment did not return comment! Got: %v", com)
+		return jira.Comment{}, fmt.Errorf("Create JIRA conflict comment failed: expected *jira.Comment; got %T", com)
 	}
 	return *co, nil
 }
 
+// getCommentUser retrieves the GitHub user who authored a comment. If the
+// user no longer exists (e.g. a deleted account) and the fallback is enabled,
+// the user embedded in the comment is used instead, so that one missing
+// account doesn't block the comments of a whole issue.
+func getCommentUser(cfg config.Config, comment github.IssueComment, gh ghClient.GitHubClient) (github.User, error) {
+	log := cfg.GetLogger()
+
+	user, err := gh.GetUser(comment.User.GetLogin())
+	if err == ghClient.ErrUserNotFound && cfg.IsGitHubUserFallback() {
+		log.Warnf("GitHub user %s not found; using the login from comment %d", comment.User.GetLogin(), comment.GetID())
+		return *comment.User, nil
+	}
+
+	return user, err
+}
+
+// eventBody renders a GitHub timeline event as the body of a JIRA comment,
+// e.g. "GitHub event (ID 42): closed by [user|url] at <time>". The ID in the
+// header is used to avoid syncing the same event twice.
+func eventBody(event github.Timeline) string {
+	actor := fmt.Sprintf("[%s|%s]", event.Actor.GetLogin(), event.Actor.GetHTMLURL())
+
+	var desc string
+	switch event.GetEvent() {
+	case "labeled":
+		desc = fmt.Sprintf("label '%s' added by %s", event.Label.GetName(), actor)
+	case "unlabeled":
+		desc = fmt.Sprintf("label '%s' removed by %s", event.Label.GetName(), actor)
+	case "assigned":
+		desc = fmt.Sprintf("assigned to %s by %s", event.Assignee.GetLogin(), actor)
+	case "unassigned":
+		desc = fmt.Sprintf("unassigned from %s by %s", event.Assignee.GetLogin(), actor)
+	case "milestoned":
+		desc = fmt.Sprintf("added to milestone '%s' by %s", event.Milestone.GetTitle(), actor)
+	case "demilestoned":
+		desc = fmt.Sprintf("removed from milestone '%s' by %s", event.Milestone.GetTitle(), actor)
+	case "renamed":
+		desc = fmt.Sprintf("renamed from '%s' to '%s' by %s", event.Rename.GetFrom(), event.Rename.GetTo(), actor)
+	case "referenced":
+		desc = fmt.Sprintf("referenced by %s in commit %s", actor, event.GetCommitID())
+	case "closed":
+		desc = fmt.Sprintf("closed by %s", actor)
+		if event.GetCommitID() != "" {
+			desc = fmt.Sprintf("%s in commit %s", desc, event.GetCommitID())
+		}
+	default:
+		desc = fmt.Sprintf("%s by %s", event.GetEvent(), actor)
+	}
+
+	return fmt.Sprintf("GitHub event (ID %d): %s at %s", event.GetID(), desc, event.GetCreatedAt().Format(commentDateFormat))
+}
+
+// stateBody renders the state change of a GitHub issue as the body of a JIRA
+// comment, e.g. "GitHub issue [#42|url] closed at <time> by [user|url]". The
+// user who reopened an issue isn't known, so only the time is given.
+func stateBody(ghIssue github.Issue) string {
+	body := fmt.Sprintf("GitHub issue [#%d|%s]", ghIssue.GetNumber(), ghIssue.GetHTMLURL())
+
+	if ghIssue.GetState() != "closed" {
+		return fmt.Sprintf("%s reopened at %s", body, ghIssue.GetUpdatedAt().Format(commentDateFormat))
+	}
+
+	body = fmt.Sprintf("%s closed at %s", body, ghIssue.GetClosedAt().Format(commentDateFormat))
+	if ghIssue.ClosedBy != nil {
+		body = fmt.Sprintf("%s by [%s|%s]", body, ghIssue.ClosedBy.GetLogin(), ghIssue.ClosedBy.GetHTMLURL())
+	}
+	return body
+}
+
+// ConflictMarker returns the text which identifies the JIRA comment noting a
+// conflict with the current description of a GitHub issue, so that it is
+// only posted once per GitHub revision of the description.
+func ConflictMarker(ghIssue github.Issue) string {
+	sum := sha256.Sum256([]byte(ghIssue.GetBody()))
+	return fmt.Sprintf("(description revision %x)", sum[:4])
+}
+
+// conflictBody renders the JIRA comment noting that the description of a
+// GitHub issue wasn't copied, e.g. "GitHub issue [#42|url] description
+// (description revision 0a1b2c3d) was not copied...".
+func conflictBody(ghIssue github.Issue) string {
+	return fmt.Sprintf("GitHub issue [#%d|%s] description %s was not copied, as the JIRA description was edited since the last sync. Merge the changes by hand, or sync with --force to overwrite the JIRA description.", ghIssue.GetNumber(), ghIssue.GetHTMLURL(), ConflictMarker(ghIssue))
+}
+
+// RefreshFieldIDs resolves the IDs of the custom fields again, in case they
+// were recreated since they were last resolved.
+func (j realJIRAClient) RefreshFieldIDs() error {
+	return j.cfg.RefreshFieldIDs(j.client)
+}
+
+// Now returns the current time in UTC, by the JIRA server's clock if it is
+// configured to be used.
+func (j realJIRAClient) Now() time.Time {
+	return j.clock.now(j.cfg.IsUsingJIRAServerClock())
+}
+
 // request takes an API function from the JIRA library
 // and calls it with exponential backoff. If the function succeeds, it
 // returns the expected value and the JIRA API response, as well as a nil
@@ -378,10 +1343,20 @@ func (j realJIRAClient) request(f func() (interface{}, *jira.Response, error)) (
 
 	var ret interface{}
 	var res *jira.Response
+	var forbidden error
 
 	op := func() error {
+		j.limiter.Acquire()
+		defer j.limiter.Release()
+
 		var err error
 		ret, res, err = f()
+		j.clock.observe(res)
+		// Permissions don't change by retrying, so stop the backoff.
+		if err != nil && res != nil && res.StatusCode == http.StatusForbidden {
+			forbidden = err
+			return nil
+		}
 		return err
 	}
 
@@ -398,6 +1373,9 @@ func (j realJIRAClient) request(f func() (interface{}, *jira.Response, error)) (
 		log.Errorf("unable to complete jira request; retrying in %v: %v", duration, err)
 	})
 
+	if forbidden != nil {
+		return ret, res, forbidden
+	}
 	return ret, res, backoffErr
 }
 
@@ -407,15 +1385,16 @@ var newlineReplaceRegex = regexp.MustCompile("\r?\n")
 
 // truncate is a utility function to replace all the newlines in
 // the string with the characters "\n", then truncate it to no
-// more than 50 characters
-func truncate(s string, length int) string {
+// more than `length` characters, followed by the configured notice.
+func truncate(cfg config.Config, s string, length int) string {
 	if s == "" {
 		return "empty"
 	}
 
 	s = newlineReplaceRegex.ReplaceAllString(s, "\\n")
-	if len(s) <= length {
+	r := []rune(s)
+	if len(r) <= length {
 		return s
 	}
-	return fmt.Sprintf("%s...", s[0:length])
+	return string(r[:length]) + cfg.GetTruncateNotice()
 }
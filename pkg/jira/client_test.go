@@ -0,0 +1,917 @@
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	"github.com/innovocloud/issue-sync/pkg/limit"
+)
+
+func TestTruncateBody(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"short", "body", 4},
+		{"maximum", strings.Repeat("a", maxBodyLength), maxBodyLength},
+		{"one over", strings.Repeat("a", maxBodyLength+1), maxBodyLength},
+		// A three-byte character straddles the limit.
+		{"multi-byte", strings.Repeat("a", maxBodyLength-1) + "€€", maxBodyLength - 1},
+		{"multi-byte at the limit", strings.Repeat("a", maxBodyLength-3) + "€€", maxBodyLength},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateBody(tt.body)
+			if len(got) != tt.want {
+				t.Errorf("truncateBody() has length %d, want %d", len(got), tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncateBody() split a character")
+			}
+			if !strings.HasPrefix(tt.body, got) {
+				t.Errorf("truncateBody() isn't a prefix of the body")
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name   string
+		notice string
+		s      string
+		length int
+		want   string
+	}{
+		{"empty", "...", "", 5, "empty"},
+		{"short", "...", "body", 5, "body"},
+		{"at the length", "...", "12345", 5, "12345"},
+		{"truncated", "...", "123456", 5, "12345..."},
+		{"custom notice", "…[truncated]", "123456", 5, "12345…[truncated]"},
+		{"no notice", "", "123456", 5, "12345"},
+		{"newlines", "...", "a\nb\r\nc", 10, "a\\nb\\nc"},
+		{"multi-byte", "...", "€€€€€€", 3, "€€€..."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.NewTestConfig(map[string]interface{}{"truncate-notice": tt.notice})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := truncate(cfg, tt.s, tt.length); got != tt.want {
+				t.Errorf("truncate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventBody(t *testing.T) {
+	created := time.Date(2020, 1, 2, 15, 4, 0, 0, time.UTC)
+	actor := &github.User{Login: github.String("octocat"), HTMLURL: github.String("https://github.com/octocat")}
+	by := "[octocat|https://github.com/octocat]"
+
+	tests := []struct {
+		name  string
+		event github.Timeline
+		want  string
+	}{
+		{"labeled", github.Timeline{Event: github.String("labeled"), Label: &github.Label{Name: github.String("bug")}}, "label 'bug' added by " + by},
+		{"unlabeled", github.Timeline{Event: github.String("unlabeled"), Label: &github.Label{Name: github.String("bug")}}, "label 'bug' removed by " + by},
+		{"assigned", github.Timeline{Event: github.String("assigned"), Assignee: &github.User{Login: github.String("hubot")}}, "assigned to hubot by " + by},
+		{"unassigned", github.Timeline{Event: github.String("unassigned"), Assignee: &github.User{Login: github.String("hubot")}}, "unassigned from hubot by " + by},
+		{"milestoned", github.Timeline{Event: github.String("milestoned"), Milestone: &github.Milestone{Title: github.String("v1")}}, "added to milestone 'v1' by " + by},
+		{"demilestoned", github.Timeline{Event: github.String("demilestoned"), Milestone: &github.Milestone{Title: github.String("v1")}}, "removed from milestone 'v1' by " + by},
+		{"renamed", github.Timeline{Event: github.String("renamed"), Rename: &github.Rename{From: github.String("Old"), To: github.String("New")}}, "renamed from 'Old' to 'New' by " + by},
+		{"referenced", github.Timeline{Event: github.String("referenced"), CommitID: github.String("abc123")}, "referenced by " + by + " in commit abc123"},
+		{"closed", github.Timeline{Event: github.String("closed")}, "closed by " + by},
+		{"closed by a commit", github.Timeline{Event: github.String("closed"), CommitID: github.String("abc123")}, "closed by " + by + " in commit abc123"},
+		{"other", github.Timeline{Event: github.String("locked")}, "locked by " + by},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := tt.event
+			event.ID = github.Int(42)
+			event.Actor = actor
+			event.CreatedAt = &created
+
+			want := "GitHub event (ID 42): " + tt.want + " at 15:04 PM, January 2 2020"
+			if got := eventBody(event); got != want {
+				t.Errorf("eventBody() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestCommentBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		indicator string
+		body      string
+		want      string
+	}{
+		{"no indicator", "", "> Earlier comment\n\nReply", "> Earlier comment\n\nReply"},
+		{"reply", "(reply)", "> Earlier comment\n\nReply", "(reply) > Earlier comment\n\nReply"},
+		{"reply after whitespace", "(reply)", "\n  > Earlier comment", "(reply) \n  > Earlier comment"},
+		{"not a reply", "(reply)", "A comment", "A comment"},
+		{"quote later in body", "(reply)", "A comment\n> quoting", "A comment\n> quoting"},
+		{"empty body", "(reply)", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.NewTestConfig(map[string]interface{}{"reply-indicator": tt.indicator})
+			if err != nil {
+				t.Fatal(err)
+			}
+			comment := github.IssueComment{Body: github.String(tt.body)}
+			if got := CommentBody(cfg, comment); got != tt.want {
+				t.Errorf("CommentBody() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditedCommentPayload(t *testing.T) {
+	user := github.User{Login: github.String("octocat")}
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	comment := func(body string, updated time.Time) github.IssueComment {
+		return github.IssueComment{
+			ID:        github.Int(2),
+			Body:      github.String(body),
+			CreatedAt: &created,
+			UpdatedAt: &updated,
+		}
+	}
+	first := time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2020, 1, 4, 0, 0, 0, 0, time.UTC)
+	// The history follows a single marker, newest edit first.
+	marker, edit := "\n\n{anchor:issue-sync-history}", "\n----\nEdited on GitHub at "
+
+	tests := []struct {
+		name  string
+		mode  string
+		id    string
+		edits []github.IssueComment
+		want  string
+	}{
+		{"replace", config.CommentEditsReplace, "10", []github.IssueComment{comment("Edited", first)}, "Edited"},
+		{"append", config.CommentEditsAppend, "10", []github.IssueComment{comment("Edited", first)},
+			"Edited" + marker + edit + first.Format(commentDateFormat) + ". Previous version:\n{quote}Original{quote}"},
+		{"append twice", config.CommentEditsAppend, "10", []github.IssueComment{comment("Edited", first), comment("Edited again", second)},
+			"Edited again" + marker + edit + second.Format(commentDateFormat) + ". Previous version:\n{quote}Edited{quote}" +
+				edit + first.Format(commentDateFormat) + ". Previous version:\n{quote}Original{quote}"},
+		{"other comment", config.CommentEditsAppend, "11", []github.IssueComment{comment("Edited", first)}, "Edited"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.NewTestConfig(map[string]interface{}{"comment-edits": tt.mode})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The issue holds the JIRA copy of the comment, updated by each edit.
+			previous := commentPayload(cfg, jira.Issue{}, comment("Original", created), user)
+			previous.ID = "10"
+			issue := jira.Issue{Fields: &jira.IssueFields{Comments: &jira.Comments{Comments: []*jira.Comment{&previous}}}}
+			var got jira.Comment
+			for _, edit := range tt.edits {
+				got = editedCommentPayload(cfg, issue, tt.id, edit, user)
+				previous.Body = got.Body
+			}
+
+			header := commentHeader(cfg, issue, tt.edits[0], user, 1, 1)
+			if !strings.HasPrefix(got.Body, header) || got.Body[len(header):] != tt.want {
+				t.Errorf("editedCommentPayload() body = %q, want %q after the header", got.Body, tt.want)
+			}
+			if body := StripCommentHistory(cfg, got.Body[len(header):]); body != *tt.edits[len(tt.edits)-1].Body {
+				t.Errorf("StripCommentHistory() = %q, want the GitHub body %q", body, *tt.edits[len(tt.edits)-1].Body)
+			}
+		})
+	}
+}
+
+func TestCommentReporterIndicator(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		values    map[string]interface{}
+		reporter  string
+		login     string
+		userName  string
+		wantAfter string
+	}{
+		{"reporter", map[string]interface{}{"reporter-indicator": "(reporter)"}, "octocat", "octocat", "", "[octocat|] (reporter) at "},
+		{"reporter with name", map[string]interface{}{"reporter-indicator": "(reporter)"}, "octocat", "octocat", "The Octocat", "(The Octocat) (reporter) at "},
+		{"other user", map[string]interface{}{"reporter-indicator": "(reporter)"}, "octocat", "hubot", "", "[hubot|] at "},
+		{"reporter unknown", map[string]interface{}{"reporter-indicator": "(reporter)"}, "", "octocat", "", "[octocat|] at "},
+		{"no indicator", nil, "octocat", "octocat", "", "[octocat|] at "},
+		{"reporter not synced", map[string]interface{}{
+			"reporter-indicator": "(reporter)",
+			"sync-fields":        []string{config.SyncSummary},
+		}, "octocat", "octocat", "", "[octocat|] at "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]interface{}{"field-ids": map[string]string{"GitHub Reporter": "10005"}}
+			for key, value := range tt.values {
+				values[key] = value
+			}
+			cfg, err := config.NewTestConfig(values)
+			if err != nil {
+				t.Fatal(err)
+			}
+			issue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{Unknowns: map[string]interface{}{}}}
+			if tt.reporter != "" {
+				issue.Fields.Unknowns[cfg.GetFieldKey(config.GitHubReporter)] = tt.reporter
+			}
+			comment := github.IssueComment{ID: github.Int(2), Body: github.String("Body"), CreatedAt: &created}
+			user := github.User{Login: github.String(tt.login)}
+			if tt.userName != "" {
+				user.Name = github.String(tt.userName)
+			}
+
+			body := commentPayload(cfg, issue, comment, user).Body
+			if !strings.Contains(body, tt.wantAfter) {
+				t.Errorf("comment body %q doesn't contain %q", body, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestForbiddenRequests(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		forbidden bool
+		requests  int
+	}{
+		// Permissions don't change by retrying.
+		{"forbidden", http.StatusForbidden, true, 1},
+		{"server error", http.StatusInternalServerError, false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.WriteHeader(tt.status)
+				w.Write([]byte(`{"errorMessages": ["Not allowed"]}`))
+			}))
+			defer server.Close()
+
+			// The backoff retries once, however short the timeout.
+			cfg, err := config.NewTestConfig(map[string]interface{}{"timeout": "1ms"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := jira.NewClient(nil, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			j := realJIRAClient{cfg: cfg, client: *client, limiter: limit.New(1), clock: &serverClock{}}
+
+			err = j.DeleteComment(jira.Issue{ID: "1", Key: "TEST-1"}, "10")
+			if err == nil {
+				t.Fatal("DeleteComment() succeeded")
+			}
+			if _, forbidden := err.(ForbiddenError); forbidden != tt.forbidden {
+				t.Errorf("DeleteComment() error = %#v, want forbidden %v", err, tt.forbidden)
+			}
+			if !strings.Contains(err.Error(), "Not allowed") {
+				t.Errorf("DeleteComment() error = %v, want the response body", err)
+			}
+			if requests != tt.requests {
+				t.Errorf("%d requests made, want %d", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestGetGitHubID(t *testing.T) {
+	cfg, err := config.NewTestConfig(map[string]interface{}{
+		"field-ids": map[string]string{"GitHub ID": "10001"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		value  interface{}
+		absent bool
+		want   int64
+		ok     bool
+	}{
+		{"number", float64(1001), false, 1001, true},
+		{"string", "1001", false, 1001, true},
+		{"decimal string", "1001.0", false, 1001, true},
+		{"absent", nil, true, 0, false},
+		{"null", nil, false, 0, false},
+		{"not a number", "abc", false, 0, false},
+		{"unexpected type", true, false, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{Unknowns: map[string]interface{}{}}}
+			if !tt.absent {
+				issue.Fields.Unknowns[cfg.GetFieldKey(config.GitHubID)] = tt.value
+			}
+
+			id, ok := GetGitHubID(cfg, issue)
+			if id != tt.want || ok != tt.ok {
+				t.Errorf("GetGitHubID() = %d, %v, want %d, %v", id, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+// userClient is a GitHub client whose users can't be retrieved, failing with
+// err.
+type userClient struct {
+	ghClient.GitHubClient
+	err error
+}
+
+func (c userClient) GetUser(login string) (github.User, error) {
+	if c.err != nil {
+		return github.User{}, c.err
+	}
+	return github.User{Login: github.String(login), Name: github.String("The Octocat")}, nil
+}
+
+func TestGetCommentUser(t *testing.T) {
+	unavailable := errors.New("GitHub unavailable")
+
+	tests := []struct {
+		name     string
+		fallback bool
+		err      error
+		wantName string
+		wantErr  error
+	}{
+		{"found", true, nil, "The Octocat", nil},
+		{"missing", true, ghClient.ErrUserNotFound, "", nil},
+		{"missing without fallback", false, ghClient.ErrUserNotFound, "", ghClient.ErrUserNotFound},
+		{"other error", true, unavailable, "", unavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := config.NewTestConfig(map[string]interface{}{"github-user-fallback": tt.fallback})
+			if err != nil {
+				t.Fatal(err)
+			}
+			comment := github.IssueComment{ID: github.Int(1), User: &github.User{Login: github.String("octocat")}}
+
+			user, err := getCommentUser(cfg, comment, userClient{err: tt.err})
+			if err != tt.wantErr {
+				t.Fatalf("getCommentUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if user.GetLogin() != "octocat" || user.GetName() != tt.wantName {
+				t.Errorf("getCommentUser() = %s (%q), want octocat (%q)", user.GetLogin(), user.GetName(), tt.wantName)
+			}
+		})
+	}
+}
+
+func TestRequestConcurrency(t *testing.T) {
+	// Only the JIRA limit applies to JIRA requests.
+	cfg, err := config.NewTestConfig(map[string]interface{}{
+		"github-concurrency": 5,
+		"jira-concurrency":   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := realJIRAClient{cfg: cfg, limiter: limit.New(cfg.GetJIRAConcurrency())}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	inFlight, max := 0, 0
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.request(func() (interface{}, *jira.Response, error) {
+				mu.Lock()
+				inFlight++
+				if inFlight > max {
+					max = inFlight
+				}
+				mu.Unlock()
+				time.Sleep(10 * time.Millisecond)
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+				return nil, nil, nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if max > cfg.GetJIRAConcurrency() {
+		t.Errorf("%d JIRA requests in flight, want at most %d", max, cfg.GetJIRAConcurrency())
+	}
+}
+
+func TestRetryHandBuiltRequests(t *testing.T) {
+	cfg, err := config.NewTestConfig(map[string]interface{}{"timeout": "10s"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	comment := github.IssueComment{
+		ID:        github.Int(2),
+		Body:      github.String("Edited"),
+		User:      &github.User{Login: github.String("octocat")},
+		CreatedAt: &created,
+	}
+	issue := jira.Issue{ID: "1", Key: "TEST-1"}
+
+	tests := []struct {
+		name string
+		do   func(realJIRAClient) error
+	}{
+		{"update comment", func(j realJIRAClient) error {
+			_, err := j.UpdateComment(issue, "10", comment, userClient{})
+			return err
+		}},
+		{"set property", func(j realJIRAClient) error {
+			return j.SetProperty(issue, "test-property", map[string]int{"version": 1})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The server fails the first attempt, after reading its body.
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				if len(bodies) == 1 {
+					http.Error(w, "unavailable", http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"id": "10"}`))
+			}))
+			defer server.Close()
+
+			client, err := jira.NewClient(nil, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			j := realJIRAClient{cfg: cfg, client: *client, limiter: limit.New(1)}
+
+			if err := tt.do(j); err != nil {
+				t.Fatalf("request failed after a retry: %v", err)
+			}
+			if len(bodies) != 2 {
+				t.Fatalf("server received %d requests, want 2", len(bodies))
+			}
+			if bodies[0] == "" || bodies[1] != bodies[0] {
+				t.Errorf("retry sent body %q, want %q", bodies[1], bodies[0])
+			}
+		})
+	}
+}
+
+func TestServerClock(t *testing.T) {
+	tests := []struct {
+		name   string
+		date   string
+		server bool
+		offset time.Duration
+	}{
+		{"local clock", "", false, 0},
+		{"server clock not seen", "", true, 0},
+		{"server clock ahead", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), true, time.Hour},
+		{"server clock behind", time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), true, -time.Hour},
+		{"server clock not used", time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), false, 0},
+		{"invalid date", "yesterday", true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.date != "" {
+					w.Header()["Date"] = []string{tt.date}
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"timeZone": "UTC"}`))
+			}))
+			defer server.Close()
+
+			cfg, err := config.NewTestConfig(map[string]interface{}{"jira-server-clock": tt.server})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := jira.NewClient(nil, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			j := realJIRAClient{cfg: cfg, client: *client, limiter: limit.New(1), clock: &serverClock{}}
+
+			// Any response shows the server's clock.
+			if _, _, err := j.request(func() (interface{}, *jira.Response, error) {
+				req, _ := client.NewRequest("GET", "rest/api/2/myself", nil)
+				res, err := client.Do(req, nil)
+				return nil, res, err
+			}); err != nil {
+				t.Fatal(err)
+			}
+
+			now := j.Now()
+			if now.Location() != time.UTC {
+				t.Errorf("Now() is in %v, want UTC", now.Location())
+			}
+			// The Date header has a precision of a second.
+			if d := now.Sub(time.Now().Add(tt.offset)); d < -2*time.Second || d > 2*time.Second {
+				t.Errorf("Now() is %v off the expected time", d)
+			}
+		})
+	}
+}
+
+func TestListIssuesClauseFallback(t *testing.T) {
+	tests := []struct {
+		name     string
+		clauses  string
+		cfFails  bool
+		wantJQL  []string
+		wantKeys int
+		wantErr  bool
+	}{
+		{"custom field works", `["cf[10001]", "GitHub ID"]`, false,
+			[]string{`project='TEST' AND cf[10001] in (1,2)`}, 1, false},
+		{"clause name fallback", `["cf[10001]", "GitHub ID"]`, true,
+			[]string{`project='TEST' AND cf[10001] in (1,2)`, `project='TEST' AND "GitHub ID" in (1,2)`}, 1, false},
+		{"no clause name", `["cf[10001]"]`, true,
+			[]string{`project='TEST' AND cf[10001] in (1,2)`}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var jqls []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				switch r.URL.Path {
+				case "/rest/api/2/field":
+					w.Write([]byte(`[
+						{"name": "GitHub ID", "clauseNames": ` + tt.clauses + `, "schema": {"type": "number", "customId": 10001}},
+						{"name": "GitHub Number", "schema": {"type": "number", "customId": 10002}}
+					]`))
+				case "/rest/api/2/search":
+					jql := r.URL.Query().Get("jql")
+					jqls = append(jqls, jql)
+					if tt.cfFails && strings.Contains(jql, "cf[") {
+						http.Error(w, `{"errorMessages": ["Field 'cf[10001]' does not exist"]}`, http.StatusBadRequest)
+						return
+					}
+					w.Write([]byte(`{"total": 1, "issues": [{"key": "TEST-1", "fields": {"customfield_10001": 1}}]}`))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			defer server.Close()
+
+			cfg, err := config.NewTestConfig(map[string]interface{}{"timeout": "1ms"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := jira.NewClient(nil, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.RefreshFieldIDs(*client); err != nil {
+				t.Fatal(err)
+			}
+			j := realJIRAClient{cfg: cfg, client: *client, limiter: limit.New(1)}
+
+			issues, err := j.ListIssues([]int{1, 2})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ListIssues() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(issues) != tt.wantKeys {
+				t.Errorf("ListIssues() returned %d issues, want %d", len(issues), tt.wantKeys)
+			}
+			// Failed searches may be retried, so only the distinct queries count.
+			var distinct []string
+			for _, jql := range jqls {
+				if len(distinct) == 0 || distinct[len(distinct)-1] != jql {
+					distinct = append(distinct, jql)
+				}
+			}
+			if !reflect.DeepEqual(distinct, tt.wantJQL) {
+				t.Errorf("searched %q, want %q", distinct, tt.wantJQL)
+			}
+		})
+	}
+}
+
+// workflowServer serves the transitions of a JIRA issue by its status, and
+// moves it to the target status of each transition applied.
+type workflowServer struct {
+	status string
+	// transitions holds the transitions out of each status, by name, to
+	// their target status.
+	transitions map[string]map[string]string
+	applied     []string
+}
+
+func (s *workflowServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/rest/api/2/issue/1/transitions" {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method == "POST" {
+		var payload struct {
+			Transition struct {
+				ID string `json:"id"`
+			} `json:"transition"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		name := payload.Transition.ID
+		to, ok := s.transitions[s.status][name]
+		if !ok {
+			http.Error(w, "transition not available", http.StatusBadRequest)
+			return
+		}
+		s.applied = append(s.applied, name)
+		s.status = to
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Transitions are identified by their name, to keep the test short.
+	var transitions []jira.Transition
+	for name := range s.transitions[s.status] {
+		transitions = append(transitions, jira.Transition{ID: name, Name: name})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"transitions": transitions})
+}
+
+func TestTransitionIssue(t *testing.T) {
+	workflow := map[string]map[string]string{
+		"Done":        {"Reopen": "Reopened"},
+		"Reopened":    {"Start Progress": "In Progress", "Close": "Done"},
+		"In Progress": {"Resolve": "Done"},
+	}
+
+	tests := []struct {
+		name    string
+		path    []string
+		status  string
+		applied []string
+	}{
+		{"one step", []string{"Reopen"}, "Reopened", []string{"Reopen"}},
+		{"several steps", []string{"Reopen", "Start Progress"}, "In Progress", []string{"Reopen", "Start Progress"}},
+		{"names ignore case", []string{"reopen", "START PROGRESS"}, "In Progress", []string{"Reopen", "Start Progress"}},
+		{"impossible step", []string{"Reopen", "Resolve", "Close"}, "Reopened", []string{"Reopen"}},
+		{"impossible first step", []string{"Start Progress", "Resolve"}, "Done", nil},
+		{"empty path", nil, "Done", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := &workflowServer{status: "Done", transitions: workflow}
+			server := httptest.NewServer(handler)
+			defer server.Close()
+
+			cfg, err := config.NewTestConfig(map[string]interface{}{"timeout": "1ms"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := jira.NewClient(nil, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			j := realJIRAClient{cfg: cfg, client: *client}
+
+			if err := j.TransitionIssue(jira.Issue{ID: "1", Key: "TEST-1"}, tt.path); err != nil {
+				t.Fatal(err)
+			}
+			if handler.status != tt.status {
+				t.Errorf("status = %s, want %s", handler.status, tt.status)
+			}
+			if !reflect.DeepEqual(handler.applied, tt.applied) {
+				t.Errorf("transitions applied = %q, want %q", handler.applied, tt.applied)
+			}
+		})
+	}
+}
+
+func TestTransitionFields(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  string
+		applied bool
+		wantErr string
+	}{
+		{"configured", `{"Resolve": {"resolution": {"name": "Done"}}}`, true, ""},
+		{"names ignore case", `{"resolve": {"resolution": {"name": "Done"}}}`, true, ""},
+		{"not configured", "", false, "requires the fields resolution,"},
+		{"other transition configured", `{"Close": {"resolution": {"name": "Done"}}}`, false, "requires the fields resolution,"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The issue has one transition, whose screen requires a resolution.
+			var applied map[string]interface{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if r.Method != "POST" {
+					json.NewEncoder(w).Encode(map[string]interface{}{"transitions": []jira.Transition{{
+						ID:     "5",
+						Name:   "Resolve",
+						Fields: map[string]jira.TransitionField{"resolution": {Required: true}},
+					}}})
+					return
+				}
+				var payload struct {
+					Fields map[string]interface{} `json:"fields"`
+				}
+				json.NewDecoder(r.Body).Decode(&payload)
+				if _, ok := payload.Fields["resolution"]; !ok {
+					w.WriteHeader(http.StatusBadRequest)
+					w.Write([]byte(`{"errorMessages": [], "errors": {"resolution": "Resolution is required."}}`))
+					return
+				}
+				applied = payload.Fields
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			cfg, err := config.NewTestConfig(map[string]interface{}{"timeout": "1ms", "transition-fields": tt.fields})
+			if err != nil {
+				t.Fatal(err)
+			}
+			client, err := jira.NewClient(nil, server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			j := realJIRAClient{cfg: cfg, client: *client}
+
+			err = j.TransitionIssue(jira.Issue{ID: "1", Key: "TEST-1"}, []string{"Resolve"})
+			if tt.wantErr == "" && err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("TransitionIssue() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			want := map[string]interface{}{"resolution": map[string]interface{}{"name": "Done"}}
+			if (applied != nil) != tt.applied || (tt.applied && !reflect.DeepEqual(applied, want)) {
+				t.Errorf("transition applied with fields %v, want applied %v with %v", applied, tt.applied, want)
+			}
+		})
+	}
+}
+
+func TestStateBody(t *testing.T) {
+	closedAt := time.Date(2020, 1, 2, 15, 4, 0, 0, time.UTC)
+	updatedAt := time.Date(2020, 1, 3, 9, 30, 0, 0, time.UTC)
+	closer := &github.User{Login: github.String("octocat"), HTMLURL: github.String("https://github.com/octocat")}
+
+	tests := []struct {
+		name     string
+		state    string
+		closedBy *github.User
+		want     string
+	}{
+		{"closed", "closed", closer, " closed at 15:04 PM, January 2 2020 by [octocat|https://github.com/octocat]"},
+		{"closed by unknown user", "closed", nil, " closed at 15:04 PM, January 2 2020"},
+		{"reopened", "open", nil, " reopened at 09:30 AM, January 3 2020"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghIssue := github.Issue{
+				Number:    github.Int(1),
+				HTMLURL:   github.String("https://github.com/o/r/issues/1"),
+				State:     github.String(tt.state),
+				ClosedAt:  &closedAt,
+				UpdatedAt: &updatedAt,
+				ClosedBy:  tt.closedBy,
+			}
+
+			want := "GitHub issue [#1|https://github.com/o/r/issues/1]" + tt.want
+			if got := stateBody(ghIssue); got != want {
+				t.Errorf("stateBody() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWritableFields(t *testing.T) {
+	var sent map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Fields map[string]interface{} `json:"fields"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		sent = payload.Fields
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "PUT" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Write([]byte(`{"id": "1", "key": "TEST-1"}`))
+	}))
+	defer server.Close()
+
+	cfg, err := config.NewTestConfig(map[string]interface{}{
+		"field-ids":       map[string]string{"GitHub ID": "10001", "GitHub Number": "10002"},
+		"writable-fields": []string{"customfield_10010"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	logger := cfg.GetLogger().Logger
+	logger.Out = &out
+	logger.Level = logrus.WarnLevel
+
+	client, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := realJIRAClient{cfg: cfg, client: *client, limiter: limit.New(1)}
+
+	tests := []struct {
+		name string
+		do   func(jira.Issue) error
+	}{
+		{"create issue", func(issue jira.Issue) error {
+			_, err := j.CreateIssue(issue)
+			return err
+		}},
+		{"update issue", func(issue jira.Issue) error {
+			_, err := j.UpdateIssue(issue)
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent, out = nil, bytes.Buffer{}
+			unknowns := map[string]interface{}{
+				"customfield_10001": 1,
+				"customfield_10010": "allowed",
+				"customfield_10099": "managed elsewhere",
+			}
+			issue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{Summary: "Title", Unknowns: unknowns}}
+
+			if err := tt.do(issue); err != nil {
+				t.Fatal(err)
+			}
+			for _, key := range []string{"customfield_10001", "customfield_10010"} {
+				if _, ok := sent[key]; !ok {
+					t.Errorf("field %s wasn't written; sent %v", key, sent)
+				}
+			}
+			if _, ok := sent["customfield_10099"]; ok {
+				t.Errorf("field customfield_10099 outside the writable fields was written; sent %v", sent)
+			}
+			if !strings.Contains(out.String(), "Not writing field customfield_10099") {
+				t.Errorf("dropping the field wasn't logged; logged:\n%s", out.String())
+			}
+			if len(issue.Fields.Unknowns) != 3 {
+				t.Errorf("fields of the given issue = %v, want them left as they are", issue.Fields.Unknowns)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package jira
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+)
+
+// serverClock tracks the offset of the JIRA server's clock from the local
+// clock, as seen in the Date headers of the server's responses.
+type serverClock struct {
+	mu     sync.Mutex
+	offset time.Duration
+	known  bool
+}
+
+// observe updates the offset of the server's clock from the Date header of
+// a response. Responses without a valid Date header are ignored.
+func (c *serverClock) observe(res *jira.Response) {
+	if c == nil || res == nil || res.Response == nil {
+		return
+	}
+	date, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.offset = date.Sub(time.Now())
+	c.known = true
+}
+
+// now returns the current time in UTC. If server is set, it is the time of
+// the JIRA server's clock, once a response has shown it.
+func (c *serverClock) now(server bool) time.Time {
+	t := time.Now()
+	if server && c != nil {
+		c.mu.Lock()
+		if c.known {
+			t = t.Add(c.offset)
+		}
+		c.mu.Unlock()
+	}
+	return t.UTC()
+}
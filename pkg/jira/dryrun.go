@@ -8,6 +8,7 @@ import (
 	jira "github.com/andygrunwald/go-jira"
 	"github.com/cenkalti/backoff"
 	"github.com/innovocloud/issue-sync/pkg/config"
+	"github.com/innovocloud/issue-sync/pkg/limit"
 	ghClient "github.com/innovocloud/issue-sync/pkg/github"
 	"github.com/google/go-github/github"
 )
@@ -17,8 +18,11 @@ import (
 // unsafe requests which may modify server data, instead printing out the
 // actions it is asked to perform without making the request.
 type dryrunJIRAClient struct {
-	cfg    config.Config
-	client jira.Client
+	cfg     config.Config
+	client  jira.Client
+	limiter limit.Limiter
+	clock   *serverClock
+	syncIDs *syncIDCache
 }
 
 // ListIssues returns a list of JIRA issues on the configured project which
@@ -27,6 +31,10 @@ type dryrunJIRAClient struct {
 //
 // This function is identical to that in realJIRAClient.
 func (j dryrunJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
+	if j.cfg.IsUsingProperties() {
+		return listIssuesByProperty(j.cfg, j, j.syncIDs, j.getIssues, ids)
+	}
+
 	log := j.cfg.GetLogger()
 
 	idStrs := make([]string, len(ids))
@@ -37,9 +45,8 @@ func (j dryrunJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
 	var jql string
 	// If the list of IDs is too long, we get a 414 Request-URI Too Large, so in that case,
 	// we'll need to do the filtering ourselves.
-	if len(ids) < maxJQLIssueLength {
-		jql = fmt.Sprintf("project='%s' AND cf[%s] in (%s)",
-			j.cfg.GetProjectKey(), j.cfg.GetFieldID(config.GitHubID), strings.Join(idStrs, ","))
+	if searchByJQL(j.cfg, ids) {
+		jql = githubIDJQL(j.cfg, fmt.Sprintf("cf[%s]", j.cfg.GetFieldID(config.GitHubID)), idStrs)
 	} else {
 		jql = fmt.Sprintf("project='%s'", j.cfg.GetProjectKey())
 	}
@@ -47,6 +54,13 @@ func (j dryrunJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
 	ji, res, err := j.request(func() (interface{}, *jira.Response, error) {
 		return j.client.Issue.Search(jql, nil)
 	})
+	if clause := j.cfg.GetGitHubIDClause(); err != nil && searchByJQL(j.cfg, ids) && clause != "" {
+		log.Warnf("Searching JIRA issues by cf[%s] failed; retrying by the clause name %s", j.cfg.GetFieldID(config.GitHubID), clause)
+		jql = githubIDJQL(j.cfg, clause, idStrs)
+		ji, res, err = j.request(func() (interface{}, *jira.Response, error) {
+			return j.client.Issue.Search(jql, nil)
+		})
+	}
 	if err != nil {
 		log.Errorf("Error retrieving JIRA issues: %v", err)
 		return nil, getErrorBody(j.cfg, res)
@@ -58,17 +72,13 @@ func (j dryrunJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
 	}
 
 	var issues []jira.Issue
-	if len(ids) < maxJQLIssueLength {
+	if searchByJQL(j.cfg, ids) {
 		// The issues were already filtered by our JQL, so use as is
 		issues = jiraIssues
 	} else {
 		// Filter only issues which have a defined GitHub ID in the list of IDs
-		ghIDFieldKey := j.cfg.GetFieldKey(config.GitHubID)
 		for _, v := range jiraIssues {
-			if val, _ := v.Fields.Unknowns.Value(ghIDFieldKey); val == nil {
-				continue
-			}
-			if id, err := v.Fields.Unknowns.Int(ghIDFieldKey); err == nil {
+			if id, ok := GetGitHubID(j.cfg, v); ok {
 				for _, idOpt := range ids {
 					if id == int64(idOpt) {
 						issues = append(issues, v)
@@ -82,8 +92,60 @@ func (j dryrunJIRAClient) ListIssues(ids []int) ([]jira.Issue, error) {
 	return issues, nil
 }
 
+// ListIssuesUpdatedSince returns a list of all JIRA issues on the configured
+// project which have been updated at or after the provided time, in ascending
+// order of update.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) ListIssuesUpdatedSince(t time.Time) ([]jira.Issue, error) {
+	jql := fmt.Sprintf("project='%s' AND updated >= '%s' ORDER BY updated ASC",
+		j.cfg.GetProjectKey(), jqlDate(j.cfg, t))
+
+	return j.getIssues(jql)
+}
+
+// getIssues returns all of the JIRA issues matching the JQL query, requesting
+// them page by page.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) getIssues(jql string) ([]jira.Issue, error) {
+	log := j.cfg.GetLogger()
+	var issues []jira.Issue
+
+	const maxResults = 50
+	// force at least one interation to occur
+	totalResults := 1
+
+	for page := 0; (page * maxResults) < totalResults; page++ {
+		ji, res, err := j.request(func() (interface{}, *jira.Response, error) {
+			opts := &jira.SearchOptions{
+				StartAt:    (maxResults * page),
+				MaxResults: maxResults,
+			}
+			return j.client.Issue.Search(jql, opts)
+		})
+
+		if err != nil {
+			log.Errorf("Error retrieving JIRA issues: %v", err)
+			return nil, getErrorBody(j.cfg, res)
+		}
+
+		totalResults = res.Total
+
+		jiraIssues, ok := ji.([]jira.Issue)
+		if !ok {
+			log.Errorf("Get JIRA issues did not return issues! Got: %v", ji)
+			return nil, fmt.Errorf("get JIRA issues failed: expected []jira.Issue; got %T", ji)
+		}
+
+		issues = append(issues, jiraIssues...)
+	}
+
+	return issues, nil
+}
+
 // GetIssue returns a single JIRA issue within the configured project
-// according to the issue key (e.g. "PROJ-13").
+// according to the issue key (e.g. "PROJ-13") or ID.
 //
 // This function is identical to that in realJIRAClient.
 func (j dryrunJIRAClient) GetIssue(key string) (jira.Issue, error) {
@@ -105,23 +167,76 @@ func (j dryrunJIRAClient) GetIssue(key string) (jira.Issue, error) {
 	return *issue, nil
 }
 
+// ResolveSprint finds the sprint with the given name, or the active sprint if
+// the name is "active", on the configured agile board. It returns the ID of
+// the sprint, and false if no such sprint exists.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) ResolveSprint(name string) (int, bool, error) {
+	return resolveSprint(j.cfg, j.client, j.request, name)
+}
+
+// UserExists returns whether there is a JIRA user with the given username.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) UserExists(name string) (bool, error) {
+	return userExists(j.cfg, j.client, j.request, name)
+}
+
+// GetProperty reads the entity property with the given key of a JIRA issue
+// into v. It returns false if the issue has no such property.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) GetProperty(issue jira.Issue, key string, v interface{}) (bool, error) {
+	return getProperty(j.cfg, j.client, j.request, issue, key, v)
+}
+
+// SetProperty prints out the value that would be stored in the entity
+// property with the given key of a JIRA issue.
+func (j dryrunJIRAClient) SetProperty(issue jira.Issue, key string, v interface{}) error {
+	log := j.cfg.GetLogger()
+
+	log.Info("")
+	log.Infof("Set property %s on JIRA issue %s:", key, issue.Key)
+	log.Infof("  Value: %+v", v)
+	if err := j.logPayload("PUT", fmt.Sprintf("rest/api/2/issue/%s/properties/%s", issue.Key, key), v); err != nil {
+		return err
+	}
+	log.Info("")
+
+	return nil
+}
+
 // CreateIssue prints out the fields that would be set on a new issue were
 // it to be created according to the provided issue object. It returns the
 // provided issue object as-is.
 func (j dryrunJIRAClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
 	log := j.cfg.GetLogger()
 
+	issue = writableFields(j.cfg, issue)
 	fields := issue.Fields
 
 	log.Info("")
 	log.Info("Create new JIRA issue:")
 	log.Infof("  Summary: %s", fields.Summary)
-	log.Infof("  Description: %s", truncate(fields.Description, 50))
+	log.Infof("  Description: %s", truncate(j.cfg, fields.Description, j.cfg.GetDryRunDescriptionLength()))
 	log.Infof("  GitHub ID: %d", fields.Unknowns[j.cfg.GetFieldKey(config.GitHubID)])
-	log.Infof("  GitHub Number: %d", fields.Unknowns[j.cfg.GetFieldKey(config.GitHubNumber)])
+	log.Infof("  GitHub Number: %v", fields.Unknowns[j.cfg.GetFieldKey(config.GitHubNumber)])
+	if j.cfg.HasField(config.GitHubRepo) {
+		log.Infof("  GitHub Repo: %s", fields.Unknowns[j.cfg.GetFieldKey(config.GitHubRepo)])
+	}
 	log.Infof("  Labels: %s", fields.Unknowns[j.cfg.GetFieldKey(config.GitHubLabels)])
 	log.Infof("  State: %s", fields.Unknowns[j.cfg.GetFieldKey(config.GitHubStatus)])
 	log.Infof("  Reporter: %s", fields.Unknowns[j.cfg.GetFieldKey(config.GitHubReporter)])
+	if fields.Reporter != nil {
+		log.Infof("  JIRA reporter: %s", fields.Reporter.Name)
+	}
+	if len(fields.Labels) > 0 {
+		log.Infof("  Triage label: %s", fields.Labels[0])
+	}
+	if err := j.logPayload("POST", "rest/api/2/issue", &issue); err != nil {
+		return jira.Issue{}, err
+	}
 	log.Info("")
 
 	return issue, nil
@@ -133,12 +248,13 @@ func (j dryrunJIRAClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
 func (j dryrunJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
 	log := j.cfg.GetLogger()
 
+	issue = writableFields(j.cfg, issue)
 	fields := issue.Fields
 
 	log.Info("")
 	log.Infof("Update JIRA issue %s:", issue.Key)
 	log.Infof("  Summary: %s", fields.Summary)
-	log.Infof("  Description: %s", truncate(fields.Description, 50))
+	log.Infof("  Description: %s", truncate(j.cfg, fields.Description, j.cfg.GetDryRunDescriptionLength()))
 	key := j.cfg.GetFieldKey(config.GitHubLabels)
 	if labels, err := fields.Unknowns.String(key); err == nil {
 		log.Infof("  Labels: %s", labels)
@@ -147,32 +263,56 @@ func (j dryrunJIRAClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
 	if state, err := fields.Unknowns.String(key); err == nil {
 		log.Infof("  State: %s", state)
 	}
+	if err := j.logPayload("PUT", fmt.Sprintf("rest/api/2/issue/%s", issue.Key), &issue); err != nil {
+		return jira.Issue{}, err
+	}
 	log.Info("")
 
 	return issue, nil
 }
 
+// TransitionIssue prints out the transitions that would be applied to a JIRA
+// issue. As the transitions available after the first one depend on the
+// issue's new status, only the first one is checked.
+func (j dryrunJIRAClient) TransitionIssue(issue jira.Issue, path []string) error {
+	log := j.cfg.GetLogger()
+
+	if len(path) == 0 {
+		return nil
+	}
+
+	transition, ok, err := findTransition(j.cfg, j.client, j.request, issue, path[0])
+	if err != nil || !ok {
+		return err
+	}
+	payload, missing := transitionPayload(j.cfg, transition)
+
+	log.Info("")
+	log.Infof("Transition JIRA issue %s:", issue.Key)
+	log.Infof("  Path: %s", strings.Join(path, " -> "))
+	if len(missing) > 0 {
+		log.Warnf("  Required fields not set: %s", strings.Join(missing, ", "))
+	}
+	if err := j.logPayload("POST", fmt.Sprintf("rest/api/2/issue/%s/transitions", issue.ID), payload); err != nil {
+		return err
+	}
+	log.Info("")
+
+	return nil
+}
+
 // CreateComment prints the body that would be set on a new comment if it were
 // to be created according to the fields of the provided GitHub comment. It then
 // returns a comment object containing the body that would be used.
 func (j dryrunJIRAClient) CreateComment(issue jira.Issue, comment github.IssueComment, github ghClient.GitHubClient) (jira.Comment, error) {
 	log := j.cfg.GetLogger()
 
-	user, err := github.GetUser(comment.User.GetLogin())
+	user, err := getCommentUser(j.cfg, comment, github)
 	if err != nil {
 		return jira.Comment{}, err
 	}
 
-	body := fmt.Sprintf("Comment (ID %d) from GitHub user %s", comment.GetID(), user.GetLogin())
-	if user.GetName() != "" {
-		body = fmt.Sprintf("%s (%s)", body, user.GetName())
-	}
-	body = fmt.Sprintf(
-		"%s at %s:\n\n%s",
-		body,
-		comment.CreatedAt.Format(commentDateFormat),
-		comment.GetBody(),
-	)
+	jComments := commentPayloads(j.cfg, issue, comment, user)
 
 	log.Info("")
 	log.Infof("Create comment on JIRA issue %s:", issue.Key)
@@ -183,12 +323,23 @@ func (j dryrunJIRAClient) CreateComment(issue jira.Issue, comment github.IssueCo
 		log.Infof("  User: %s", user.GetLogin())
 	}
 	log.Infof("  Posted at: %s", comment.CreatedAt.Format(commentDateFormat))
-	log.Infof("  Body: %s", truncate(comment.GetBody(), 100))
+	// The bodies are those the real client posts, header and marker included.
+	if len(jComments) > 1 {
+		log.Infof("  Split into %d comments", len(jComments))
+		for i, jComment := range jComments {
+			log.Infof("  Body of part %d: %s", i+1, truncate(j.cfg, jComment.Body, j.cfg.GetDryRunCommentLength()))
+		}
+	} else {
+		log.Infof("  Body: %s", truncate(j.cfg, jComments[0].Body, j.cfg.GetDryRunCommentLength()))
+	}
+	for i := range jComments {
+		if err := j.logPayload("POST", fmt.Sprintf("rest/api/2/issue/%s/comment", issue.ID), &jComments[i]); err != nil {
+			return jira.Comment{}, err
+		}
+	}
 	log.Info("")
 
-	return jira.Comment{
-		Body: body,
-	}, nil
+	return jComments[0], nil
 }
 
 // UpdateComment prints the body that would be set on a comment were it to be
@@ -197,21 +348,12 @@ func (j dryrunJIRAClient) CreateComment(issue jira.Issue, comment github.IssueCo
 func (j dryrunJIRAClient) UpdateComment(issue jira.Issue, id string, comment github.IssueComment, github ghClient.GitHubClient) (jira.Comment, error) {
 	log := j.cfg.GetLogger()
 
-	user, err := github.GetUser(comment.User.GetLogin())
+	user, err := getCommentUser(j.cfg, comment, github)
 	if err != nil {
 		return jira.Comment{}, err
 	}
 
-	body := fmt.Sprintf("Comment (ID %d) from GitHub user %s", comment.GetID(), user.GetLogin())
-	if user.GetName() != "" {
-		body = fmt.Sprintf("%s (%s)", body, user.GetName())
-	}
-	body = fmt.Sprintf(
-		"%s at %s:\n\n%s",
-		body,
-		comment.CreatedAt.Format(commentDateFormat),
-		comment.GetBody(),
-	)
+	jComment := editedCommentPayload(j.cfg, issue, id, comment, user)
 
 	log.Info("")
 	log.Infof("Update JIRA comment %s on issue %s:", id, issue.Key)
@@ -222,13 +364,123 @@ func (j dryrunJIRAClient) UpdateComment(issue jira.Issue, id string, comment git
 		log.Infof("  User: %s", user.GetLogin())
 	}
 	log.Infof("  Posted at: %s", comment.CreatedAt.Format(commentDateFormat))
-	log.Infof("  Body: %s", truncate(comment.GetBody(), 100))
+	log.Infof("  Body: %s", truncate(j.cfg, jComment.Body, j.cfg.GetDryRunCommentLength()))
+	if err := j.logPayload("PUT", commentURL(issue, id), commentUpdate{Body: jComment.Body}); err != nil {
+		return jira.Comment{}, err
+	}
+	log.Info("")
+
+	jComment.ID = id
+	return jComment, nil
+}
+
+// DeleteComment prints the comment which would be deleted from a JIRA issue.
+func (j dryrunJIRAClient) DeleteComment(issue jira.Issue, id string) error {
+	log := j.cfg.GetLogger()
+
+	log.Info("")
+	log.Infof("Delete JIRA comment %s on issue %s", id, issue.Key)
+	log.Info("")
+
+	return nil
+}
+
+// CreateEventComment prints the body that would be set on a new comment
+// describing the provided GitHub timeline event. It then returns a comment
+// object containing the body that would be used.
+func (j dryrunJIRAClient) CreateEventComment(issue jira.Issue, event github.Timeline) (jira.Comment, error) {
+	log := j.cfg.GetLogger()
+
+	jComment := jira.Comment{
+		Body: eventBody(event),
+	}
+
+	log.Info("")
+	log.Infof("Create event comment on JIRA issue %s:", issue.Key)
+	log.Infof("  GitHub event ID: %d", event.GetID())
+	log.Infof("  Event: %s", event.GetEvent())
+	log.Infof("  Body: %s", truncate(j.cfg, jComment.Body, j.cfg.GetDryRunCommentLength()))
+	if err := j.logPayload("POST", fmt.Sprintf("rest/api/2/issue/%s/comment", issue.ID), &jComment); err != nil {
+		return jira.Comment{}, err
+	}
+	log.Info("")
+
+	return jComment, nil
+}
+
+// CreateStateComment prints the body that would be set on a new comment
+// noting the state change of a GitHub issue. It returns a comment with
+// that body, without an ID.
+func (j dryrunJIRAClient) CreateStateComment(issue jira.Issue, ghIssue github.Issue) (jira.Comment, error) {
+	log := j.cfg.GetLogger()
+
+	jComment := jira.Comment{
+		Body: stateBody(ghIssue),
+	}
+
+	log.Info("")
+	log.Infof("Create state comment on JIRA issue %s:", issue.Key)
+	log.Infof("  State: %s", ghIssue.GetState())
+	log.Infof("  Body: %s", truncate(j.cfg, jComment.Body, j.cfg.GetDryRunCommentLength()))
+	if err := j.logPayload("POST", fmt.Sprintf("rest/api/2/issue/%s/comment", issue.ID), &jComment); err != nil {
+		return jira.Comment{}, err
+	}
+	log.Info("")
+
+	return jComment, nil
+}
+
+// CreateConflictComment prints the body that would be set on a new comment
+// noting a conflict with the description of a GitHub issue. It returns a
+// comment with that body, without an ID.
+func (j dryrunJIRAClient) CreateConflictComment(issue jira.Issue, ghIssue github.Issue) (jira.Comment, error) {
+	log := j.cfg.GetLogger()
+
+	jComment := jira.Comment{
+		Body: conflictBody(ghIssue),
+	}
+
+	log.Info("")
+	log.Infof("Create conflict comment on JIRA issue %s:", issue.Key)
+	log.Infof("  Body: %s", truncate(j.cfg, jComment.Body, j.cfg.GetDryRunCommentLength()))
+	if err := j.logPayload("POST", fmt.Sprintf("rest/api/2/issue/%s/comment", issue.ID), &jComment); err != nil {
+		return jira.Comment{}, err
+	}
 	log.Info("")
 
-	return jira.Comment{
-		ID:   id,
-		Body: body,
-	}, nil
+	return jComment, nil
+}
+
+// logPayload builds the request the real client would send and prints its
+// serialized body at debug level, so that a dry run fails where the real run
+// would on a payload which can't be built.
+func (j dryrunJIRAClient) logPayload(method, url string, body interface{}) error {
+	log := j.cfg.GetLogger()
+
+	payload, err := requestPayload(j.client, method, url, body)
+	if err != nil {
+		log.Errorf("Error building JIRA request payload: %v", err)
+		return err
+	}
+	log.Debugf("  Payload: %s", payload)
+
+	return nil
+}
+
+// RefreshFieldIDs resolves the IDs of the custom fields again, in case they
+// were recreated since they were last resolved.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) RefreshFieldIDs() error {
+	return j.cfg.RefreshFieldIDs(j.client)
+}
+
+// Now returns the current time in UTC, by the JIRA server's clock if it is
+// configured to be used.
+//
+// This function is identical to that in realJIRAClient.
+func (j dryrunJIRAClient) Now() time.Time {
+	return j.clock.now(j.cfg.IsUsingJIRAServerClock())
 }
 
 // request takes an API function from the JIRA library
@@ -245,8 +497,12 @@ func (j dryrunJIRAClient) request(f func() (interface{}, *jira.Response, error))
 	var res *jira.Response
 
 	op := func() error {
+		j.limiter.Acquire()
+		defer j.limiter.Release()
+
 		var err error
 		ret, res, err = f()
+		j.clock.observe(res)
 		return err
 	}
 
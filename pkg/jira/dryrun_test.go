@@ -0,0 +1,242 @@
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	"github.com/innovocloud/issue-sync/pkg/limit"
+)
+
+// loggedPayloads returns the request payloads logged by a dry run, from log
+// output in the JSON format.
+func loggedPayloads(t *testing.T, out *bytes.Buffer) []string {
+	var payloads []string
+	dec := json.NewDecoder(out)
+	for dec.More() {
+		var entry struct {
+			Msg string `json:"msg"`
+		}
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatal(err)
+		}
+		if strings.HasPrefix(entry.Msg, "  Payload: ") {
+			payloads = append(payloads, strings.TrimPrefix(entry.Msg, "  Payload: "))
+		}
+	}
+	return payloads
+}
+
+func TestDryRunPayloads(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sent = append(sent, string(body))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "1", "key": "TEST-1"}`))
+	}))
+	defer server.Close()
+
+	cfg, err := config.NewTestConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	logger := cfg.GetLogger().Logger
+	logger.Out = &out
+	logger.Formatter = &logrus.JSONFormatter{}
+	logger.Level = logrus.DebugLevel
+
+	client, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := realJIRAClient{cfg: cfg, client: *client, limiter: limit.New(1)}
+	dryrun := dryrunJIRAClient{cfg: cfg, client: *client, limiter: limit.New(1)}
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	comment := github.IssueComment{
+		ID:        github.Int(2),
+		Body:      github.String("Thanks @octocat"),
+		User:      &github.User{Login: github.String("octocat")},
+		HTMLURL:   github.String("https://github.com/o/r/issues/1#issuecomment-2"),
+		CreatedAt: &created,
+	}
+	issue := jira.Issue{ID: "1", Key: "TEST-1", Fields: &jira.IssueFields{
+		Summary:     "Title",
+		Description: "Body",
+		Unknowns:    map[string]interface{}{cfg.GetFieldKey(config.GitHubID): 1},
+	}}
+
+	tests := []struct {
+		name string
+		do   func(JIRAClient) error
+	}{
+		{"create issue", func(j JIRAClient) error {
+			_, err := j.CreateIssue(issue)
+			return err
+		}},
+		{"update issue", func(j JIRAClient) error {
+			_, err := j.UpdateIssue(issue)
+			return err
+		}},
+		{"set property", func(j JIRAClient) error {
+			return j.SetProperty(issue, "test-property", map[string]int{"version": 1})
+		}},
+		{"create comment", func(j JIRAClient) error {
+			_, err := j.CreateComment(issue, comment, userClient{})
+			return err
+		}},
+		{"update comment", func(j JIRAClient) error {
+			_, err := j.UpdateComment(issue, "10", comment, userClient{})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent, out = nil, bytes.Buffer{}
+
+			if err := tt.do(real); err != nil {
+				t.Fatal(err)
+			}
+			if len(sent) != 1 {
+				t.Fatalf("real client sent %d requests, want 1", len(sent))
+			}
+			want := strings.TrimSpace(sent[0])
+
+			if err := tt.do(dryrun); err != nil {
+				t.Fatal(err)
+			}
+			if len(sent) != 1 {
+				t.Fatalf("dry run sent %d requests, want none", len(sent)-1)
+			}
+			payloads := loggedPayloads(t, &out)
+			if len(payloads) != 1 || strings.TrimSpace(payloads[0]) != want {
+				t.Errorf("dry run logged payloads %q, want %q", payloads, want)
+			}
+		})
+	}
+}
+
+// loggedBodyRegex matches the comment bodies logged by a dry run.
+var loggedBodyRegex = regexp.MustCompile(`(?s)^  Body(?: of part \d+)?: (.*)$`)
+
+func TestDryRunCommentBodies(t *testing.T) {
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Body string `json:"body"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Error(err)
+		}
+		sent = append(sent, payload.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "10"}`))
+	}))
+	defer server.Close()
+
+	// Dry runs don't shorten the logged bodies, so they can be compared.
+	cfg, err := config.NewTestConfig(map[string]interface{}{
+		"chunk-comments":         true,
+		"dry-run-comment-length": 1 << 17,
+		"reporter-indicator":     "(reporter)",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	logger := cfg.GetLogger().Logger
+	logger.Out = &out
+	logger.Formatter = &logrus.JSONFormatter{}
+	logger.Level = logrus.InfoLevel
+
+	client, err := jira.NewClient(nil, server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	real := realJIRAClient{cfg: cfg, client: *client, limiter: limit.New(1)}
+	dryrun := dryrunJIRAClient{cfg: cfg, client: *client, limiter: limit.New(1)}
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	issue := jira.Issue{ID: "1", Key: "TEST-1", Fields: &jira.IssueFields{
+		Unknowns: map[string]interface{}{cfg.GetFieldKey(config.GitHubReporter): "octocat"},
+	}}
+	comment := func(body string) github.IssueComment {
+		return github.IssueComment{
+			ID:        github.Int(2),
+			Body:      github.String(body),
+			User:      &github.User{Login: github.String("octocat"), HTMLURL: github.String("https://github.com/octocat")},
+			HTMLURL:   github.String("https://github.com/o/r/issues/1#issuecomment-2"),
+			CreatedAt: &created,
+		}
+	}
+	long := strings.Repeat("A paragraph of a long comment.\n\n", 2000)
+
+	tests := []struct {
+		name string
+		do   func(JIRAClient) error
+	}{
+		{"create comment", func(j JIRAClient) error {
+			_, err := j.CreateComment(issue, comment("Thanks @octocat\nfor the fix"), userClient{})
+			return err
+		}},
+		{"create split comment", func(j JIRAClient) error {
+			_, err := j.CreateComment(issue, comment(long), userClient{})
+			return err
+		}},
+		{"update comment", func(j JIRAClient) error {
+			_, err := j.UpdateComment(issue, "10", comment("Edited"), userClient{})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent = nil
+			if err := tt.do(real); err != nil {
+				t.Fatal(err)
+			}
+			if len(sent) == 0 {
+				t.Fatal("real client posted no comment")
+			}
+			var want []string
+			for _, body := range sent {
+				want = append(want, newlineReplaceRegex.ReplaceAllString(body, "\\n"))
+			}
+
+			out.Reset()
+			if err := tt.do(dryrun); err != nil {
+				t.Fatal(err)
+			}
+			var logged []string
+			dec := json.NewDecoder(&out)
+			for dec.More() {
+				var entry struct {
+					Msg string `json:"msg"`
+				}
+				if err := dec.Decode(&entry); err != nil {
+					t.Fatal(err)
+				}
+				if m := loggedBodyRegex.FindStringSubmatch(entry.Msg); m != nil {
+					logged = append(logged, m[1])
+				}
+			}
+			if !reflect.DeepEqual(logged, want) {
+				t.Errorf("dry run logged %d bodies, want the %d bodies posted: %.200q, want %.200q", len(logged), len(want), logged, want)
+			}
+		})
+	}
+}
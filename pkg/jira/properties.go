@@ -0,0 +1,40 @@
+package jira
+
+import (
+	"sync"
+)
+
+// syncIDCache remembers the GitHub ID in the SyncProperty of each JIRA issue
+// read while matching issues by property, so that the property of an issue
+// is requested only once per client rather than on every search. Issues
+// without the property are remembered too, with an ID of 0; they gain one
+// only when issue-sync sets it, which updates the cache.
+type syncIDCache struct {
+	mu  sync.Mutex
+	ids map[string]int
+}
+
+// get returns the cached GitHub ID of the issue with the given key, and
+// whether the issue's property has been read.
+func (c *syncIDCache) get(key string) (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id, ok := c.ids[key]
+	return id, ok
+}
+
+// set caches the GitHub ID of the issue with the given key.
+func (c *syncIDCache) set(key string, id int) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ids == nil {
+		c.ids = map[string]int{}
+	}
+	c.ids[key] = id
+}
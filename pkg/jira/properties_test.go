@@ -0,0 +1,94 @@
+package jira
+
+import (
+	"testing"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/innovocloud/issue-sync/pkg/config"
+)
+
+// countingClient serves SyncProperty GitHub IDs by issue key, counting the
+// requests made for them.
+type countingClient struct {
+	JIRAClient
+	ids      map[string]int
+	requests int
+}
+
+func (c *countingClient) GetProperty(issue jira.Issue, key string, v interface{}) (bool, error) {
+	c.requests++
+	id, ok := c.ids[issue.Key]
+	if ok {
+		*v.(*SyncProperty) = SyncProperty{GitHubID: id}
+	}
+	return ok, nil
+}
+
+func TestListIssuesByPropertyCache(t *testing.T) {
+	cfg, err := config.NewTestConfig(map[string]interface{}{
+		"use-properties": true,
+		"field-ids":      map[string]string{"GitHub ID": "10001"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getIssues := func(string) ([]jira.Issue, error) {
+		// Fresh issues, as the matched issues are modified.
+		var issues []jira.Issue
+		for _, key := range []string{"TEST-1", "TEST-2", "TEST-3"} {
+			issues = append(issues, jira.Issue{Key: key, Fields: &jira.IssueFields{}})
+		}
+		return issues, nil
+	}
+	client := &countingClient{ids: map[string]int{"TEST-1": 1, "TEST-2": 2}}
+	cache := &syncIDCache{}
+
+	tests := []struct {
+		name     string
+		ids      []int
+		want     []string
+		requests int
+	}{
+		{"first search", []int{1}, []string{"TEST-1"}, 3},
+		{"cached", []int{1, 2}, []string{"TEST-1", "TEST-2"}, 3},
+		{"no match", []int{3}, nil, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issues, err := listIssuesByProperty(cfg, client, cache, getIssues, tt.ids)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var keys []string
+			for _, issue := range issues {
+				keys = append(keys, issue.Key)
+				if _, ok := GetGitHubID(cfg, issue); !ok {
+					t.Errorf("issue %s has no GitHub ID", issue.Key)
+				}
+			}
+			if len(keys) != len(tt.want) {
+				t.Fatalf("listIssuesByProperty() = %v, want %v", keys, tt.want)
+			}
+			for i := range keys {
+				if keys[i] != tt.want[i] {
+					t.Errorf("listIssuesByProperty() = %v, want %v", keys, tt.want)
+				}
+			}
+			if client.requests != tt.requests {
+				t.Errorf("%d property requests, want %d", client.requests, tt.requests)
+			}
+		})
+	}
+
+	// Setting a property caches the new ID of the issue.
+	cache.set("TEST-3", 3)
+	issues, err := listIssuesByProperty(cfg, client, cache, getIssues, []int{3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(issues) != 1 || issues[0].Key != "TEST-3" || client.requests != 3 {
+		t.Errorf("listIssuesByProperty() = %v after %d requests, want TEST-3 without any", issues, client.requests)
+	}
+}
@@ -0,0 +1,201 @@
+package jira
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	jira "github.com/andygrunwald/go-jira"
+)
+
+// snapshot is an export of a JIRA project, in the formats returned by the
+// JIRA REST API, which an offline dry run reads instead of the server.
+type snapshot struct {
+	// Project is the project, as returned by /rest/api/2/project/<key>.
+	Project json.RawMessage `json:"project"`
+	// Fields are the issue fields, as returned by /rest/api/2/field.
+	Fields json.RawMessage `json:"fields"`
+	// Issues are the issues of the project with their comments, as returned
+	// by /rest/api/2/search with fields=*all.
+	Issues []json.RawMessage `json:"issues"`
+	// Properties are the entity properties of the issues, by issue key and
+	// property key.
+	Properties map[string]map[string]json.RawMessage `json:"properties,omitempty"`
+	// TimeZone is the time zone of the JIRA user, as returned by
+	// /rest/api/2/myself, in which JQL dates are given. The default is UTC.
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// issues are the parsed issues, in the same order.
+	issues []jira.Issue
+	// location is the parsed time zone.
+	location *time.Location
+}
+
+// loadSnapshot reads and parses a JIRA snapshot file.
+func loadSnapshot(path string) (*snapshot, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read JIRA snapshot: %v", err)
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, fmt.Errorf("unable to parse JIRA snapshot: %v", err)
+	}
+	if len(s.Project) == 0 || len(s.Fields) == 0 {
+		return nil, fmt.Errorf("JIRA snapshot %s must have a project and fields", path)
+	}
+
+	if s.TimeZone == "" {
+		s.TimeZone = "UTC"
+	}
+	if s.location, err = time.LoadLocation(s.TimeZone); err != nil {
+		return nil, fmt.Errorf("unknown time zone %q in JIRA snapshot: %v", s.TimeZone, err)
+	}
+
+	s.issues = make([]jira.Issue, len(s.Issues))
+	for i, raw := range s.Issues {
+		if err := json.Unmarshal(raw, &s.issues[i]); err != nil {
+			return nil, fmt.Errorf("unable to parse issue %d of JIRA snapshot: %v", i, err)
+		}
+	}
+
+	return &s, nil
+}
+
+// jiraTimeFormat is the format of the times JIRA returns, which parsing also
+// accepts with milliseconds.
+const jiraTimeFormat = "2006-01-02T15:04:05-0700"
+
+// snapshotUpdatedJQL matches the update condition of the JQL searches made by
+// ListIssuesUpdatedSince.
+var snapshotUpdatedJQL = regexp.MustCompile(`updated >= '([^']+)'`)
+
+// RoundTrip answers the GET requests of the JIRA client from the snapshot,
+// so that a dry run makes no network requests. Searches return every issue
+// in the snapshot, except for the update condition of the JQL; the callers
+// match the issues to GitHub issues by their IDs. Requests for anything not
+// in the snapshot fail with 404 Not Found.
+func (s *snapshot) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		return nil, fmt.Errorf("offline dry run attempted %s %s", req.Method, req.URL.Path)
+	}
+
+	path := strings.TrimSuffix(req.URL.Path, "/")
+	parts := strings.Split(path[strings.Index(path, "/rest/")+1:], "/")
+
+	switch {
+	case len(parts) == 4 && parts[3] == "myself":
+		return s.respond(req, http.StatusOK, map[string]string{"timeZone": s.TimeZone})
+	case len(parts) == 4 && parts[3] == "field":
+		return s.respond(req, http.StatusOK, s.Fields)
+	case len(parts) == 4 && parts[3] == "project":
+		return s.respond(req, http.StatusOK, []json.RawMessage{s.Project})
+	case len(parts) == 5 && parts[3] == "project":
+		return s.respond(req, http.StatusOK, s.Project)
+	case len(parts) == 4 && parts[3] == "search":
+		return s.search(req)
+	case len(parts) == 5 && parts[3] == "issue":
+		if i, ok := s.find(parts[4]); ok {
+			return s.respond(req, http.StatusOK, s.Issues[i])
+		}
+	case len(parts) == 7 && parts[3] == "issue" && parts[5] == "properties":
+		if i, ok := s.find(parts[4]); ok {
+			if v, ok := s.Properties[s.issues[i].Key][parts[6]]; ok {
+				return s.respond(req, http.StatusOK, map[string]interface{}{"key": parts[6], "value": v})
+			}
+		}
+	}
+
+	return s.respond(req, http.StatusNotFound, map[string][]string{
+		"errorMessages": {fmt.Sprintf("%s is not in the JIRA snapshot", path)},
+	})
+}
+
+// find returns the index of the issue with the given key or ID.
+func (s *snapshot) find(keyOrID string) (int, bool) {
+	for i, issue := range s.issues {
+		if issue.Key == keyOrID || issue.ID == keyOrID {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// search answers a JQL search with a page of the snapshot's issues.
+func (s *snapshot) search(req *http.Request) (*http.Response, error) {
+	q := req.URL.Query()
+
+	var since time.Time
+	if m := snapshotUpdatedJQL.FindStringSubmatch(q.Get("jql")); m != nil {
+		t, err := time.ParseInLocation(jqlDateFormat, m[1], s.location)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse update time of JQL %q: %v", q.Get("jql"), err)
+		}
+		since = t
+	}
+
+	var issues []json.RawMessage
+	for i, issue := range s.issues {
+		if !since.IsZero() {
+			updated, err := time.Parse(jiraTimeFormat, issue.Fields.Updated)
+			if err == nil && updated.Before(since) {
+				continue
+			}
+		}
+		issues = append(issues, s.Issues[i])
+	}
+
+	startAt, _ := strconv.Atoi(q.Get("startAt"))
+	maxResults, err := strconv.Atoi(q.Get("maxResults"))
+	if err != nil || maxResults <= 0 {
+		maxResults = 50
+	}
+	total := len(issues)
+	if startAt > total {
+		startAt = total
+	}
+	if end := startAt + maxResults; end < total {
+		issues = issues[startAt:end]
+	} else {
+		issues = issues[startAt:]
+	}
+	if issues == nil {
+		issues = []json.RawMessage{}
+	}
+
+	return s.respond(req, http.StatusOK, map[string]interface{}{
+		"startAt":    startAt,
+		"maxResults": maxResults,
+		"total":      total,
+		"issues":     issues,
+	})
+}
+
+// respond returns a JSON response to the request.
+func (s *snapshot) respond(req *http.Request, status int, v interface{}) (*http.Response, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set("Date", time.Now().UTC().Format(http.TimeFormat))
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,180 @@
+package jira
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/innovocloud/issue-sync/pkg/config"
+)
+
+// testSnapshot is a snapshot of a project with the custom fields of
+// issue-sync, and two synced issues updated a day apart.
+const testSnapshot = `{
+	"project": {"id": "1", "key": "TEST", "name": "Test"},
+	"fields": [
+		{"id": "customfield_10001", "name": "GitHub ID", "custom": true, "schema": {"type": "number", "customId": 10001}},
+		{"id": "customfield_10002", "name": "GitHub Number", "custom": true, "schema": {"type": "number", "customId": 10002}},
+		{"id": "customfield_10006", "name": "Last Issue-Sync Update", "custom": true, "schema": {"type": "datetime", "customId": 10006}}
+	],
+	"issues": [
+		{"id": "11", "key": "TEST-1", "fields": {"summary": "One", "updated": "2020-01-01T10:00:00.000+0000", "customfield_10001": 101}},
+		{"id": "12", "key": "TEST-2", "fields": {"summary": "Two", "updated": "2020-01-02T10:00:00.000+0000", "customfield_10001": 102}}
+	],
+	"timeZone": "UTC"
+}`
+
+func TestSnapshotDryRun(t *testing.T) {
+	// Any request reaching the JIRA server fails the test.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("offline dry run requested %s %s from JIRA", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	f, err := ioutil.TempFile("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(testSnapshot)
+	f.Close()
+
+	cfg, err := config.NewTestConfig(map[string]interface{}{
+		"dry-run":       true,
+		"jira-snapshot": f.Name(),
+		"jira-uri":      server.URL,
+		"timeout":       "1ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewJIRAClient(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.(dryrunJIRAClient); !ok {
+		t.Fatalf("NewJIRAClient() = %T, want a dry run client", client)
+	}
+	if id := cfg.GetFieldID(config.GitHubID); id != "10001" {
+		t.Errorf("GitHub ID field ID = %q, want 10001 from the snapshot", id)
+	}
+
+	keys := func(issues []jira.Issue) []string {
+		var keys []string
+		for _, issue := range issues {
+			keys = append(keys, issue.Key)
+		}
+		return keys
+	}
+	newIssue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{Summary: "New", Unknowns: map[string]interface{}{}}}
+
+	tests := []struct {
+		name    string
+		do      func() ([]string, error)
+		want    []string
+		wantErr bool
+	}{
+		// Searches return every issue; callers match them by GitHub ID.
+		{"list issues", func() ([]string, error) {
+			issues, err := client.ListIssues([]int{101})
+			return keys(issues), err
+		}, []string{"TEST-1", "TEST-2"}, false},
+		{"updated since", func() ([]string, error) {
+			issues, err := client.ListIssuesUpdatedSince(time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC))
+			return keys(issues), err
+		}, []string{"TEST-2"}, false},
+		{"get issue", func() ([]string, error) {
+			issue, err := client.GetIssue("TEST-2")
+			return []string{issue.Key}, err
+		}, []string{"TEST-2"}, false},
+		{"missing issue", func() ([]string, error) {
+			_, err := client.GetIssue("TEST-9")
+			return nil, err
+		}, nil, true},
+		{"create issue", func() ([]string, error) {
+			_, err := client.CreateIssue(newIssue)
+			return nil, err
+		}, nil, false},
+		{"update issue", func() ([]string, error) {
+			_, err := client.UpdateIssue(newIssue)
+			return nil, err
+		}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.do()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got issues %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotSearchTimeZone(t *testing.T) {
+	// The issue was updated at 10:00 UTC, i.e. 12:00 in Berlin.
+	issue := `{"key": "TEST-1", "fields": {"updated": "2026-06-01T10:00:00.000+0000"}}`
+
+	tests := []struct {
+		zone  string
+		since time.Time
+		want  int
+	}{
+		{"UTC", time.Date(2026, 6, 1, 9, 30, 0, 0, time.UTC), 1},
+		{"UTC", time.Date(2026, 6, 1, 10, 30, 0, 0, time.UTC), 0},
+		{"Europe/Berlin", time.Date(2026, 6, 1, 9, 30, 0, 0, time.UTC), 1},
+		{"Europe/Berlin", time.Date(2026, 6, 1, 10, 30, 0, 0, time.UTC), 0},
+		{"America/New_York", time.Date(2026, 6, 1, 10, 30, 0, 0, time.UTC), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.zone, tt.since.Format(time.Kitchen)), func(t *testing.T) {
+			loc, err := time.LoadLocation(tt.zone)
+			if err != nil {
+				t.Skipf("time zone data unavailable: %v", err)
+			}
+			f, err := ioutil.TempFile("", "snapshot")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(f.Name())
+			fmt.Fprintf(f, `{"project": {"key": "TEST"}, "fields": [], "issues": [%s], "timeZone": %q}`, issue, tt.zone)
+			f.Close()
+
+			s, err := loadSnapshot(f.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The query is written as ListIssuesUpdatedSince writes it.
+			jql := fmt.Sprintf("project='TEST' AND updated >= '%s'", tt.since.In(loc).Format(jqlDateFormat))
+			req, _ := http.NewRequest("GET", "https://jira.example.com/rest/api/2/search?jql="+url.QueryEscape(jql), nil)
+			res, err := s.RoundTrip(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var result struct {
+				Total int `json:"total"`
+			}
+			if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+				t.Fatal(err)
+			}
+			if result.Total != tt.want {
+				t.Errorf("search since %s found %d issues, want %d", tt.since, result.Total, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package limit
+
+import (
+	"sync"
+	"time"
+)
+
+// Limiter bounds the number of API calls which may be in flight at
+// once. A nil Limiter places no bound.
+type Limiter chan struct{}
+
+// New creates a Limiter allowing at most n concurrent calls. If n is
+// zero or negative, it returns nil, which is unlimited.
+func New(n int) Limiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(Limiter, n)
+}
+
+// Acquire blocks until a call may proceed.
+func (l Limiter) Acquire() {
+	if l != nil {
+		l <- struct{}{}
+	}
+}
+
+// Release marks a call started with Acquire as finished.
+func (l Limiter) Release() {
+	if l != nil {
+		<-l
+	}
+}
+
+// Throttle spaces calls at least an interval apart, and pauses after every
+// batch of calls, e.g. so that a burst of calls doesn't trip a server's abuse
+// detection. A nil Throttle doesn't delay calls. It is safe for concurrent
+// use; concurrent calls are spaced as well.
+type Throttle struct {
+	mu       sync.Mutex
+	interval time.Duration
+	batch    int
+	pause    time.Duration
+	next     time.Time
+	count    int
+}
+
+// NewThrottle creates a Throttle allowing at most rate calls per second,
+// pausing for the given duration after every batch calls. If rate is zero or
+// negative, calls aren't spaced; if batch is zero or negative, there are no
+// pauses. If neither limits the calls, it returns nil.
+func NewThrottle(rate float64, batch int, pause time.Duration) *Throttle {
+	if rate <= 0 && (batch <= 0 || pause <= 0) {
+		return nil
+	}
+	t := &Throttle{batch: batch, pause: pause}
+	if rate > 0 {
+		t.interval = time.Duration(float64(time.Second) / rate)
+	}
+	return t
+}
+
+// Wait blocks until a call may proceed.
+func (t *Throttle) Wait() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if d := time.Until(t.next); d > 0 {
+		time.Sleep(d)
+	}
+
+	t.count++
+	t.next = time.Now().Add(t.interval)
+	if t.batch > 0 && t.count%t.batch == 0 {
+		t.next = t.next.Add(t.pause)
+	}
+}
@@ -0,0 +1,123 @@
+package limit
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		min, max int
+	}{
+		{"unlimited", 0, 2, 10},
+		{"one", 1, 1, 1},
+		{"three", 3, 1, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(tt.n)
+
+			var mu sync.Mutex
+			var wg sync.WaitGroup
+			inFlight, max := 0, 0
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					l.Acquire()
+					defer l.Release()
+
+					mu.Lock()
+					inFlight++
+					if inFlight > max {
+						max = inFlight
+					}
+					mu.Unlock()
+					time.Sleep(10 * time.Millisecond)
+					mu.Lock()
+					inFlight--
+					mu.Unlock()
+				}()
+			}
+			wg.Wait()
+
+			if max < tt.min || max > tt.max {
+				t.Errorf("%d calls in flight, want %d to %d", max, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestNewThrottle(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    float64
+		batch   int
+		pause   time.Duration
+		wantNil bool
+	}{
+		{"unlimited", 0, 0, 0, true},
+		{"batch without pause", 0, 10, 0, true},
+		{"pause without batch", 0, 0, time.Second, true},
+		{"rate", 2, 0, 0, false},
+		{"batch", 0, 10, time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewThrottle(tt.rate, tt.batch, tt.pause); (got == nil) != tt.wantNil {
+				t.Errorf("got throttle %v, want nil: %v", got, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestThrottleWait(t *testing.T) {
+	tests := []struct {
+		name       string
+		rate       float64
+		batch      int
+		pause      time.Duration
+		calls      int
+		concurrent bool
+		min        time.Duration
+	}{
+		// The first call isn't delayed, each of the others by the interval.
+		{"rate", 100, 0, 0, 5, false, 40 * time.Millisecond},
+		{"rate concurrent", 100, 0, 0, 5, true, 40 * time.Millisecond},
+		// Pauses follow the second and fourth calls.
+		{"batch", 0, 2, 30 * time.Millisecond, 5, false, 60 * time.Millisecond},
+		{"rate and batch", 100, 2, 30 * time.Millisecond, 5, false, 100 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			throttle := NewThrottle(tt.rate, tt.batch, tt.pause)
+
+			start := time.Now()
+			if tt.concurrent {
+				var wg sync.WaitGroup
+				for i := 0; i < tt.calls; i++ {
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						throttle.Wait()
+					}()
+				}
+				wg.Wait()
+			} else {
+				for i := 0; i < tt.calls; i++ {
+					throttle.Wait()
+				}
+			}
+
+			if elapsed := time.Since(start); elapsed < tt.min {
+				t.Errorf("%d calls took %v, want at least %v", tt.calls, elapsed, tt.min)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package sync
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	gosync "sync"
+)
+
+// checkpoint records the GitHub issues which have been processed during a
+// run in a file, one ID per line, so that a run which is interrupted can be
+// resumed without re-processing them. A nil checkpoint records nothing. It
+// may be used by repositories synced in parallel.
+type checkpoint struct {
+	mu   gosync.Mutex
+	path string
+	done map[int]bool
+}
+
+// loadCheckpoint reads the checkpoint file at path, if it exists, and returns
+// a checkpoint holding the IDs of the already processed issues.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{
+		path: path,
+		done: map[int]bool{},
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		// A partially written last line is ignored; the issue is simply processed again.
+		if id, err := strconv.Atoi(line); err == nil {
+			cp.done[id] = true
+		}
+	}
+
+	return cp, scanner.Err()
+}
+
+// IsDone returns whether the issue with the given GitHub ID was already
+// processed.
+func (cp *checkpoint) IsDone(id int) bool {
+	if cp == nil {
+		return false
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	return cp.done[id]
+}
+
+// MarkDone appends the GitHub ID of a processed issue to the checkpoint file.
+func (cp *checkpoint) MarkDone(id int) error {
+	if cp == nil {
+		return nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	f, err := os.OpenFile(cp.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintln(f, id); err != nil {
+		return err
+	}
+	cp.done[id] = true
+
+	return nil
+}
+
+// Clear removes the checkpoint file once a run has completed.
+func (cp *checkpoint) Clear() error {
+	if cp == nil {
+		return nil
+	}
+
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+
+	if err := os.Remove(cp.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	cp.done = map[int]bool{}
+
+	return nil
+}
@@ -0,0 +1,193 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+// tempCheckpoint returns the path of a checkpoint file in a new temporary
+// directory, and a function removing the directory.
+func tempCheckpoint(t *testing.T) (string, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "issue-sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, "checkpoint"), func() { os.RemoveAll(dir) }
+}
+
+func TestLoadCheckpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		contents *string
+		want     []int
+	}{
+		{"no file", nil, nil},
+		{"empty", github.String(""), nil},
+		{"ids", github.String("1\n2\n3\n"), []int{1, 2, 3}},
+		{"blank lines", github.String("1\n\n  \n2\n"), []int{1, 2}},
+		{"partially written line", github.String("1\n2\n3x"), []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := tempCheckpoint(t)
+			defer cleanup()
+			if tt.contents != nil {
+				if err := ioutil.WriteFile(path, []byte(*tt.contents), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cp, err := loadCheckpoint(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for id := range cp.done {
+				got = append(got, id)
+			}
+			sort.Ints(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkpoint holds %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// listingClient is a JIRA client holding fixed JIRA issues, which records the
+// GitHub IDs of the issues listed.
+type listingClient struct {
+	jClient.JIRAClient
+	issues []jira.Issue
+	listed *[]int
+}
+
+func (c listingClient) ListIssues(ids []int) ([]jira.Issue, error) {
+	*c.listed = append(*c.listed, ids...)
+	return c.issues, nil
+}
+
+func (c listingClient) GetIssue(key string) (jira.Issue, error) {
+	for _, jIssue := range c.issues {
+		if jIssue.Key == key {
+			return jIssue, nil
+		}
+	}
+	return jira.Issue{}, fmt.Errorf("no JIRA issue %s", key)
+}
+
+func TestCheckpointResume(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+
+	// GitHub issues 1 to 4, each already synced to JIRA.
+	var ghIssues []github.Issue
+	var jIssues []jira.Issue
+	for id := 1; id <= 4; id++ {
+		ghIssue := testIssue("Title", "Body")
+		ghIssue.ID = github.Int(id)
+		ghIssues = append(ghIssues, ghIssue)
+		jIssue := syncedIssue(cfg, ghIssue, time.Now())
+		jIssue.Key = fmt.Sprintf("TEST-%d", id)
+		jIssues = append(jIssues, jIssue)
+	}
+
+	tests := []struct {
+		name   string
+		issues []github.Issue
+		listed []int
+	}{
+		// The run is interrupted after the first two issues.
+		{"interrupted run", ghIssues[:2], []int{1, 2}},
+		{"resumed run", ghIssues, []int{3, 4}},
+		{"resumed again", ghIssues, nil},
+	}
+
+	path, cleanup := tempCheckpoint(t)
+	defer cleanup()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Each run loads the checkpoint file anew.
+			cp, err := loadCheckpoint(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var listed []int
+			client := listingClient{issues: jIssues, listed: &listed}
+
+			if _, err := compareIssues(cfg, tt.issues, nil, client, cp); err != nil {
+				t.Fatal(err)
+			}
+			sort.Ints(listed)
+			if !reflect.DeepEqual(listed, tt.listed) {
+				t.Errorf("issues %v processed, want %v", listed, tt.listed)
+			}
+		})
+	}
+}
+
+// failingListClient is a JIRA client which fails to list issues.
+type failingListClient struct {
+	jClient.JIRAClient
+}
+
+func (failingListClient) ListIssues(ids []int) ([]jira.Issue, error) {
+	return nil, errors.New("JIRA unavailable")
+}
+
+func TestSyncClearsCheckpoint(t *testing.T) {
+	tests := []struct {
+		name    string
+		failing bool
+		kept    bool
+	}{
+		{"successful run", false, false},
+		{"failed run", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, cleanup := tempCheckpoint(t)
+			defer cleanup()
+			if err := ioutil.WriteFile(path, []byte("1\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := newTestConfig(t, map[string]interface{}{
+				"search-query":    "is:issue",
+				"checkpoint-file": path,
+			})
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.ID = github.Int(2)
+
+			var client jClient.JIRAClient = listingClient{
+				issues: []jira.Issue{syncedIssue(cfg, ghIssue, time.Now())},
+				listed: &[]int{},
+			}
+			if tt.failing {
+				client = failingListClient{}
+			}
+
+			if err := Sync(cfg, searchClient{issues: []github.Issue{ghIssue}}, client); (err != nil) != tt.failing {
+				t.Fatalf("Sync() = %v, want error %v", err, tt.failing)
+			}
+
+			_, err := os.Stat(path)
+			if kept := err == nil; kept != tt.kept {
+				t.Errorf("checkpoint file kept = %v, want %v", kept, tt.kept)
+			}
+		})
+	}
+}
@@ -1,11 +1,16 @@
 package sync
 
 import (
+	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/andygrunwald/go-jira"
 	"github.com/innovocloud/issue-sync/pkg/config"
+	"github.com/innovocloud/issue-sync/pkg/convert"
 	ghClient "github.com/innovocloud/issue-sync/pkg/github"
 	jClient "github.com/innovocloud/issue-sync/pkg/jira"
 	"github.com/google/go-github/github"
@@ -14,7 +19,7 @@ import (
 // jCommentRegex matches a generated JIRA comment. It has matching groups to retrieve the
 // GitHub Comment ID (\1), the GitHub username (\2), the GitHub real name (\3, if it exists),
 // the time the comment was posted (\3 or \4), and the body of the comment (\4 or \5).
-var jCommentRegex = regexp.MustCompile("^Comment \\[\\(ID (\\d+)\\)\\|.*?] from GitHub user \\[(\\w+)\\|.*?] \\((.+)\\)? at (.+):\\n\\n(.+)$")
+var jCommentRegex = regexp.MustCompile("^Comment \\[\\(ID (\\d+)\\)\\|.*?] from GitHub user \\[([^|]+)\\|.*?](?: \\((.+?)\\))? at (.+?):\\n\\n(?s:(.*))$")
 
 // jCommentIDRegex just matches the beginning of a generated JIRA comment. It's a smaller,
 // simpler, and more efficient regex, to quickly filter only generated comments and retrieve
@@ -37,6 +42,12 @@ func CompareComments(config config.Config, ghIssue github.Issue, jIssue jira.Iss
 		return err
 	}
 
+	// Create the comments in the order they were posted, so that the JIRA
+	// conversation reads the same as the GitHub one.
+	sort.SliceStable(ghComments, func(i, j int) bool {
+		return ghComments[i].GetCreatedAt().Before(ghComments[j].GetCreatedAt())
+	})
+
 	var jComments []jira.Comment
 	if jIssue.Fields.Comments == nil {
 		log.Debugf("JIRA issue %s has no comments.", jIssue.Key)
@@ -49,24 +60,50 @@ func CompareComments(config config.Config, ghIssue github.Issue, jIssue jira.Iss
 		log.Debugf("JIRA issue %s has %d comments", jIssue.Key, len(jComments))
 	}
 
+	if config.IsDedupingComments() {
+		jComments, err = removeDuplicateComments(config, jIssue, jComments, jClient)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The JIRA copies of each GitHub comment, in order of their parts if the
+	// comment was split.
+	copies := map[int][]jira.Comment{}
+	for _, jComment := range jComments {
+		if id, ok := commentGitHubID(config, jComment.Body); ok {
+			copies[id] = append(copies[id], jComment)
+		}
+	}
+	for _, c := range copies {
+		sort.SliceStable(c, func(i, j int) bool {
+			return commentPart(config, c[i].Body) < commentPart(config, c[j].Body)
+		})
+	}
+
+	commentSince, hasCommentSince := config.GetCommentSince()
+
 	for _, ghComment := range ghComments {
-		found := false
-		for _, jComment := range jComments {
-			if !jCommentIDRegex.MatchString(jComment.Body) {
-				continue
-			}
-			// matches[0] is the whole string, matches[1] is the ID
-			matches := jCommentIDRegex.FindStringSubmatch(jComment.Body)
-			id, _ := strconv.Atoi(matches[1])
-			if *ghComment.ID != id {
-				continue
-			}
-			found = true
+		if _, ok := mirroredCommentID(config, ghComment.GetBody()); ok {
+			// Copied from JIRA; copying it back would duplicate the original.
+			continue
+		}
 
-			UpdateComment(config, *ghComment, jComment, jIssue, ghClient, jClient)
-			break
+		ghComment := syncableComment(config, *ghComment)
+		existing := copies[ghComment.GetID()]
+		switch {
+		case len(existing) == 1 && len(commentChunks(config, commentBody(config, *ghComment))) == 1:
+			UpdateComment(config, *ghComment, existing[0], jIssue, ghClient, jClient)
+			continue
+		case len(existing) > 0:
+			if err := updateChunkedComment(config, *ghComment, existing, jIssue, ghClient, jClient); err != nil {
+				log.Errorf("Error updating the JIRA comments of GitHub comment %d: %v", ghComment.GetID(), err)
+			}
+			continue
 		}
-		if found {
+
+		if hasCommentSince && ghComment.GetCreatedAt().Before(commentSince) {
+			log.Debugf("Skipping GitHub comment %d, created before %s", ghComment.GetID(), commentSince.Format(time.RFC3339))
 			continue
 		}
 
@@ -82,6 +119,133 @@ func CompareComments(config config.Config, ghIssue github.Issue, jIssue jira.Iss
 	return nil
 }
 
+// removeDuplicateComments deletes the JIRA comments copied from a GitHub
+// comment which already has an earlier copy on the issue, e.g. because the
+// issue was relinked, and returns the remaining comments. Only comments
+// created by issue-sync are considered; the parts of a split comment are
+// copies of different parts. JIRA lists comments oldest first. The duplicates
+// are all collected before any is deleted, so a dry run lists exactly those a
+// real run deletes.
+func removeDuplicateComments(cfg config.Config, jIssue jira.Issue, jComments []jira.Comment, jiraClient jClient.JIRAClient) ([]jira.Comment, error) {
+	log := cfg.GetLogger()
+
+	kept, duplicates := duplicateComments(cfg, jComments)
+	if len(duplicates) == 0 {
+		return kept, nil
+	}
+
+	log.Debugf("Deleting %d duplicate comments on JIRA issue %s", len(duplicates), jIssue.Key)
+	err := deleteComments(cfg.GetDeleteWorkers(), duplicates, func(jComment jira.Comment) error {
+		if err := jiraClient.DeleteComment(jIssue, jComment.ID); err != nil {
+			return err
+		}
+		id, _ := commentGitHubID(cfg, jComment.Body)
+		log.Infof("Deleted JIRA comment %s on issue %s, a duplicate copy of GitHub comment %d", jComment.ID, jIssue.Key, id)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return kept, nil
+}
+
+// duplicateComments splits JIRA comments into those to keep and the later
+// copies of a GitHub comment, or of a part of one, which are to be deleted.
+func duplicateComments(cfg config.Config, jComments []jira.Comment) ([]jira.Comment, []jira.Comment) {
+	type copyOf struct{ id, part int }
+	seen := map[copyOf]bool{}
+	var kept, duplicates []jira.Comment
+	for _, jComment := range jComments {
+		id, ok := commentGitHubID(cfg, jComment.Body)
+		key := copyOf{id, commentPart(cfg, jComment.Body)}
+		if !ok || !seen[key] {
+			if ok {
+				seen[key] = true
+			}
+			kept = append(kept, jComment)
+			continue
+		}
+		duplicates = append(duplicates, jComment)
+	}
+	return kept, duplicates
+}
+
+// commentGitHubID returns the ID of the GitHub comment a JIRA comment was
+// copied from, and whether it is such a copy. The hidden comment marker is
+// used if present; comments created before markers were added are matched
+// by the ID in their header instead.
+func commentGitHubID(cfg config.Config, body string) (int, bool) {
+	if id, _, ok := jClient.ParseCommentMarker(cfg, body); ok {
+		return id, true
+	}
+
+	// matches[0] is the whole string, matches[1] is the ID
+	matches := jCommentIDRegex.FindStringSubmatch(body)
+	if matches == nil {
+		return 0, false
+	}
+	id, _ := strconv.Atoi(matches[1])
+	return id, true
+}
+
+// commentPart returns the part of its GitHub comment a JIRA comment holds,
+// which is 1 unless the comment was split.
+func commentPart(cfg config.Config, body string) int {
+	return jClient.ParseCommentPart(cfg, body)
+}
+
+// commentChunks returns the bodies of the JIRA comments a GitHub comment body
+// is copied to.
+func commentChunks(cfg config.Config, body string) []string {
+	return jClient.CommentChunks(cfg, body)
+}
+
+// stripCommentMarker returns the body of a JIRA comment without its hidden
+// comment marker, if it has one.
+func stripCommentMarker(cfg config.Config, body string) string {
+	_, body, _ = jClient.ParseCommentMarker(cfg, body)
+	return body
+}
+
+// stripCommentHistory returns the body of a JIRA comment after its header
+// without the history of its edits.
+func stripCommentHistory(cfg config.Config, body string) string {
+	return jClient.StripCommentHistory(cfg, body)
+}
+
+// syncableComment returns the GitHub comment to copy to JIRA in place of one
+// with content the configured converter can't represent: a comment linking to
+// the original, with the configured text. Without the text, the comment is
+// copied as it is.
+func syncableComment(cfg config.Config, ghComment github.IssueComment) *github.IssueComment {
+	log := cfg.GetLogger()
+
+	checker, ok := cfg.GetConverter().(convert.Checker)
+	if !ok {
+		return &ghComment
+	}
+	err := checker.Check(ghComment.GetBody())
+	if err == nil {
+		return &ghComment
+	}
+
+	text := cfg.GetUnsyncableComment()
+	if text == "" {
+		log.Debugf("GitHub comment %d can't be fully copied: %v", ghComment.GetID(), err)
+		return &ghComment
+	}
+	log.Debugf("Copying a link to GitHub comment %d, as %v", ghComment.GetID(), err)
+	body := fmt.Sprintf("%s [View the comment on GitHub|%s]", text, ghComment.GetHTMLURL())
+	ghComment.Body = &body
+	return &ghComment
+}
+
+// commentBody returns the body of a GitHub comment as it is copied to JIRA.
+func commentBody(cfg config.Config, ghComment github.IssueComment) string {
+	return jClient.CommentBody(cfg, ghComment)
+}
+
 // UpdateComment compares the body of a GitHub comment with the body (minus header)
 // of the JIRA comment, and updates the JIRA comment if necessary.
 func UpdateComment(config config.Config, ghComment github.IssueComment, jComment jira.Comment, jIssue jira.Issue, ghClient ghClient.GitHubClient, jClient jClient.JIRAClient) error {
@@ -89,9 +253,9 @@ func UpdateComment(config config.Config, ghComment github.IssueComment, jComment
 
 	// fields[0] is the whole body, 1 is the ID, 2 is the username, 3 is the real name (or "" if none)
 	// 4 is the date, and 5 is the real body
-	fields := jCommentRegex.FindStringSubmatch(jComment.Body)
+	fields := jCommentRegex.FindStringSubmatch(stripCommentMarker(config, jComment.Body))
 
-	if fields[5] == ghComment.GetBody() {
+	if fields != nil && stripCommentHistory(config, fields[5]) == commentBody(config, ghComment) {
 		return nil
 	}
 
@@ -104,3 +268,40 @@ func UpdateComment(config config.Config, ghComment github.IssueComment, jComment
 
 	return nil
 }
+
+// updateChunkedComment compares the body of a GitHub comment with the bodies
+// of the JIRA comments it was split into, and replaces them if necessary. The
+// JIRA comments are deleted and created again, as the number of parts may
+// change; this moves them to the end of the JIRA conversation.
+func updateChunkedComment(config config.Config, ghComment github.IssueComment, jComments []jira.Comment, jIssue jira.Issue, ghClient ghClient.GitHubClient, jClient jClient.JIRAClient) error {
+	log := config.GetLogger()
+
+	chunks := commentChunks(config, commentBody(config, ghComment))
+	if len(chunks) == len(jComments) {
+		// Whitespace at the ends of a part may not survive JIRA; comparing
+		// with it would replace the parts on every run.
+		same := true
+		for i, jComment := range jComments {
+			fields := jCommentRegex.FindStringSubmatch(stripCommentMarker(config, jComment.Body))
+			same = same && fields != nil && strings.TrimSpace(fields[5]) == strings.TrimSpace(chunks[i])
+		}
+		if same {
+			return nil
+		}
+	}
+
+	err := deleteComments(config.GetDeleteWorkers(), jComments, func(jComment jira.Comment) error {
+		return jClient.DeleteComment(jIssue, jComment.ID)
+	})
+	if err != nil {
+		return err
+	}
+	comment, err := jClient.CreateComment(jIssue, ghComment, ghClient)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Replaced the JIRA comments of GitHub comment %d, starting with %s.", ghComment.GetID(), comment.ID)
+
+	return nil
+}
@@ -0,0 +1,476 @@
+package sync
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+func TestSyncableComment(t *testing.T) {
+	const video = "Demo: https://github.com/user-attachments/assets/1/demo.mp4"
+	url := "https://github.com/org/repo/issues/1#issuecomment-2"
+
+	tests := []struct {
+		name        string
+		placeholder string
+		body        string
+		want        string
+	}{
+		{"representable", "Video on GitHub.", "Fixed.", "Fixed."},
+		{"without placeholder", "", video, video},
+		{"with placeholder", "Video on GitHub.", video, "Video on GitHub. [View the comment on GitHub|" + url + "]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"unsyncable-comment": tt.placeholder})
+			ghComment := github.IssueComment{ID: github.Int(2), Body: github.String(tt.body), HTMLURL: github.String(url)}
+
+			got := syncableComment(cfg, ghComment)
+			if got.GetBody() != tt.want {
+				t.Errorf("syncableComment() body = %q, want %q", got.GetBody(), tt.want)
+			}
+			if got.GetID() != 2 {
+				t.Errorf("syncableComment() ID = %d, want 2", got.GetID())
+			}
+			if ghComment.GetBody() != tt.body {
+				t.Error("syncableComment() modified the original comment")
+			}
+			if !strings.Contains(commentBody(cfg, *got), tt.want) {
+				t.Errorf("commentBody() = %q, want it to contain %q", commentBody(cfg, *got), tt.want)
+			}
+		})
+	}
+}
+
+// deletingClient is a JIRA client which records the IDs of the comments it
+// deletes.
+type deletingClient struct {
+	jClient.JIRAClient
+	mu      *gosync.Mutex
+	deleted map[string]bool
+}
+
+func (c deletingClient) DeleteComment(issue jira.Issue, id string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted[id] = true
+	return nil
+}
+
+func TestRemoveDuplicateComments(t *testing.T) {
+	// The copies of GitHub comments 5 and 6, oldest first: 5 was copied
+	// three times, and 6 split in two parts and its first part copied twice.
+	bodies := []struct{ id, body string }{
+		{"1", "{anchor:issue-sync-5}first copy"},
+		{"2", "A comment written in JIRA"},
+		{"3", "{anchor:issue-sync-5}second copy"},
+		{"4", "{anchor:issue-sync-6}part one"},
+		{"5", "{anchor:issue-sync-6-2}part two"},
+		{"6", "{anchor:issue-sync-6}part one again"},
+		{"7", "{anchor:issue-sync-5}third copy"},
+	}
+	var jComments []jira.Comment
+	for _, b := range bodies {
+		jComments = append(jComments, jira.Comment{ID: b.id, Body: b.body})
+	}
+
+	for _, workers := range []int{1, 4} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"delete-workers": workers})
+			client := deletingClient{mu: &gosync.Mutex{}, deleted: map[string]bool{}}
+
+			_, collected := duplicateComments(cfg, jComments)
+			kept, err := removeDuplicateComments(cfg, jira.Issue{Key: "TEST-1"}, jComments, client)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := map[string]bool{"3": true, "6": true, "7": true}
+			if len(collected) != len(want) {
+				t.Errorf("collected %d duplicates, want %d", len(collected), len(want))
+			}
+			for _, c := range collected {
+				if !want[c.ID] {
+					t.Errorf("collected comment %s, which isn't a duplicate", c.ID)
+				}
+			}
+			if !reflect.DeepEqual(client.deleted, want) {
+				t.Errorf("deleted %v, want the collected duplicates %v", client.deleted, want)
+			}
+
+			var keptIDs []string
+			for _, c := range kept {
+				keptIDs = append(keptIDs, c.ID)
+			}
+			if !reflect.DeepEqual(keptIDs, []string{"1", "2", "4", "5"}) {
+				t.Errorf("kept %v, want [1 2 4 5]", keptIDs)
+			}
+		})
+	}
+}
+
+// commentsClient is a JIRA client holding the comments of one issue, which
+// it copies GitHub comments to in the format of the real client.
+type commentsClient struct {
+	jClient.JIRAClient
+	cfg      config.Config
+	comments *[]*jira.Comment
+	created  *int
+	deleted  *int
+}
+
+func (c commentsClient) CreateComment(issue jira.Issue, comment github.IssueComment, gh ghClient.GitHubClient) (jira.Comment, error) {
+	chunks := jClient.CommentChunks(c.cfg, commentBody(c.cfg, comment))
+	var first jira.Comment
+	for i, chunk := range chunks {
+		marker := jClient.CommentMarker(c.cfg, comment.GetID())
+		header := fmt.Sprintf("Comment [(ID %d)|%s] from GitHub user [%s|%s] at %s",
+			comment.GetID(), comment.GetHTMLURL(), comment.User.GetLogin(), comment.User.GetHTMLURL(), comment.GetCreatedAt().Format(time.RFC1123))
+		if len(chunks) > 1 {
+			if i > 0 {
+				marker = fmt.Sprintf("{anchor:%s-%d-%d}", c.cfg.GetCommentMarker(), comment.GetID(), i+1)
+			}
+			header += fmt.Sprintf(" (part %d of %d)", i+1, len(chunks))
+		}
+		*c.created++
+		jComment := &jira.Comment{ID: strconv.Itoa(*c.created), Body: marker + header + ":\n\n" + chunk}
+		*c.comments = append(*c.comments, jComment)
+		if i == 0 {
+			first = *jComment
+		}
+	}
+	return first, nil
+}
+
+func (c commentsClient) DeleteComment(issue jira.Issue, id string) error {
+	*c.deleted++
+	for i, jComment := range *c.comments {
+		if jComment.ID == id {
+			*c.comments = append((*c.comments)[:i], (*c.comments)[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// listCommentsClient is a GitHub client listing fixed comments.
+type listCommentsClient struct {
+	ghClient.GitHubClient
+	comments []*github.IssueComment
+}
+
+func (c listCommentsClient) ListComments(issue github.Issue) ([]*github.IssueComment, error) {
+	return c.comments, nil
+}
+
+func TestCompareChunkedComment(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{"chunk-comments": true})
+
+	// A body of paragraphs, long enough for three JIRA comments.
+	paragraph := strings.Repeat("word ", 1000)
+	long := strings.Repeat(paragraph+"\n\n", 14)
+	if n := len(jClient.CommentChunks(cfg, long)); n != 3 {
+		t.Fatalf("test comment splits into %d chunks, want 3", n)
+	}
+
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ghComment := &github.IssueComment{
+		ID:        github.Int(2),
+		Body:      github.String(long),
+		User:      &github.User{Login: github.String("octocat"), HTMLURL: github.String("https://github.com/octocat")},
+		HTMLURL:   github.String("https://github.com/o/r/issues/1#issuecomment-2"),
+		CreatedAt: &created,
+	}
+	ghIssue := testIssue("Title", "Body")
+	ghIssue.Comments = github.Int(1)
+	gh := listCommentsClient{comments: []*github.IssueComment{ghComment}}
+
+	var comments []*jira.Comment
+	var nCreated, nDeleted int
+	client := commentsClient{cfg: cfg, comments: &comments, created: &nCreated, deleted: &nDeleted}
+
+	tests := []struct {
+		name             string
+		body             string
+		created, deleted int
+	}{
+		{"first sync", long, 3, 0},
+		{"unchanged", long, 3, 0},
+		{"unchanged again", long, 3, 0},
+		{"edited", long + "Edited.", 6, 3},
+		{"unchanged after edit", long + "Edited.", 6, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghComment.Body = github.String(tt.body)
+			jIssue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{
+				Comments: &jira.Comments{Comments: append([]*jira.Comment(nil), comments...)},
+			}}
+
+			if err := CompareComments(cfg, ghIssue, jIssue, gh, client); err != nil {
+				t.Fatal(err)
+			}
+			if nCreated != tt.created || nDeleted != tt.deleted {
+				t.Errorf("%d comments created and %d deleted, want %d and %d", nCreated, nDeleted, tt.created, tt.deleted)
+			}
+			if len(comments) != 3 {
+				t.Errorf("JIRA issue has %d comments, want 3", len(comments))
+			}
+		})
+	}
+}
+
+func TestCommentOrder(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+	base := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	comment := func(id int, posted time.Duration) *github.IssueComment {
+		created := base.Add(posted)
+		return &github.IssueComment{
+			ID:        github.Int(id),
+			Body:      github.String(fmt.Sprintf("Comment %d", id)),
+			User:      &github.User{Login: github.String("octocat")},
+			CreatedAt: &created,
+		}
+	}
+
+	tests := []struct {
+		name     string
+		comments []*github.IssueComment
+		want     []int
+	}{
+		{"chronological", []*github.IssueComment{comment(1, 0), comment(2, time.Minute), comment(3, time.Hour)}, []int{1, 2, 3}},
+		{"reversed", []*github.IssueComment{comment(3, time.Hour), comment(2, time.Minute), comment(1, 0)}, []int{1, 2, 3}},
+		{"shuffled", []*github.IssueComment{comment(2, time.Minute), comment(3, time.Hour), comment(1, 0)}, []int{1, 2, 3}},
+		{"same time keeps listing order", []*github.IssueComment{comment(5, 0), comment(4, 0)}, []int{5, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.Comments = github.Int(len(tt.comments))
+			gh := listCommentsClient{comments: tt.comments}
+
+			var comments []*jira.Comment
+			var nCreated, nDeleted int
+			client := commentsClient{cfg: cfg, comments: &comments, created: &nCreated, deleted: &nDeleted}
+			jIssue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{}}
+
+			if err := CompareComments(cfg, ghIssue, jIssue, gh, client); err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, jComment := range comments {
+				id, _, _ := jClient.ParseCommentMarker(cfg, jComment.Body)
+				got = append(got, id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("comments created in order %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommentSince(t *testing.T) {
+	early := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	late := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	ghComments := []*github.IssueComment{
+		{ID: github.Int(1), Body: github.String("Early"), User: &github.User{Login: github.String("octocat")}, CreatedAt: &early},
+		{ID: github.Int(2), Body: github.String("Late"), User: &github.User{Login: github.String("octocat")}, CreatedAt: &late},
+	}
+
+	tests := []struct {
+		name   string
+		since  string
+		copied bool
+		want   []int
+	}{
+		{"no cutoff", "", false, []int{1, 2}},
+		{"cutoff", "2020-03-01T00:00:00+0000", false, []int{2}},
+		{"cutoff after all comments", "2020-12-01T00:00:00+0000", false, nil},
+		{"copied before the cutoff", "2020-03-01T00:00:00+0000", true, []int{1, 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.Comments = github.Int(len(ghComments))
+			gh := listCommentsClient{comments: ghComments}
+
+			var comments []*jira.Comment
+			var nCreated, nDeleted int
+			if tt.copied {
+				// Both comments were copied before the cutoff was configured.
+				cfg := newTestConfig(t, nil)
+				client := commentsClient{cfg: cfg, comments: &comments, created: &nCreated, deleted: &nDeleted}
+				if err := CompareComments(cfg, ghIssue, jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{}}, gh, client); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfg := newTestConfig(t, map[string]interface{}{"comment-since": tt.since})
+			client := commentsClient{cfg: cfg, comments: &comments, created: &nCreated, deleted: &nDeleted}
+			jIssue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{
+				Comments: &jira.Comments{Comments: append([]*jira.Comment(nil), comments...)},
+			}}
+			if err := CompareComments(cfg, ghIssue, jIssue, gh, client); err != nil {
+				t.Fatal(err)
+			}
+
+			var got []int
+			for _, jComment := range comments {
+				id, _, _ := jClient.ParseCommentMarker(cfg, jComment.Body)
+				got = append(got, id)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JIRA comments copy GitHub comments %v, want %v", got, tt.want)
+			}
+			if nCreated != len(tt.want) || nDeleted != 0 {
+				t.Errorf("%d comments created and %d deleted, want %d and 0", nCreated, nDeleted, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestCompareDedupesComments(t *testing.T) {
+	created := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	ghComments := []*github.IssueComment{
+		{ID: github.Int(1), Body: github.String("First"), User: &github.User{Login: github.String("octocat")}, CreatedAt: &created},
+		{ID: github.Int(2), Body: github.String("Second"), User: &github.User{Login: github.String("octocat")}, CreatedAt: &created},
+	}
+	ghIssue := testIssue("Title", "Body")
+	ghIssue.Comments = github.Int(len(ghComments))
+	gh := listCommentsClient{comments: ghComments}
+
+	tests := []struct {
+		name             string
+		dedupe           bool
+		created, deleted int
+		want             []string
+	}{
+		{"dedupe", true, 4, 2, []string{"1", "2", "10", "11"}},
+		// Without deduplication, the copies are taken for the parts of a
+		// split comment, and rewritten as one.
+		{"no dedupe", false, 6, 4, []string{"10", "11", "5", "6"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"dedupe-comments": tt.dedupe})
+
+			// The relinked issue holds the copies made for it and for the
+			// duplicate issue, and two identical comments written in JIRA.
+			var comments []*jira.Comment
+			var nCreated, nDeleted int
+			client := commentsClient{cfg: cfg, comments: &comments, created: &nCreated, deleted: &nDeleted}
+			for i := 0; i < 2; i++ {
+				if err := CompareComments(cfg, ghIssue, jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{}}, gh, client); err != nil {
+					t.Fatal(err)
+				}
+			}
+			comments = append(comments, &jira.Comment{ID: "10", Body: "Written in JIRA"}, &jira.Comment{ID: "11", Body: "Written in JIRA"})
+			jIssue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{
+				Comments: &jira.Comments{Comments: append([]*jira.Comment(nil), comments...)},
+			}}
+
+			if err := CompareComments(cfg, ghIssue, jIssue, gh, client); err != nil {
+				t.Fatal(err)
+			}
+			if nCreated != tt.created || nDeleted != tt.deleted {
+				t.Errorf("%d comments created and %d deleted, want %d and %d", nCreated, nDeleted, tt.created, tt.deleted)
+			}
+
+			var got []string
+			for _, jComment := range comments {
+				got = append(got, jComment.ID)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("JIRA issue has comments %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// eventsClient is a GitHub client listing a fixed timeline.
+type eventsClient struct {
+	ghClient.GitHubClient
+	events []*github.Timeline
+}
+
+func (c eventsClient) ListTimeline(issue github.Issue) ([]*github.Timeline, error) {
+	return c.events, nil
+}
+
+// eventClient is a JIRA client which records the IDs of the events it is
+// asked to create comments for.
+type eventClient struct {
+	jClient.JIRAClient
+	created *[]int
+}
+
+func (c eventClient) CreateEventComment(issue jira.Issue, event github.Timeline) (jira.Comment, error) {
+	*c.created = append(*c.created, event.GetID())
+	return jira.Comment{}, nil
+}
+
+func TestCompareEvents(t *testing.T) {
+	event := func(id int, typ string) *github.Timeline {
+		return &github.Timeline{ID: github.Int(id), Event: github.String(typ)}
+	}
+	timeline := []*github.Timeline{
+		event(1, "labeled"),
+		event(2, "closed"),
+		event(3, "subscribed"),
+		{Event: github.String("closed")},
+		event(4, "labeled"),
+	}
+
+	tests := []struct {
+		name   string
+		types  []string
+		synced []string
+		want   []int
+	}{
+		{"disabled", nil, nil, nil},
+		{"configured types", []string{"labeled", "closed"}, nil, []int{1, 2, 4}},
+		{"one type", []string{"closed"}, nil, []int{2}},
+		{"already synced", []string{"labeled", "closed"}, []string{
+			"GitHub event (ID 1): label 'bug' added by [octocat|url] at now",
+			"GitHub event (ID 2): closed by [octocat|url] at now",
+		}, []int{4}},
+		{"other comments", []string{"labeled"}, []string{
+			"Mentions GitHub event (ID 1)",
+			"GitHub event (ID 10): label 'bug' added by [octocat|url] at now",
+		}, []int{1, 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"timeline-events": tt.types})
+			jIssue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{Comments: &jira.Comments{}}}
+			for _, body := range tt.synced {
+				jIssue.Fields.Comments.Comments = append(jIssue.Fields.Comments.Comments, &jira.Comment{Body: body})
+			}
+
+			var created []int
+			err := CompareEvents(cfg, testIssue("Title", "Body"), jIssue, eventsClient{events: timeline}, eventClient{created: &created})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(created, tt.want) {
+				t.Errorf("events %v synced, want %v", created, tt.want)
+			}
+		})
+	}
+}
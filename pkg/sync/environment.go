@@ -0,0 +1,68 @@
+package sync
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+)
+
+// headingRegex matches a Markdown ATX heading, capturing its text.
+var headingRegex = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+
+// environmentData is the data the environment and summary templates are
+// executed with. It exposes all of the fields of the GitHub issue, e.g.
+// `{{.Title}}`.
+type environmentData struct {
+	*github.Issue
+}
+
+// Section returns the text under the Markdown heading with the given name in
+// the issue body, up to the next heading. This is how GitHub renders the
+// answers of issue forms. Unanswered sections, shown by GitHub as
+// "_No response_", are returned empty.
+func (d environmentData) Section(name string) string {
+	var section []string
+	found := false
+
+	for _, line := range strings.Split(d.GetBody(), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if matches := headingRegex.FindStringSubmatch(line); matches != nil {
+			if found {
+				break
+			}
+			found = strings.EqualFold(matches[1], name)
+			continue
+		}
+		if found {
+			section = append(section, line)
+		}
+	}
+
+	text := strings.TrimSpace(strings.Join(section, "\n"))
+	if text == "_No response_" {
+		return ""
+	}
+	return text
+}
+
+// issueEnvironment executes the configured environment template against the
+// GitHub issue. It returns false if no template is configured, or if it fails.
+func issueEnvironment(cfg config.Config, ghIssue github.Issue) (string, bool) {
+	log := cfg.GetLogger()
+
+	tmpl := cfg.GetEnvironmentTemplate()
+	if tmpl == nil {
+		return "", false
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, environmentData{&ghIssue}); err != nil {
+		log.Errorf("Error executing environment template for GitHub issue #%d: %v", ghIssue.GetNumber(), err)
+		return "", false
+	}
+
+	return strings.TrimSpace(buf.String()), true
+}
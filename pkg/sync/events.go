@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+// jEventIDRegex matches the beginning of a JIRA comment generated from a GitHub
+// timeline event, and retrieves the GitHub event ID for matching.
+var jEventIDRegex = regexp.MustCompile("^GitHub event \\(ID (\\d+)\\)")
+
+// CompareEvents retrieves the timeline of a GitHub issue, and creates a JIRA
+// comment for each event of a configured type which hasn't already been synced
+// to the JIRA issue. Events are never updated, as they can't change.
+func CompareEvents(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient ghClient.GitHubClient, jClient jClient.JIRAClient) error {
+	log := cfg.GetLogger()
+
+	eventTypes := cfg.GetTimelineEvents()
+	if len(eventTypes) == 0 {
+		return nil
+	}
+
+	wanted := map[string]bool{}
+	for _, t := range eventTypes {
+		wanted[t] = true
+	}
+
+	events, err := ghClient.ListTimeline(ghIssue)
+	if err != nil {
+		return err
+	}
+
+	synced := map[int]bool{}
+	if jIssue.Fields.Comments != nil {
+		for _, jComment := range jIssue.Fields.Comments.Comments {
+			// matches[0] is the whole string, matches[1] is the ID
+			matches := jEventIDRegex.FindStringSubmatch(jComment.Body)
+			if matches == nil {
+				continue
+			}
+			id, _ := strconv.Atoi(matches[1])
+			synced[id] = true
+		}
+	}
+
+	for _, event := range events {
+		if !wanted[event.GetEvent()] {
+			continue
+		}
+		// Events without an ID can't be matched on later runs, so they would be duplicated.
+		if event.GetID() == 0 {
+			log.Debugf("Skipping %s event on GitHub issue #%d without an ID.", event.GetEvent(), ghIssue.GetNumber())
+			continue
+		}
+		if synced[event.GetID()] {
+			continue
+		}
+
+		comment, err := jClient.CreateEventComment(jIssue, *event)
+		if err != nil {
+			return err
+		}
+
+		log.Debugf("Created JIRA event comment %s.", comment.ID)
+	}
+
+	log.Debugf("Copied timeline events from GH issue #%d to JIRA issue %s.", ghIssue.GetNumber(), jIssue.Key)
+	return nil
+}
@@ -0,0 +1,61 @@
+package sync
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/innovocloud/issue-sync/pkg/config"
+)
+
+// hookResult is the result of a sync as it is passed to the post-sync command.
+type hookResult struct {
+	Result
+	Duration    string   `json:"duration"`
+	FailedRepos []string `json:"failed_repos,omitempty"`
+}
+
+// runPostSyncHook runs the configured post-sync command through the shell,
+// passing it the result of the sync as JSON both on its standard input and
+// in the ISSUE_SYNC_RESULT environment variable. The command is an external
+// action, such as a notification, so its failure doesn't fail the sync.
+func runPostSyncHook(cfg config.Config, result Result, duration time.Duration, failedRepos []string) {
+	log := cfg.GetLogger()
+
+	command := cfg.GetPostSyncCommand()
+	if command == "" {
+		return
+	}
+	if cfg.IsDryRun() {
+		log.Infof("Dry run; not running post-sync command %q", command)
+		return
+	}
+
+	b, err := json.Marshal(hookResult{
+		Result:      result,
+		Duration:    duration.Round(time.Millisecond).String(),
+		FailedRepos: failedRepos,
+	})
+	if err != nil {
+		log.Errorf("Error encoding the sync result for the post-sync command: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.GetTimeout())
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = bytes.NewReader(b)
+	cmd.Env = append(os.Environ(), "ISSUE_SYNC_RESULT="+string(b))
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		log.Errorf("Post-sync command failed: %v. Output: %s", err, out)
+		return
+	}
+
+	log.Debugf("Post-sync command output: %s", out)
+}
@@ -0,0 +1,75 @@
+package sync
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRunPostSyncHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issue-sync-hook")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	stdin := filepath.Join(dir, "stdin.json")
+	env := filepath.Join(dir, "env.json")
+	// The command records the result it receives both ways.
+	record := "cat > " + stdin + " && printf %s \"$ISSUE_SYNC_RESULT\" > " + env
+
+	result := Result{Created: 1, Updated: 2, Failed: 1, Total: 5}
+
+	tests := []struct {
+		name        string
+		command     string
+		dryRun      bool
+		failedRepos []string
+		want        *hookResult
+	}{
+		{"no command", "", false, nil, nil},
+		{"result", record, false, nil, &hookResult{Result: result, Duration: "1.5s"}},
+		{"failed repos", record, false, []string{"o/r"}, &hookResult{Result: result, Duration: "1.5s", FailedRepos: []string{"o/r"}}},
+		{"dry run", record, true, nil, nil},
+		{"failing command", "exit 1", false, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(stdin)
+			os.Remove(env)
+			cfg := newTestConfig(t, map[string]interface{}{
+				"post-sync-command": tt.command,
+				"dry-run":           tt.dryRun,
+				"timeout":           "10s",
+			})
+
+			// A failing command is only logged.
+			runPostSyncHook(cfg, result, 1500*time.Millisecond, tt.failedRepos)
+
+			for _, path := range []string{stdin, env} {
+				b, err := ioutil.ReadFile(path)
+				if tt.want == nil {
+					if err == nil {
+						t.Errorf("command ran, want it not run")
+					}
+					return
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got hookResult
+				if err := json.Unmarshal(b, &got); err != nil {
+					t.Fatalf("command received %q: %v", b, err)
+				}
+				if !reflect.DeepEqual(got, *tt.want) {
+					t.Errorf("command received %+v in %s, want %+v", got, filepath.Base(path), *tt.want)
+				}
+			}
+		})
+	}
+}
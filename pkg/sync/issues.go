@@ -1,13 +1,15 @@
 package sync
 
 import (
+	"fmt"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/andygrunwald/go-jira"
+	"github.com/fatih/structs"
 	"github.com/google/go-github/github"
 	"github.com/innovocloud/issue-sync/pkg/config"
-	"github.com/innovocloud/issue-sync/pkg/convert"
 	ghClient "github.com/innovocloud/issue-sync/pkg/github"
 	jClient "github.com/innovocloud/issue-sync/pkg/jira"
 )
@@ -15,16 +17,70 @@ import (
 // dateFormat is the format used for the Last IS Update field
 const dateFormat = "2006-01-02T15:04:05.0-0700"
 
+// jiraDateFormat is the format of the dates returned by JIRA, without the
+// milliseconds.
+const jiraDateFormat = "2006-01-02T15:04:05-0700"
+
+// jiraDueDateFormat is the format of the JIRA due date field.
+const jiraDueDateFormat = "2006-01-02"
+
+// syncedFieldKeys are the keys of the standard JIRA fields which issue-sync
+// always sets itself, and which configured defaults may therefore not
+// override. Defaults don't override the other fields issue-sync sets on an
+// issue either; see createDefaults.
+var syncedFieldKeys = map[string]bool{
+	"project":     true,
+	"issuetype":   true,
+	"summary":     true,
+	"description": true,
+}
+
+// PartialError is returned when the sync ran to completion, but some of the
+// issues, or all issues of some repositories, could not be synchronized; the
+// errors for those have been logged.
+type PartialError struct {
+	Failed int
+	Total  int
+	// Repos are the names of the organisations or repositories which failed.
+	Repos []string
+}
+
+func (e PartialError) Error() string {
+	msg := fmt.Sprintf("failed to synchronize %d of %d issues", e.Failed, e.Total)
+	if len(e.Repos) > 0 {
+		msg = fmt.Sprintf("%s and the issues of %s", msg, strings.Join(e.Repos, ", "))
+	}
+	return msg
+}
+
 // CompareIssues gets the list of GitHub issues updated since the `since` date,
 // gets the list of JIRA issues which have GitHub ID custom fields in that list,
 // then matches each one. If a JIRA issue already exists for a given GitHub issue,
 // it calls UpdateIssue; if no JIRA issue already exists, it calls CreateIssue.
 func CompareIssues(cfg config.Config, ghIssues []github.Issue, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient) error {
+	_, err := compareIssues(cfg, ghIssues, ghClient, jiraClient, nil)
+	return err
+}
+
+// compareIssues is CompareIssues, recording each successfully processed issue in
+// the provided checkpoint, and skipping those the checkpoint already holds. It
+// returns the counts of the issues processed.
+func compareIssues(cfg config.Config, ghIssues []github.Issue, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient, cp *checkpoint) (Result, error) {
 	log := cfg.GetLogger()
 
+	var pending []github.Issue
+	for _, ghIssue := range ghIssues {
+		if cp.IsDone(ghIssue.GetID()) {
+			log.Debugf("GitHub issue #%d was processed before the last interruption, skipping.", ghIssue.GetNumber())
+			continue
+		}
+		pending = append(pending, ghIssue)
+	}
+	ghIssues = pending
+
 	if len(ghIssues) == 0 {
 		log.Info("No GitHub Issues retrieved")
-		return nil
+		return Result{}, nil
 	}
 
 	ids := make([]int, len(ghIssues))
@@ -36,106 +92,388 @@ func CompareIssues(cfg config.Config, ghIssues []github.Issue, ghClient ghClient
 
 	jiraIssues, err := jiraClient.ListIssues(ids)
 	if err != nil {
-		return err
+		return Result{}, err
 	}
 
 	log.Debug("Collected JIRA issues")
 
-	for _, ghIssue := range ghIssues {
-		found := false
-		for _, jIssue := range jiraIssues {
-			id, _ := jIssue.Fields.Unknowns.Int(cfg.GetFieldKey(config.GitHubID))
-			if int64(*ghIssue.ID) == id {
-				found = true
-				if err := UpdateIssue(cfg, ghIssue, jIssue, ghClient, jiraClient); err != nil {
-					log.Errorf("Error updating issue %s. Error: %v", jIssue.Key, err)
-				}
-				break
-			}
+	result := processIssues(cfg.GetSyncWorkers(), ghIssues, func(ghIssue github.Issue) fetchedIssue {
+		return prefetchIssue(cfg, ghIssue, ghClient)
+	}, func(issue fetchedIssue) Result {
+		return syncMatchedIssue(cfg, issue.issue, jiraIssues, issue.client, jiraClient, cp)
+	})
+
+	if result.Failed > 0 {
+		return result, PartialError{Failed: result.Failed, Total: result.Total}
+	}
+
+	return result, nil
+}
+
+// syncMatchedIssue updates the JIRA issue of a GitHub issue among the JIRA
+// issues, or creates one if there is none, and returns the counts for the
+// issue.
+func syncMatchedIssue(cfg config.Config, ghIssue github.Issue, jiraIssues []jira.Issue, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient, cp *checkpoint) Result {
+	log := cfg.GetLogger()
+
+	result := Result{Total: 1}
+	var err error
+	jIssue, found := matchIssue(cfg, ghIssue, jiraIssues)
+	if found {
+		var diff IssueDiff
+		if diff, err = issueDiff(cfg, ghIssue, jIssue, ghClient, jiraClient); err != nil {
+			log.Errorf("Error comparing issue %s. Error: %v", jIssue.Key, err)
+		} else if err = UpdateIssue(cfg, ghIssue, jIssue, diff, ghClient, jiraClient); err != nil {
+			log.Errorf("Error updating issue %s. Error: %v", jIssue.Key, err)
+		} else if shouldUpdate(cfg, ghIssue, jIssue, diff) {
+			result.Updated++
 		}
-		if !found {
-			if err := CreateIssue(cfg, ghIssue, ghClient, jiraClient); err != nil {
-				log.Errorf("Error creating issue for #%d. Error: %v", *ghIssue.Number, err)
-			}
+	}
+	if !found && tooOld(cfg, ghIssue) {
+		log.Debugf("GitHub issue #%d is older than the maximum issue age, skipping.", ghIssue.GetNumber())
+	} else if !found {
+		if err = CreateIssue(cfg, ghIssue, ghClient, jiraClient); err != nil {
+			log.Errorf("Error creating issue for #%d. Error: %v", *ghIssue.Number, err)
+		} else {
+			result.Created++
 		}
 	}
 
-	return nil
+	if err != nil {
+		result.Failed++
+	} else if err := cp.MarkDone(ghIssue.GetID()); err != nil {
+		log.Errorf("Error writing checkpoint for issue #%d. Error: %v", ghIssue.GetNumber(), err)
+	}
+	return result
 }
 
+// matchIssue returns the JIRA issue synced from a GitHub issue, and false if
+// none of the JIRA issues is.
+func matchIssue(cfg config.Config, ghIssue github.Issue, jiraIssues []jira.Issue) (jira.Issue, bool) {
+	for _, jIssue := range jiraIssues {
+		if id, _ := jClient.GetGitHubID(cfg, jIssue); int64(ghIssue.GetID()) == id {
+			return jIssue, true
+		}
+	}
+	return jira.Issue{}, false
+}
+
+// tooOld returns whether a GitHub issue was created longer ago than the
+// configured maximum issue age.
+func tooOld(cfg config.Config, ghIssue github.Issue) bool {
+	age := cfg.GetMaxIssueAge()
+	return age > 0 && ghIssue.GetCreatedAt().Before(time.Now().Add(-age))
+}
+
+// IssueDiff is the set of fields which differ between a GitHub issue and its
+// JIRA issue. The synced fields are named as in the `sync-fields` option (see
+// config.SyncSummary etc.); the others by DiffRepo, DiffType, DiffEnvironment,
+// DiffDueDate, DiffCommentCount and DiffStateReason.
+type IssueDiff map[string]bool
+
+// Fields returns the names of the fields which differ, sorted.
+func (d IssueDiff) Fields() []string {
+	fields := make([]string, 0, len(d))
+	for f, changed := range d {
+		if changed {
+			fields = append(fields, f)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Names of the fields in an IssueDiff which aren't configured by `sync-fields`.
+const (
+	DiffRepo         = "repo"
+	DiffType         = "type"
+	DiffEnvironment  = "environment"
+	DiffDueDate      = "duedate"
+	DiffCommentCount = "comments"
+	DiffStateReason  = "state reason"
+)
+
 // DidIssueChange tests each of the relevant fields on the provided JIRA and GitHub issue
-// and returns whether or not they differ.
-func DidIssueChange(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue) bool {
+// and returns the set of those which differ, which is empty if none do.
+func DidIssueChange(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient ghClient.GitHubClient) IssueDiff {
 	log := cfg.GetLogger()
 
 	log.Debugf("Comparing GitHub issue #%d and JIRA issue %s", ghIssue.GetNumber(), jIssue.Key)
 
-	anyDifferent := false
+	diff := IssueDiff{}
+
+	if cfg.IsFieldSynced(config.SyncSummary) && !sameSummary(cfg, ghIssue, jIssue.Fields.Summary) {
+		diff[config.SyncSummary] = true
+	}
+	if descriptionDiffers(cfg, ghIssue, jIssue) {
+		diff[config.SyncDescription] = true
+	}
 
-	anyDifferent = anyDifferent || (ghIssue.GetTitle() != jIssue.Fields.Summary)
-	anyDifferent = anyDifferent || (filterIssueBody(ghIssue.GetBody()) != jIssue.Fields.Description)
+	if cfg.IsFieldSynced(config.SyncStatus) {
+		key := cfg.GetFieldKey(config.GitHubStatus)
+		field, err := jIssue.Fields.Unknowns.String(key)
+		if err != nil || *ghIssue.State != field {
+			diff[config.SyncStatus] = true
+		}
+	}
 
-	key := cfg.GetFieldKey(config.GitHubStatus)
-	field, err := jIssue.Fields.Unknowns.String(key)
-	if err != nil || *ghIssue.State != field {
-		anyDifferent = true
+	if cfg.IsFieldSynced(config.SyncReporter) {
+		key := cfg.GetFieldKey(config.GitHubReporter)
+		field, err := jIssue.Fields.Unknowns.String(key)
+		if err != nil || *ghIssue.User.Login != field {
+			diff[config.SyncReporter] = true
+		}
 	}
 
-	key = cfg.GetFieldKey(config.GitHubReporter)
-	field, err = jIssue.Fields.Unknowns.String(key)
-	if err != nil || *ghIssue.User.Login != field {
-		anyDifferent = true
+	if cfg.IsFieldSynced(config.SyncURI) {
+		key := cfg.GetFieldKey(config.GitHubURI)
+		field, err := jIssue.Fields.Unknowns.String(key)
+		if err != nil || *ghIssue.HTMLURL != field {
+			diff[config.SyncURI] = true
+		}
 	}
 
-	key = cfg.GetFieldKey(config.GitHubURI)
-	field, err = jIssue.Fields.Unknowns.String(key)
-	if err != nil || *ghIssue.HTMLURL != field {
-		anyDifferent = true
+	if option, ok := issueTypeOption(cfg, ghIssue); ok && option != typeOption(cfg, jIssue) {
+		diff[DiffType] = true
 	}
 
-	labels := make([]string, len(ghIssue.Labels))
-	for i, l := range ghIssue.Labels {
-		labels[i] = *l.Name
+	if cfg.HasField(config.GitHubRepo) {
+		field, err := jIssue.Fields.Unknowns.String(cfg.GetFieldKey(config.GitHubRepo))
+		if err != nil || issueRepo(ghIssue) != field {
+			diff[DiffRepo] = true
+		}
 	}
 
-	key = cfg.GetFieldKey(config.GitHubLabels)
-	field, err = jIssue.Fields.Unknowns.String(key)
-	if err != nil && strings.Join(labels, ",") != field {
-		anyDifferent = true
+	if env, ok := issueEnvironment(cfg, ghIssue); ok {
+		// JIRA returns an empty environment as null.
+		field, _ := jIssue.Fields.Unknowns.String("environment")
+		if env != field {
+			diff[DiffEnvironment] = true
+		}
 	}
 
-	log.Debugf("Issues have differences: %t", anyDifferent)
+	if cfg.IsFieldSynced(config.SyncLabels) {
+		field, ok := jiraLabels(cfg, jIssue)
+		if !ok || !sameLabels(issueLabels(cfg, ghIssue), field) {
+			diff[config.SyncLabels] = true
+		}
+		if removed := removedLabels(field, issueLabels(cfg, ghIssue)); len(removed) > 0 {
+			log.Debugf("Labels %s were removed from GitHub issue #%d", strings.Join(removed, ", "), ghIssue.GetNumber())
+		}
+	}
 
-	return anyDifferent
+	if cfg.HasField(config.GitHubCommentCount) {
+		key := cfg.GetFieldKey(config.GitHubCommentCount)
+		// An unset field is null, which can't be read as a number.
+		if val, _ := jIssue.Fields.Unknowns.Value(key); val == nil {
+			diff[DiffCommentCount] = true
+		} else if field, err := jIssue.Fields.Unknowns.Int(key); err != nil || int64(ghIssue.GetComments()) != field {
+			diff[DiffCommentCount] = true
+		}
+	}
+
+	if reason, ok := issueStateReason(cfg, ghIssue, ghClient); ok {
+		// JIRA returns an empty field as null.
+		field, _ := jIssue.Fields.Unknowns.String(cfg.GetFieldKey(config.GitHubStateReason))
+		if reason != field {
+			diff[DiffStateReason] = true
+		}
+	}
+
+	if cfg.IsSyncingDueDate() && issueDueDate(cfg, ghIssue) != jIssue.Fields.Duedate {
+		diff[DiffDueDate] = true
+	}
+
+	if len(diff) > 0 {
+		log.Debugf("Issues differ in: %s", strings.Join(diff.Fields(), ", "))
+	} else {
+		log.Debug("Issues have no differences")
+	}
+
+	return diff
 }
 
-// UpdateIssue compares each field of a GitHub issue to a JIRA issue; if any of them
-// differ, the differing fields of the JIRA issue are updated to match the GitHub
-// issue.
-func UpdateIssue(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient ghClient.GitHubClient, jClient jClient.JIRAClient) error {
+// descriptionDiffers returns whether the description of a GitHub issue should
+// be copied to its JIRA issue. An empty description is never sent to JIRA, so
+// it can't differ.
+func descriptionDiffers(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue) bool {
+	desc := issueDescription(cfg, ghIssue)
+	return desc != "" && cfg.IsFieldSynced(config.SyncDescription) && desc != jIssue.Fields.Description
+}
+
+// descriptionConflict returns whether a differing JIRA description must be
+// kept, for the `detect-conflicts` option, because it was edited in JIRA: it
+// no longer matches the hash of the description issue-sync last wrote. Other
+// updates of the issue, e.g. issue-sync's own comments, don't matter. Issues
+// without a stored hash, e.g. last synced before the option was set, have no
+// conflict.
+func descriptionConflict(cfg config.Config, jIssue jira.Issue, jiraClient jClient.JIRAClient) (bool, error) {
+	if !cfg.IsDetectingConflicts() || cfg.IsForce() {
+		return false, nil
+	}
+	var prop jClient.DescriptionProperty
+	ok, err := jiraClient.GetProperty(jIssue, jClient.DescriptionPropertyKey, &prop)
+	if err != nil || !ok {
+		return false, err
+	}
+	return prop.Hash != jClient.DescriptionHash(jIssue.Fields.Description), nil
+}
+
+// setDescriptionProperty stores the hash of the description written to a
+// JIRA issue, for the `detect-conflicts` option.
+func setDescriptionProperty(cfg config.Config, jIssue jira.Issue, desc string, jiraClient jClient.JIRAClient) error {
+	if !cfg.IsDetectingConflicts() {
+		return nil
+	}
+	return jiraClient.SetProperty(jIssue, jClient.DescriptionPropertyKey, jClient.DescriptionProperty{
+		Hash: jClient.DescriptionHash(desc),
+	})
+}
+
+// refreshDescriptionProperty updates the stored hash of a JIRA description
+// which matches that of its GitHub issue, if the issue was edited since the
+// last sync: the edit may have been a conflict being resolved by hand, after
+// which the description isn't in conflict any more.
+func refreshDescriptionProperty(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, jiraClient jClient.JIRAClient) error {
+	if !cfg.IsDetectingConflicts() || descriptionDiffers(cfg, ghIssue, jIssue) {
+		return nil
+	}
+	if _, edited := editedSinceSync(cfg, jIssue); !edited {
+		return nil
+	}
+	var prop jClient.DescriptionProperty
+	ok, err := jiraClient.GetProperty(jIssue, jClient.DescriptionPropertyKey, &prop)
+	if err != nil || !ok || prop.Hash == jClient.DescriptionHash(jIssue.Fields.Description) {
+		return err
+	}
+	return setDescriptionProperty(cfg, jIssue, jIssue.Fields.Description, jiraClient)
+}
+
+// editedSinceSync returns when a JIRA issue was last updated, and whether
+// that was after the last sync, i.e. by someone other than issue-sync. It
+// returns false if either time isn't known.
+func editedSinceSync(cfg config.Config, jIssue jira.Issue) (time.Time, bool) {
+	last, err := jIssue.Fields.Unknowns.String(cfg.GetFieldKey(config.LastISUpdate))
+	if err != nil {
+		return time.Time{}, false
+	}
+	// JIRA returns dates with milliseconds, which parsing accepts.
+	lastSync, err := time.Parse(jiraDateFormat, last)
+	if err != nil {
+		return time.Time{}, false
+	}
+	updated, err := time.Parse(jiraDateFormat, jIssue.Fields.Updated)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return updated, updated.After(lastSync.Add(lastWriterSlack))
+}
+
+// lastWriterSlack is how long after the last sync a JIRA issue may have been
+// updated by issue-sync itself, e.g. while copying comments, before the update
+// is attributed to someone else.
+const lastWriterSlack = time.Minute
+
+// forcedDiff returns the diff which rewrites every synced field, for the
+// `force` option.
+func forcedDiff(cfg config.Config, ghIssue github.Issue) IssueDiff {
+	diff := IssueDiff{
+		DiffType:         true,
+		DiffEnvironment:  true,
+		DiffStateReason:  true,
+		DiffRepo:         cfg.HasField(config.GitHubRepo),
+		DiffCommentCount: cfg.HasField(config.GitHubCommentCount),
+		DiffDueDate:      cfg.IsSyncingDueDate(),
+	}
+	for _, f := range []string{config.SyncSummary, config.SyncStatus, config.SyncReporter, config.SyncURI, config.SyncLabels} {
+		diff[f] = cfg.IsFieldSynced(f)
+	}
+	// An empty description is never sent to JIRA.
+	diff[config.SyncDescription] = cfg.IsFieldSynced(config.SyncDescription) && issueDescription(cfg, ghIssue) != ""
+	return diff
+}
+
+// issueDiff returns the fields of a JIRA issue to update from its GitHub
+// issue: those which differ, or every synced field with the `force` option.
+// A description in conflict is left out. It is computed once per issue and
+// passed to shouldUpdate and UpdateIssue.
+func issueDiff(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient) (IssueDiff, error) {
+	if cfg.IsForce() {
+		return forcedDiff(cfg, ghIssue), nil
+	}
+	diff := DidIssueChange(cfg, ghIssue, jIssue, ghClient)
+	if diff[config.SyncDescription] {
+		conflict, err := descriptionConflict(cfg, jIssue, jiraClient)
+		if err != nil {
+			return nil, err
+		}
+		if conflict {
+			log := cfg.GetLogger()
+			log.Debugf("The description of JIRA issue %s was edited since the last sync; not overwriting it", jIssue.Key)
+			delete(diff, config.SyncDescription)
+		}
+	}
+	return diff, nil
+}
+
+// shouldUpdate returns whether the fields of the JIRA issue should be updated
+// from the GitHub issue: they must differ, and, if the last writer wins, the
+// JIRA issue must not have been edited more recently than the GitHub issue
+// since the last sync. Otherwise edits on both sides could overwrite each
+// other in turn.
+func shouldUpdate(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, diff IssueDiff) bool {
+	if cfg.IsForce() {
+		return true
+	}
+	if len(diff.Fields()) == 0 {
+		return false
+	}
+	if !cfg.IsLastWriterWins() {
+		return true
+	}
+
+	if updated, edited := editedSinceSync(cfg, jIssue); edited && updated.After(ghIssue.GetUpdatedAt()) {
+		log := cfg.GetLogger()
+		log.Debugf("JIRA issue %s was edited after GitHub issue #%d; not overwriting it", jIssue.Key, ghIssue.GetNumber())
+		return false
+	}
+
+	return true
+}
+
+// UpdateIssue updates a JIRA issue from its GitHub issue; if any fields
+// differ, only the fields in the diff are sent to be updated to match the
+// GitHub issue, which avoids needless JIRA notifications and conflicts.
+func UpdateIssue(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, diff IssueDiff, ghClient ghClient.GitHubClient, jClient jClient.JIRAClient) error {
 	log := cfg.GetLogger()
 
 	log.Debugf("Updating JIRA %s with GitHub #%d", jIssue.Key, *ghIssue.Number)
 
 	var issue jira.Issue
 
-	if DidIssueChange(cfg, ghIssue, jIssue) {
+	// A differing description is only left out of the diff if it conflicts.
+	conflict := !inTerminalStatus(cfg, jIssue) && !cfg.IsForce() && descriptionDiffers(cfg, ghIssue, jIssue) && !diff[config.SyncDescription]
+
+	if err := refreshDescriptionProperty(cfg, ghIssue, jIssue, jClient); err != nil {
+		return err
+	}
+
+	if inTerminalStatus(cfg, jIssue) {
+		log.Debugf("JIRA issue %s is in the terminal status %s; not updating its fields", jIssue.Key, jIssue.Fields.Status.Name)
+	} else if shouldUpdate(cfg, ghIssue, jIssue, diff) {
 		fields := jira.IssueFields{}
 		fields.Unknowns = map[string]interface{}{}
 
-		fields.Summary = ghIssue.GetTitle()
-		fields.Description = filterIssueBody(ghIssue.GetBody())
-		fields.Unknowns[cfg.GetFieldKey(config.GitHubStatus)] = ghIssue.GetState()
-		fields.Unknowns[cfg.GetFieldKey(config.GitHubReporter)] = ghIssue.User.GetLogin()
-		fields.Unknowns[cfg.GetFieldKey(config.GitHubURI)] = ghIssue.GetHTMLURL()
-
-		labels := make([]string, len(ghIssue.Labels))
-		for i, l := range ghIssue.Labels {
-			labels[i] = l.GetName()
+		// The summary is always sent, so keep JIRA's when it didn't change.
+		fields.Summary = jIssue.Fields.Summary
+		if diff[config.SyncSummary] {
+			fields.Summary = issueSummary(cfg, ghIssue)
 		}
-		fields.Unknowns[cfg.GetFieldKey(config.GitHubLabels)] = strings.Join(labels, ",")
+		setChangedFields(cfg, ghIssue, diff, &fields, ghClient)
 
-		fields.Unknowns[cfg.GetFieldKey(config.LastISUpdate)] = time.Now().Format(dateFormat)
+		if cfg.HasField(config.LastISUpdate) {
+			fields.Unknowns[cfg.GetFieldKey(config.LastISUpdate)] = jClient.Now().Format(dateFormat)
+		}
 
 		fields.Type = jIssue.Fields.Type
 
@@ -151,26 +489,584 @@ func UpdateIssue(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, ghC
 			return err
 		}
 
+		if err := setSyncProperty(cfg, ghIssue, jIssue, jClient); err != nil {
+			return err
+		}
+
+		if diff[config.SyncDescription] {
+			if err := setDescriptionProperty(cfg, jIssue, fields.Description, jClient); err != nil {
+				return err
+			}
+		}
+
 		log.Debugf("Successfully updated JIRA issue %s!", jIssue.Key)
 	} else {
 		log.Debugf("JIRA issue %s is already up to date!", jIssue.Key)
 	}
 
+	if err := tolerate(cfg, config.OperationTransition, jIssue, reopenIssue(cfg, ghIssue, jIssue, jClient)); err != nil {
+		return err
+	}
+
+	if err := tolerate(cfg, config.OperationComment, jIssue, commentStateChange(cfg, ghIssue, jIssue, jClient)); err != nil {
+		return err
+	}
+
 	issue, err := jClient.GetIssue(jIssue.Key)
 	if err != nil {
 		log.Debugf("Failed to retrieve JIRA issue %s!", jIssue.Key)
 		return err
 	}
 
-	if err := CompareComments(cfg, ghIssue, issue, ghClient, jClient); err != nil {
+	if conflict {
+		if err := tolerate(cfg, config.OperationComment, issue, commentConflict(cfg, ghIssue, issue, jClient)); err != nil {
+			return err
+		}
+	}
+
+	if err := tolerate(cfg, config.OperationComment, issue, CompareComments(cfg, ghIssue, issue, ghClient, jClient)); err != nil {
+		return err
+	}
+
+	if err := tolerate(cfg, config.OperationComment, issue, CompareEvents(cfg, ghIssue, issue, ghClient, jClient)); err != nil {
+		return err
+	}
+
+	if err := MirrorComments(cfg, ghIssue, issue, ghClient); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// tolerate returns the error of a step of the sync of a JIRA issue, unless
+// JIRA forbade the step and its operation is lenient, in which case a warning
+// is logged and the rest of the issue is still synced.
+func tolerate(cfg config.Config, operation string, jIssue jira.Issue, err error) error {
+	if _, forbidden := err.(jClient.ForbiddenError); !forbidden || !cfg.IsLenient(operation) {
+		return err
+	}
+
+	log := cfg.GetLogger()
+	log.Warnf("Skipping %s on JIRA issue %s, which JIRA forbids: %v", operation, jIssue.Key, err)
+	return nil
+}
+
+// inTerminalStatus returns whether a JIRA issue is in one of the configured
+// terminal statuses, in which its fields can't be edited.
+func inTerminalStatus(cfg config.Config, jIssue jira.Issue) bool {
+	if jIssue.Fields.Status == nil {
+		return false
+	}
+	for _, status := range cfg.GetTerminalStatuses() {
+		if strings.EqualFold(status, jIssue.Fields.Status.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// reopenIssue moves a JIRA issue out of a done status through the configured
+// reopen transitions when its GitHub issue has been reopened.
+func reopenIssue(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, jiraClient jClient.JIRAClient) error {
+	path := cfg.GetReopenTransitions()
+	if len(path) == 0 || ghIssue.GetState() != "open" {
+		return nil
+	}
+	if jIssue.Fields.Status == nil || jIssue.Fields.Status.StatusCategory.Key != "done" {
+		return nil
+	}
+
+	log := cfg.GetLogger()
+	log.Debugf("GitHub issue #%d was reopened; transitioning JIRA issue %s", ghIssue.GetNumber(), jIssue.Key)
+
+	return jiraClient.TransitionIssue(jIssue, path)
+}
+
+// transitionRecreated moves a new JIRA issue through the configured recreate
+// transitions if its GitHub issue is open but was reopened, which it usually
+// is when its previous JIRA issue was deleted. The timeline is only requested
+// when there are transitions to apply.
+func transitionRecreated(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient) error {
+	path := cfg.GetRecreateTransitions()
+	if len(path) == 0 || ghIssue.GetState() != "open" {
+		return nil
+	}
+
+	events, err := ghClient.ListTimeline(ghIssue)
+	if err != nil {
+		return err
+	}
+	reopened := false
+	for _, event := range events {
+		reopened = reopened || event.GetEvent() == "reopened"
+	}
+	if !reopened {
+		return nil
+	}
+
+	log := cfg.GetLogger()
+	log.Debugf("GitHub issue #%d was reopened; transitioning new JIRA issue %s", ghIssue.GetNumber(), jIssue.Key)
+
+	return jiraClient.TransitionIssue(jIssue, path)
+}
+
+// commentStateChange posts a comment on a JIRA issue if the state of its
+// GitHub issue differs from the one last synced to the GitHub Status field.
+// jIssue must be the JIRA issue as it was before the update.
+func commentStateChange(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, jiraClient jClient.JIRAClient) error {
+	// Without the status field, the last synced state isn't known.
+	if !cfg.IsCommentingStateChanges() || !cfg.IsFieldSynced(config.SyncStatus) {
+		return nil
+	}
+
+	state, err := jIssue.Fields.Unknowns.String(cfg.GetFieldKey(config.GitHubStatus))
+	if err != nil || state == "" || state == ghIssue.GetState() {
+		return nil
+	}
+
+	comment, err := jiraClient.CreateStateComment(jIssue, ghIssue)
+	if err != nil {
+		return err
+	}
+
+	log := cfg.GetLogger()
+	log.Debugf("Created JIRA state comment %s.", comment.ID)
+
+	return nil
+}
+
+// commentConflict posts a comment on a JIRA issue noting that the description
+// of its GitHub issue wasn't copied, unless one was posted already for the
+// current GitHub description.
+func commentConflict(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, jiraClient jClient.JIRAClient) error {
+	log := cfg.GetLogger()
+
+	marker := jClient.ConflictMarker(ghIssue)
+	if jIssue.Fields.Comments != nil {
+		for _, jComment := range jIssue.Fields.Comments.Comments {
+			if strings.Contains(jComment.Body, marker) {
+				log.Debugf("JIRA issue %s already notes the description conflict with GitHub issue #%d", jIssue.Key, ghIssue.GetNumber())
+				return nil
+			}
+		}
+	}
+
+	log.Warnf("The description of JIRA issue %s was edited since the last sync; not copying that of GitHub issue #%d", jIssue.Key, ghIssue.GetNumber())
+
+	comment, err := jiraClient.CreateConflictComment(jIssue, ghIssue)
+	if err != nil {
 		return err
 	}
 
+	log.Debugf("Created JIRA conflict comment %s.", comment.ID)
+
 	return nil
 }
 
-func filterIssueBody(body string) string {
-	return convert.ToJira(body)
+// issueTypeOption returns the option of the type field for the first label of
+// a GitHub issue which is mapped to one, and false if none is. JIRA issues are
+// left as they are when their GitHub issue has no type label.
+func issueTypeOption(cfg config.Config, ghIssue github.Issue) (string, bool) {
+	if !cfg.HasField(config.TypeField) {
+		return "", false
+	}
+
+	typeLabels := cfg.GetTypeLabels()
+	for _, l := range ghIssue.Labels {
+		if option, ok := typeLabels[strings.ToLower(l.GetName())]; ok {
+			return option, true
+		}
+	}
+	return "", false
+}
+
+// typeOption returns the option currently selected in the type field of a
+// JIRA issue, or "" if there is none.
+func typeOption(cfg config.Config, jIssue jira.Issue) string {
+	val, _ := jIssue.Fields.Unknowns.Value(cfg.GetFieldKey(config.TypeField))
+	option, ok := val.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	value, _ := option["value"].(string)
+	return value
+}
+
+// issueRepo returns the repository of a GitHub issue as `owner/repo`, which
+// together with the issue number identifies it across repositories.
+func issueRepo(ghIssue github.Issue) string {
+	// The API URL has the form https://api.github.com/repos/<owner>/<repo>/issues/<number>
+	splitURL := strings.Split(ghIssue.GetURL(), "/")
+	if len(splitURL) < 6 {
+		return ""
+	}
+	return splitURL[4] + "/" + splitURL[5]
+}
+
+// issueType returns the name of the JIRA issue type to create for a GitHub
+// issue. Pull requests use the configured pull request issue type, if any;
+// otherwise the issue type of the repository overrides the global one.
+func issueType(cfg config.Config, ghIssue github.Issue) string {
+	if ghIssue.PullRequestLinks != nil && cfg.GetPRIssueType() != "" {
+		return cfg.GetPRIssueType()
+	}
+	if t := cfg.GetRepoOverride(issueRepo(ghIssue)).IssueType; t != "" {
+		return t
+	}
+	return cfg.GetIssueType()
+}
+
+// setSyncProperty stores the sync metadata of a JIRA issue in its entity
+// property, if properties are in use.
+func setSyncProperty(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, jiraClient jClient.JIRAClient) error {
+	if !cfg.IsUsingProperties() {
+		return nil
+	}
+
+	return jiraClient.SetProperty(jIssue, jClient.SyncPropertyKey, jClient.SyncProperty{
+		GitHubID:     ghIssue.GetID(),
+		GitHubNumber: ghIssue.GetNumber(),
+		GitHubRepo:   issueRepo(ghIssue),
+		LastSync:     jiraClient.Now().Format(dateFormat),
+	})
+}
+
+// setSyncedFields sets the description and the GitHub custom fields which
+// are configured to be synced on the fields of a new JIRA issue.
+func setSyncedFields(cfg config.Config, ghIssue github.Issue, fields *jira.IssueFields, ghClient ghClient.GitHubClient) {
+	if cfg.IsFieldSynced(config.SyncDescription) {
+		fields.Description = issueDescription(cfg, ghIssue)
+	}
+	if cfg.IsFieldSynced(config.SyncStatus) {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubStatus)] = ghIssue.GetState()
+	}
+	if cfg.IsFieldSynced(config.SyncReporter) {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubReporter)] = ghIssue.User.GetLogin()
+	}
+	if cfg.IsFieldSynced(config.SyncURI) {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubURI)] = ghIssue.GetHTMLURL()
+	}
+	if cfg.HasField(config.GitHubRepo) {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubRepo)] = issueRepo(ghIssue)
+	}
+	if cfg.HasField(config.GitHubCommentCount) {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubCommentCount)] = ghIssue.GetComments()
+	}
+	if reason, ok := issueStateReason(cfg, ghIssue, ghClient); ok && reason != "" {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubStateReason)] = reason
+	}
+	if option, ok := issueTypeOption(cfg, ghIssue); ok {
+		fields.Unknowns[cfg.GetFieldKey(config.TypeField)] = map[string]string{"value": option}
+	}
+	if cfg.HasField(config.Version) {
+		fields.Unknowns[cfg.GetFieldKey(config.Version)] = cfg.GetVersion()
+	}
+	if cfg.IsFieldSynced(config.SyncLabels) {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubLabels)] = issueLabels(cfg, ghIssue)
+	}
+	if env, ok := issueEnvironment(cfg, ghIssue); ok {
+		fields.Unknowns["environment"] = env
+	}
+	if cfg.IsSyncingDueDate() {
+		fields.Duedate = issueDueDate(cfg, ghIssue)
+	}
+}
+
+// setTriageFields flags a new JIRA issue for triage with the configured label,
+// assignee and component. Updates never send these fields, so once they are
+// cleared by hand they aren't set again.
+func setTriageFields(cfg config.Config, fields *jira.IssueFields) {
+	if label := cfg.GetTriageLabel(); label != "" {
+		fields.Labels = []string{label}
+	}
+	if assignee := cfg.GetTriageAssignee(); assignee != "" {
+		fields.Assignee = &jira.User{Name: assignee}
+	}
+	if component := cfg.GetTriageComponent(); component != "" {
+		fields.Components = []*jira.Component{{Name: component}}
+	}
+}
+
+// setRepoComponents adds the components of the repository overrides of a
+// GitHub issue to its new JIRA issue, skipping those already set, such as the
+// triage component. Like the triage component, they are only set on creation.
+func setRepoComponents(cfg config.Config, ghIssue github.Issue, fields *jira.IssueFields) {
+	seen := map[string]bool{}
+	for _, c := range fields.Components {
+		seen[c.Name] = true
+	}
+	for _, name := range cfg.GetRepoOverride(issueRepo(ghIssue)).Components {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		fields.Components = append(fields.Components, &jira.Component{Name: name})
+	}
+}
+
+// nativeReporter returns the JIRA user who reports the JIRA issue of a GitHub
+// issue: the user its author is mapped to, or the default reporter if the
+// author isn't mapped or the mapped user doesn't exist, as JIRA rejects
+// unknown reporters. It is empty if there is neither.
+func nativeReporter(cfg config.Config, ghIssue github.Issue, jClient jClient.JIRAClient) (string, error) {
+	log := cfg.GetLogger()
+
+	login := ghIssue.User.GetLogin()
+	if name := cfg.GetUserMap()[strings.ToLower(login)]; name != "" {
+		ok, err := jClient.UserExists(name)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return name, nil
+		}
+		log.Warnf("JIRA user %s, mapped from GitHub user %s, doesn't exist; using the default reporter", name, login)
+	}
+
+	return cfg.GetDefaultReporter(), nil
+}
+
+// setChangedFields sets the fields in the diff on the JIRA issue fields, for
+// an update which leaves the other fields as they are.
+func setChangedFields(cfg config.Config, ghIssue github.Issue, diff IssueDiff, fields *jira.IssueFields, ghClient ghClient.GitHubClient) {
+	if diff[config.SyncDescription] {
+		fields.Description = issueDescription(cfg, ghIssue)
+	}
+	if diff[config.SyncStatus] {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubStatus)] = ghIssue.GetState()
+	}
+	if diff[config.SyncReporter] {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubReporter)] = ghIssue.User.GetLogin()
+	}
+	if diff[config.SyncURI] {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubURI)] = ghIssue.GetHTMLURL()
+	}
+	if diff[DiffRepo] {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubRepo)] = issueRepo(ghIssue)
+	}
+	if diff[DiffCommentCount] {
+		fields.Unknowns[cfg.GetFieldKey(config.GitHubCommentCount)] = ghIssue.GetComments()
+	}
+	if reason, ok := issueStateReason(cfg, ghIssue, ghClient); ok && diff[DiffStateReason] {
+		// An empty text field is cleared with null.
+		if reason != "" {
+			fields.Unknowns[cfg.GetFieldKey(config.GitHubStateReason)] = reason
+		} else {
+			fields.Unknowns[cfg.GetFieldKey(config.GitHubStateReason)] = nil
+		}
+	}
+	if option, ok := issueTypeOption(cfg, ghIssue); ok && diff[DiffType] {
+		fields.Unknowns[cfg.GetFieldKey(config.TypeField)] = map[string]string{"value": option}
+	}
+	// The version is only written along with other changes; DidIssueChange
+	// ignores it, so an upgrade doesn't update every issue.
+	if cfg.HasField(config.Version) {
+		fields.Unknowns[cfg.GetFieldKey(config.Version)] = cfg.GetVersion()
+	}
+	if diff[config.SyncLabels] {
+		// Removing the last label clears the field with null, like JIRA
+		// returns it.
+		if labels := issueLabels(cfg, ghIssue); labels != "" {
+			fields.Unknowns[cfg.GetFieldKey(config.GitHubLabels)] = labels
+		} else {
+			fields.Unknowns[cfg.GetFieldKey(config.GitHubLabels)] = nil
+		}
+	}
+	if env, ok := issueEnvironment(cfg, ghIssue); ok && diff[DiffEnvironment] {
+		fields.Unknowns["environment"] = env
+	}
+	if diff[DiffDueDate] {
+		// An empty due date is left out of the request, so clear it with null.
+		if due := issueDueDate(cfg, ghIssue); due != "" {
+			fields.Duedate = due
+		} else {
+			fields.Unknowns["duedate"] = nil
+		}
+	}
+}
+
+// issueStateReason returns the state reason of a GitHub issue, e.g.
+// "not_planned", to store in the State Reason field, and false if the field
+// doesn't exist or the reason couldn't be retrieved. Only the reasons of
+// closed issues are stored; open issues have none.
+func issueStateReason(cfg config.Config, ghIssue github.Issue, ghClient ghClient.GitHubClient) (string, bool) {
+	if !cfg.HasField(config.GitHubStateReason) {
+		return "", false
+	}
+	if ghIssue.GetState() != "closed" {
+		return "", true
+	}
+
+	reason, err := ghClient.GetStateReason(ghIssue)
+	if err != nil {
+		log := cfg.GetLogger()
+		log.Warnf("Leaving the state reason of GitHub issue #%d as it is: %v", ghIssue.GetNumber(), err)
+		return "", false
+	}
+	return reason, true
+}
+
+// issueDueDate returns the due date of a GitHub issue in the JIRA format: the
+// date of its first valid due date label, if configured, or else the due date
+// of its milestone. It returns an empty string if the issue has neither.
+func issueDueDate(cfg config.Config, ghIssue github.Issue) string {
+	if prefix := cfg.GetDueDateLabel(); prefix != "" {
+		for _, l := range ghIssue.Labels {
+			if !strings.HasPrefix(l.GetName(), prefix) {
+				continue
+			}
+			due, err := time.Parse(jiraDueDateFormat, strings.TrimPrefix(l.GetName(), prefix))
+			if err != nil {
+				log := cfg.GetLogger()
+				log.Warnf("Ignoring due date label %q of GitHub issue #%d: %v", l.GetName(), ghIssue.GetNumber(), err)
+				continue
+			}
+			return due.Format(jiraDueDateFormat)
+		}
+	}
+
+	if ghIssue.Milestone != nil && ghIssue.Milestone.DueOn != nil {
+		return ghIssue.Milestone.DueOn.UTC().Format(jiraDueDateFormat)
+	}
+	return ""
+}
+
+// issueLabels returns the comma-separated labels to store on the JIRA issue:
+// the labels of the GitHub issue and any configured default labels, those of
+// its repository replacing the global ones, without duplicates and sorted, so
+// the field doesn't change with their order.
+func issueLabels(cfg config.Config, ghIssue github.Issue) string {
+	var labels []string
+	seen := map[string]bool{}
+	for _, l := range ghIssue.Labels {
+		if !seen[l.GetName()] {
+			seen[l.GetName()] = true
+			labels = append(labels, l.GetName())
+		}
+	}
+	defaults := cfg.GetRepoOverride(issueRepo(ghIssue)).DefaultLabels
+	if len(defaults) == 0 {
+		defaults = cfg.GetDefaultLabels()
+	}
+	for _, l := range defaults {
+		if !seen[l] {
+			seen[l] = true
+			labels = append(labels, l)
+		}
+	}
+	sort.Strings(labels)
+	return strings.Join(labels, ",")
+}
+
+// jiraLabels returns the labels stored on a JIRA issue. JIRA returns a text
+// field set to the empty string as absent, so an absent or null field holds no
+// labels; false is only returned if the field holds something else.
+func jiraLabels(cfg config.Config, jIssue jira.Issue) (string, bool) {
+	v, ok := jIssue.Fields.Unknowns[cfg.GetFieldKey(config.GitHubLabels)]
+	if !ok || v == nil {
+		return "", true
+	}
+	s, ok := v.(string)
+	return s, ok
+}
+
+// sameLabels returns whether two comma-separated label lists hold the same
+// labels, in any order. Only labels which were added, removed or renamed
+// make them differ.
+func sameLabels(a, b string) bool {
+	as, bs := labelSet(a), labelSet(b)
+	if len(as) != len(bs) {
+		return false
+	}
+	for l := range as {
+		if !bs[l] {
+			return false
+		}
+	}
+	return true
+}
+
+// removedLabels returns the labels in the old comma-separated list which
+// aren't in the new one, sorted.
+func removedLabels(old, new string) []string {
+	kept := labelSet(new)
+	var removed []string
+	for l := range labelSet(old) {
+		if !kept[l] {
+			removed = append(removed, l)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// labelSet returns the set of labels in a comma-separated list.
+func labelSet(labels string) map[string]bool {
+	s := map[string]bool{}
+	for _, l := range strings.Split(labels, ",") {
+		if l = strings.TrimSpace(l); l != "" {
+			s[l] = true
+		}
+	}
+	return s
+}
+
+// issueDescription returns the JIRA description for a GitHub issue: its
+// converted body, truncated to the configured maximum length with a notice
+// linking to the full issue on GitHub. The length of the converted body,
+// not the raw one, is what counts against the limit.
+func issueDescription(cfg config.Config, ghIssue github.Issue) string {
+	desc := filterIssueBody(cfg, ghIssue.GetBody())
+
+	max := cfg.GetMaxDescriptionLength()
+	if max <= 0 || len([]rune(desc)) <= max {
+		return desc
+	}
+
+	notice := fmt.Sprintf("\n\n_(Truncated; see the [full issue on GitHub|%s].)_", ghIssue.GetHTMLURL())
+	keep := max - len([]rune(notice))
+	if keep < 0 {
+		keep = 0
+	}
+
+	return string([]rune(desc)[:keep]) + notice
+}
+
+// filterIssueBody rewrites a GitHub issue body with the configured rules and
+// converts it into the markup of the target using the configured converter. If the converted body is empty,
+// the configured placeholder is used instead; if there is no placeholder,
+// the empty description is left out of the request so that an existing
+// JIRA description isn't cleared.
+func filterIssueBody(cfg config.Config, body string) string {
+	out := cfg.GetConverter().ToTarget(cfg.RewriteBody(body))
+	if strings.TrimSpace(out) == "" {
+		return cfg.GetEmptyBodyPlaceholder()
+	}
+	return out
+}
+
+// setCreateDefaults adds the configured defaults to the fields of a new JIRA
+// issue. Defaults never override the fields issue-sync sets itself: the
+// custom fields, the standard fields set through the struct, such as the
+// triage labels and assignee or the reporter, and the synced due date even
+// when the issue has none.
+func setCreateDefaults(cfg config.Config, fields *jira.IssueFields) {
+	// The fields are marshalled by go-jira the same way, so the keys of the
+	// map are those of the fields in the request; empty fields are left out.
+	set := structs.Map(fields)
+	for key, value := range cfg.GetCreateDefaults() {
+		if _, ok := fields.Unknowns[key]; ok || syncedFieldKeys[key] {
+			continue
+		}
+		if _, ok := set[key]; ok {
+			continue
+		}
+		if key == "duedate" && cfg.IsSyncingDueDate() {
+			continue
+		}
+		fields.Unknowns[key] = value
+	}
 }
 
 // CreateIssue generates a JIRA issue from the various fields on the given GitHub issue, then
@@ -182,27 +1078,47 @@ func CreateIssue(cfg config.Config, issue github.Issue, ghClient ghClient.GitHub
 
 	fields := jira.IssueFields{
 		Type: jira.IssueType{
-			Name: "Aufgabe", // TODO: Determine issue type
+			Name: issueType(cfg, issue),
 		},
-		Project:     cfg.GetProject(),
-		Summary:     issue.GetTitle(),
-		Description: filterIssueBody(issue.GetBody()),
-		Unknowns:    map[string]interface{}{},
+		Project: cfg.GetProject(),
+		// JIRA requires a summary, so it is set on creation even if it isn't synced.
+		Summary:  issueSummary(cfg, issue),
+		Unknowns: map[string]interface{}{},
 	}
 
-	fields.Unknowns[cfg.GetFieldKey(config.GitHubID)] = issue.GetID()
-	fields.Unknowns[cfg.GetFieldKey(config.GitHubNumber)] = issue.GetNumber()
-	fields.Unknowns[cfg.GetFieldKey(config.GitHubStatus)] = issue.GetState()
-	fields.Unknowns[cfg.GetFieldKey(config.GitHubReporter)] = issue.User.GetLogin()
-	fields.Unknowns[cfg.GetFieldKey(config.GitHubURI)] = issue.GetHTMLURL()
+	fields.Unknowns[cfg.GetFieldKey(config.GitHubID)] = cfg.GetNumberValue(config.GitHubID, issue.GetID())
+	fields.Unknowns[cfg.GetFieldKey(config.GitHubNumber)] = cfg.GetNumberValue(config.GitHubNumber, issue.GetNumber())
+	setSyncedFields(cfg, issue, &fields, ghClient)
+
+	if cfg.HasField(config.LastISUpdate) {
+		fields.Unknowns[cfg.GetFieldKey(config.LastISUpdate)] = jClient.Now().Format(dateFormat)
+	}
+
+	setTriageFields(cfg, &fields)
+	setRepoComponents(cfg, issue, &fields)
+
+	if cfg.IsSyncingNativeReporter() {
+		reporter, err := nativeReporter(cfg, issue, jClient)
+		if err != nil {
+			return err
+		}
+		if reporter != "" {
+			fields.Reporter = &jira.User{Name: reporter}
+		}
+	}
 
-	strs := make([]string, len(issue.Labels))
-	for i, v := range issue.Labels {
-		strs[i] = *v.Name
+	if sprint := cfg.GetSprint(); sprint != "" {
+		id, ok, err := jClient.ResolveSprint(sprint)
+		if err != nil {
+			return err
+		} else if ok {
+			fields.Unknowns[cfg.GetFieldKey(config.Sprint)] = id
+		} else {
+			log.Warnf("JIRA sprint %q not found; creating issue for #%d without a sprint", sprint, issue.GetNumber())
+		}
 	}
-	fields.Unknowns[cfg.GetFieldKey(config.GitHubLabels)] = strings.Join(strs, ",")
 
-	fields.Unknowns[cfg.GetFieldKey(config.LastISUpdate)] = time.Now().Format(dateFormat)
+	setCreateDefaults(cfg, &fields)
 
 	jIssue := jira.Issue{
 		Fields: &fields,
@@ -213,14 +1129,43 @@ func CreateIssue(cfg config.Config, issue github.Issue, ghClient ghClient.GitHub
 		return err
 	}
 
-	jIssue, err = jClient.GetIssue(jIssue.Key)
+	// Some JIRA configurations only return the ID of the created issue; its
+	// key is then resolved by fetching it by ID.
+	ref := jIssue.Key
+	if ref == "" {
+		ref = jIssue.ID
+		log.Debugf("JIRA returned no key for the issue created for #%d; fetching it by ID %s", issue.GetNumber(), ref)
+	}
+	if ref == "" {
+		return fmt.Errorf("JIRA returned neither a key nor an ID for the issue created for GitHub issue #%d", issue.GetNumber())
+	}
+
+	jIssue, err = jClient.GetIssue(ref)
 	if err != nil {
 		return err
 	}
 
+	if err := setSyncProperty(cfg, issue, jIssue, jClient); err != nil {
+		return err
+	}
+
+	if fields.Description != "" {
+		if err := setDescriptionProperty(cfg, jIssue, fields.Description, jClient); err != nil {
+			return err
+		}
+	}
+
 	log.Debugf("Created JIRA issue %s!", jIssue.Key)
 
-	if err := CompareComments(cfg, issue, jIssue, ghClient, jClient); err != nil {
+	if err := tolerate(cfg, config.OperationTransition, jIssue, transitionRecreated(cfg, issue, jIssue, ghClient, jClient)); err != nil {
+		return err
+	}
+
+	if err := tolerate(cfg, config.OperationComment, jIssue, CompareComments(cfg, issue, jIssue, ghClient, jClient)); err != nil {
+		return err
+	}
+
+	if err := tolerate(cfg, config.OperationComment, jIssue, CompareEvents(cfg, issue, jIssue, ghClient, jClient)); err != nil {
 		return err
 	}
 
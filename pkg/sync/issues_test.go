@@ -0,0 +1,1820 @@
+package sync
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	"github.com/innovocloud/issue-sync/pkg/convert"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+// testIssue returns an open GitHub issue with the given title, body and
+// labels.
+func testIssue(title, body string, labels ...string) github.Issue {
+	issue := github.Issue{
+		ID:      github.Int(1001),
+		Number:  github.Int(1),
+		Title:   github.String(title),
+		Body:    github.String(body),
+		State:   github.String("open"),
+		User:    &github.User{Login: github.String("octocat")},
+		HTMLURL: github.String("https://github.com/o/r/issues/1"),
+	}
+	for _, l := range labels {
+		issue.Labels = append(issue.Labels, github.Label{Name: github.String(l)})
+	}
+	return issue
+}
+
+// syncedIssue returns the JIRA issue of a GitHub issue as issue-sync last
+// wrote it, at the given time.
+func syncedIssue(cfg config.Config, ghIssue github.Issue, synced time.Time) jira.Issue {
+	return jira.Issue{
+		Key: "TEST-1",
+		Fields: &jira.IssueFields{
+			Summary:     issueSummary(cfg, ghIssue),
+			Description: issueDescription(cfg, ghIssue),
+			Updated:     synced.Format(jiraDateFormat),
+			Unknowns: map[string]interface{}{
+				cfg.GetFieldKey(config.GitHubID):       float64(ghIssue.GetID()),
+				cfg.GetFieldKey(config.GitHubNumber):   float64(ghIssue.GetNumber()),
+				cfg.GetFieldKey(config.GitHubLabels):   issueLabels(cfg, ghIssue),
+				cfg.GetFieldKey(config.GitHubStatus):   ghIssue.GetState(),
+				cfg.GetFieldKey(config.GitHubReporter): ghIssue.User.GetLogin(),
+				cfg.GetFieldKey(config.GitHubURI):      ghIssue.GetHTMLURL(),
+				cfg.GetFieldKey(config.LastISUpdate):   synced.Format(jiraDateFormat),
+			},
+		},
+	}
+}
+
+func TestDidIssueChange(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+	synced := testIssue("Title", "Body", "bug", "ui")
+
+	closed := testIssue("Title", "Body", "bug", "ui")
+	closed.State = github.String("closed")
+
+	tests := []struct {
+		name  string
+		issue github.Issue
+		want  []string
+	}{
+		{"unchanged", synced, []string{}},
+		{"labels reordered", testIssue("Title", "Body", "ui", "bug"), []string{}},
+		{"title", testIssue("New title", "Body", "bug", "ui"), []string{config.SyncSummary}},
+		{"body", testIssue("Title", "New body", "bug", "ui"), []string{config.SyncDescription}},
+		{"empty body", testIssue("Title", "", "bug", "ui"), []string{}},
+		{"label added", testIssue("Title", "Body", "bug", "ui", "p1"), []string{config.SyncLabels}},
+		{"state", closed, []string{config.SyncStatus}},
+		{"title and body", testIssue("New title", "New body", "bug", "ui"), []string{config.SyncDescription, config.SyncSummary}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jIssue := syncedIssue(cfg, synced, time.Now())
+			got := DidIssueChange(cfg, tt.issue, jIssue, nil).Fields()
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("DidIssueChange() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShouldUpdate(t *testing.T) {
+	now := time.Now().Round(time.Second)
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		diff   IssueDiff
+		// edited is how long after the last sync JIRA was edited, and
+		// ghUpdated how long after it GitHub was.
+		edited, ghUpdated time.Duration
+		want              bool
+	}{
+		{"no diff", nil, IssueDiff{}, 0, 0, false},
+		{"only false entries", nil, IssueDiff{config.SyncSummary: false}, 0, 0, false},
+		{"diff", nil, IssueDiff{config.SyncSummary: true}, 0, 0, true},
+		{"forced", map[string]interface{}{"force": true}, IssueDiff{}, 0, 0, true},
+		{"JIRA edited", nil, IssueDiff{config.SyncSummary: true}, time.Hour, time.Minute, true},
+		{"last writer JIRA", map[string]interface{}{"last-writer-wins": true}, IssueDiff{config.SyncSummary: true}, time.Hour, 2 * time.Minute, false},
+		{"last writer GitHub", map[string]interface{}{"last-writer-wins": true}, IssueDiff{config.SyncSummary: true}, time.Hour, 2 * time.Hour, true},
+		{"last writer within slack", map[string]interface{}{"last-writer-wins": true}, IssueDiff{config.SyncSummary: true}, lastWriterSlack / 2, 0, true},
+		// Near-simultaneous edits: whichever side was edited last wins.
+		{"both within slack", map[string]interface{}{"last-writer-wins": true}, IssueDiff{config.SyncSummary: true}, lastWriterSlack / 2, lastWriterSlack / 4, true},
+		{"JIRA a second later", map[string]interface{}{"last-writer-wins": true}, IssueDiff{config.SyncSummary: true}, 2 * time.Minute, 2*time.Minute - time.Second, false},
+		{"GitHub a second later", map[string]interface{}{"last-writer-wins": true}, IssueDiff{config.SyncSummary: true}, 2 * time.Minute, 2*time.Minute + time.Second, true},
+		{"same time", map[string]interface{}{"last-writer-wins": true}, IssueDiff{config.SyncSummary: true}, 2 * time.Minute, 2 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.values)
+			ghIssue := testIssue("Title", "Body")
+			updated := now.Add(tt.ghUpdated)
+			ghIssue.UpdatedAt = &updated
+			jIssue := syncedIssue(cfg, ghIssue, now)
+			jIssue.Fields.Updated = now.Add(tt.edited).Format(jiraDateFormat)
+
+			if got := shouldUpdate(cfg, ghIssue, jIssue, tt.diff); got != tt.want {
+				t.Errorf("shouldUpdate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditedSinceSync(t *testing.T) {
+	now := time.Now().Round(time.Second)
+	cfg := newTestConfig(t, nil)
+
+	tests := []struct {
+		name     string
+		lastSync string
+		updated  string
+		edited   bool
+	}{
+		{"not edited", now.Format(jiraDateFormat), now.Format(jiraDateFormat), false},
+		{"edited by issue-sync", now.Format(jiraDateFormat), now.Add(lastWriterSlack).Format(jiraDateFormat), false},
+		{"edited after the slack", now.Format(jiraDateFormat), now.Add(lastWriterSlack + time.Second).Format(jiraDateFormat), true},
+		{"milliseconds", now.Format("2006-01-02T15:04:05.000-0700"), now.Add(time.Hour).Format("2006-01-02T15:04:05.000-0700"), true},
+		{"never synced", "", now.Format(jiraDateFormat), false},
+		{"invalid last sync", "yesterday", now.Format(jiraDateFormat), false},
+		{"invalid update", now.Format(jiraDateFormat), "today", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jIssue := jira.Issue{Fields: &jira.IssueFields{
+				Updated:  tt.updated,
+				Unknowns: map[string]interface{}{},
+			}}
+			if tt.lastSync != "" {
+				jIssue.Fields.Unknowns[cfg.GetFieldKey(config.LastISUpdate)] = tt.lastSync
+			}
+
+			if _, edited := editedSinceSync(cfg, jIssue); edited != tt.edited {
+				t.Errorf("editedSinceSync() = %v, want %v", edited, tt.edited)
+			}
+		})
+	}
+}
+
+func TestIssueDiffForced(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{"force": true})
+	ghIssue := testIssue("Title", "Body")
+	jIssue := syncedIssue(cfg, ghIssue, time.Now())
+
+	diff, err := issueDiff(cfg, ghIssue, jIssue, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []string{config.SyncSummary, config.SyncDescription, config.SyncStatus, config.SyncLabels} {
+		if !diff[f] {
+			t.Errorf("forced diff doesn't rewrite %s: %v", f, diff.Fields())
+		}
+	}
+}
+
+func TestForceUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		force   bool
+		updated bool
+	}{
+		{"forced", true, true},
+		{"not forced", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"force": tt.force})
+			ghIssue := testIssue("Title", "Body", "bug")
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			if diff := DidIssueChange(cfg, ghIssue, jIssue, nil); len(diff.Fields()) != 0 {
+				t.Fatalf("test issue changed: %v", diff.Fields())
+			}
+
+			diff, err := issueDiff(cfg, ghIssue, jIssue, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var updated jira.Issue
+			UpdateIssue(cfg, ghIssue, jIssue, diff, nil, updateClient{updated: &updated})
+			if got := updated.Fields != nil; got != tt.updated {
+				t.Fatalf("issue updated = %v, want %v", got, tt.updated)
+			}
+			if !tt.updated {
+				return
+			}
+			if updated.Fields.Summary != jIssue.Fields.Summary || updated.Fields.Description != jIssue.Fields.Description {
+				t.Errorf("rewrote summary %q and description %q, want %q and %q",
+					updated.Fields.Summary, updated.Fields.Description, jIssue.Fields.Summary, jIssue.Fields.Description)
+			}
+			key := cfg.GetFieldKey(config.GitHubLabels)
+			if !reflect.DeepEqual(updated.Fields.Unknowns[key], jIssue.Fields.Unknowns[key]) {
+				t.Errorf("rewrote labels %v, want %v", updated.Fields.Unknowns[key], jIssue.Fields.Unknowns[key])
+			}
+		})
+	}
+}
+
+// propertyClient is a JIRA client which only stores entity properties.
+type propertyClient struct {
+	jClient.JIRAClient
+	props map[string]interface{}
+}
+
+func (c propertyClient) GetProperty(issue jira.Issue, key string, v interface{}) (bool, error) {
+	value, ok := c.props[issue.Key+"/"+key]
+	if !ok {
+		return false, nil
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(b, v)
+}
+
+func (c propertyClient) SetProperty(issue jira.Issue, key string, v interface{}) error {
+	c.props[issue.Key+"/"+key] = v
+	return nil
+}
+
+func TestIssueDiffConflict(t *testing.T) {
+	ghIssue := testIssue("Title", "New body")
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		// written is the description whose hash is stored, if any, and
+		// current the JIRA description.
+		written, current string
+		want             bool
+	}{
+		{"not detecting", nil, "Body", "Edited body", true},
+		{"no hash", map[string]interface{}{"detect-conflicts": true}, "", "Body", true},
+		{"unedited", map[string]interface{}{"detect-conflicts": true}, "Body", "Body", true},
+		{"edited", map[string]interface{}{"detect-conflicts": true}, "Body", "Edited body", false},
+		{"forced", map[string]interface{}{"detect-conflicts": true, "force": true}, "Body", "Edited body", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.values)
+			client := propertyClient{props: map[string]interface{}{}}
+			// issue-sync's own writes, e.g. comments, update the issue
+			// long after the last sync; they mustn't be taken for edits.
+			jIssue := syncedIssue(cfg, ghIssue, time.Now().Add(-time.Hour))
+			jIssue.Fields.Updated = time.Now().Format(jiraDateFormat)
+			jIssue.Fields.Description = tt.current
+			if tt.written != "" {
+				client.SetProperty(jIssue, jClient.DescriptionPropertyKey, jClient.DescriptionProperty{Hash: jClient.DescriptionHash(tt.written)})
+			}
+
+			diff, err := issueDiff(cfg, ghIssue, jIssue, nil, client)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := diff[config.SyncDescription]; got != tt.want {
+				t.Errorf("description in diff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshDescriptionProperty(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{"detect-conflicts": true})
+	ghIssue := testIssue("Title", "Body")
+	client := propertyClient{props: map[string]interface{}{}}
+
+	// The conflict was resolved by copying the GitHub description by hand.
+	jIssue := syncedIssue(cfg, ghIssue, time.Now().Add(-time.Hour))
+	jIssue.Fields.Updated = time.Now().Format(jiraDateFormat)
+	client.SetProperty(jIssue, jClient.DescriptionPropertyKey, jClient.DescriptionProperty{Hash: jClient.DescriptionHash("Old body")})
+
+	if err := refreshDescriptionProperty(cfg, ghIssue, jIssue, client); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next GitHub edit is copied rather than taken for a conflict.
+	edited := testIssue("Title", "New body")
+	diff, err := issueDiff(cfg, edited, jIssue, nil, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !diff[config.SyncDescription] {
+		t.Errorf("description after resolving the conflict is still in conflict")
+	}
+}
+
+func TestSetRepoComponents(t *testing.T) {
+	tests := []struct {
+		name     string
+		triage   string
+		override []string
+		want     []string
+	}{
+		{"none", "", nil, nil},
+		{"override", "", []string{"API", "UI"}, []string{"API", "UI"}},
+		{"repeated", "", []string{"API", "UI", "API"}, []string{"API", "UI"}},
+		{"triage first", "Triage", []string{"Triage", "API"}, []string{"Triage", "API"}},
+		{"triage later", "Triage", []string{"API", "Triage"}, []string{"Triage", "API"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{
+				"triage-component": tt.triage,
+				"repos": []map[string]interface{}{{
+					"name":      "org",
+					"overrides": map[string]interface{}{"repo": map[string]interface{}{"components": tt.override}},
+				}},
+			})
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.URL = github.String("https://api.github.com/repos/org/repo/issues/1")
+
+			var fields jira.IssueFields
+			setTriageFields(cfg, &fields)
+			setRepoComponents(cfg, ghIssue, &fields)
+
+			var got []string
+			for _, c := range fields.Components {
+				got = append(got, c.Name)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("components = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDidIssueChangeWithoutLabels(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+	ghIssue := testIssue("Title", "Body")
+	key := cfg.GetFieldKey(config.GitHubLabels)
+
+	tests := []struct {
+		name   string
+		field  interface{}
+		absent bool
+		want   bool
+	}{
+		{"absent", nil, true, false},
+		{"null", nil, false, false},
+		{"empty", "", false, false},
+		{"labels", "bug", false, true},
+		{"not text", float64(1), false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			if tt.absent {
+				delete(jIssue.Fields.Unknowns, key)
+			} else {
+				jIssue.Fields.Unknowns[key] = tt.field
+			}
+
+			// The issue is compared on several runs, as written by the last.
+			for run := 1; run <= 3; run++ {
+				diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+				if diff[config.SyncLabels] != (tt.want && run == 1) {
+					t.Fatalf("run %d: labels changed = %v, want %v", run, diff[config.SyncLabels], tt.want && run == 1)
+				}
+				var fields jira.IssueFields
+				fields.Unknowns = map[string]interface{}{}
+				setChangedFields(cfg, ghIssue, diff, &fields, nil)
+				if v, ok := fields.Unknowns[key]; ok {
+					jIssue.Fields.Unknowns[key] = v
+				}
+			}
+		})
+	}
+}
+
+func TestLabelComparison(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+	key := cfg.GetFieldKey(config.GitHubLabels)
+
+	tests := []struct {
+		name    string
+		stored  string
+		labels  []string
+		changed bool
+		want    string
+	}{
+		{"same order", "bug,ui", []string{"bug", "ui"}, false, ""},
+		{"reordered on GitHub", "bug,ui", []string{"ui", "bug"}, false, ""},
+		// Stored unsorted, e.g. by an older version of issue-sync.
+		{"stored unsorted", "ui,p1,bug", []string{"bug", "p1", "ui"}, false, ""},
+		{"stored with spaces", "bug, ui", []string{"ui", "bug"}, false, ""},
+		{"renamed", "bug,ui", []string{"bug", "frontend"}, true, "bug,frontend"},
+		{"renamed case", "bug,ui", []string{"bug", "UI"}, true, "UI,bug"},
+		{"added", "bug", []string{"ui", "bug"}, true, "bug,ui"},
+		{"removed", "bug,ui", []string{"ui"}, true, "ui"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghIssue := testIssue("Title", "Body", tt.labels...)
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			jIssue.Fields.Unknowns[key] = tt.stored
+
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			if diff[config.SyncLabels] != tt.changed {
+				t.Fatalf("labels changed = %v, want %v", diff[config.SyncLabels], tt.changed)
+			}
+			if !tt.changed {
+				return
+			}
+			fields := jira.IssueFields{Unknowns: map[string]interface{}{}}
+			setChangedFields(cfg, ghIssue, diff, &fields, nil)
+			if got := fields.Unknowns[key]; got != tt.want {
+				t.Errorf("labels written = %#v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelRemoval(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+	key := cfg.GetFieldKey(config.GitHubLabels)
+
+	tests := []struct {
+		name   string
+		synced []string
+		now    []string
+		want   interface{}
+	}{
+		{"one of several", []string{"bug", "ui", "p1"}, []string{"bug", "p1"}, "bug,p1"},
+		{"last label", []string{"bug"}, nil, nil},
+		{"replaced", []string{"bug"}, []string{"feature"}, "feature"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jIssue := syncedIssue(cfg, testIssue("Title", "Body", tt.synced...), time.Now())
+			ghIssue := testIssue("Title", "Body", tt.now...)
+
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			if !diff[config.SyncLabels] {
+				t.Fatal("DidIssueChange() didn't report the removed labels")
+			}
+			var fields jira.IssueFields
+			fields.Unknowns = map[string]interface{}{}
+			setChangedFields(cfg, ghIssue, diff, &fields, nil)
+
+			got, ok := fields.Unknowns[key]
+			if !ok || got != tt.want {
+				t.Fatalf("labels field = %#v, want %#v", got, tt.want)
+			}
+			for _, removed := range removedLabels(strings.Join(tt.synced, ","), strings.Join(tt.now, ",")) {
+				if s, _ := got.(string); labelSet(s)[removed] {
+					t.Errorf("labels field %q still holds the removed label %s", s, removed)
+				}
+			}
+		})
+	}
+}
+
+func TestIssueLabels(t *testing.T) {
+	tests := []struct {
+		name     string
+		defaults []string
+		labels   []string
+		want     string
+	}{
+		{"none", nil, nil, ""},
+		{"sorted", nil, []string{"bug", "p1", "ui"}, "bug,p1,ui"},
+		{"unsorted", nil, []string{"ui", "bug", "p1"}, "bug,p1,ui"},
+		{"duplicate", nil, []string{"ui", "bug", "ui"}, "bug,ui"},
+		{"defaults", []string{"synced", "github"}, []string{"ui", "bug"}, "bug,github,synced,ui"},
+		{"default also on issue", []string{"bug"}, []string{"ui", "bug"}, "bug,ui"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"default-labels": tt.defaults})
+
+			// Every order of the labels, as GitHub may list them in
+			// different runs, is stored as the same string.
+			reversed := make([]string, len(tt.labels))
+			for i, l := range tt.labels {
+				reversed[len(reversed)-1-i] = l
+			}
+			for _, labels := range [][]string{tt.labels, reversed} {
+				if got := issueLabels(cfg, testIssue("Title", "Body", labels...)); got != tt.want {
+					t.Errorf("issueLabels(%q) = %q, want %q", labels, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  []string
+		stored  string
+		changed bool
+		want    string
+	}{
+		{"stored before defaults were configured", []string{"bug"}, "bug", true, "bug,github"},
+		{"no labels", nil, "", true, "github"},
+		{"already stored", []string{"bug"}, "bug,github", false, "bug,github"},
+		{"on the GitHub issue too", []string{"bug", "github"}, "bug,github", false, "bug,github"},
+		{"label added", []string{"bug", "ui"}, "bug,github", true, "bug,github,ui"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"default-labels": []string{"github"}})
+			key := cfg.GetFieldKey(config.GitHubLabels)
+			ghIssue := testIssue("Title", "Body", tt.labels...)
+
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			jIssue.Fields.Unknowns[key] = tt.stored
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			if diff[config.SyncLabels] != tt.changed {
+				t.Errorf("labels changed = %v, want %v", diff[config.SyncLabels], tt.changed)
+			}
+			if tt.changed {
+				var fields jira.IssueFields
+				fields.Unknowns = map[string]interface{}{}
+				setChangedFields(cfg, ghIssue, diff, &fields, nil)
+				if got := fields.Unknowns[key]; got != tt.want {
+					t.Errorf("updated labels = %#v, want %q", got, tt.want)
+				}
+			}
+
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+			if got := created.Fields.Unknowns[key]; got != tt.want {
+				t.Errorf("created labels = %#v, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIssueDescription(t *testing.T) {
+	notice := "\n\n_(Truncated; see the [full issue on GitHub|https://github.com/o/r/issues/1].)_"
+
+	tests := []struct {
+		name      string
+		max       int
+		body      string
+		want      string
+		truncated bool
+	}{
+		{"unlimited", 0, strings.Repeat("a", 1000), strings.Repeat("a", 1000), false},
+		{"short", 200, "Body", "Body", false},
+		{"maximum", 200, strings.Repeat("a", 200), strings.Repeat("a", 200), false},
+		{"oversized", 200, strings.Repeat("a", 201), strings.Repeat("a", 200-len(notice)), true},
+		{"multi-byte", 200, strings.Repeat("€", 201), strings.Repeat("€", 200-len(notice)), true},
+		// The converted body, "*a*" for each "**a**", is measured.
+		{"longer before conversion", 150, strings.Repeat("**a**", 50), strings.Repeat("*a*", 50), false},
+		{"limit shorter than the notice", 10, "Body, longer than ten", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"max-description-length": tt.max})
+			want := tt.want
+			if tt.truncated {
+				want += notice
+			}
+
+			if got := issueDescription(cfg, testIssue("Title", tt.body)); got != want {
+				t.Errorf("issueDescription() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestBodyRewrites(t *testing.T) {
+	// The rule matches the GitHub markup, so it must run before conversion.
+	cfg := newTestConfig(t, map[string]interface{}{
+		"body-rewrites": `[{"pattern": "\\*\\*secret: \\w+\\*\\*", "replace": "[redacted]"}]`,
+	})
+
+	tests := []struct {
+		name        string
+		body        string
+		description string
+		comment     string
+	}{
+		{"secret", "The **secret: hunter2** is set", "The [redacted] is set", "The [redacted] is set"},
+		{"other markup", "Some **bold** text", "Some *bold* text", "Some **bold** text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := issueDescription(cfg, testIssue("Title", tt.body)); got != tt.description {
+				t.Errorf("issueDescription() = %q, want %q", got, tt.description)
+			}
+			comment := github.IssueComment{Body: github.String(tt.body)}
+			if got := commentBody(cfg, comment); got != tt.comment {
+				t.Errorf("commentBody() = %q, want %q", got, tt.comment)
+			}
+		})
+	}
+}
+
+func TestSyncFields(t *testing.T) {
+	all := []string{config.SyncSummary, config.SyncDescription, config.SyncStatus, config.SyncReporter, config.SyncURI, config.SyncLabels}
+
+	tests := []struct {
+		name   string
+		fields []string
+	}{
+		{"all", all},
+		{"title and description", []string{config.SyncSummary, config.SyncDescription}},
+		{"all but labels", []string{config.SyncSummary, config.SyncDescription, config.SyncStatus, config.SyncReporter, config.SyncURI}},
+		{"none", []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"sync-fields": tt.fields})
+			synced := map[string]bool{}
+			for _, f := range tt.fields {
+				synced[f] = true
+			}
+
+			// Every field differs from the JIRA issue.
+			jIssue := syncedIssue(cfg, testIssue("Title", "Body", "bug"), time.Now())
+			ghIssue := testIssue("New title", "New body", "ui")
+			ghIssue.State = github.String("closed")
+			ghIssue.User = &github.User{Login: github.String("hubot")}
+			ghIssue.HTMLURL = github.String("https://github.com/o/r/issues/2")
+
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			for _, f := range all {
+				if diff[f] != synced[f] {
+					t.Errorf("%s changed = %v, want %v", f, diff[f], synced[f])
+				}
+			}
+
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+			written := map[string]bool{
+				config.SyncDescription: created.Fields.Description != "",
+			}
+			for f, key := range map[string]string{
+				config.SyncStatus:   cfg.GetFieldKey(config.GitHubStatus),
+				config.SyncReporter: cfg.GetFieldKey(config.GitHubReporter),
+				config.SyncURI:      cfg.GetFieldKey(config.GitHubURI),
+				config.SyncLabels:   cfg.GetFieldKey(config.GitHubLabels),
+			} {
+				_, written[f] = created.Fields.Unknowns[key]
+			}
+			for f, w := range written {
+				if w != synced[f] {
+					t.Errorf("%s written on create = %v, want %v", f, w, synced[f])
+				}
+			}
+		})
+	}
+}
+
+func TestIssueEnvironment(t *testing.T) {
+	form := "### Version\n\n1.2.3\n\n### Operating system\n\n_No response_\n\n### Steps\n\nRun it."
+
+	tests := []struct {
+		name     string
+		template string
+		body     string
+		want     string
+		ok       bool
+	}{
+		{"no template", "", form, "", false},
+		{"issue fields", "GitHub #{{.Number}} by {{.User.Login}}", form, "GitHub #1 by octocat", true},
+		{"form section", "Version: {{.Section \"Version\"}}", form, "Version: 1.2.3", true},
+		{"section case", "{{.Section \"version\"}}", form, "1.2.3", true},
+		{"unanswered section", "OS: {{.Section \"Operating system\"}}", form, "OS:", true},
+		{"missing section", "{{.Section \"Browser\"}}", form, "", true},
+		{"last section", "{{.Section \"Steps\"}}", form, "Run it.", true},
+		{"failing template", "{{.Missing}}", form, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"environment-template": tt.template})
+			ghIssue := testIssue("Title", tt.body)
+
+			env, ok := issueEnvironment(cfg, ghIssue)
+			if env != tt.want || ok != tt.ok {
+				t.Fatalf("issueEnvironment() = %q, %v, want %q, %v", env, ok, tt.want, tt.ok)
+			}
+
+			// The environment is written on create, and updated once it
+			// differs.
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+			if got, set := created.Fields.Unknowns["environment"]; set != tt.ok || (set && got != tt.want) {
+				t.Errorf("created environment = %#v, want %q set %v", got, tt.want, tt.ok)
+			}
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			jIssue.Fields.Unknowns["environment"] = "Edited"
+			if diff := DidIssueChange(cfg, ghIssue, jIssue, nil); diff[DiffEnvironment] != tt.ok {
+				t.Errorf("environment changed = %v, want %v", diff[DiffEnvironment], tt.ok)
+			}
+			jIssue.Fields.Unknowns["environment"] = env
+			if diff := DidIssueChange(cfg, ghIssue, jIssue, nil); diff[DiffEnvironment] {
+				t.Error("unchanged environment reported as changed")
+			}
+		})
+	}
+}
+
+func TestDueDate(t *testing.T) {
+	// The milestone is due late in the day, after midnight UTC.
+	milestone := time.Date(2026, 12, 31, 23, 30, 0, 0, time.FixedZone("PST", -8*60*60))
+
+	tests := []struct {
+		name      string
+		prefix    string
+		labels    []string
+		milestone *time.Time
+		stored    string
+		want      string
+		changed   bool
+	}{
+		{"milestone", "", nil, &milestone, "", "2027-01-01", true},
+		{"milestone unchanged", "", nil, &milestone, "2027-01-01", "2027-01-01", false},
+		{"milestone moved", "", nil, &milestone, "2026-06-30", "2027-01-01", true},
+		{"milestone removed", "", nil, nil, "2027-01-01", "", true},
+		{"no due date", "", nil, nil, "", "", false},
+		{"label", "due:", []string{"bug", "due:2026-03-01"}, &milestone, "", "2026-03-01", true},
+		{"labels not configured", "", []string{"due:2026-03-01"}, nil, "", "", false},
+		{"invalid label", "due:", []string{"due:soon"}, &milestone, "2027-01-01", "2027-01-01", false},
+		{"first valid label", "due:", []string{"due:soon", "due:2026-03-01", "due:2026-04-01"}, nil, "", "2026-03-01", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"sync-due-date": true, "due-date-label": tt.prefix})
+			ghIssue := testIssue("Title", "Body", tt.labels...)
+			if tt.milestone != nil {
+				ghIssue.Milestone = &github.Milestone{DueOn: tt.milestone}
+			}
+
+			if got := issueDueDate(cfg, ghIssue); got != tt.want {
+				t.Fatalf("issueDueDate() = %q, want %q", got, tt.want)
+			}
+
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+			if created.Fields.Duedate != tt.want {
+				t.Errorf("created due date = %q, want %q", created.Fields.Duedate, tt.want)
+			}
+
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			jIssue.Fields.Duedate = tt.stored
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			if diff[DiffDueDate] != tt.changed {
+				t.Fatalf("due date changed = %v, want %v", diff[DiffDueDate], tt.changed)
+			}
+			if !tt.changed {
+				return
+			}
+
+			// A removed due date is cleared with null.
+			fields := jira.IssueFields{Unknowns: map[string]interface{}{}}
+			setChangedFields(cfg, ghIssue, diff, &fields, nil)
+			cleared, set := fields.Unknowns["duedate"]
+			if fields.Duedate != tt.want || set != (tt.want == "") || cleared != nil {
+				t.Errorf("due date written = %q, cleared %v, want %q, cleared %v", fields.Duedate, set, tt.want, tt.want == "")
+			}
+		})
+	}
+}
+
+func TestIssueType(t *testing.T) {
+	tests := []struct {
+		name     string
+		prType   string
+		override string
+		pr       bool
+		want     string
+	}{
+		{"issue", "Pull Request", "", false, "Task"},
+		{"pull request", "Pull Request", "", true, "Pull Request"},
+		{"pull request without its own type", "", "", true, "Task"},
+		{"repository issue type", "Pull Request", "Bug", false, "Bug"},
+		{"repository pull request", "Pull Request", "Bug", true, "Pull Request"},
+		{"repository pull request without its own type", "", "Bug", true, "Bug"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{
+				"issue-type":    "Task",
+				"pr-issue-type": tt.prType,
+				"repos": []map[string]interface{}{{
+					"name":      "org",
+					"overrides": map[string]interface{}{"repo": map[string]interface{}{"issue-type": tt.override}},
+				}},
+			})
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.URL = github.String("https://api.github.com/repos/org/repo/issues/1")
+			if tt.pr {
+				ghIssue.PullRequestLinks = &github.PullRequestLinks{URL: github.String("https://api.github.com/repos/org/repo/pulls/1")}
+			}
+
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+			if created.Fields.Type.Name != tt.want {
+				t.Errorf("created issue type = %q, want %q", created.Fields.Type.Name, tt.want)
+			}
+		})
+	}
+}
+
+// transitionClient is a JIRA client which records the transition paths it is
+// asked to apply.
+type transitionClient struct {
+	jClient.JIRAClient
+	paths *[][]string
+}
+
+func (c transitionClient) TransitionIssue(issue jira.Issue, path []string) error {
+	*c.paths = append(*c.paths, path)
+	return nil
+}
+
+func TestReopenIssue(t *testing.T) {
+	path := []string{"Reopen", "Start Progress"}
+
+	tests := []struct {
+		name     string
+		path     []string
+		state    string
+		category string
+		want     [][]string
+	}{
+		{"reopened", path, "open", "done", [][]string{path}},
+		{"no path", nil, "open", "done", nil},
+		{"closed on GitHub", path, "closed", "done", nil},
+		{"not done in JIRA", path, "open", "indeterminate", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"reopen-transitions": tt.path})
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.State = github.String(tt.state)
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			jIssue.Fields.Status = &jira.Status{Name: "Status", StatusCategory: jira.StatusCategory{Key: tt.category}}
+
+			var paths [][]string
+			if err := reopenIssue(cfg, ghIssue, jIssue, transitionClient{paths: &paths}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("transition paths applied = %q, want %q", paths, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransitionRecreated(t *testing.T) {
+	path := []string{"Reopen", "Start Progress"}
+	reopened := []*github.Timeline{{Event: github.String("closed")}, {Event: github.String("reopened")}}
+
+	tests := []struct {
+		name   string
+		path   []string
+		state  string
+		events []*github.Timeline
+		want   [][]string
+	}{
+		{"reopened", path, "open", reopened, [][]string{path}},
+		{"no path", nil, "open", reopened, nil},
+		{"closed on GitHub", path, "closed", reopened, nil},
+		{"never reopened", path, "open", []*github.Timeline{{Event: github.String("labeled")}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"recreate-transitions": tt.path})
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.State = github.String(tt.state)
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+
+			var paths [][]string
+			if err := transitionRecreated(cfg, ghIssue, jIssue, eventsClient{events: tt.events}, transitionClient{paths: &paths}); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("transition paths applied = %q, want %q", paths, tt.want)
+			}
+		})
+	}
+}
+
+// forbiddingClient is a JIRA client which updates issues, and fails to
+// comment and to transition issues with the given errors.
+type forbiddingClient struct {
+	jClient.JIRAClient
+	jIssue        jira.Issue
+	updated       *bool
+	comments      *int
+	commentErr    error
+	transitionErr error
+}
+
+func (c forbiddingClient) Now() time.Time {
+	return time.Now()
+}
+
+func (c forbiddingClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
+	*c.updated = true
+	return issue, nil
+}
+
+func (c forbiddingClient) GetIssue(key string) (jira.Issue, error) {
+	return c.jIssue, nil
+}
+
+func (c forbiddingClient) CreateComment(issue jira.Issue, comment github.IssueComment, gh ghClient.GitHubClient) (jira.Comment, error) {
+	if c.commentErr != nil {
+		return jira.Comment{}, c.commentErr
+	}
+	*c.comments++
+	return jira.Comment{ID: "1"}, nil
+}
+
+func (c forbiddingClient) TransitionIssue(issue jira.Issue, path []string) error {
+	return c.transitionErr
+}
+
+func TestLenientOperations(t *testing.T) {
+	forbidden := jClient.ForbiddenError{Body: "You do not have the permission to do this."}
+
+	tests := []struct {
+		name          string
+		lenient       []string
+		commentErr    error
+		transitionErr error
+		comments      int
+		wantErr       bool
+	}{
+		{"comment forbidden", nil, forbidden, nil, 0, true},
+		{"comment forbidden, comment lenient", []string{"comment"}, forbidden, nil, 0, false},
+		{"comment forbidden, transition lenient", []string{"transition"}, forbidden, nil, 0, true},
+		{"comment failed, comment lenient", []string{"comment"}, errors.New("500 Internal Server Error"), nil, 0, true},
+		{"transition forbidden", nil, nil, forbidden, 0, true},
+		{"transition forbidden, transition lenient", []string{"transition"}, nil, forbidden, 1, false},
+		{"allowed", []string{"comment", "transition"}, nil, nil, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{
+				"lenient-operations": tt.lenient,
+				"reopen-transitions": []string{"Reopen"},
+			})
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.Comments = github.Int(1)
+			created := time.Now()
+			gh := listCommentsClient{comments: []*github.IssueComment{{
+				ID:        github.Int(2),
+				Body:      github.String("Comment"),
+				User:      &github.User{Login: github.String("octocat")},
+				CreatedAt: &created,
+			}}}
+			// The JIRA issue is done, so it is reopened, and its summary is
+			// outdated, so it is updated.
+			jIssue := syncedIssue(cfg, testIssue("Old title", "Body"), time.Now())
+			jIssue.Fields.Status = &jira.Status{Name: "Done", StatusCategory: jira.StatusCategory{Key: "done"}}
+
+			var updated bool
+			var comments int
+			client := forbiddingClient{
+				jIssue:        jIssue,
+				updated:       &updated,
+				comments:      &comments,
+				commentErr:    tt.commentErr,
+				transitionErr: tt.transitionErr,
+			}
+			err := UpdateIssue(cfg, ghIssue, jIssue, DidIssueChange(cfg, ghIssue, jIssue, nil), gh, client)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("UpdateIssue() error = %v, want error %v", err, tt.wantErr)
+			}
+			// The fields are updated before any comment or transition.
+			if !updated {
+				t.Error("issue not updated")
+			}
+			if comments != tt.comments {
+				t.Errorf("%d comments created, want %d", comments, tt.comments)
+			}
+		})
+	}
+}
+
+func TestIssueRepoField(t *testing.T) {
+	fieldIDs := map[string]string{"GitHub Repo": "10008"}
+	for name, id := range testFieldIDs {
+		fieldIDs[name] = id
+	}
+	cfg := newTestConfig(t, map[string]interface{}{"field-ids": fieldIDs})
+	key := cfg.GetFieldKey(config.GitHubRepo)
+
+	// Issue #1 of another repository, synced into the same project.
+	ghIssue := testIssue("Title", "Body")
+	ghIssue.URL = github.String("https://api.github.com/repos/b/y/issues/1")
+
+	tests := []struct {
+		name    string
+		stored  interface{}
+		changed bool
+	}{
+		{"same repository", "b/y", false},
+		{"other repository", "a/x", true},
+		{"not recorded", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			jIssue.Fields.Unknowns[key] = tt.stored
+
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			if diff[DiffRepo] != tt.changed {
+				t.Errorf("repository changed = %v, want %v", diff[DiffRepo], tt.changed)
+			}
+		})
+	}
+
+	var created jira.Issue
+	CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+	if got := created.Fields.Unknowns[key]; got != "b/y" {
+		t.Errorf("created repository = %#v, want %q", got, "b/y")
+	}
+}
+
+// stateCommentClient is a JIRA client which counts the state comments it is
+// asked to create.
+type stateCommentClient struct {
+	jClient.JIRAClient
+	created *int
+}
+
+func (c stateCommentClient) CreateStateComment(issue jira.Issue, ghIssue github.Issue) (jira.Comment, error) {
+	*c.created++
+	return jira.Comment{}, nil
+}
+
+func TestCommentStateChange(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		synced  interface{}
+		state   string
+		comment bool
+	}{
+		{"closed", map[string]interface{}{"state-comments": true}, "open", "closed", true},
+		{"reopened", map[string]interface{}{"state-comments": true}, "closed", "open", true},
+		{"unchanged", map[string]interface{}{"state-comments": true}, "open", "open", false},
+		{"disabled", nil, "open", "closed", false},
+		{"state not synced", map[string]interface{}{"state-comments": true}, nil, "closed", false},
+		{"status not in sync-fields", map[string]interface{}{"state-comments": true, "sync-fields": []string{config.SyncSummary}}, "open", "closed", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.values)
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.State = github.String(tt.state)
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			jIssue.Fields.Unknowns[cfg.GetFieldKey(config.GitHubStatus)] = tt.synced
+
+			var created int
+			if err := commentStateChange(cfg, ghIssue, jIssue, stateCommentClient{created: &created}); err != nil {
+				t.Fatal(err)
+			}
+			if (created > 0) != tt.comment {
+				t.Errorf("%d state comments created, want a comment %v", created, tt.comment)
+			}
+		})
+	}
+}
+
+func TestCommentCountField(t *testing.T) {
+	fieldIDs := map[string]string{"GitHub Comment Count": "10011"}
+	for name, id := range testFieldIDs {
+		fieldIDs[name] = id
+	}
+
+	tests := []struct {
+		name     string
+		fieldIDs map[string]string
+		comments int
+		stored   interface{}
+		changed  bool
+	}{
+		{"same count", fieldIDs, 3, float64(3), false},
+		{"new comment", fieldIDs, 4, float64(3), true},
+		{"not recorded", fieldIDs, 3, nil, true},
+		{"no comments", fieldIDs, 0, float64(0), false},
+		{"field missing", testFieldIDs, 4, float64(3), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"field-ids": tt.fieldIDs})
+			hasField := cfg.HasField(config.GitHubCommentCount)
+			key := cfg.GetFieldKey(config.GitHubCommentCount)
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.Comments = github.Int(tt.comments)
+
+			// The count is written on create, if the field exists.
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+			if got, set := created.Fields.Unknowns[key]; set != hasField || (set && got != tt.comments) {
+				t.Errorf("created comment count = %#v, want %d set %v", got, tt.comments, hasField)
+			}
+
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			if hasField {
+				jIssue.Fields.Unknowns[key] = tt.stored
+			}
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			if diff[DiffCommentCount] != tt.changed {
+				t.Fatalf("comment count changed = %v, want %v", diff[DiffCommentCount], tt.changed)
+			}
+			if !tt.changed {
+				return
+			}
+			fields := jira.IssueFields{Unknowns: map[string]interface{}{}}
+			setChangedFields(cfg, ghIssue, diff, &fields, nil)
+			if got := fields.Unknowns[key]; got != tt.comments {
+				t.Errorf("comment count written = %#v, want %d", got, tt.comments)
+			}
+		})
+	}
+}
+
+// reasonClient is a GitHub client returning a fixed state reason, or failing
+// with err.
+type reasonClient struct {
+	ghClient.GitHubClient
+	reason string
+	err    error
+}
+
+func (c reasonClient) GetStateReason(issue github.Issue) (string, error) {
+	return c.reason, c.err
+}
+
+func TestStateReasonField(t *testing.T) {
+	fieldIDs := map[string]string{"GitHub State Reason": "10012"}
+	for name, id := range testFieldIDs {
+		fieldIDs[name] = id
+	}
+
+	tests := []struct {
+		name     string
+		fieldIDs map[string]string
+		state    string
+		client   reasonClient
+		stored   interface{}
+		created  interface{}
+		changed  bool
+		written  interface{}
+	}{
+		{"completed", fieldIDs, "closed", reasonClient{reason: "completed"}, "completed", "completed", false, nil},
+		{"newly completed", fieldIDs, "closed", reasonClient{reason: "completed"}, nil, "completed", true, "completed"},
+		{"not planned", fieldIDs, "closed", reasonClient{reason: "not_planned"}, "completed", "not_planned", true, "not_planned"},
+		{"no reason", fieldIDs, "closed", reasonClient{}, nil, nil, false, nil},
+		{"reopened", fieldIDs, "open", reasonClient{reason: "reopened"}, "not_planned", nil, true, nil},
+		{"open", fieldIDs, "open", reasonClient{}, nil, nil, false, nil},
+		{"lookup error", fieldIDs, "closed", reasonClient{err: errors.New("not found")}, "completed", nil, false, nil},
+		{"field missing", testFieldIDs, "closed", reasonClient{reason: "completed"}, nil, nil, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"field-ids": tt.fieldIDs})
+			key := cfg.GetFieldKey(config.GitHubStateReason)
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.State = github.String(tt.state)
+
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, tt.client, createClient{created: &created})
+			if got := created.Fields.Unknowns[key]; got != tt.created {
+				t.Errorf("created state reason = %#v, want %#v", got, tt.created)
+			}
+
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			if cfg.HasField(config.GitHubStateReason) {
+				jIssue.Fields.Unknowns[key] = tt.stored
+			}
+			diff := DidIssueChange(cfg, ghIssue, jIssue, tt.client)
+			if diff[DiffStateReason] != tt.changed {
+				t.Fatalf("state reason changed = %v, want %v", diff[DiffStateReason], tt.changed)
+			}
+			if !tt.changed {
+				return
+			}
+			fields := jira.IssueFields{Unknowns: map[string]interface{}{}}
+			setChangedFields(cfg, ghIssue, diff, &fields, tt.client)
+			if got, set := fields.Unknowns[key]; !set || got != tt.written {
+				t.Errorf("state reason written = %#v, want %#v", got, tt.written)
+			}
+		})
+	}
+}
+
+func TestVersionField(t *testing.T) {
+	fieldIDs := map[string]string{"Issue-Sync Version": "10009"}
+	for name, id := range testFieldIDs {
+		fieldIDs[name] = id
+	}
+	cfg := newTestConfig(t, map[string]interface{}{"field-ids": fieldIDs})
+	cfg.SetVersion("2.0.0")
+	key := cfg.GetFieldKey(config.Version)
+
+	tests := []struct {
+		name    string
+		stored  interface{}
+		title   string
+		changed bool
+	}{
+		{"older version", "1.0.0", "Title", false},
+		{"not recorded", nil, "Title", false},
+		{"older version and edited title", "1.0.0", "New title", true},
+		{"same version and edited title", "2.0.0", "New title", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jIssue := syncedIssue(cfg, testIssue("Title", "Body"), time.Now())
+			jIssue.Fields.Unknowns[key] = tt.stored
+			ghIssue := testIssue(tt.title, "Body")
+
+			// The version alone never causes an update.
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			if changed := shouldUpdate(cfg, ghIssue, jIssue, diff); changed != tt.changed {
+				t.Fatalf("shouldUpdate() = %v, want %v (diff %q)", changed, tt.changed, diff.Fields())
+			}
+			if !tt.changed {
+				return
+			}
+			fields := jira.IssueFields{Unknowns: map[string]interface{}{}}
+			setChangedFields(cfg, ghIssue, diff, &fields, nil)
+			if got := fields.Unknowns[key]; got != "2.0.0" {
+				t.Errorf("version written on update = %#v, want %q", got, "2.0.0")
+			}
+		})
+	}
+
+	var created jira.Issue
+	CreateIssue(cfg, testIssue("Title", "Body"), nil, createClient{created: &created})
+	if got := created.Fields.Unknowns[key]; got != "2.0.0" {
+		t.Errorf("version written on create = %#v, want %q", got, "2.0.0")
+	}
+}
+
+func TestTypeLabels(t *testing.T) {
+	fieldIDs := map[string]string{"type field": "10010"}
+	for name, id := range testFieldIDs {
+		fieldIDs[name] = id
+	}
+	cfg := newTestConfig(t, map[string]interface{}{
+		"field-ids":   fieldIDs,
+		"type-field":  "Issue Kind",
+		"type-labels": map[string]string{"bug": "Bug", "enhancement": "Feature"},
+	})
+	key := cfg.GetFieldKey(config.TypeField)
+
+	tests := []struct {
+		name    string
+		labels  []string
+		stored  interface{}
+		want    string
+		changed bool
+	}{
+		{"type label", []string{"ui", "bug"}, nil, "Bug", true},
+		{"label case", []string{"BUG"}, nil, "Bug", true},
+		{"first type label", []string{"enhancement", "bug"}, nil, "Feature", true},
+		{"no type label", []string{"ui"}, map[string]interface{}{"value": "Bug"}, "", false},
+		{"already selected", []string{"bug"}, map[string]interface{}{"value": "Bug"}, "Bug", false},
+		{"label changed", []string{"enhancement"}, map[string]interface{}{"value": "Bug"}, "Feature", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghIssue := testIssue("Title", "Body", tt.labels...)
+
+			option, ok := issueTypeOption(cfg, ghIssue)
+			if option != tt.want || ok != (tt.want != "") {
+				t.Errorf("issueTypeOption() = %q, %v, want %q, %v", option, ok, tt.want, tt.want != "")
+			}
+
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			jIssue.Fields.Unknowns[key] = tt.stored
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			if diff[DiffType] != tt.changed {
+				t.Fatalf("type changed = %v, want %v", diff[DiffType], tt.changed)
+			}
+			if !tt.changed {
+				return
+			}
+			fields := jira.IssueFields{Unknowns: map[string]interface{}{}}
+			setChangedFields(cfg, ghIssue, diff, &fields, nil)
+			if got, want := fields.Unknowns[key], map[string]string{"value": tt.want}; !reflect.DeepEqual(got, want) {
+				t.Errorf("type field written = %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestEmptyBody(t *testing.T) {
+	const placeholder = "(no description provided)"
+
+	tests := []struct {
+		name        string
+		placeholder string
+		body        string
+		// changed is whether the description of a synced JIRA issue holding
+		// a manually added description is updated, and want is the
+		// description sent on create and update.
+		changed bool
+		want    string
+	}{
+		{"body", "", "Body", true, "Body"},
+		{"empty", "", "", false, ""},
+		{"whitespace", "", " \n\t", false, ""},
+		{"placeholder", placeholder, "", true, placeholder},
+		{"placeholder for whitespace", placeholder, " \n", true, placeholder},
+		{"placeholder with body", placeholder, "Body", true, "Body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"empty-body-placeholder": tt.placeholder})
+			ghIssue := testIssue("Title", tt.body)
+
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+			if created.Fields.Description != tt.want {
+				t.Errorf("created description = %q, want %q", created.Fields.Description, tt.want)
+			}
+			if b, err := json.Marshal(created.Fields); err != nil {
+				t.Fatal(err)
+			} else if sent := strings.Contains(string(b), `"description"`); sent != (tt.want != "") {
+				t.Errorf("description sent on create = %v, want %v", sent, tt.want != "")
+			}
+
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			jIssue.Fields.Description = "Added in JIRA"
+			diff := DidIssueChange(cfg, ghIssue, jIssue, nil)
+			if diff[config.SyncDescription] != tt.changed {
+				t.Errorf("description changed = %v, want %v", diff[config.SyncDescription], tt.changed)
+			}
+			if tt.changed {
+				fields := jira.IssueFields{Unknowns: map[string]interface{}{}}
+				setChangedFields(cfg, ghIssue, diff, &fields, nil)
+				if fields.Description != tt.want {
+					t.Errorf("updated description = %q, want %q", fields.Description, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// createClient is a JIRA client which records the issue it is asked to
+// create, and then fails, so that nothing else is requested.
+type createClient struct {
+	jClient.JIRAClient
+	created *jira.Issue
+}
+
+func (c createClient) Now() time.Time {
+	return time.Now()
+}
+
+func (c createClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
+	*c.created = issue
+	return jira.Issue{}, errors.New("not created")
+}
+
+// createdClient is a JIRA client which creates the given issue, and records
+// the reference by which it is then fetched, failing to fetch it.
+type createdClient struct {
+	jClient.JIRAClient
+	created jira.Issue
+	fetched *string
+}
+
+func (c createdClient) Now() time.Time {
+	return time.Now()
+}
+
+func (c createdClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
+	return c.created, nil
+}
+
+func (c createdClient) GetIssue(key string) (jira.Issue, error) {
+	*c.fetched = key
+	return jira.Issue{}, errors.New("not fetched")
+}
+
+func TestCreatedIssueReference(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+
+	tests := []struct {
+		name    string
+		created jira.Issue
+		want    string
+	}{
+		{"key and ID", jira.Issue{ID: "10000", Key: "TEST-1"}, "TEST-1"},
+		{"key only", jira.Issue{Key: "TEST-1"}, "TEST-1"},
+		{"ID only", jira.Issue{ID: "10000"}, "10000"},
+		{"neither", jira.Issue{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetched string
+			err := CreateIssue(cfg, testIssue("Title", "Body"), nil, createdClient{created: tt.created, fetched: &fetched})
+			if fetched != tt.want {
+				t.Errorf("created issue fetched by %q, want %q", fetched, tt.want)
+			}
+			if tt.want == "" && (err == nil || !strings.Contains(err.Error(), "neither a key nor an ID")) {
+				t.Errorf("CreateIssue() = %v, want an error for the missing key and ID", err)
+			}
+		})
+	}
+}
+
+// syncClient is a JIRA client holding fixed JIRA issues, which counts the
+// issues it is asked to create and update. Creating fails, so that nothing
+// else is requested for the new issue.
+type syncClient struct {
+	listingClient
+	created, updated *int
+}
+
+func (c syncClient) Now() time.Time {
+	return time.Now()
+}
+
+func (c syncClient) CreateIssue(issue jira.Issue) (jira.Issue, error) {
+	*c.created++
+	return jira.Issue{}, errors.New("not created")
+}
+
+func (c syncClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
+	*c.updated++
+	return issue, nil
+}
+
+// updateClient is a JIRA client which records the issue it is asked to
+// update, and then fails, so that nothing else is requested. Fetching the
+// issue fails too.
+type updateClient struct {
+	jClient.JIRAClient
+	updated *jira.Issue
+}
+
+func (c updateClient) Now() time.Time {
+	return time.Now()
+}
+
+func (c updateClient) UpdateIssue(issue jira.Issue) (jira.Issue, error) {
+	*c.updated = issue
+	return jira.Issue{}, errors.New("not updated")
+}
+
+func (c updateClient) GetIssue(key string) (jira.Issue, error) {
+	return jira.Issue{}, errors.New("not fetched")
+}
+
+func TestTerminalStatuses(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		status   *jira.Status
+		updated  bool
+	}{
+		{"terminal status", []string{"Done", "Closed"}, &jira.Status{Name: "Closed"}, false},
+		{"status case", []string{"done"}, &jira.Status{Name: "Done"}, false},
+		{"other status", []string{"Done"}, &jira.Status{Name: "In Progress"}, true},
+		{"no terminal statuses", nil, &jira.Status{Name: "Done"}, true},
+		{"no status", []string{"Done"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"terminal-statuses": tt.statuses})
+			jIssue := syncedIssue(cfg, testIssue("Title", "Body"), time.Now())
+			jIssue.Fields.Status = tt.status
+			if got := inTerminalStatus(cfg, jIssue); got != !tt.updated {
+				t.Errorf("inTerminalStatus() = %v, want %v", got, !tt.updated)
+			}
+
+			ghIssue := testIssue("New title", "Body")
+			var updated jira.Issue
+			UpdateIssue(cfg, ghIssue, jIssue, DidIssueChange(cfg, ghIssue, jIssue, nil), nil, updateClient{updated: &updated})
+			if got := updated.Fields != nil; got != tt.updated {
+				t.Errorf("fields updated = %v, want %v", got, tt.updated)
+			}
+		})
+	}
+}
+
+func TestTriageFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		label     string
+		assignee  string
+		component string
+	}{
+		{"none", "", "", ""},
+		{"label", "triage", "", ""},
+		{"assignee", "", "triager", ""},
+		{"component", "", "", "Inbox"},
+		{"all", "triage", "triager", "Inbox"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{
+				"triage-label":     tt.label,
+				"triage-assignee":  tt.assignee,
+				"triage-component": tt.component,
+			})
+			ghIssue := testIssue("Title", "Body")
+
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+			fields := created.Fields
+			if got := strings.Join(fields.Labels, ","); got != tt.label {
+				t.Errorf("created labels = %q, want %q", got, tt.label)
+			}
+			if (fields.Assignee != nil) != (tt.assignee != "") || (fields.Assignee != nil && fields.Assignee.Name != tt.assignee) {
+				t.Errorf("created assignee = %v, want %q", fields.Assignee, tt.assignee)
+			}
+			if (len(fields.Components) > 0) != (tt.component != "") || (len(fields.Components) > 0 && fields.Components[0].Name != tt.component) {
+				t.Errorf("created components = %v, want %q", fields.Components, tt.component)
+			}
+
+			// Once triaged, an update of the issue doesn't flag it again.
+			edited := testIssue("New title", "New body", "bug")
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			var updated jira.Issue
+			UpdateIssue(cfg, edited, jIssue, DidIssueChange(cfg, edited, jIssue, nil), nil, updateClient{updated: &updated})
+			if updated.Fields == nil {
+				t.Fatal("issue not updated")
+			}
+			if f := updated.Fields; len(f.Labels) > 0 || f.Assignee != nil || len(f.Components) > 0 {
+				t.Errorf("update sent labels %v, assignee %v and components %v, want none", f.Labels, f.Assignee, f.Components)
+			}
+		})
+	}
+}
+
+func TestMaxIssueAge(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		maxAge  string
+		age     time.Duration
+		synced  bool
+		created int
+		updated int
+	}{
+		{"recent", "720h", time.Hour, false, 1, 0},
+		{"old", "720h", 1000 * time.Hour, false, 0, 0},
+		{"no limit", "0", 100000 * time.Hour, false, 1, 0},
+		{"old but synced", "720h", 1000 * time.Hour, true, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"max-issue-age": tt.maxAge})
+			ghIssue := testIssue("Title", "Body")
+			created := now.Add(-tt.age)
+			ghIssue.CreatedAt = &created
+
+			var jIssues []jira.Issue
+			if tt.synced {
+				// The title was edited since the last sync.
+				jIssues = append(jIssues, syncedIssue(cfg, testIssue("Old title", "Body"), now))
+			}
+			var nCreated, nUpdated int
+			client := syncClient{listingClient{issues: jIssues, listed: &[]int{}}, &nCreated, &nUpdated}
+
+			syncMatchedIssue(cfg, ghIssue, jIssues, nil, client, nil)
+			if nCreated != tt.created || nUpdated != tt.updated {
+				t.Errorf("%d JIRA issues created and %d updated, want %d and %d", nCreated, nUpdated, tt.created, tt.updated)
+			}
+		})
+	}
+}
+
+func TestCreateDefaults(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]interface{}
+		defaults string
+		want     map[string]string
+	}{
+		{"unset fields", nil, `{"priority": {"name": "High"}, "labels": ["default"], "assignee": {"name": "lead"}}`, map[string]string{
+			"priority": `{"name":"High"}`,
+			"labels":   `["default"]`,
+			"assignee": `{"name":"lead"}`,
+		}},
+		{"summary and description", nil, `{"summary": "Default", "description": "Default"}`, map[string]string{
+			"summary":     `"Title"`,
+			"description": `"Body"`,
+		}},
+		{"custom field", nil, `{"customfield_10002": 5}`, map[string]string{
+			"customfield_10002": `1`,
+		}},
+		{"triage fields", map[string]interface{}{
+			"triage-label":     "triage",
+			"triage-assignee":  "triager",
+			"triage-component": "Inbox",
+		}, `{"labels": ["default"], "assignee": {"name": "lead"}, "components": [{"name": "Default"}]}`, map[string]string{
+			"labels":     `["triage"]`,
+			"assignee":   `{"Password":"","name":"triager"}`,
+			"components": `[{"name":"Inbox"}]`,
+		}},
+		{"reporter", map[string]interface{}{"native-reporter": true, "default-reporter": "reporter"}, `{"reporter": {"name": "other"}}`, map[string]string{
+			"reporter": `{"Password":"","name":"reporter"}`,
+		}},
+		{"due date not synced", nil, `{"duedate": "2020-01-01"}`, map[string]string{
+			"duedate": `"2020-01-01"`,
+		}},
+		{"due date synced", map[string]interface{}{"sync-due-date": true}, `{"duedate": "2020-01-01"}`, map[string]string{
+			"duedate": ``,
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]interface{}{"create-defaults": tt.defaults}
+			for key, value := range tt.values {
+				values[key] = value
+			}
+			cfg := newTestConfig(t, values)
+
+			var created jira.Issue
+			if err := CreateIssue(cfg, testIssue("Title", "Body"), nil, createClient{created: &created}); err == nil {
+				t.Fatal("CreateIssue() succeeded with a failing client")
+			}
+			if created.Fields == nil {
+				t.Fatal("no issue created")
+			}
+
+			b, err := json.Marshal(created.Fields)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var payload map[string]json.RawMessage
+			if err := json.Unmarshal(b, &payload); err != nil {
+				t.Fatal(err)
+			}
+			for key, want := range tt.want {
+				if got := string(payload[key]); got != want {
+					t.Errorf("%s = %s, want %s", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+// usersClient is a JIRA client which records the issue it is asked to
+// create, and knows fixed JIRA users, or fails to look them up with err.
+type usersClient struct {
+	createClient
+	users map[string]bool
+	err   error
+}
+
+func (c usersClient) UserExists(name string) (bool, error) {
+	return c.users[name], c.err
+}
+
+func TestNativeReporter(t *testing.T) {
+	users := map[string]bool{"octo": true, "reporter": true}
+
+	tests := []struct {
+		name    string
+		values  map[string]interface{}
+		err     error
+		want    string
+		wantErr bool
+	}{
+		{"mapped user", map[string]interface{}{"user-map": map[string]string{"octocat": "octo"}, "default-reporter": "reporter"}, nil, "octo", false},
+		{"mapped user without default", map[string]interface{}{"user-map": map[string]string{"octocat": "octo"}}, nil, "octo", false},
+		{"mapped user missing", map[string]interface{}{"user-map": map[string]string{"octocat": "gone"}, "default-reporter": "reporter"}, nil, "reporter", false},
+		{"not mapped", map[string]interface{}{"default-reporter": "reporter"}, nil, "reporter", false},
+		{"neither", nil, nil, "", false},
+		{"lookup error", map[string]interface{}{"user-map": map[string]string{"octocat": "octo"}, "default-reporter": "reporter"}, errors.New("JIRA unavailable"), "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]interface{}{"native-reporter": true}
+			for key, value := range tt.values {
+				values[key] = value
+			}
+			cfg := newTestConfig(t, values)
+
+			var created jira.Issue
+			client := usersClient{createClient: createClient{created: &created}, users: users, err: tt.err}
+			CreateIssue(cfg, testIssue("Title", "Body"), nil, client)
+			if tt.wantErr {
+				if created.Fields != nil {
+					t.Error("issue created although its reporter couldn't be looked up")
+				}
+				return
+			}
+			if created.Fields == nil {
+				t.Fatal("no issue created")
+			}
+			var got string
+			if created.Fields.Reporter != nil {
+				got = created.Fields.Reporter.Name
+			}
+			if got != tt.want {
+				t.Errorf("reporter = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// stubConverter marks the bodies it converts, in either direction.
+type stubConverter struct{}
+
+func (stubConverter) ToTarget(source string) string {
+	return "target:" + source
+}
+
+func (stubConverter) ToSource(target string) string {
+	return "source:" + target
+}
+
+func TestSetConverter(t *testing.T) {
+	tests := []struct {
+		name      string
+		converter convert.Converter
+		body      string
+		want      string
+		source    string
+	}{
+		{"default", nil, "**bold**", "*bold*", "**bold**"},
+		{"custom", stubConverter{}, "**bold**", "target:**bold**", "source:target:**bold**"},
+		{"identity", convert.IdentityConverter{}, "**bold**", "**bold**", "**bold**"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, nil)
+			if tt.converter != nil {
+				cfg.SetConverter(tt.converter)
+			}
+			ghIssue := testIssue("Title", tt.body)
+
+			var created jira.Issue
+			CreateIssue(cfg, ghIssue, nil, createClient{created: &created})
+			if created.Fields == nil {
+				t.Fatal("no issue created")
+			}
+			if created.Fields.Description != tt.want {
+				t.Errorf("created description = %q, want %q", created.Fields.Description, tt.want)
+			}
+
+			// The synced description compares equal, so it isn't rewritten.
+			jIssue := syncedIssue(cfg, ghIssue, time.Now())
+			if jIssue.Fields.Description != tt.want {
+				t.Errorf("synced description = %q, want %q", jIssue.Fields.Description, tt.want)
+			}
+			if diff := DidIssueChange(cfg, ghIssue, jIssue, nil); diff[config.SyncDescription] {
+				t.Error("DidIssueChange() reports the converted description as changed")
+			}
+
+			// JIRA comments mirrored to GitHub are converted back.
+			jComment := jira.Comment{ID: "1", Body: tt.want, Author: jira.User{DisplayName: "User"}}
+			if body := mirrorCommentBody(cfg, jIssue, jComment); !strings.HasSuffix(body, tt.source) {
+				t.Errorf("mirrored comment body = %q, want it to end with %q", body, tt.source)
+			}
+		})
+	}
+}
@@ -0,0 +1,179 @@
+package sync
+
+import (
+	gosync "sync"
+
+	jira "github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+)
+
+// fetchedIssue is a GitHub issue passed from the GitHub stage of
+// processIssues to its JIRA stage, with the client holding the data fetched
+// for it.
+type fetchedIssue struct {
+	issue  github.Issue
+	client ghClient.GitHubClient
+}
+
+// processIssues syncs GitHub issues in a two-stage pipeline, and returns the
+// sum of their results. The GitHub stage fetches the data of each issue in
+// turn with fetch, and passes it through a queue holding at most one issue
+// per worker to the JIRA stage, a pool of workers which sync the issues with
+// sync. So the GitHub requests for the next issues overlap the JIRA requests
+// for the current ones, and a slow JIRA holds back the fetching rather than
+// filling memory. Once every issue has been fetched the queue is closed, and
+// it returns once the workers have drained it; failures are counted in the
+// results rather than stopping the others. With one worker, the issues are
+// fetched and synced in order without any goroutines.
+func processIssues(workers int, ghIssues []github.Issue, fetch func(github.Issue) fetchedIssue, sync func(fetchedIssue) Result) Result {
+	var total Result
+
+	if workers <= 1 {
+		for _, ghIssue := range ghIssues {
+			total.add(sync(fetch(ghIssue)))
+		}
+		return total
+	}
+
+	queue := make(chan fetchedIssue, workers)
+	results := make(chan Result, workers)
+
+	go func() {
+		defer close(queue)
+		for _, ghIssue := range ghIssues {
+			queue <- fetch(ghIssue)
+		}
+	}()
+
+	var wg gosync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for issue := range queue {
+				results <- sync(issue)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		total.add(r)
+	}
+	return total
+}
+
+// prefetchedClient is a GitHub client which returns the comments, timeline
+// and state reason of one issue as they were fetched by the GitHub stage of
+// processIssues, errors included, rather than requesting them again. Other
+// requests are passed on.
+type prefetchedClient struct {
+	ghClient.GitHubClient
+	id int
+
+	comments    []*github.IssueComment
+	commentsErr error
+	hasComments bool
+
+	timeline    []*github.Timeline
+	timelineErr error
+	hasTimeline bool
+
+	reason    string
+	reasonErr error
+	hasReason bool
+}
+
+func (c prefetchedClient) ListComments(issue github.Issue) ([]*github.IssueComment, error) {
+	if c.hasComments && issue.GetID() == c.id {
+		return c.comments, c.commentsErr
+	}
+	return c.GitHubClient.ListComments(issue)
+}
+
+func (c prefetchedClient) ListTimeline(issue github.Issue) ([]*github.Timeline, error) {
+	if c.hasTimeline && issue.GetID() == c.id {
+		return c.timeline, c.timelineErr
+	}
+	return c.GitHubClient.ListTimeline(issue)
+}
+
+func (c prefetchedClient) GetStateReason(issue github.Issue) (string, error) {
+	if c.hasReason && issue.GetID() == c.id {
+		return c.reason, c.reasonErr
+	}
+	return c.GitHubClient.GetStateReason(issue)
+}
+
+// prefetchIssue is the GitHub stage of processIssues: it fetches the
+// comments, timeline and state reason of a GitHub issue which syncing it
+// needs.
+func prefetchIssue(cfg config.Config, ghIssue github.Issue, client ghClient.GitHubClient) fetchedIssue {
+	c := prefetchedClient{GitHubClient: client, id: ghIssue.GetID()}
+	if ghIssue.GetComments() > 0 {
+		c.comments, c.commentsErr = client.ListComments(ghIssue)
+		c.hasComments = true
+	}
+	if len(cfg.GetTimelineEvents()) > 0 {
+		c.timeline, c.timelineErr = client.ListTimeline(ghIssue)
+		c.hasTimeline = true
+	}
+	if cfg.HasField(config.GitHubStateReason) && ghIssue.GetState() == "closed" {
+		c.reason, c.reasonErr = client.GetStateReason(ghIssue)
+		c.hasReason = true
+	}
+	return fetchedIssue{issue: ghIssue, client: c}
+}
+
+// deleteComments deletes JIRA comments with a pool of workers. Every comment
+// is attempted even if some deletions fail; the first error is returned. With
+// one worker, the comments are deleted in order without any goroutines.
+func deleteComments(workers int, jComments []jira.Comment, del func(jira.Comment) error) error {
+	var first error
+
+	if workers <= 1 {
+		for _, jComment := range jComments {
+			if err := del(jComment); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	}
+
+	queue := make(chan jira.Comment, workers)
+	errs := make(chan error, workers)
+
+	go func() {
+		defer close(queue)
+		for _, jComment := range jComments {
+			queue <- jComment
+		}
+	}()
+
+	var wg gosync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for jComment := range queue {
+				errs <- del(jComment)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(errs)
+	}()
+
+	for err := range errs {
+		if err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
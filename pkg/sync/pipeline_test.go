@@ -0,0 +1,200 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	gosync "sync"
+	"testing"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+)
+
+func TestProcessIssues(t *testing.T) {
+	tests := []struct {
+		workers, issues int
+	}{
+		{1, 0},
+		{1, 5},
+		{2, 0},
+		{2, 1},
+		{2, 50},
+		{4, 3},
+		{8, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%d workers %d issues", tt.workers, tt.issues), func(t *testing.T) {
+			ghIssues := make([]github.Issue, tt.issues)
+			for i := range ghIssues {
+				ghIssues[i].ID = github.Int(i)
+			}
+
+			var mu gosync.Mutex
+			var fetched, synced []int
+			// pending counts the issues fetched but not yet synced, and
+			// running the issues being synced.
+			var pending, maxPending, running, maxRunning int
+
+			result := processIssues(tt.workers, ghIssues, func(ghIssue github.Issue) fetchedIssue {
+				mu.Lock()
+				defer mu.Unlock()
+				fetched = append(fetched, ghIssue.GetID())
+				if pending++; pending > maxPending {
+					maxPending = pending
+				}
+				return fetchedIssue{issue: ghIssue}
+			}, func(issue fetchedIssue) Result {
+				mu.Lock()
+				if running++; running > maxRunning {
+					maxRunning = running
+				}
+				mu.Unlock()
+
+				defer func() {
+					mu.Lock()
+					defer mu.Unlock()
+					running--
+					pending--
+					synced = append(synced, issue.issue.GetID())
+				}()
+				if issue.issue.GetID()%3 == 0 {
+					return Result{Failed: 1, Total: 1}
+				}
+				return Result{Updated: 1, Total: 1}
+			})
+
+			if result.Total != tt.issues {
+				t.Errorf("total = %d, want %d", result.Total, tt.issues)
+			}
+			if want := (tt.issues + 2) / 3; result.Failed != want {
+				t.Errorf("failed = %d, want %d", result.Failed, want)
+			}
+			if result.Failed+result.Updated != result.Total {
+				t.Errorf("failed %d and updated %d don't add up to the total %d", result.Failed, result.Updated, result.Total)
+			}
+
+			// The GitHub stage fetches the issues in order, and every issue
+			// is synced exactly once.
+			for i, id := range fetched {
+				if id != i {
+					t.Fatalf("issue %d was fetched as number %d", id, i)
+				}
+			}
+			sort.Ints(synced)
+			if !reflect.DeepEqual(synced, fetched) {
+				t.Errorf("synced %v, want %v", synced, fetched)
+			}
+
+			if maxRunning > tt.workers {
+				t.Errorf("%d issues were synced at once by %d workers", maxRunning, tt.workers)
+			}
+			// At most one issue per worker is queued, besides those being
+			// synced and the one being fetched.
+			if limit := 2*tt.workers + 1; maxPending > limit {
+				t.Errorf("%d issues were fetched ahead, more than %d", maxPending, limit)
+			}
+		})
+	}
+}
+
+// timelineClient is a GitHub client counting the requests for comments and
+// timelines, which fail with err.
+type timelineClient struct {
+	ghClient.GitHubClient
+	requests *int
+	err      error
+}
+
+func (c timelineClient) ListComments(issue github.Issue) ([]*github.IssueComment, error) {
+	*c.requests++
+	return []*github.IssueComment{{ID: github.Int(issue.GetID())}}, c.err
+}
+
+func (c timelineClient) ListTimeline(issue github.Issue) ([]*github.Timeline, error) {
+	*c.requests++
+	return nil, c.err
+}
+
+func TestPrefetchIssue(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   map[string]interface{}
+		comments int
+		err      error
+		want     int
+	}{
+		{"nothing to fetch", nil, 0, nil, 0},
+		{"comments", nil, 2, nil, 1},
+		{"comments and timeline", map[string]interface{}{"timeline-events": []string{"closed"}}, 2, nil, 2},
+		{"error", nil, 2, errors.New("rate limited"), 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.values)
+			var requests int
+			client := timelineClient{requests: &requests, err: tt.err}
+			ghIssue := github.Issue{ID: github.Int(7), Comments: github.Int(tt.comments)}
+
+			fetched := prefetchIssue(cfg, ghIssue, client)
+			if requests != tt.want {
+				t.Errorf("GitHub stage made %d requests, want %d", requests, tt.want)
+			}
+
+			if tt.comments == 0 {
+				return
+			}
+
+			// The JIRA stage gets the prefetched data, and its error,
+			// without further requests.
+			comments, err := fetched.client.ListComments(ghIssue)
+			if err != tt.err {
+				t.Errorf("ListComments() error = %v, want %v", err, tt.err)
+			}
+			if len(comments) != 1 || comments[0].GetID() != 7 {
+				t.Errorf("ListComments() = %v, want the prefetched comment", comments)
+			}
+			if len(cfg.GetTimelineEvents()) > 0 {
+				fetched.client.ListTimeline(ghIssue)
+			}
+			if requests != tt.want {
+				t.Errorf("JIRA stage made %d more requests", requests-tt.want)
+			}
+		})
+	}
+}
+
+func TestDeleteComments(t *testing.T) {
+	var jComments []jira.Comment
+	for i := 1; i <= 20; i++ {
+		jComments = append(jComments, jira.Comment{ID: strconv.Itoa(i)})
+	}
+
+	for _, workers := range []int{1, 3} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			var mu gosync.Mutex
+			attempted := map[string]bool{}
+			err := deleteComments(workers, jComments, func(c jira.Comment) error {
+				mu.Lock()
+				defer mu.Unlock()
+				attempted[c.ID] = true
+				if c.ID == "7" {
+					return errors.New("forbidden")
+				}
+				return nil
+			})
+
+			if err == nil {
+				t.Error("deleteComments() = nil, want the failed deletion's error")
+			}
+			if len(attempted) != len(jComments) {
+				t.Errorf("%d deletions attempted, want all %d", len(attempted), len(jComments))
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+// Link is a GitHub issue and the JIRA issue it is synced to, if any.
+type Link struct {
+	GitHubNumber int    `json:"github_number"`
+	GitHubURL    string `json:"github_url"`
+	JIRAKey      string `json:"jira_key"`
+	LastSync     string `json:"last_synced"`
+}
+
+// Links matches every GitHub issue in the configured repositories with
+// its JIRA issue, without changing either. The JIRA key of issues which
+// haven't been synced is empty.
+func Links(cfg config.Config, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient) ([]Link, error) {
+	queries, err := buildQueries(cfg, ghClient, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+
+	var links []Link
+	for _, q := range queries {
+		ghIssues, err := ghClient.SearchIssues(q.query)
+		if err != nil {
+			return nil, fmt.Errorf("error searching GitHub issues of %s: %v", q.name, err)
+		}
+		if len(ghIssues) == 0 {
+			continue
+		}
+
+		ids := make([]int, len(ghIssues))
+		for i, v := range ghIssues {
+			ids[i] = v.GetID()
+		}
+		jiraIssues, err := jiraClient.ListIssues(ids)
+		if err != nil {
+			return nil, fmt.Errorf("error listing JIRA issues of %s: %v", q.name, err)
+		}
+
+		for _, ghIssue := range ghIssues {
+			link := Link{
+				GitHubNumber: ghIssue.GetNumber(),
+				GitHubURL:    ghIssue.GetHTMLURL(),
+			}
+			if jIssue, ok := matchIssue(cfg, ghIssue, jiraIssues); ok {
+				link.JIRAKey = jIssue.Key
+				if jIssue.Fields != nil {
+					link.LastSync, _ = jIssue.Fields.Unknowns.String(cfg.GetFieldKey(config.LastISUpdate))
+				}
+			}
+			links = append(links, link)
+		}
+	}
+
+	return links, nil
+}
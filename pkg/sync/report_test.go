@@ -0,0 +1,67 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+)
+
+func TestLinks(t *testing.T) {
+	// The query is configured, so no members are looked up.
+	cfg := newTestConfig(t, map[string]interface{}{"search-query": "org:o"})
+	synced := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	// GitHub issues 1 to 3, of which 1 and 3 are synced to JIRA.
+	var ghIssues []github.Issue
+	var jIssues []jira.Issue
+	for id := 1; id <= 3; id++ {
+		ghIssue := testIssue("Title", "Body")
+		ghIssue.ID = github.Int(100 + id)
+		ghIssue.Number = github.Int(id)
+		ghIssue.HTMLURL = github.String(fmt.Sprintf("https://github.com/o/r/issues/%d", id))
+		ghIssues = append(ghIssues, ghIssue)
+		if id != 2 {
+			jIssue := syncedIssue(cfg, ghIssue, synced)
+			jIssue.Key = fmt.Sprintf("TEST-%d", id)
+			jIssues = append(jIssues, jIssue)
+		}
+	}
+	lastSync := synced.Format(jiraDateFormat)
+
+	tests := []struct {
+		name    string
+		gh      searchClient
+		jIssues []jira.Issue
+		want    []Link
+		wantErr bool
+	}{
+		{"matched and unmatched", searchClient{issues: ghIssues}, jIssues, []Link{
+			{GitHubNumber: 1, GitHubURL: "https://github.com/o/r/issues/1", JIRAKey: "TEST-1", LastSync: lastSync},
+			{GitHubNumber: 2, GitHubURL: "https://github.com/o/r/issues/2"},
+			{GitHubNumber: 3, GitHubURL: "https://github.com/o/r/issues/3", JIRAKey: "TEST-3", LastSync: lastSync},
+		}, false},
+		{"none synced", searchClient{issues: ghIssues[1:2]}, nil, []Link{
+			{GitHubNumber: 2, GitHubURL: "https://github.com/o/r/issues/2"},
+		}, false},
+		{"no issues", searchClient{}, jIssues, nil, false},
+		{"search error", searchClient{err: errors.New("rate limited")}, jIssues, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var listed []int
+			got, err := Links(cfg, tt.gh, listingClient{issues: tt.jIssues, listed: &listed})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Links() error = %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Links() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package sync
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+// ApplyStatusLabels finds the JIRA issues updated since the last run whose
+// status is mapped to a GitHub label, and adds that label to the linked
+// GitHub issue. The label is only added on a transition into the status: the
+// status acted on is recorded in an entity property of the JIRA issue, and
+// cleared once the issue leaves it, so that a label removed on GitHub isn't
+// added again until the issue next enters the status.
+func ApplyStatusLabels(cfg config.Config, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient) error {
+	log := cfg.GetLogger()
+
+	statusLabels := cfg.GetStatusLabels()
+	if len(statusLabels) == 0 {
+		return nil
+	}
+
+	jIssues, err := jiraClient.ListIssuesUpdatedSince(cfg.GetSinceParam())
+	if err != nil {
+		return err
+	}
+
+	for _, jIssue := range jIssues {
+		if jIssue.Fields == nil || jIssue.Fields.Status == nil {
+			continue
+		}
+		status := jIssue.Fields.Status.Name
+
+		var prop jClient.StatusProperty
+		found, err := jiraClient.GetProperty(jIssue, jClient.StatusPropertyKey, &prop)
+		if err != nil {
+			log.Errorf("Error reading the labeled status of JIRA issue %s. Error: %v", jIssue.Key, err)
+			continue
+		}
+
+		label, ok := statusLabels[strings.ToLower(status)]
+		if !ok {
+			if found && prop.Status != "" {
+				if err := jiraClient.SetProperty(jIssue, jClient.StatusPropertyKey, jClient.StatusProperty{}); err != nil {
+					log.Errorf("Error clearing the labeled status of JIRA issue %s. Error: %v", jIssue.Key, err)
+				}
+			}
+			continue
+		}
+
+		if found && strings.EqualFold(prop.Status, status) {
+			log.Debugf("GitHub issue of JIRA issue %s was already labeled for status %s", jIssue.Key, status)
+			continue
+		}
+
+		if hasSyncedLabel(cfg, jIssue, label) {
+			log.Debugf("GitHub issue of JIRA issue %s already has label %s", jIssue.Key, label)
+		} else if err := addStatusLabel(cfg, jIssue, label, ghClient); err != nil {
+			log.Errorf("Error labeling GitHub issue of JIRA issue %s. Error: %v", jIssue.Key, err)
+			continue
+		}
+
+		if err := jiraClient.SetProperty(jIssue, jClient.StatusPropertyKey, jClient.StatusProperty{Status: status}); err != nil {
+			log.Errorf("Error recording the labeled status of JIRA issue %s. Error: %v", jIssue.Key, err)
+		}
+	}
+
+	return nil
+}
+
+// addStatusLabel adds the label of the status of a JIRA issue to its GitHub
+// issue.
+func addStatusLabel(cfg config.Config, jIssue jira.Issue, label string, ghClient ghClient.GitHubClient) error {
+	log := cfg.GetLogger()
+
+	owner, repo, number, err := gitHubIssueRef(cfg, jIssue)
+	if err != nil {
+		return err
+	}
+
+	if err := ghClient.AddLabels(owner, repo, number, []string{label}); err != nil {
+		return err
+	}
+
+	log.Debugf("Added label %s to GitHub issue %s/%s#%d for JIRA status %s", label, owner, repo, number, jIssue.Fields.Status.Name)
+	return nil
+}
+
+// hasSyncedLabel returns whether the GitHub labels last synced to the JIRA
+// issue include the given label.
+func hasSyncedLabel(cfg config.Config, jIssue jira.Issue, label string) bool {
+	labels, err := jIssue.Fields.Unknowns.String(cfg.GetFieldKey(config.GitHubLabels))
+	if err != nil {
+		return false
+	}
+	for _, l := range strings.Split(labels, ",") {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// gitHubIssueRef returns the owner, repository and number of the GitHub issue
+// linked to a JIRA issue. They are read from the GitHub Repo and GitHub Number
+// fields if the former exists and is set. Otherwise, they are parsed from the
+// GitHub URI field, which has the form
+// https://github.com/<owner>/<repo>/issues/<number>.
+func gitHubIssueRef(cfg config.Config, jIssue jira.Issue) (owner, repo string, number int, err error) {
+	if cfg.HasField(config.GitHubRepo) {
+		ref, _ := jIssue.Fields.Unknowns.String(cfg.GetFieldKey(config.GitHubRepo))
+		num, numErr := jIssue.Fields.Unknowns.Int(cfg.GetFieldKey(config.GitHubNumber))
+		if parts := strings.Split(ref, "/"); len(parts) == 2 && numErr == nil {
+			return parts[0], parts[1], int(num), nil
+		}
+	}
+
+	uri, err := jIssue.Fields.Unknowns.String(cfg.GetFieldKey(config.GitHubURI))
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	parts := strings.Split(strings.TrimSuffix(uri, "/"), "/")
+	if len(parts) < 4 || parts[len(parts)-2] != "issues" {
+		return "", "", 0, fmt.Errorf("unexpected GitHub URI %q", uri)
+	}
+
+	if _, err := fmt.Sscan(parts[len(parts)-1], &number); err != nil {
+		return "", "", 0, fmt.Errorf("unexpected GitHub URI %q: %v", uri, err)
+	}
+
+	return parts[len(parts)-4], parts[len(parts)-3], number, nil
+}
+
+// MirrorComments copies the comments made on a JIRA issue back to its GitHub
+// issue, converting their markup to Markdown. Each copy carries a hidden
+// marker with the ID of the JIRA comment, so it is only copied once and is
+// never synced to JIRA itself. Comments issue-sync created in JIRA are not
+// copied, nor are comments restricted to a JIRA group or role.
+func MirrorComments(cfg config.Config, ghIssue github.Issue, jIssue jira.Issue, ghClient ghClient.GitHubClient) error {
+	log := cfg.GetLogger()
+
+	if !cfg.IsMirroringJIRAComments() || jIssue.Fields == nil || jIssue.Fields.Comments == nil {
+		return nil
+	}
+
+	mirrored := map[string]bool{}
+	if ghIssue.GetComments() > 0 {
+		ghComments, err := ghClient.ListComments(ghIssue)
+		if err != nil {
+			return err
+		}
+		for _, ghComment := range ghComments {
+			if id, ok := mirroredCommentID(cfg, ghComment.GetBody()); ok {
+				mirrored[id] = true
+			}
+		}
+	}
+
+	for _, jComment := range jIssue.Fields.Comments.Comments {
+		if mirrored[jComment.ID] || isSyncComment(cfg, *jComment) || jComment.Visibility.Value != "" {
+			continue
+		}
+
+		if _, err := ghClient.CreateComment(ghIssue, mirrorCommentBody(cfg, jIssue, *jComment)); err != nil {
+			return err
+		}
+
+		log.Debugf("Copied JIRA comment %s to GitHub issue #%d.", jComment.ID, ghIssue.GetNumber())
+	}
+
+	return nil
+}
+
+// jStateRegex matches the beginning of a JIRA comment noting the state change
+// of a GitHub issue.
+var jStateRegex = regexp.MustCompile("^GitHub issue \\[#\\d+\\|")
+
+// isSyncComment returns whether a JIRA comment was created by issue-sync,
+// either as the copy of a GitHub comment, for a GitHub event or for a state
+// change, so that it is never copied back to GitHub. Comments by the JIRA
+// user issue-sync authenticates as are its own even if their marker was
+// edited away.
+func isSyncComment(cfg config.Config, jComment jira.Comment) bool {
+	if _, ok := commentGitHubID(cfg, jComment.Body); ok {
+		return true
+	}
+	if jEventIDRegex.MatchString(jComment.Body) || jStateRegex.MatchString(jComment.Body) {
+		return true
+	}
+
+	user := cfg.GetConfigString("jira-user")
+	if !cfg.IsBasicAuth() || user == "" {
+		return false
+	}
+	for _, name := range []string{jComment.Author.Name, jComment.Author.Key, jComment.Author.EmailAddress} {
+		if strings.EqualFold(name, user) {
+			return true
+		}
+	}
+	return false
+}
+
+// mirrorMarkerRegex matches the hidden marker of a GitHub comment copied from
+// JIRA, capturing the configured comment marker and the JIRA comment ID.
+var mirrorMarkerRegex = regexp.MustCompile(`^<!-- (.+)-jira-(\d+) -->`)
+
+// mirroredCommentID returns the ID of the JIRA comment a GitHub comment was
+// copied from, and whether it is such a copy.
+func mirroredCommentID(cfg config.Config, body string) (string, bool) {
+	matches := mirrorMarkerRegex.FindStringSubmatch(body)
+	if matches == nil || matches[1] != cfg.GetCommentMarker() {
+		return "", false
+	}
+	return matches[2], true
+}
+
+// mirrorCommentBody returns the body of the GitHub copy of a JIRA comment: a
+// hidden marker, a header linking to the original, and the converted body.
+func mirrorCommentBody(cfg config.Config, jIssue jira.Issue, jComment jira.Comment) string {
+	uri := fmt.Sprintf("%s/browse/%s?focusedCommentId=%s", strings.TrimSuffix(cfg.GetConfigString("jira-uri"), "/"), jIssue.Key, jComment.ID)
+
+	body := stripCommentMarker(cfg, jComment.Body)
+
+	return fmt.Sprintf(
+		"<!-- %s-jira-%s -->\n[Comment](%s) from JIRA user %s:\n\n%s",
+		cfg.GetCommentMarker(),
+		jComment.ID,
+		uri,
+		jComment.Author.DisplayName,
+		cfg.GetConverter().ToSource(body),
+	)
+}
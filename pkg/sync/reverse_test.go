@@ -0,0 +1,300 @@
+package sync
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+// updatedClient is a JIRA client which stores entity properties and lists a
+// fixed set of updated issues.
+type updatedClient struct {
+	propertyClient
+	updated []jira.Issue
+}
+
+func (c updatedClient) ListIssuesUpdatedSince(t time.Time) ([]jira.Issue, error) {
+	return c.updated, nil
+}
+
+// labelClient is a GitHub client which records the labels added to issues.
+type labelClient struct {
+	ghClient.GitHubClient
+	added *[]string
+}
+
+func (c labelClient) AddLabels(owner, repo string, number int, labels []string) error {
+	*c.added = append(*c.added, labels...)
+	return nil
+}
+
+func TestApplyStatusLabels(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{
+		"status-labels": map[string]string{"done": "fixed"},
+	})
+
+	// Each run sees the issue in the given status, with the given labels
+	// synced from GitHub.
+	type run struct {
+		status, labels string
+	}
+	tests := []struct {
+		name string
+		runs []run
+		want []string
+	}{
+		{"unmapped", []run{{"In Progress", ""}}, nil},
+		{"into status", []run{{"Done", ""}}, []string{"fixed"}},
+		{"already labeled", []run{{"Done", "fixed"}}, nil},
+		{"label removed on GitHub", []run{{"Done", ""}, {"Done", ""}, {"Done", ""}}, []string{"fixed"}},
+		{"status case", []run{{"Done", ""}, {"DONE", ""}}, []string{"fixed"}},
+		{"reentered", []run{{"Done", ""}, {"In Progress", ""}, {"Done", ""}}, []string{"fixed", "fixed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			props := map[string]interface{}{}
+			var added []string
+			for _, r := range tt.runs {
+				jIssue := jira.Issue{
+					Key: "TEST-1",
+					Fields: &jira.IssueFields{
+						Status: &jira.Status{Name: r.status},
+						Unknowns: map[string]interface{}{
+							cfg.GetFieldKey(config.GitHubURI):    "https://github.com/o/r/issues/1",
+							cfg.GetFieldKey(config.GitHubLabels): r.labels,
+						},
+					},
+				}
+				client := updatedClient{propertyClient{props: props}, []jira.Issue{jIssue}}
+				if err := ApplyStatusLabels(cfg, labelClient{added: &added}, client); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if !reflect.DeepEqual(added, tt.want) {
+				t.Errorf("labels added = %q, want %q", added, tt.want)
+			}
+		})
+	}
+}
+
+// mirrorClient is a GitHub client listing fixed comments, which records the
+// bodies of the comments created.
+type mirrorClient struct {
+	listCommentsClient
+	created *[]string
+}
+
+func (c mirrorClient) CreateComment(issue github.Issue, body string) (github.IssueComment, error) {
+	*c.created = append(*c.created, body)
+	return github.IssueComment{Body: github.String(body)}, nil
+}
+
+func TestMirrorComments(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{"mirror-jira-comments": true})
+	user := jira.User{Name: "jdoe", DisplayName: "Jane Doe"}
+
+	tests := []struct {
+		name     string
+		cfg      config.Config
+		comment  jira.Comment
+		existing string
+		mirrored bool
+	}{
+		{"JIRA comment", cfg, jira.Comment{ID: "1", Author: user, Body: "Hello."}, "", true},
+		{"mirroring disabled", newTestConfig(t, nil), jira.Comment{ID: "1", Author: user, Body: "Hello."}, "", false},
+		{"already mirrored", cfg, jira.Comment{ID: "1", Author: user, Body: "Hello."}, "<!-- issue-sync-jira-1 -->\nHello.", false},
+		{"other comment mirrored", cfg, jira.Comment{ID: "1", Author: user, Body: "Hello."}, "<!-- issue-sync-jira-2 -->\nHello.", true},
+		{"mirrored with another marker", cfg, jira.Comment{ID: "1", Author: user, Body: "Hello."}, "<!-- other-jira-1 -->\nHello.", true},
+		{"copy of a GitHub comment", cfg, jira.Comment{ID: "1", Author: user, Body: jClient.CommentMarker(cfg, 2) + "Comment [(ID 2)|url] from GitHub user [octocat|url] at now:\n\nHello."}, "", false},
+		{"GitHub event", cfg, jira.Comment{ID: "1", Author: user, Body: "GitHub event (ID 3) by [octocat|url]: labeled"}, "", false},
+		{"state change", cfg, jira.Comment{ID: "1", Author: user, Body: "GitHub issue [#1|url] was closed."}, "", false},
+		{"by the issue-sync user", cfg, jira.Comment{ID: "1", Author: jira.User{Name: "Issue-Sync"}, Body: "Hello."}, "", false},
+		{"restricted", cfg, jira.Comment{ID: "1", Author: user, Body: "Hello.", Visibility: jira.CommentVisibility{Type: "role", Value: "Developers"}}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghIssue := testIssue("Title", "Body")
+			var existing []*github.IssueComment
+			if tt.existing != "" {
+				existing = append(existing, &github.IssueComment{Body: github.String(tt.existing)})
+				ghIssue.Comments = github.Int(len(existing))
+			}
+			jComment := tt.comment
+			jIssue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{
+				Comments: &jira.Comments{Comments: []*jira.Comment{&jComment}},
+			}}
+
+			var created []string
+			client := mirrorClient{listCommentsClient{comments: existing}, &created}
+			if err := MirrorComments(tt.cfg, ghIssue, jIssue, client); err != nil {
+				t.Fatal(err)
+			}
+			if mirrored := len(created) > 0; mirrored != tt.mirrored {
+				t.Errorf("comment mirrored = %v, want %v", mirrored, tt.mirrored)
+			}
+		})
+	}
+}
+
+func TestIsSyncComment(t *testing.T) {
+	cfg := newTestConfig(t, nil)
+	user := jira.User{Name: "jdoe", Key: "jdoe", EmailAddress: "jdoe@example.com"}
+
+	tests := []struct {
+		name    string
+		comment jira.Comment
+		want    bool
+	}{
+		{"JIRA comment", jira.Comment{Author: user, Body: "Hello."}, false},
+		{"marked copy", jira.Comment{Author: user, Body: jClient.CommentMarker(cfg, 2) + "Hello."}, true},
+		{"copy without marker", jira.Comment{Author: user, Body: "Comment [(ID 2)|url] from GitHub user [octocat|url] at now:\n\nHello."}, true},
+		{"GitHub event", jira.Comment{Author: user, Body: "GitHub event (ID 3) by [octocat|url]: labeled"}, true},
+		{"state change", jira.Comment{Author: user, Body: "GitHub issue [#1|url] was closed."}, true},
+		{"marker mentioned later", jira.Comment{Author: user, Body: "Quoting: GitHub event (ID 3)"}, false},
+		// Comments by the issue-sync user are its own even if their marker was edited away.
+		{"by name", jira.Comment{Author: jira.User{Name: "Issue-Sync"}, Body: "Hello."}, true},
+		{"by key", jira.Comment{Author: jira.User{Name: "bot", Key: "issue-sync"}, Body: "Hello."}, true},
+		{"by email", jira.Comment{Author: jira.User{EmailAddress: "issue-sync"}, Body: "Hello."}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isSyncComment(cfg, tt.comment); got != tt.want {
+				t.Errorf("isSyncComment() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMirrorCommentBody(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{"jira-uri": "https://jira.example.com/"})
+	jIssue := jira.Issue{Key: "TEST-1"}
+	header := "<!-- issue-sync-jira-7 -->\n[Comment](https://jira.example.com/browse/TEST-1?focusedCommentId=7) from JIRA user Jane Doe:\n\n"
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"plain", "Hello.", "Hello."},
+		{"converted", "*bold* and [a link|https://example.com]", "**bold** and [a link](https://example.com)"},
+		{"issue-sync marker", jClient.CommentMarker(cfg, 2) + "Hello.", "Hello."},
+		{"other marker", "{anchor:other-2}Hello.", "{anchor:other-2}Hello."},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jComment := jira.Comment{ID: "7", Body: tt.body, Author: jira.User{DisplayName: "Jane Doe"}}
+			body := mirrorCommentBody(cfg, jIssue, jComment)
+			if !strings.HasPrefix(body, header) {
+				t.Fatalf("body = %q, want header %q", body, header)
+			}
+			if got := strings.TrimPrefix(body, header); got != tt.want {
+				t.Errorf("converted body = %q, want %q", got, tt.want)
+			}
+			// The copy is recognised, and so never synced to JIRA.
+			if id, ok := mirroredCommentID(cfg, body); !ok || id != "7" {
+				t.Errorf("mirroredCommentID() = %q, %v, want 7, true", id, ok)
+			}
+		})
+	}
+}
+
+func TestCompareMirroredComment(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{"mirror-jira-comments": true})
+
+	tests := []struct {
+		name    string
+		body    string
+		created int
+	}{
+		{"GitHub comment", "Hello.", 1},
+		{"copy of a JIRA comment", "<!-- issue-sync-jira-1 -->\n[Comment](url) from JIRA user Jane Doe:\n\nHello.", 0},
+		{"copy with another marker", "<!-- other-jira-1 -->\nHello.", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ghComment := &github.IssueComment{
+				ID:   github.Int(2),
+				Body: github.String(tt.body),
+				User: &github.User{Login: github.String("octocat")},
+			}
+			ghIssue := testIssue("Title", "Body")
+			ghIssue.Comments = github.Int(1)
+			jIssue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{}}
+
+			var comments []*jira.Comment
+			var created, deleted int
+			client := commentsClient{cfg: cfg, comments: &comments, created: &created, deleted: &deleted}
+			gh := listCommentsClient{comments: []*github.IssueComment{ghComment}}
+			if err := CompareComments(cfg, ghIssue, jIssue, gh, client); err != nil {
+				t.Fatal(err)
+			}
+			if created != tt.created {
+				t.Errorf("%d JIRA comments created, want %d", created, tt.created)
+			}
+		})
+	}
+}
+
+func TestGitHubIssueRef(t *testing.T) {
+	fieldIDs := map[string]string{"GitHub Repo": "10008"}
+	for name, id := range testFieldIDs {
+		fieldIDs[name] = id
+	}
+	withRepo := newTestConfig(t, map[string]interface{}{"field-ids": fieldIDs})
+	withoutRepo := newTestConfig(t, nil)
+
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		repo   interface{}
+		number interface{}
+		uri    string
+		want   string
+		err    bool
+	}{
+		// Issues #5 of two repositories synced into one project.
+		{"first repository", withRepo, "a/x", float64(5), "https://github.com/a/x/issues/5", "a/x#5", false},
+		{"second repository", withRepo, "b/y", float64(5), "https://github.com/b/y/issues/5", "b/y#5", false},
+		{"repository field preferred", withRepo, "b/y", float64(5), "https://github.com/a/x/issues/5", "b/y#5", false},
+		{"repository not recorded", withRepo, nil, float64(5), "https://github.com/a/x/issues/5", "a/x#5", false},
+		{"invalid repository", withRepo, "x", float64(5), "https://github.com/a/x/issues/5", "a/x#5", false},
+		{"no repository field", withoutRepo, "b/y", float64(5), "https://github.com/a/x/issues/5", "a/x#5", false},
+		{"invalid URI", withoutRepo, nil, float64(5), "https://github.com/a/x", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jIssue := jira.Issue{Key: "TEST-1", Fields: &jira.IssueFields{Unknowns: map[string]interface{}{
+				tt.cfg.GetFieldKey(config.GitHubNumber): tt.number,
+				tt.cfg.GetFieldKey(config.GitHubURI):    tt.uri,
+			}}}
+			if tt.cfg.HasField(config.GitHubRepo) {
+				jIssue.Fields.Unknowns[tt.cfg.GetFieldKey(config.GitHubRepo)] = tt.repo
+			}
+
+			owner, repo, number, err := gitHubIssueRef(tt.cfg, jIssue)
+			if (err != nil) != tt.err {
+				t.Fatalf("gitHubIssueRef() error = %v, want error %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got := fmt.Sprintf("%s/%s#%d", owner, repo, number); got != tt.want {
+				t.Errorf("gitHubIssueRef() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
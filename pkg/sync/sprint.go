@@ -0,0 +1,49 @@
+package sync
+
+import (
+	gosync "sync"
+
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+// sprintCachingClient is a JIRA client which resolves each sprint name once,
+// rather than listing the sprints of the board for every issue created. It
+// is made for one sync, as the active sprint changes between syncs; errors
+// aren't cached. Other requests are passed on.
+type sprintCachingClient struct {
+	jClient.JIRAClient
+
+	mu      *gosync.Mutex
+	sprints map[string]resolvedSprint
+}
+
+// resolvedSprint is the result of resolving a sprint name.
+type resolvedSprint struct {
+	id    int
+	found bool
+}
+
+// newSprintCachingClient returns a client which caches the sprints resolved
+// through client.
+func newSprintCachingClient(client jClient.JIRAClient) sprintCachingClient {
+	return sprintCachingClient{
+		JIRAClient: client,
+		mu:         &gosync.Mutex{},
+		sprints:    map[string]resolvedSprint{},
+	}
+}
+
+func (c sprintCachingClient) ResolveSprint(name string) (int, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if s, ok := c.sprints[name]; ok {
+		return s.id, s.found, nil
+	}
+	id, found, err := c.JIRAClient.ResolveSprint(name)
+	if err != nil {
+		return 0, false, err
+	}
+	c.sprints[name] = resolvedSprint{id, found}
+	return id, found, nil
+}
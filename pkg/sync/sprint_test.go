@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"errors"
+	gosync "sync"
+	"testing"
+
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+// sprintClient resolves sprints from a map, counting the requests made.
+type sprintClient struct {
+	jClient.JIRAClient
+	sprints  map[string]int
+	fail     bool
+	requests int
+}
+
+func (c *sprintClient) ResolveSprint(name string) (int, bool, error) {
+	c.requests++
+	if c.fail {
+		return 0, false, errors.New("JIRA unavailable")
+	}
+	id, ok := c.sprints[name]
+	return id, ok, nil
+}
+
+func TestSprintCachingClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		sprint   string
+		fail     bool
+		wantID   int
+		wantOK   bool
+		requests int
+	}{
+		{"found", "Sprint 1", false, 11, true, 1},
+		{"missing", "Sprint 9", false, 0, false, 1},
+		// Errors aren't cached, so every call is retried.
+		{"error", "Sprint 1", true, 0, false, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inner := &sprintClient{sprints: map[string]int{"Sprint 1": 11}, fail: tt.fail}
+			client := newSprintCachingClient(inner)
+
+			var wg gosync.WaitGroup
+			for i := 0; i < 5; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					id, ok, err := client.ResolveSprint(tt.sprint)
+					if (err != nil) != tt.fail || id != tt.wantID || ok != tt.wantOK {
+						t.Errorf("ResolveSprint() = %d, %v, %v; want %d, %v", id, ok, err, tt.wantID, tt.wantOK)
+					}
+				}()
+			}
+			wg.Wait()
+
+			if inner.requests != tt.requests {
+				t.Errorf("%d sprint requests, want %d", inner.requests, tt.requests)
+			}
+		})
+	}
+}
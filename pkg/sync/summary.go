@@ -0,0 +1,71 @@
+package sync
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+)
+
+// issueSummary returns the JIRA summary of a GitHub issue: its title, or the
+// configured summary template executed against it. If the template fails,
+// the title is used.
+func issueSummary(cfg config.Config, ghIssue github.Issue) string {
+	log := cfg.GetLogger()
+
+	tmpl := cfg.GetSummaryTemplate()
+	if tmpl == nil {
+		return ghIssue.GetTitle()
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, environmentData{&ghIssue}); err != nil {
+		log.Errorf("Error executing summary template for GitHub issue #%d: %v", ghIssue.GetNumber(), err)
+		return ghIssue.GetTitle()
+	}
+
+	return strings.TrimSpace(buf.String())
+}
+
+// sameSummary returns whether a JIRA summary matches the GitHub issue. With a
+// summary template, only the title portion of the summary is compared: the
+// text the template puts before or after the title may be edited in JIRA,
+// e.g. to reword a prefix, without the sync reverting it on every run.
+func sameSummary(cfg config.Config, ghIssue github.Issue, summary string) bool {
+	rendered := issueSummary(cfg, ghIssue)
+	if summary == rendered {
+		return true
+	}
+
+	title := ghIssue.GetTitle()
+	i := strings.Index(rendered, title)
+	if cfg.GetSummaryTemplate() == nil || title == "" || i < 0 {
+		return false
+	}
+	prefix, suffix := rendered[:i], rendered[i+len(title):]
+
+	// An edited prefix must still end with the separator the template puts
+	// before the title, e.g. "] ", so that a title shortened at the front
+	// isn't taken for an edit; likewise for the suffix.
+	if sep := strings.TrimRightFunc(prefix, isSummarySeparator); sep != prefix && strings.HasSuffix(summary, title+suffix) {
+		sep = prefix[len(sep):]
+		if strings.HasSuffix(strings.TrimSuffix(summary, title+suffix), sep) {
+			return true
+		}
+	}
+	if sep := strings.TrimLeftFunc(suffix, isSummarySeparator); sep != suffix && strings.HasPrefix(summary, prefix+title) {
+		sep = suffix[:len(suffix)-len(sep)]
+		if strings.HasPrefix(strings.TrimPrefix(summary, prefix+title), sep) {
+			return true
+		}
+	}
+	return false
+}
+
+// isSummarySeparator returns whether a rune may separate the title from the
+// rest of the summary, i.e. isn't part of a word.
+func isSummarySeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+}
@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"testing"
+	"time"
+
+	"github.com/innovocloud/issue-sync/pkg/config"
+)
+
+func TestSameSummary(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		summary  string
+		want     bool
+	}{
+		{"title", "", "Crash on start", true},
+		{"title changed", "", "Crash on exit", false},
+		{"rendered", "[#{{.Number}}] {{.Title}}", "[#1] Crash on start", true},
+		{"prefix edited", "[#{{.Number}}] {{.Title}}", "[#1][P1] Crash on start", true},
+		{"prefix replaced", "[#{{.Number}}] {{.Title}}", "[backend] Crash on start", true},
+		{"separator removed", "[#{{.Number}}] {{.Title}}", "#1: Crash on start", false},
+		{"word before title", "[#{{.Number}}] {{.Title}}", "[#1] Rare Crash on start", false},
+		{"title edited", "[#{{.Number}}] {{.Title}}", "[#1] Crash on exit", false},
+		{"suffix edited", "{{.Title}} (#{{.Number}})", "Crash on start (#1, backend)", true},
+		{"suffix removed", "{{.Title}} (#{{.Number}})", "Crash on start", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"summary-template": tt.template})
+			ghIssue := testIssue("Crash on start", "Body")
+
+			if got := sameSummary(cfg, ghIssue, tt.summary); got != tt.want {
+				t.Errorf("sameSummary(%q) = %v, want %v", tt.summary, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSummaryTemplateStable(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{"summary-template": "[#{{.Number}}] {{.Title}}"})
+	ghIssue := testIssue("Crash on start", "Body")
+
+	// The summary, once edited in JIRA, isn't rewritten on later runs.
+	jIssue := syncedIssue(cfg, ghIssue, time.Now())
+	jIssue.Fields.Summary = "[#1][P1] Crash on start"
+	for run := 1; run <= 3; run++ {
+		if diff := DidIssueChange(cfg, ghIssue, jIssue, nil); diff[config.SyncSummary] {
+			t.Fatalf("run %d: summary reported changed", run)
+		}
+	}
+}
@@ -2,86 +2,481 @@ package sync
 
 import (
 	"fmt"
+	"strings"
+	gosync "sync"
 	"time"
 
+	"github.com/Sirupsen/logrus"
 	"github.com/innovocloud/issue-sync/pkg/config"
 	ghClient "github.com/innovocloud/issue-sync/pkg/github"
 	jClient "github.com/innovocloud/issue-sync/pkg/jira"
 	"github.com/google/go-github/github"
 )
 
+// Result counts the GitHub issues processed by a sync.
+type Result struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Failed  int `json:"failed"`
+	Total   int `json:"total"`
+}
+
+// add adds the counts of another result to r.
+func (r *Result) add(o Result) {
+	r.Created += o.Created
+	r.Updated += o.Updated
+	r.Failed += o.Failed
+	r.Total += o.Total
+}
+
+// syncLock prevents a full sync and webhook-triggered single-issue syncs
+// from running in parallel, which could create duplicate JIRA issues.
+var syncLock gosync.Mutex
+
+// Sync synchronizes all GitHub issues updated since the `since` date to JIRA.
+// Each configured organisation or repository is synced independently, so
+// that an error in one, e.g. a renamed repository, doesn't affect the others.
+// If only some of the issues or repositories fail, the sync completes and a
+// PartialError is returned.
 func Sync(cfg config.Config, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient) error {
+	log := cfg.GetLogger()
+
+	syncLock.Lock()
+	defer syncLock.Unlock()
 
-	// TODO: hack to compile
+	start := time.Now()
+	jiraClient = newSprintCachingClient(jiraClient)
 
-	// TODO: needs a lock to prevent parallel runs
+	var cp *checkpoint
+	if path := cfg.GetCheckpointFile(); path != "" && !cfg.IsDryRun() {
+		var err error
+		cp, err = loadCheckpoint(path)
+		if err != nil {
+			return err
+		}
+	}
 
-	ghIssues, err := getGitHubIssues(cfg, ghClient)
+	queries, err := buildQueries(cfg, ghClient, cfg.GetSinceParam())
 	if err != nil {
 		return err
 	}
+	logQueries(cfg, queries)
 
-	err = CompareIssues(cfg, ghIssues, ghClient, jiraClient)
-	if err != nil {
+	results := make([]Result, len(queries))
+	errs := make([]error, len(queries))
+	var wg gosync.WaitGroup
+	for i, q := range queries {
+		if !cfg.IsSyncingReposInParallel() {
+			results[i], errs[i] = syncQuery(cfg, q.query, ghClient, jiraClient, cp)
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, q repoQuery) {
+			defer wg.Done()
+			results[i], errs[i] = syncQuery(cfg, q.query, ghClient, jiraClient, cp)
+		}(i, q)
+	}
+	wg.Wait()
+
+	var result Result
+	var partial PartialError
+	for i, err := range errs {
+		result.add(results[i])
+		switch err.(type) {
+		case nil, PartialError:
+		default:
+			log.Errorf("Error syncing %s. Error: %v", queries[i].name, err)
+			partial.Repos = append(partial.Repos, queries[i].name)
+		}
+	}
+
+	if len(queries) > 0 && len(partial.Repos) == len(queries) {
+		logResult(cfg, result, time.Since(start))
+		return errs[0]
+	}
+
+	if err := ApplyStatusLabels(cfg, ghClient, jiraClient); err != nil {
+		logResult(cfg, result, time.Since(start))
+		return err
+	}
+
+	if err := cp.Clear(); err != nil {
+		logResult(cfg, result, time.Since(start))
 		return err
 	}
 
+	duration := time.Since(start)
+	logResult(cfg, result, duration)
+	runPostSyncHook(cfg, result, duration, partial.Repos)
+
+	if result.Failed > 0 || len(partial.Repos) > 0 {
+		partial.Failed, partial.Total = result.Failed, result.Total
+		return partial
+	}
 	return nil
+}
+
+// logResult logs a summary of a sync, and warns if the share of the issues
+// which failed exceeds the configured threshold.
+func logResult(cfg config.Config, result Result, duration time.Duration) {
+	log := cfg.GetLogger()
 
+	log.WithFields(logrus.Fields{
+		"created":  result.Created,
+		"updated":  result.Updated,
+		"failed":   result.Failed,
+		"total":    result.Total,
+		"duration": duration.Round(time.Millisecond).String(),
+	}).Info("Sync finished")
+
+	if result.Total == 0 {
+		return
+	}
+	if ratio := float64(result.Failed) / float64(result.Total); ratio > cfg.GetFailureThreshold() {
+		log.Warnf("%d of %d issues (%.0f%%) failed to sync, more than the failure threshold of %.0f%%", result.Failed, result.Total, ratio*100, cfg.GetFailureThreshold()*100)
+	}
 }
 
-func getGitHubIssues(cfg config.Config, ghClient ghClient.GitHubClient) ([]github.Issue, error) {
+// syncQuery synchronizes the GitHub issues found by a search query to JIRA.
+func syncQuery(cfg config.Config, query string, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient, cp *checkpoint) (Result, error) {
+	ghIssues, err := ghClient.SearchIssues(query)
+	if err != nil {
+		return Result{}, err
+	}
 
-	query := buildQuery(cfg, ghClient)
+	return compareIssues(cfg, ghIssues, ghClient, jiraClient, cp)
+}
 
-	return ghClient.SearchIssues(query)
+// SyncIssue synchronizes a single GitHub issue to JIRA, creating or updating
+// the matching JIRA issue. It is used when an issue is known to have changed,
+// e.g. on receipt of a webhook.
+func SyncIssue(cfg config.Config, ghIssue github.Issue, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient) error {
+	syncLock.Lock()
+	defer syncLock.Unlock()
 
+	return CompareIssues(cfg, []github.Issue{ghIssue}, ghClient, jiraClient)
 }
 
-func buildQuery(cfg config.Config, ghClient ghClient.GitHubClient) (q string) {
-	q += buildUserQuery(cfg, ghClient)
+// repoQuery is the GitHub search query for the issues of one organisation
+// or repository, named after it. The query is made of the user, scope and
+// since clauses, which are kept to explain it.
+type repoQuery struct {
+	name  string
+	query string
 
-	q += buildOrgQuery(cfg.GetRepos())
+	users string
+	scope string
+	since string
+}
 
-	q += buildSinceQuery(cfg.GetSinceParam())
+// newRepoQuery returns the query made of the given clauses.
+func newRepoQuery(name, users, scope, since string) repoQuery {
+	return repoQuery{name: name, query: users + scope + since, users: users, scope: scope, since: since}
+}
 
-	return q
+// logQueries logs the GitHub search queries at debug level, or at info level
+// in a dry run. With the `explain` option, their clauses are logged too.
+func logQueries(cfg config.Config, queries []repoQuery) {
+	log := cfg.GetLogger()
+
+	logf := log.Debugf
+	if cfg.IsDryRun() || cfg.IsExplaining() {
+		logf = log.Infof
+	}
+
+	for _, q := range queries {
+		logf("GitHub search query for %s: %s", q.name, strings.TrimSpace(q.query))
+		if !cfg.IsExplaining() {
+			continue
+		}
+		for _, clause := range []struct{ name, q string }{
+			{"Users", q.users},
+			{"Scope", q.scope},
+			{"Since", q.since},
+		} {
+			if c := strings.TrimSpace(clause.q); c != "" {
+				log.Infof("  %s: %s", clause.name, c)
+			} else {
+				log.Infof("  %s: (none)", clause.name)
+			}
+		}
+	}
 }
 
+// maxQueryLength is the longest search query GitHub accepts; longer queries
+// are rejected with 422 Unprocessable Entity.
+const maxQueryLength = 256
+
+// buildQueries returns a search query for each configured organisation or
+// repository. A configured search query is used as it is, in a single query.
+// Unless archived repositories are included, the queries of an organisation
+// list its repositories which aren't archived, split so that each stays
+// within the query length limit. The queries find the issues updated since
+// the given time, or all issues if it is zero.
+func buildQueries(cfg config.Config, ghClient ghClient.GitHubClient, updatedSince time.Time) ([]repoQuery, error) {
+	if query := cfg.GetSearchQuery(); query != "" {
+		var since string
+		if cfg.IsSearchQuerySince() {
+			since = buildSinceQuery(updatedSince)
+		}
+		return []repoQuery{newRepoQuery("search query", "", strings.TrimSpace(query)+" ", since)}, nil
+	}
+
+	users, err := buildUserQuery(cfg, ghClient)
+	if err != nil {
+		return nil, err
+	}
+	users += buildAuthorQuery(cfg)
+	since := buildSinceQuery(updatedSince)
+
+	var queries []repoQuery
+	for _, org := range cfg.GetRepos() {
+		if len(org.Repos) == 0 {
+			if cfg.IsIncludingArchived() {
+				queries = append(queries, newRepoQuery(org.Name, users, buildOrgQuery(cfg, ghClient, org), since))
+				continue
+			}
+			length := maxQueryLength - len(users) - len(since)
+			if length <= 0 {
+				return nil, fmt.Errorf("the user and since clauses of the GitHub search query are %d characters long; GitHub accepts at most %d", len(users)+len(since), maxQueryLength)
+			}
+			scopes, err := buildActiveReposQueries(cfg, ghClient, org, length)
+			if err != nil {
+				return nil, err
+			}
+			for i, q := range scopes {
+				name := org.Name
+				if len(scopes) > 1 {
+					name = fmt.Sprintf("%s (%d/%d)", org.Name, i+1, len(scopes))
+				}
+				queries = append(queries, newRepoQuery(name, users, q, since))
+			}
+			continue
+		}
+		for _, repo := range org.Repos {
+			name := fmt.Sprintf("%s/%s", org.Name, repo)
+			queries = append(queries, newRepoQuery(name, users, buildRepoQuery(name), since))
+		}
+	}
+
+	// An organisation whose repositories are all archived has no queries; only
+	// a configuration without any searches all repositories.
+	if len(cfg.GetRepos()) == 0 {
+		queries = append(queries, newRepoQuery("all repositories", users, "", since))
+	}
+
+	return queries, nil
+}
+
+// buildSinceQuery returns the clause for the issues updated since the given
+// time, which is written in UTC so that its offset is always correct.
 func buildSinceQuery(since time.Time) (q string) {
-	return fmt.Sprintf("updated:>=%s ", since.Format("2006-01-02T15:04:05+07:00"))
+	if since.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("updated:>=%s ", since.UTC().Format(time.RFC3339))
 }
 
-func buildOrgQuery(orgs []config.Organisation) (q string) {
-	for _, org := range orgs {
-		if len(org.Repos) == 0 {
-			q += fmt.Sprintf("org:%s ", org.Name)
-		} else {
-			q += buildRepoQuery(org)
+// buildOrgQuery returns the query for the issues of an organisation. A
+// personal account may be configured as an organisation, in which case the
+// user qualifier is used instead.
+func buildOrgQuery(cfg config.Config, ghClient ghClient.GitHubClient, org config.Organisation) (q string) {
+	if isUserAccount(cfg, ghClient, org.Name) {
+		return fmt.Sprintf("user:%s ", org.Name)
+	}
+	return fmt.Sprintf("org:%s ", org.Name)
+}
+
+// userAccounts caches whether each configured organisation is actually a
+// personal account, which doesn't change between syncs.
+var userAccounts = struct {
+	gosync.Mutex
+	m map[string]bool
+}{m: map[string]bool{}}
+
+// isUserAccount returns whether the named GitHub account is a user rather
+// than an organisation. If it can't be looked up, it is assumed to be an
+// organisation until the next sync.
+func isUserAccount(cfg config.Config, ghClient ghClient.GitHubClient, name string) bool {
+	userAccounts.Lock()
+	defer userAccounts.Unlock()
+
+	if user, ok := userAccounts.m[name]; ok {
+		return user
+	}
+
+	account, err := ghClient.GetUser(name)
+	if err != nil {
+		log := cfg.GetLogger()
+		log.Warnf("Error looking up GitHub account %s; assuming it is an organisation: %v", name, err)
+		return false
+	}
+
+	user := account.GetType() == "User"
+	userAccounts.m[name] = user
+	return user
+}
+
+func buildRepoQuery(repo string) (q string) {
+	return fmt.Sprintf("repo:%s ", repo)
+}
+
+// buildActiveReposQueries returns the queries for the repositories of the
+// organisation which aren't archived, or none if they all are. The
+// repositories are split across as many queries as needed to keep each one
+// within the given length; a repository whose clause alone is longer gets a
+// query of its own.
+func buildActiveReposQueries(cfg config.Config, ghClient ghClient.GitHubClient, org config.Organisation, length int) ([]string, error) {
+	log := cfg.GetLogger()
+
+	repos, err := ghClient.ListRepos(org.Name, isUserAccount(cfg, ghClient, org.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, repo := range repos {
+		if repo.Archived {
+			log.Debugf("Skipping archived repository %s", repo.GetFullName())
+			continue
 		}
+		names = append(names, repo.GetFullName())
 	}
 
-	return q
+	if len(names) == 0 {
+		log.Infof("All of the repositories of %s are archived; skipping it", org.Name)
+	}
+
+	return splitRepoQueries(names, length), nil
+}
+
+// splitRepoQueries returns the repo clauses of the named repositories, packed
+// into as few queries as possible of at most the given length.
+func splitRepoQueries(names []string, length int) []string {
+	var queries []string
+	var q string
+	for _, name := range names {
+		clause := buildRepoQuery(name)
+		if q != "" && len(q)+len(clause) > length {
+			queries = append(queries, q)
+			q = ""
+		}
+		q += clause
+	}
+	if q != "" {
+		queries = append(queries, q)
+	}
+	return queries
 }
 
-func buildRepoQuery(org config.Organisation) (q string) {
-	for _, repo := range org.Repos {
-		q += fmt.Sprintf("repo:%s/%s ", org.Name, repo)
+// buildAuthorQuery returns the clauses restricting the issues to those opened
+// by the authors of the author filter. GitHub combines repeated author
+// qualifiers with OR.
+func buildAuthorQuery(cfg config.Config) (q string) {
+	for _, login := range cfg.GetAuthorFilter() {
+		q += fmt.Sprintf("author:%s ", login)
 	}
 	return q
 }
 
-func buildUserQuery(cfg config.Config, ghClient ghClient.GitHubClient) (q string) {
-
-	users, err := ghClient.GetMembers(cfg.GetSourceOrganisation())
+// buildUserQuery returns the clauses restricting the issues to those
+// involving the members of the user source organisation, or of its source
+// team.
+func buildUserQuery(cfg config.Config, ghClient ghClient.GitHubClient) (q string, err error) {
+	users, err := sourceMembers(cfg, ghClient)
 	if err != nil {
-		// TODO: bubble up err
-		panic(err)
+		return "", err
 	}
 
 	for _, user := range users {
 		q += fmt.Sprintf("involves:%s ", user.GetLogin())
 	}
 
-	return q
+	return q, nil
+}
+
+// sourceMembers returns the members of the user source organisation, or of
+// its source team.
+func sourceMembers(cfg config.Config, ghClient ghClient.GitHubClient) ([]*github.User, error) {
+	if team := cfg.GetSourceTeam(); team != "" {
+		return ghClient.GetTeamMembers(cfg.GetSourceOrganisation(), team)
+	}
+	return ghClient.GetMembers(cfg.GetSourceOrganisation())
+}
+
+// InScope returns whether a GitHub issue is one the sync searches for: it is
+// in one of the configured organisations or repositories, was opened by one
+// of the authors of the author filter, and involves one of the source users.
+// An issue involves the users who opened it, are assigned to it, or are given
+// as involved, e.g. the user who commented on it. A configured search query
+// can't be checked locally, so only the users are checked then.
+func InScope(cfg config.Config, ghClient ghClient.GitHubClient, ghIssue github.Issue, involved ...string) (bool, error) {
+	if cfg.GetSearchQuery() == "" && !repoInScope(cfg, issueRepo(ghIssue)) {
+		return false, nil
+	}
+
+	author := ghIssue.User.GetLogin()
+	if authors := cfg.GetAuthorFilter(); len(authors) > 0 && !containsFold(authors, author) {
+		return false, nil
+	}
+
+	if cfg.GetSourceOrganisation() == "" {
+		return true, nil
+	}
+	members, err := sourceMembers(cfg, ghClient)
+	if err != nil {
+		return false, err
+	}
+	// Without members, the search isn't restricted to any users either.
+	if len(members) == 0 {
+		return true, nil
+	}
+
+	logins := append([]string{author}, involved...)
+	for _, assignee := range ghIssue.Assignees {
+		logins = append(logins, assignee.GetLogin())
+	}
+	for _, member := range members {
+		if containsFold(logins, member.GetLogin()) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// repoInScope returns whether the named repository, of the form owner/name,
+// is one of the configured repositories or belongs to one of the configured
+// organisations. Without any, every repository is.
+func repoInScope(cfg config.Config, repo string) bool {
+	orgs := cfg.GetRepos()
+	if len(orgs) == 0 {
+		return true
+	}
+
+	for _, org := range orgs {
+		if len(org.Repos) == 0 {
+			if strings.HasPrefix(strings.ToLower(repo), strings.ToLower(org.Name)+"/") {
+				return true
+			}
+			continue
+		}
+		for _, name := range org.Repos {
+			if strings.EqualFold(repo, org.Name+"/"+name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsFold returns whether s is in list, ignoring case as GitHub does for
+// logins.
+func containsFold(list []string, s string) bool {
+	for _, l := range list {
+		if strings.EqualFold(l, s) {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,561 @@
+package sync
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"github.com/andygrunwald/go-jira"
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+)
+
+// testFieldIDs pins the custom fields of test configurations to fixed IDs.
+var testFieldIDs = map[string]string{
+	"GitHub ID":              "10001",
+	"GitHub Number":          "10002",
+	"GitHub Labels":          "10003",
+	"GitHub Status":          "10004",
+	"GitHub Reporter":        "10005",
+	"Last Issue-Sync Update": "10006",
+	"GitHub URI":             "10007",
+}
+
+// newTestConfig returns a configuration of the given option values and the
+// test field IDs, failing the test if it is invalid.
+func newTestConfig(t *testing.T, values map[string]interface{}) config.Config {
+	t.Helper()
+	all := map[string]interface{}{"field-ids": testFieldIDs}
+	for key, value := range values {
+		all[key] = value
+	}
+	cfg, err := config.NewTestConfig(all)
+	if err != nil {
+		t.Fatalf("invalid test configuration: %v", err)
+	}
+	return cfg
+}
+
+func TestSplitRepoQueries(t *testing.T) {
+	tests := []struct {
+		name   string
+		repos  []string
+		length int
+		want   []string
+	}{
+		{"none", nil, 256, nil},
+		{"fits", []string{"o/a", "o/b"}, 256, []string{"repo:o/a repo:o/b "}},
+		{"exact", []string{"o/a", "o/b"}, 18, []string{"repo:o/a repo:o/b "}},
+		{"split", []string{"o/a", "o/b", "o/c"}, 17, []string{"repo:o/a ", "repo:o/b ", "repo:o/c "}},
+		{"pairs", []string{"o/a", "o/b", "o/c"}, 20, []string{"repo:o/a repo:o/b ", "repo:o/c "}},
+		{"too long", []string{"o/long-name"}, 5, []string{"repo:o/long-name "}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitRepoQueries(tt.repos, tt.length)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitRepoQueries(%q, %d) = %q, want %q", tt.repos, tt.length, got, tt.want)
+			}
+		})
+	}
+}
+
+// membersClient is a GitHub client listing fixed organisation and team
+// members, or failing with err.
+type membersClient struct {
+	ghClient.GitHubClient
+	members []*github.User
+	err     error
+}
+
+func (c membersClient) GetMembers(org string) ([]*github.User, error) {
+	return c.members, c.err
+}
+
+func (c membersClient) GetTeamMembers(org, team string) ([]*github.User, error) {
+	return c.members, c.err
+}
+
+func TestBuildUserQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		client  membersClient
+		want    string
+		wantErr bool
+	}{
+		{"members", membersClient{members: []*github.User{{Login: github.String("a")}, {Login: github.String("b")}}}, "involves:a involves:b ", false},
+		{"no members", membersClient{}, "", false},
+		{"error", membersClient{err: errors.New("not found")}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{"github-user-source-org": "org"})
+
+			got, err := buildUserQuery(cfg, tt.client)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("buildUserQuery() = %q, %v; want %q, error %v", got, err, tt.want, tt.wantErr)
+			}
+
+			if _, err := buildQueries(cfg, tt.client, time.Time{}); (err != nil) != tt.wantErr {
+				t.Errorf("buildQueries() error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// reposClient is a GitHub client listing fixed repositories of organisations.
+type reposClient struct {
+	membersClient
+	repos map[string][]ghClient.Repository
+}
+
+func (c reposClient) GetUser(login string) (github.User, error) {
+	return github.User{Login: github.String(login), Type: github.String("Organization")}, nil
+}
+
+func (c reposClient) ListRepos(owner string, user bool) ([]ghClient.Repository, error) {
+	return c.repos[owner], nil
+}
+
+// testRepo returns a repository of the given full name.
+func testRepo(name string, archived bool) ghClient.Repository {
+	return ghClient.Repository{Repository: github.Repository{FullName: github.String(name)}, Archived: archived}
+}
+
+func TestBuildQueries(t *testing.T) {
+	var members []*github.User
+	for i := 0; i < 30; i++ {
+		members = append(members, &github.User{Login: github.String(fmt.Sprintf("member-%d", i))})
+	}
+	repos := map[string][]ghClient.Repository{
+		"active":   {testRepo("active/a", false), testRepo("active/b", true)},
+		"archived": {testRepo("archived/a", true), testRepo("archived/b", true)},
+	}
+
+	tests := []struct {
+		name    string
+		repos   []map[string]interface{}
+		members []*github.User
+		want    []string
+		wantErr bool
+	}{
+		{"no repos", nil, nil, []string{"all repositories"}, false},
+		{"active repos", []map[string]interface{}{{"name": "active"}}, nil, []string{"active"}, false},
+		{"all archived", []map[string]interface{}{{"name": "archived"}}, nil, nil, false},
+		{"archived and active", []map[string]interface{}{{"name": "archived"}, {"name": "active"}}, nil, []string{"active"}, false},
+		{"listed repos", []map[string]interface{}{{"name": "archived", "repos": []string{"a"}}}, nil, []string{"archived/a"}, false},
+		{"user clause too long", []map[string]interface{}{{"name": "active"}}, members, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{
+				"github-user-source-org": "org",
+				"repos":                  tt.repos,
+			})
+			client := reposClient{membersClient: membersClient{members: tt.members}, repos: repos}
+
+			queries, err := buildQueries(cfg, client, time.Time{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("buildQueries() error = %v, want error %v", err, tt.wantErr)
+			}
+			var names []string
+			for _, q := range queries {
+				names = append(names, q.name)
+				if strings.Contains(q.query, "active/b") {
+					t.Errorf("query %q searches the archived repository active/b", q.query)
+				}
+				if len(q.query) > maxQueryLength {
+					t.Errorf("query %q is longer than %d", q.query, maxQueryLength)
+				}
+			}
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("buildQueries() queries = %q, want %q", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildSinceQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		since time.Time
+		want  string
+	}{
+		{"zero", time.Time{}, ""},
+		{"UTC", time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), "updated:>=2020-01-02T03:04:05Z "},
+		{"east of UTC", time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("UTC+7", 7*60*60)), "updated:>=2020-01-01T20:04:05Z "},
+		{"west of UTC", time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("UTC-5", -5*60*60)), "updated:>=2020-01-02T08:04:05Z "},
+		{"half hour offset", time.Date(2020, 1, 2, 3, 4, 5, 0, time.FixedZone("UTC+5:30", 11*30*60)), "updated:>=2020-01-01T21:34:05Z "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := buildSinceQuery(tt.since); got != tt.want {
+				t.Errorf("buildSinceQuery() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogQueries(t *testing.T) {
+	query := "GitHub search query for o/r: author:a repo:o/r updated:>=2020-01-02T03:04:05Z"
+	clauses := []string{"Users: author:a", "Scope: repo:o/r", "Since: updated:>=2020-01-02T03:04:05Z"}
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   []string
+		absent []string
+	}{
+		{"info level", map[string]interface{}{"log-level": "info"}, nil, append([]string{query}, clauses...)},
+		{"debug level", map[string]interface{}{"log-level": "debug"}, []string{query}, clauses},
+		{"dry run", map[string]interface{}{"log-level": "info", "dry-run": true}, []string{query}, clauses},
+		{"explain", map[string]interface{}{"log-level": "info", "explain": true}, append([]string{query}, clauses...), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]interface{}{
+				"repos":         []map[string]interface{}{{"name": "o", "repos": []string{"r"}}},
+				"author-filter": []string{"a"},
+			}
+			for key, value := range tt.values {
+				values[key] = value
+			}
+			cfg := newTestConfig(t, values)
+			var out bytes.Buffer
+			cfg.GetLogger().Logger.Out = &out
+
+			queries, err := buildQueries(cfg, reposClient{}, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+			if err != nil {
+				t.Fatal(err)
+			}
+			logQueries(cfg, queries)
+
+			for _, s := range tt.want {
+				if !strings.Contains(out.String(), s) {
+					t.Errorf("logQueries() didn't log %q; logged:\n%s", s, out.String())
+				}
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(out.String(), s) {
+					t.Errorf("logQueries() logged %q; logged:\n%s", s, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestBuildAuthorQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		authors []string
+		want    string
+	}{
+		{"no filter", nil, ""},
+		{"one author", []string{"a"}, "author:a "},
+		{"several authors", []string{"a", "app/b"}, "author:a author:app/b "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{
+				"repos":         []map[string]interface{}{{"name": "o", "repos": []string{"r"}}},
+				"author-filter": tt.authors,
+			})
+			if got := buildAuthorQuery(cfg); got != tt.want {
+				t.Errorf("buildAuthorQuery() = %q, want %q", got, tt.want)
+			}
+
+			queries, err := buildQueries(cfg, reposClient{}, time.Time{})
+			if err != nil {
+				t.Fatalf("buildQueries() error = %v", err)
+			}
+			if len(queries) != 1 || !strings.Contains(queries[0].query, tt.want+"repo:o/r") {
+				t.Errorf("buildQueries() = %+v, want the query to contain %q", queries, tt.want)
+			}
+		})
+	}
+}
+
+// accountsClient is a GitHub client looking up accounts of fixed types,
+// counting the lookups, and listing which owners' repositories were
+// requested as users.
+type accountsClient struct {
+	ghClient.GitHubClient
+	types   map[string]string
+	lookups *int
+	users   map[string]bool
+}
+
+func (c accountsClient) GetUser(login string) (github.User, error) {
+	*c.lookups++
+	t, ok := c.types[login]
+	if !ok {
+		return github.User{}, errors.New("not found")
+	}
+	return github.User{Login: github.String(login), Type: github.String(t)}, nil
+}
+
+func (c accountsClient) ListRepos(owner string, user bool) ([]ghClient.Repository, error) {
+	c.users[owner] = user
+	return []ghClient.Repository{testRepo(owner+"/a", false)}, nil
+}
+
+func TestBuildOrgQuery(t *testing.T) {
+	types := map[string]string{"person": "User", "company": "Organization"}
+
+	tests := []struct {
+		name        string
+		org         string
+		want        string
+		wantUser    bool
+		wantLookups int
+	}{
+		{"user account", "person", "user:person ", true, 1},
+		{"organisation", "company", "org:company ", false, 1},
+		// A failed lookup isn't cached, so it is retried on the next sync.
+		{"lookup error", "missing", "org:missing ", false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userAccounts.m = map[string]bool{}
+			cfg := newTestConfig(t, nil)
+			client := accountsClient{types: types, lookups: new(int), users: map[string]bool{}}
+			org := config.Organisation{Name: tt.org}
+
+			for i := 0; i < 2; i++ {
+				if got := buildOrgQuery(cfg, client, org); got != tt.want {
+					t.Errorf("buildOrgQuery(%q) = %q, want %q", tt.org, got, tt.want)
+				}
+			}
+			if *client.lookups != tt.wantLookups {
+				t.Errorf("looked up %q %d times, want %d", tt.org, *client.lookups, tt.wantLookups)
+			}
+
+			if _, err := buildActiveReposQueries(cfg, client, org, maxQueryLength); err != nil {
+				t.Fatal(err)
+			}
+			if user := client.users[tt.org]; user != tt.wantUser {
+				t.Errorf("listed repositories of %q as a user = %t, want %t", tt.org, user, tt.wantUser)
+			}
+		})
+	}
+}
+
+func TestBuildSearchQuery(t *testing.T) {
+	since := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		since  time.Time
+		want   string
+	}{
+		{"override", map[string]interface{}{"search-query": "org:o label:bug"}, since, "org:o label:bug "},
+		{"since appended", map[string]interface{}{"search-query": "org:o label:bug", "search-query-since": true}, since, "org:o label:bug updated:>=2020-01-02T03:04:05Z "},
+		{"first run", map[string]interface{}{"search-query": "org:o label:bug", "search-query-since": true}, time.Time{}, "org:o label:bug "},
+		{"surrounding space", map[string]interface{}{"search-query": "  org:o  ", "search-query-since": true}, since, "org:o updated:>=2020-01-02T03:04:05Z "},
+		// The configured repositories and members are ignored.
+		{"repos", map[string]interface{}{"search-query": "is:open", "repos": []map[string]interface{}{{"name": "active"}}, "github-user-source-org": "org"}, since, "is:open "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.values)
+			// The client fails any request, as none is needed.
+			client := reposClient{membersClient: membersClient{err: errors.New("unexpected request")}}
+
+			queries, err := buildQueries(cfg, client, tt.since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(queries) != 1 || queries[0].query != tt.want {
+				t.Errorf("buildQueries() = %+v, want the single query %q", queries, tt.want)
+			}
+		})
+	}
+}
+
+func TestInScope(t *testing.T) {
+	members := membersClient{members: []*github.User{{Login: github.String("Member")}}}
+	inRepo := func(repo, author string, assignees ...string) github.Issue {
+		issue := github.Issue{
+			URL:  github.String("https://api.github.com/repos/" + repo + "/issues/1"),
+			User: &github.User{Login: github.String(author)},
+		}
+		for _, a := range assignees {
+			issue.Assignees = append(issue.Assignees, &github.User{Login: github.String(a)})
+		}
+		return issue
+	}
+
+	tests := []struct {
+		name     string
+		values   map[string]interface{}
+		issue    github.Issue
+		involved []string
+		want     bool
+	}{
+		{"no scope", nil, inRepo("any/repo", "someone"), nil, true},
+		{"organisation", map[string]interface{}{"repos": []map[string]interface{}{{"name": "Org"}}}, inRepo("org/repo", "someone"), nil, true},
+		{"other organisation", map[string]interface{}{"repos": []map[string]interface{}{{"name": "org"}}}, inRepo("org2/repo", "someone"), nil, false},
+		{"listed repository", map[string]interface{}{"repos": []map[string]interface{}{{"name": "org", "repos": []string{"repo"}}}}, inRepo("org/repo", "someone"), nil, true},
+		{"unlisted repository", map[string]interface{}{"repos": []map[string]interface{}{{"name": "org", "repos": []string{"repo"}}}}, inRepo("org/other", "someone"), nil, false},
+		{"search query", map[string]interface{}{"search-query": "label:bug", "repos": []map[string]interface{}{{"name": "org"}}}, inRepo("other/repo", "someone"), nil, true},
+		{"author filter", map[string]interface{}{"author-filter": []string{"author"}}, inRepo("org/repo", "Author"), nil, true},
+		{"other author", map[string]interface{}{"author-filter": []string{"author"}}, inRepo("org/repo", "someone"), nil, false},
+		{"member author", map[string]interface{}{"github-user-source-org": "org"}, inRepo("org/repo", "member"), nil, true},
+		{"member assignee", map[string]interface{}{"github-user-source-org": "org"}, inRepo("org/repo", "someone", "member"), nil, true},
+		{"member involved", map[string]interface{}{"github-user-source-org": "org"}, inRepo("org/repo", "someone"), []string{"member"}, true},
+		{"no member", map[string]interface{}{"github-user-source-org": "org"}, inRepo("org/repo", "someone"), []string{"other"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, tt.values)
+			got, err := InScope(cfg, members, tt.issue, tt.involved...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("InScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSyncAllArchived(t *testing.T) {
+	cfg := newTestConfig(t, map[string]interface{}{
+		"repos": []map[string]interface{}{{"name": "archived"}},
+	})
+	client := reposClient{repos: map[string][]ghClient.Repository{
+		"archived": {testRepo("archived/a", true)},
+	}}
+
+	if err := Sync(cfg, client, nil); err != nil {
+		t.Errorf("Sync() = %v, want nil", err)
+	}
+}
+
+// searchClient is a GitHub client whose searches return fixed issues, or fail
+// with err.
+type searchClient struct {
+	ghClient.GitHubClient
+	issues []github.Issue
+	err    error
+}
+
+func (c searchClient) SearchIssues(query string) ([]github.Issue, error) {
+	return c.issues, c.err
+}
+
+// failingUpdatedClient is a JIRA client which fails to list updated issues.
+type failingUpdatedClient struct {
+	jClient.JIRAClient
+}
+
+func (failingUpdatedClient) ListIssuesUpdatedSince(t time.Time) ([]jira.Issue, error) {
+	return nil, errors.New("JIRA unavailable")
+}
+
+func TestSyncLogsResult(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		search error
+	}{
+		{"all repos fail", nil, errors.New("search failed")},
+		{"status labels fail", map[string]interface{}{"status-labels": map[string]string{"done": "fixed"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := map[string]interface{}{"search-query": "is:issue", "log-level": "info"}
+			for key, value := range tt.values {
+				values[key] = value
+			}
+			cfg := newTestConfig(t, values)
+			var out bytes.Buffer
+			cfg.GetLogger().Logger.Out = &out
+
+			err := Sync(cfg, searchClient{err: tt.search}, failingUpdatedClient{})
+			if err == nil {
+				t.Fatal("Sync() succeeded, want an error")
+			}
+			if !strings.Contains(out.String(), "Sync finished") {
+				t.Errorf("Sync() didn't log its result; logged:\n%s", out.String())
+			}
+		})
+	}
+}
+
+// repoSearchClient is a GitHub client whose searches find no issues, except
+// those of the failing repository, which fail. It records the queries.
+type repoSearchClient struct {
+	ghClient.GitHubClient
+	failing string
+
+	mu       *gosync.Mutex
+	searched map[string]bool
+}
+
+func (c repoSearchClient) SearchIssues(query string) ([]github.Issue, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.searched[query] = true
+	if strings.Contains(query, "repo:"+c.failing+" ") {
+		return nil, errors.New("404 Not Found")
+	}
+	return nil, nil
+}
+
+func TestSyncIsolatesRepos(t *testing.T) {
+	tests := []struct {
+		name     string
+		parallel bool
+	}{
+		{"sequential", false},
+		{"parallel", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newTestConfig(t, map[string]interface{}{
+				"parallel-repos": tt.parallel,
+				"repos": []map[string]interface{}{
+					{"name": "org", "repos": []string{"first", "renamed", "last"}},
+				},
+			})
+			client := repoSearchClient{
+				GitHubClient: membersClient{},
+				failing:      "org/renamed",
+				mu:           &gosync.Mutex{},
+				searched:     map[string]bool{},
+			}
+
+			err := Sync(cfg, client, nil)
+			partial, ok := err.(PartialError)
+			if !ok {
+				t.Fatalf("Sync() = %v, want a PartialError", err)
+			}
+			if !reflect.DeepEqual(partial.Repos, []string{"org/renamed"}) {
+				t.Errorf("failed repos = %q, want only org/renamed", partial.Repos)
+			}
+			if len(client.searched) != 3 {
+				t.Errorf("%d repos searched, want 3", len(client.searched))
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"net/http"
+
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+	jClient "github.com/innovocloud/issue-sync/pkg/jira"
+	"github.com/innovocloud/issue-sync/pkg/sync"
+)
+
+// Handler receives GitHub webhook deliveries for the `issues` and
+// `issue_comment` events, verifies their signature, and immediately
+// synchronizes the affected issue to JIRA if it is one the sync searches for.
+type Handler struct {
+	cfg      config.Config
+	ghClient ghClient.GitHubClient
+
+	// syncIssue syncs an issue to JIRA; it is run after the response.
+	syncIssue func(github.Issue) error
+}
+
+// NewHandler creates a webhook Handler which syncs issues using the
+// provided clients.
+func NewHandler(cfg config.Config, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient) *Handler {
+	return &Handler{
+		cfg:      cfg,
+		ghClient: ghClient,
+		syncIssue: func(issue github.Issue) error {
+			return sync.SyncIssue(cfg, issue, ghClient, jiraClient)
+		},
+	}
+}
+
+// ServeHTTP validates the signature of a webhook delivery against the
+// configured secret, then dispatches the event to the single-issue sync.
+// Events other than `issues` and `issue_comment` are acknowledged and ignored.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log := h.cfg.GetLogger()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload, err := github.ValidatePayload(r, []byte(h.cfg.GetWebhookSecret()))
+	if err != nil {
+		log.Warnf("Rejected webhook delivery %s: %v", github.DeliveryID(r), err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := github.ParseWebHook(github.WebHookType(r), payload)
+	if err != nil {
+		log.Debugf("Ignoring webhook delivery %s: %v", github.DeliveryID(r), err)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	issue, sender := eventIssue(event)
+	if issue == nil {
+		log.Debugf("Ignoring webhook delivery %s of type %s", github.DeliveryID(r), github.WebHookType(r))
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// The hook may deliver events of repositories and users which aren't synced.
+	ok, err := sync.InScope(h.cfg, h.ghClient, *issue, sender.GetLogin())
+	if err != nil {
+		log.Errorf("Error checking whether GitHub issue #%d of webhook delivery %s is synced: %v", issue.GetNumber(), github.DeliveryID(r), err)
+		http.Error(w, "error checking the issue", http.StatusInternalServerError)
+		return
+	}
+	if !ok {
+		log.Debugf("Ignoring webhook delivery %s for GitHub issue %s, which isn't synced", github.DeliveryID(r), issue.GetHTMLURL())
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	log.Debugf("Received webhook delivery %s for GitHub issue #%d", github.DeliveryID(r), issue.GetNumber())
+
+	// Respond straight away; GitHub times out deliveries which take too long.
+	w.WriteHeader(http.StatusAccepted)
+
+	go func() {
+		if err := h.syncIssue(*issue); err != nil {
+			log.Errorf("Error syncing GitHub issue #%d from webhook: %v", issue.GetNumber(), err)
+		}
+	}()
+}
+
+// eventIssue returns the issue affected by a parsed webhook event and the user
+// who triggered it, or a nil issue if the event isn't one which is synced.
+// Pull requests are never synced.
+func eventIssue(event interface{}) (issue *github.Issue, sender *github.User) {
+	switch e := event.(type) {
+	case *github.IssuesEvent:
+		issue, sender = e.Issue, e.Sender
+	case *github.IssueCommentEvent:
+		issue, sender = e.Issue, e.Sender
+	}
+
+	if issue == nil || issue.PullRequestLinks != nil {
+		return nil, nil
+	}
+	return issue, sender
+}
+
+// ListenAndServe starts an HTTP server on the configured address, serving
+// webhook deliveries on every path. It only returns on error.
+func ListenAndServe(cfg config.Config, ghClient ghClient.GitHubClient, jiraClient jClient.JIRAClient) error {
+	log := cfg.GetLogger()
+
+	log.Infof("Listening for GitHub webhooks on %s", cfg.GetWebhookAddress())
+
+	return http.ListenAndServe(cfg.GetWebhookAddress(), NewHandler(cfg, ghClient, jiraClient))
+}
@@ -0,0 +1,131 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+	"github.com/innovocloud/issue-sync/pkg/config"
+	ghClient "github.com/innovocloud/issue-sync/pkg/github"
+)
+
+const testSecret = "secret"
+
+// membersClient is a GitHub client listing fixed organisation members.
+type membersClient struct {
+	ghClient.GitHubClient
+	members []string
+}
+
+func (c membersClient) GetMembers(org string) ([]*github.User, error) {
+	var users []*github.User
+	for _, login := range c.members {
+		users = append(users, &github.User{Login: github.String(login)})
+	}
+	return users, nil
+}
+
+// sign returns the signature of a payload with the given secret, as GitHub
+// sends it in the X-Hub-Signature header.
+func sign(payload, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// issuePayload returns the payload of an event for an issue of the given
+// repository, opened by author and sent by sender.
+func issuePayload(repo, author, sender string, pr bool) string {
+	issue := `"number": 1, "url": "https://api.github.com/repos/` + repo + `/issues/1", "user": {"login": "` + author + `"}`
+	if pr {
+		issue += `, "pull_request": {"url": "https://api.github.com/repos/` + repo + `/pulls/1"}`
+	}
+	return `{"action": "opened", "issue": {` + issue + `}, "sender": {"login": "` + sender + `"}}`
+}
+
+func TestServeHTTP(t *testing.T) {
+	cfg, err := config.NewTestConfig(map[string]interface{}{
+		"webhook-secret":         testSecret,
+		"github-user-source-org": "org",
+		"repos":                  []map[string]interface{}{{"name": "org"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := membersClient{members: []string{"member"}}
+
+	valid := issuePayload("org/repo", "member", "member", false)
+
+	tests := []struct {
+		name      string
+		method    string
+		event     string
+		payload   string
+		signature string
+		status    int
+		synced    bool
+	}{
+		{"issues", "POST", "issues", valid, sign(valid, testSecret), http.StatusAccepted, true},
+		{"issue comment", "POST", "issue_comment", valid, sign(valid, testSecret), http.StatusAccepted, true},
+		{"not a POST", "GET", "issues", valid, sign(valid, testSecret), http.StatusMethodNotAllowed, false},
+		{"missing signature", "POST", "issues", valid, "", http.StatusUnauthorized, false},
+		{"bad signature", "POST", "issues", valid, sign(valid, "wrong"), http.StatusUnauthorized, false},
+		{"signature of another payload", "POST", "issues", valid, sign(valid+" ", testSecret), http.StatusUnauthorized, false},
+		{"pull request", "POST", "issue_comment", issuePayload("org/repo", "member", "member", true), "", http.StatusNoContent, false},
+		{"other event", "POST", "push", `{"ref": "refs/heads/master"}`, "", http.StatusNoContent, false},
+		{"unknown event", "POST", "unknown", `{}`, "", http.StatusNoContent, false},
+		{"other repository", "POST", "issues", issuePayload("other/repo", "member", "member", false), "", http.StatusNoContent, false},
+		{"no member involved", "POST", "issues", issuePayload("org/repo", "someone", "someone", false), "", http.StatusNoContent, false},
+		{"member commented", "POST", "issue_comment", issuePayload("org/repo", "someone", "member", false), "", http.StatusAccepted, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			synced := make(chan int, 1)
+			h := NewHandler(cfg, client, nil)
+			h.syncIssue = func(issue github.Issue) error {
+				synced <- issue.GetNumber()
+				return nil
+			}
+
+			signature := tt.signature
+			if signature == "" && tt.status != http.StatusUnauthorized {
+				signature = sign(tt.payload, testSecret)
+			}
+			r := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.payload))
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("X-GitHub-Event", tt.event)
+			if signature != "" {
+				r.Header.Set("X-Hub-Signature", signature)
+			}
+			w := httptest.NewRecorder()
+
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d", w.Code, tt.status)
+			}
+			if !tt.synced {
+				// The sync only runs once the delivery is accepted.
+				if w.Code == http.StatusAccepted {
+					t.Error("delivery accepted, want it not synced")
+				}
+				return
+			}
+			select {
+			case n := <-synced:
+				if n != 1 {
+					t.Errorf("synced issue #%d, want #1", n)
+				}
+			case <-time.After(time.Second):
+				t.Error("issue not synced")
+			}
+		})
+	}
+}